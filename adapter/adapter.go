@@ -0,0 +1,93 @@
+// Package adapter holds the parts shared by the adapters of the web frameworks, e.g. the
+// extraction of the hot-spot parameters from the requests:
+//
+//	ahasgin.SentinelMiddleware(ahasgin.WithParams(adapter.FromPath("id"), adapter.FromHeader("X-Tenant")))
+//
+// The parameters are carried by key (see paramkey), which the hot-spot rules refer to by ParamKey.
+//
+// The adapters of the dependencies (e.g. adapter/sql and adapter/redis) guard the invocations
+// of a labeled context (see WithLabel) by the resource of the label.
+package adapter
+
+import (
+	"context"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/paramkey"
+)
+
+// DefaultRetryAfterSec is the Retry-After of the requests blocked by the rules other than the
+// circuit breaking rules, as the flow control is based on the per-second statistics.
+const DefaultRetryAfterSec = 1
+
+// labelKey is the key of the resource label in the context.
+type labelKey struct{}
+
+// WithLabel returns the context whose invocations of the dependencies are guarded by the
+// resource of the label.
+func WithLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// Label returns the resource label of the context, or empty if absent.
+func Label(ctx context.Context) string {
+	label, _ := ctx.Value(labelKey{}).(string)
+	return label
+}
+
+// ParamSource is where the parameter is extracted from the request.
+type ParamSource int
+
+const (
+	// PathParam is the path variable of the route, e.g. "id" of "/users/:id".
+	PathParam ParamSource = iota
+	QueryParam
+	HeaderParam
+)
+
+// Param is a hot-spot parameter extracted from the request.
+type Param struct {
+	// Key is the ParamKey of the hot-spot rules.
+	Key    string
+	Source ParamSource
+	// Name is the name of the path variable, query parameter or header.
+	Name string
+}
+
+// FromPath extracts the path variable as the parameter of the same key.
+func FromPath(name string) Param {
+	return Param{Key: name, Source: PathParam, Name: name}
+}
+
+// FromQuery extracts the query parameter as the parameter of the same key.
+func FromQuery(name string) Param {
+	return Param{Key: name, Source: QueryParam, Name: name}
+}
+
+// FromHeader extracts the header as the parameter of the same key.
+func FromHeader(name string) Param {
+	return Param{Key: name, Source: HeaderParam, Name: name}
+}
+
+// ParamOptions returns the entry options carrying the parameters, where the lookup returns the
+// value of the named parameter from the source. Absent (empty) parameters are skipped.
+func ParamOptions(params []Param, lookup func(source ParamSource, name string) string) []sentinel.EntryOption {
+	opts := make([]sentinel.EntryOption, 0, len(params))
+	for _, p := range params {
+		if v := lookup(p.Source, p.Name); v != "" {
+			opts = append(opts, paramkey.WithParam(p.Key, v))
+		}
+	}
+	return opts
+}
+
+// RetryAfterSec returns the seconds after which the blocked request is worth retrying, which
+// is the retry timeout of the circuit breaker, or DefaultRetryAfterSec for the other rules.
+func RetryAfterSec(b *base.BlockError) int {
+	if rule, ok := b.TriggeredRule().(*circuitbreaker.Rule); ok && rule != nil && rule.RetryTimeoutMs > 0 {
+		return int((rule.RetryTimeoutMs + 999) / 1000)
+	}
+	return DefaultRetryAfterSec
+}
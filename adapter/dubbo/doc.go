@@ -0,0 +1,15 @@
+// Package dubbo provides the Sentinel filters of dubbo-go, which guard the invocations by
+// the resources of the interface and the method (see resourcename.Dubbo), with the
+// arguments as the hot-spot parameters. Importing the package registers the filters,
+// which are enabled in the dubbo-go configuration:
+//
+//	services:
+//	  "GreeterProvider":
+//	    filter: "ahas_provider"
+//
+// The consumer filter attaches the app name to the invocations, which is the origin of the
+// authority rules on the provider side, the same as the Java Dubbo support of AHAS.
+//
+// The filters are a separate module requiring github.com/apache/dubbo-go, so that the SDK
+// doesn't depend on it.
+package dubbo
@@ -0,0 +1,113 @@
+package dubbo
+
+import (
+	"context"
+	"sync/atomic"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/apache/dubbo-go/common/extension"
+	"github.com/apache/dubbo-go/filter"
+	"github.com/apache/dubbo-go/protocol"
+)
+
+const (
+	// ProviderFilterName is the name of the provider filter in the dubbo-go configuration.
+	ProviderFilterName = "ahas_provider"
+	// ConsumerFilterName is the name of the consumer filter in the dubbo-go configuration.
+	ConsumerFilterName = "ahas_consumer"
+	// ApplicationAttachmentKey is the attachment of the consumer app, the same as the Java SDK.
+	ApplicationAttachmentKey = authority.OriginAttachmentKey
+)
+
+// BlockFallback returns the result of the blocked invocation.
+type BlockFallback func(ctx context.Context, invoker protocol.Invoker, invocation protocol.Invocation, b *base.BlockError) protocol.Result
+
+var (
+	providerFallback atomic.Value
+	consumerFallback atomic.Value
+)
+
+func init() {
+	providerFallback.Store(BlockFallback(DefaultBlockFallback))
+	consumerFallback.Store(BlockFallback(DefaultBlockFallback))
+	extension.SetFilter(ProviderFilterName, func() filter.Filter {
+		return &sentinelFilter{trafficType: base.Inbound, fallback: &providerFallback}
+	})
+	extension.SetFilter(ConsumerFilterName, func() filter.Filter {
+		return &sentinelFilter{trafficType: base.Outbound, fallback: &consumerFallback}
+	})
+}
+
+// SetProviderBlockFallback customizes the result of the invocations blocked on the provider side.
+func SetProviderBlockFallback(f BlockFallback) {
+	if f != nil {
+		providerFallback.Store(f)
+	}
+}
+
+// SetConsumerBlockFallback customizes the result of the invocations blocked on the consumer side.
+func SetConsumerBlockFallback(f BlockFallback) {
+	if f != nil {
+		consumerFallback.Store(f)
+	}
+}
+
+// DefaultBlockFallback results in the block error.
+func DefaultBlockFallback(_ context.Context, _ protocol.Invoker, _ protocol.Invocation, b *base.BlockError) protocol.Result {
+	return &protocol.RPCResult{Err: b}
+}
+
+type sentinelFilter struct {
+	trafficType base.TrafficType
+	fallback    *atomic.Value
+}
+
+func (f *sentinelFilter) Invoke(ctx context.Context, invoker protocol.Invoker, invocation protocol.Invocation) protocol.Result {
+	opts := []sentinel.EntryOption{
+		sentinel.WithTrafficType(f.trafficType),
+		sentinel.WithResourceType(base.ResTypeRPC),
+	}
+	if f.trafficType == base.Inbound {
+		origin := invocation.AttachmentsByKey(authority.OriginAttachmentKey, "")
+		// The trusted origins bypass all protection.
+		if authority.IsTrusted(origin) {
+			return invoker.Invoke(ctx, invocation)
+		}
+		if origin != "" {
+			opts = append(opts, authority.WithOrigin(origin))
+		}
+	} else if invocation.AttachmentsByKey(authority.OriginAttachmentKey, "") == "" {
+		invocation.SetAttachments(authority.OriginAttachmentKey, authority.LocalOrigin())
+	}
+
+	iface := invoker.GetUrl().Service()
+	interfaceEntry, b := sentinel.Entry(iface, opts...)
+	if b != nil {
+		return f.blocked(ctx, invoker, invocation, b)
+	}
+	defer interfaceEntry.Exit()
+	methodEntry, b := sentinel.Entry(resourcename.Dubbo(iface, invocation.MethodName()),
+		append(opts, sentinel.WithArgs(invocation.Arguments()...))...)
+	if b != nil {
+		return f.blocked(ctx, invoker, invocation, b)
+	}
+	defer methodEntry.Exit()
+
+	result := invoker.Invoke(ctx, invocation)
+	if err := result.Error(); err != nil {
+		sentinel.TraceError(interfaceEntry, err)
+		sentinel.TraceError(methodEntry, err)
+	}
+	return result
+}
+
+func (f *sentinelFilter) OnResponse(_ context.Context, result protocol.Result, _ protocol.Invoker, _ protocol.Invocation) protocol.Result {
+	return result
+}
+
+func (f *sentinelFilter) blocked(ctx context.Context, invoker protocol.Invoker, invocation protocol.Invocation, b *base.BlockError) protocol.Result {
+	return f.fallback.Load().(BlockFallback)(ctx, invoker, invocation, b)
+}
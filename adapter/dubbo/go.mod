@@ -0,0 +1,11 @@
+module github.com/aliyun/aliyun-ahas-go-sdk/adapter/dubbo
+
+go 1.13
+
+require (
+	github.com/alibaba/sentinel-golang v0.6.2
+	github.com/aliyun/aliyun-ahas-go-sdk v0.0.0
+	github.com/apache/dubbo-go v1.5.4
+)
+
+replace github.com/aliyun/aliyun-ahas-go-sdk => ../..
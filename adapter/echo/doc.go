@@ -0,0 +1,10 @@
+// Package echo provides the Sentinel middleware of Echo, which names the resources of the
+// requests by the route templates (e.g. "GET:/users/:id") rather than the raw URLs, and
+// responds 429 Too Many Requests with Retry-After once blocked:
+//
+//	e := echo.New()
+//	e.Use(ahasecho.SentinelMiddleware(ahasecho.WithParams(adapter.FromPath("id"))))
+//
+// The middleware is a separate module requiring github.com/labstack/echo/v4, so that the SDK
+// doesn't depend on it.
+package echo
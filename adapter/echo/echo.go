@@ -0,0 +1,146 @@
+package echo
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/labstack/echo/v4"
+)
+
+// errServerError marks the 5xx responses as the errors of the resource for circuit breaking.
+var errServerError = errors.New("server error response")
+
+type options struct {
+	resource func(c echo.Context) string
+	origin   func(c echo.Context) string
+	params   []adapter.Param
+	blocked  func(c echo.Context, b *base.BlockError) error
+}
+
+// Option is the option of SentinelMiddleware.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the request.
+func WithResourceExtractor(f func(c echo.Context) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// WithOriginExtractor extracts the origin (e.g. the caller app from a header) of the request
+// for the authority rules, see authority.WithOrigin. The origin is extracted from the header
+// authority.OriginHeader by default.
+func WithOriginExtractor(f func(c echo.Context) string) Option {
+	return func(o *options) {
+		o.origin = f
+	}
+}
+
+// WithParams extracts the hot-spot parameters from the path variables, query parameters and headers.
+func WithParams(params ...adapter.Param) Option {
+	return func(o *options) {
+		o.params = append(o.params, params...)
+	}
+}
+
+// WithBlockHandler customizes the response of the blocked requests.
+// It responds 429 with Retry-After by default.
+func WithBlockHandler(f func(c echo.Context, b *base.BlockError) error) Option {
+	return func(o *options) {
+		o.blocked = f
+	}
+}
+
+// SentinelMiddleware returns the middleware which guards the requests with Sentinel.
+func SentinelMiddleware(opts ...Option) echo.MiddlewareFunc {
+	o := &options{
+		resource: routeResource,
+		origin: func(c echo.Context) string {
+			return authority.OriginFromHeader(c.Request().Header)
+		},
+		blocked: DefaultBlockHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			origin := ""
+			if o.origin != nil {
+				origin = o.origin(c)
+			}
+			// The trusted origins bypass all protection.
+			if authority.IsTrusted(origin) {
+				return next(c)
+			}
+			entryOpts := []sentinel.EntryOption{
+				sentinel.WithTrafficType(base.Inbound),
+				sentinel.WithResourceType(base.ResTypeWeb),
+			}
+			if o.origin != nil {
+				entryOpts = append(entryOpts, authority.WithOrigin(origin))
+			}
+			if len(o.params) > 0 {
+				entryOpts = append(entryOpts, adapter.ParamOptions(o.params, func(source adapter.ParamSource, name string) string {
+					switch source {
+					case adapter.PathParam:
+						return c.Param(name)
+					case adapter.QueryParam:
+						return c.QueryParam(name)
+					case adapter.HeaderParam:
+						return c.Request().Header.Get(name)
+					}
+					return ""
+				})...)
+			}
+			e, b := sentinel.Entry(o.resource(c), entryOpts...)
+			if b != nil {
+				return o.blocked(c, b)
+			}
+			defer e.Exit()
+			err := next(c)
+			if isServerError(c, err) {
+				if err == nil {
+					err = errServerError
+				}
+				sentinel.TraceError(e, err)
+			}
+			return err
+		}
+	}
+}
+
+// isServerError reports whether the request results in a 5xx response. The returned errors
+// are responded by the HTTP error handler later, as 500 unless they are HTTP errors.
+func isServerError(c echo.Context, err error) bool {
+	if err == nil {
+		return c.Response().Status >= http.StatusInternalServerError
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// routeResource names the request by the route template, or resourcename.UnmatchedRoute
+// if no route matched.
+func routeResource(c echo.Context) string {
+	route := c.Path()
+	if route == "" {
+		route = resourcename.UnmatchedRoute
+	}
+	return resourcename.HTTP(c.Request().Method, route)
+}
+
+// DefaultBlockHandler responds 429 Too Many Requests with Retry-After to the blocked request.
+func DefaultBlockHandler(c echo.Context, b *base.BlockError) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(adapter.RetryAfterSec(b)))
+	return c.NoContent(http.StatusTooManyRequests)
+}
@@ -0,0 +1,11 @@
+module github.com/aliyun/aliyun-ahas-go-sdk/adapter/echo
+
+go 1.13
+
+require (
+	github.com/alibaba/sentinel-golang v0.6.0
+	github.com/aliyun/aliyun-ahas-go-sdk v0.0.0
+	github.com/labstack/echo/v4 v4.1.17
+)
+
+replace github.com/aliyun/aliyun-ahas-go-sdk => ../..
@@ -0,0 +1,15 @@
+// Package fiber provides the Sentinel middleware of Fiber, which names the resources of the
+// requests by the route templates (e.g. "GET:/users/:id") rather than the raw URLs, and
+// responds 429 Too Many Requests with Retry-After once blocked.
+//
+// The middleware must be registered along with the handlers of each route, rather than by
+// Use. Fiber resolves the route of the handlers registered by Use as the prefix of Use
+// (e.g. "/"), and the route of the request is only known after the entry, so the requests
+// would all be named by the prefix. Such a registration is warned once in the log.
+//
+//	guard := ahasfiber.SentinelMiddleware(ahasfiber.WithParams(adapter.FromPath("id")))
+//	app.Get("/users/:id", guard, getUser)
+//
+// The middleware is a separate module requiring github.com/gofiber/fiber/v2, so that the SDK
+// doesn't depend on it.
+package fiber
@@ -0,0 +1,178 @@
+package fiber
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/gofiber/fiber/v2"
+)
+
+// misplacedOnce warns only once about the middleware registered by Use.
+var misplacedOnce sync.Once
+
+// errServerError marks the 5xx responses as the errors of the resource for circuit breaking.
+var errServerError = errors.New("server error response")
+
+type options struct {
+	resource func(c *fiber.Ctx) string
+	origin   func(c *fiber.Ctx) string
+	params   []adapter.Param
+	blocked  func(c *fiber.Ctx, b *base.BlockError) error
+}
+
+// Option is the option of SentinelMiddleware.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the request.
+func WithResourceExtractor(f func(c *fiber.Ctx) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// WithOriginExtractor extracts the origin (e.g. the caller app from a header) of the request
+// for the authority rules, see authority.WithOrigin. The origin is extracted from the header
+// authority.OriginHeader by default.
+func WithOriginExtractor(f func(c *fiber.Ctx) string) Option {
+	return func(o *options) {
+		o.origin = f
+	}
+}
+
+// WithParams extracts the hot-spot parameters from the path variables, query parameters and headers.
+func WithParams(params ...adapter.Param) Option {
+	return func(o *options) {
+		o.params = append(o.params, params...)
+	}
+}
+
+// WithBlockHandler customizes the response of the blocked requests.
+// It responds 429 with Retry-After by default.
+func WithBlockHandler(f func(c *fiber.Ctx, b *base.BlockError) error) Option {
+	return func(o *options) {
+		o.blocked = f
+	}
+}
+
+// SentinelMiddleware returns the middleware which guards the requests with Sentinel.
+func SentinelMiddleware(opts ...Option) fiber.Handler {
+	o := &options{
+		resource: routeResource,
+		origin: func(c *fiber.Ctx) string {
+			return authority.OriginFromCarrier(requestHeader{c})
+		},
+		blocked: DefaultBlockHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *fiber.Ctx) error {
+		origin := ""
+		if o.origin != nil {
+			origin = o.origin(c)
+		}
+		// The trusted origins bypass all protection.
+		if authority.IsTrusted(origin) {
+			return c.Next()
+		}
+		entryOpts := []sentinel.EntryOption{
+			sentinel.WithTrafficType(base.Inbound),
+			sentinel.WithResourceType(base.ResTypeWeb),
+		}
+		if o.origin != nil {
+			entryOpts = append(entryOpts, authority.WithOrigin(origin))
+		}
+		if len(o.params) > 0 {
+			entryOpts = append(entryOpts, adapter.ParamOptions(o.params, func(source adapter.ParamSource, name string) string {
+				switch source {
+				case adapter.PathParam:
+					return c.Params(name)
+				case adapter.QueryParam:
+					return c.Query(name)
+				case adapter.HeaderParam:
+					return c.Get(name)
+				}
+				return ""
+			})...)
+		}
+		e, b := sentinel.Entry(o.resource(c), entryOpts...)
+		if b != nil {
+			return o.blocked(c, b)
+		}
+		defer e.Exit()
+		route := routePath(c)
+		err := c.Next()
+		if routePath(c) != route {
+			// The route has been resolved by the following handlers, i.e. the middleware
+			// has been registered by Use, and named the request by the prefix of Use.
+			misplacedOnce.Do(func() {
+				logger.Warnf("[Sentinel Fiber] SentinelMiddleware is registered by Use, which names the requests by the prefix %s; "+
+					"it should be registered along with the handlers of each route", route)
+			})
+		}
+		if isServerError(c, err) {
+			if err == nil {
+				err = errServerError
+			}
+			sentinel.TraceError(e, err)
+		}
+		return err
+	}
+}
+
+// isServerError reports whether the request results in a 5xx response. The returned errors
+// are responded by the error handler later, as 500 unless they are Fiber errors.
+func isServerError(c *fiber.Ctx, err error) bool {
+	if err == nil {
+		return c.Response().StatusCode() >= http.StatusInternalServerError
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// requestHeader is the origin carrier of the request headers.
+type requestHeader struct {
+	c *fiber.Ctx
+}
+
+func (h requestHeader) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h requestHeader) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func routePath(c *fiber.Ctx) string {
+	if r := c.Route(); r != nil {
+		return r.Path
+	}
+	return ""
+}
+
+// routeResource names the request by the route template, or resourcename.UnmatchedRoute
+// if no route matched.
+func routeResource(c *fiber.Ctx) string {
+	route := routePath(c)
+	if route == "" {
+		route = resourcename.UnmatchedRoute
+	}
+	return resourcename.HTTP(c.Method(), route)
+}
+
+// DefaultBlockHandler responds 429 Too Many Requests with Retry-After to the blocked request.
+func DefaultBlockHandler(c *fiber.Ctx, b *base.BlockError) error {
+	c.Set("Retry-After", strconv.Itoa(adapter.RetryAfterSec(b)))
+	return c.SendStatus(http.StatusTooManyRequests)
+}
@@ -0,0 +1,10 @@
+// Package gin provides the Sentinel middleware of Gin, which names the resources of the
+// requests by the route templates (e.g. "GET:/users/:id") rather than the raw URLs, and
+// responds 429 Too Many Requests with Retry-After once blocked:
+//
+//	r := gin.New()
+//	r.Use(ahasgin.SentinelMiddleware(ahasgin.WithParams(adapter.FromPath("id"))))
+//
+// The middleware is a separate module requiring github.com/gin-gonic/gin, so that the SDK
+// doesn't depend on it.
+package gin
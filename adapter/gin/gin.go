@@ -0,0 +1,129 @@
+package gin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/gin-gonic/gin"
+)
+
+// errServerError marks the 5xx responses as the errors of the resource for circuit breaking.
+var errServerError = errors.New("server error response")
+
+type options struct {
+	resource func(c *gin.Context) string
+	origin   func(c *gin.Context) string
+	params   []adapter.Param
+	blocked  func(c *gin.Context, b *base.BlockError)
+}
+
+// Option is the option of SentinelMiddleware.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the request.
+func WithResourceExtractor(f func(c *gin.Context) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// WithOriginExtractor extracts the origin (e.g. the caller app from a header) of the request
+// for the authority rules, see authority.WithOrigin. The origin is extracted from the header
+// authority.OriginHeader by default.
+func WithOriginExtractor(f func(c *gin.Context) string) Option {
+	return func(o *options) {
+		o.origin = f
+	}
+}
+
+// WithParams extracts the hot-spot parameters from the path variables, query parameters and headers.
+func WithParams(params ...adapter.Param) Option {
+	return func(o *options) {
+		o.params = append(o.params, params...)
+	}
+}
+
+// WithBlockHandler customizes the response of the blocked requests, which should abort the
+// context. It responds 429 with Retry-After by default.
+func WithBlockHandler(f func(c *gin.Context, b *base.BlockError)) Option {
+	return func(o *options) {
+		o.blocked = f
+	}
+}
+
+// SentinelMiddleware returns the middleware which guards the requests with Sentinel.
+func SentinelMiddleware(opts ...Option) gin.HandlerFunc {
+	o := &options{
+		resource: routeResource,
+		origin: func(c *gin.Context) string {
+			return authority.OriginFromHeader(c.Request.Header)
+		},
+		blocked: DefaultBlockHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(c *gin.Context) {
+		origin := ""
+		if o.origin != nil {
+			origin = o.origin(c)
+		}
+		// The trusted origins bypass all protection.
+		if authority.IsTrusted(origin) {
+			c.Next()
+			return
+		}
+		entryOpts := []sentinel.EntryOption{
+			sentinel.WithTrafficType(base.Inbound),
+			sentinel.WithResourceType(base.ResTypeWeb),
+		}
+		if o.origin != nil {
+			entryOpts = append(entryOpts, authority.WithOrigin(origin))
+		}
+		if len(o.params) > 0 {
+			entryOpts = append(entryOpts, adapter.ParamOptions(o.params, func(source adapter.ParamSource, name string) string {
+				switch source {
+				case adapter.PathParam:
+					return c.Param(name)
+				case adapter.QueryParam:
+					return c.Query(name)
+				case adapter.HeaderParam:
+					return c.GetHeader(name)
+				}
+				return ""
+			})...)
+		}
+		e, b := sentinel.Entry(o.resource(c), entryOpts...)
+		if b != nil {
+			o.blocked(c, b)
+			return
+		}
+		defer e.Exit()
+		c.Next()
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			sentinel.TraceError(e, errServerError)
+		}
+	}
+}
+
+// routeResource names the request by the route template, or resourcename.UnmatchedRoute
+// if no route matched.
+func routeResource(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = resourcename.UnmatchedRoute
+	}
+	return resourcename.HTTP(c.Request.Method, route)
+}
+
+// DefaultBlockHandler responds 429 Too Many Requests with Retry-After to the blocked request.
+func DefaultBlockHandler(c *gin.Context, b *base.BlockError) {
+	c.Header("Retry-After", strconv.Itoa(adapter.RetryAfterSec(b)))
+	c.AbortWithStatus(http.StatusTooManyRequests)
+}
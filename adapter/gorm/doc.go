@@ -0,0 +1,13 @@
+// Package gorm provides the Sentinel plugin of GORM, which guards the operations of the
+// models by the resources like "SELECT users", or the digests of the raw statements (see
+// resourcename.SQL), so that the circuit breaking rules of the slow request ratio shed the
+// load off a struggling database:
+//
+//	db.Use(ahasgorm.New())
+//	db.WithContext(adapter.WithLabel(ctx, "listOrders")).Find(&orders)
+//
+// The operations of a labeled context (see adapter.WithLabel) are guarded by the resource of the
+// label instead. The blocked operations fail with the *base.BlockError in db.Error.
+//
+// The plugin is a separate module requiring gorm.io/gorm, so that the SDK doesn't depend on it.
+package gorm
@@ -0,0 +1,11 @@
+module github.com/aliyun/aliyun-ahas-go-sdk/adapter/gorm
+
+go 1.14
+
+require (
+	github.com/alibaba/sentinel-golang v0.6.0
+	github.com/aliyun/aliyun-ahas-go-sdk v0.0.0
+	gorm.io/gorm v1.20.8
+)
+
+replace github.com/aliyun/aliyun-ahas-go-sdk => ../..
@@ -0,0 +1,126 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"gorm.io/gorm"
+)
+
+const (
+	pluginName = "ahas:sentinel"
+	// entryKey is the key of the entry in the instance of the operation.
+	entryKey = "ahas:sentinel_entry"
+)
+
+type options struct {
+	resource func(db *gorm.DB, op string) string
+}
+
+// Option is the option of the plugin.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the operation, where op is one of
+// "INSERT", "SELECT", "UPDATE", "DELETE" and "RAW".
+func WithResourceExtractor(f func(db *gorm.DB, op string) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+type plugin struct {
+	opts *options
+}
+
+// New returns the plugin which guards the operations with Sentinel.
+func New(opts ...Option) gorm.Plugin {
+	o := &options{
+		resource: operationResource,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &plugin{opts: o}
+}
+
+func (p *plugin) Name() string {
+	return pluginName
+}
+
+func (p *plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	// The processors of GORM are unexported, so their registration methods are kept instead.
+	operations := []struct {
+		before, after func(name string, fn func(*gorm.DB)) error
+		op            string
+	}{
+		{cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register, "INSERT"},
+		{cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register, "SELECT"},
+		{cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register, "UPDATE"},
+		{cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register, "DELETE"},
+		{cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register, "SELECT"},
+		{cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register, "RAW"},
+	}
+	for _, o := range operations {
+		if err := o.before(pluginName+":before", p.before(o.op)); err != nil {
+			return err
+		}
+		if err := o.after(pluginName+":after", p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *plugin) before(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil {
+			return
+		}
+		e, b := sentinel.Entry(p.opts.resource(db, op),
+			sentinel.WithTrafficType(base.Outbound),
+			sentinel.WithResourceType(base.ResTypeDBSQL))
+		if b != nil {
+			// The error skips the operation, as the GORM callbacks only run without errors.
+			_ = db.AddError(b)
+			return
+		}
+		db.InstanceSet(entryKey, e)
+	}
+}
+
+func (p *plugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(entryKey)
+	if !ok {
+		return
+	}
+	e, ok := v.(*base.SentinelEntry)
+	if !ok {
+		return
+	}
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, context.Canceled) {
+		sentinel.TraceError(e, err)
+	}
+	e.Exit()
+}
+
+// operationResource names the operation by the label of the context, the digest of the raw
+// statement, or the operation and the table.
+func operationResource(db *gorm.DB, op string) string {
+	if ctx := db.Statement.Context; ctx != nil {
+		if label := adapter.Label(ctx); label != "" {
+			return label
+		}
+	}
+	if sql := db.Statement.SQL.String(); sql != "" {
+		return resourcename.SQL(sql)
+	}
+	if db.Statement.Table == "" {
+		return op
+	}
+	return op + " " + db.Statement.Table
+}
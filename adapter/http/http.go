@@ -0,0 +1,203 @@
+// Package http provides the Sentinel middleware of net/http, which names the resources of
+// the requests by method and route (see resourcename.HTTP), and responds 429 Too Many Requests
+// with Retry-After once blocked:
+//
+//	mux.Handle("/users/", ahashttp.Route("/users/{id}", usersHandler))
+//	http.ListenAndServe(":8080", ahashttp.Middleware()(mux))
+//
+// Middleware names the resources by the URL path, which should only be used if the paths
+// have no variable segments; otherwise the handlers should be wrapped by Route.
+//
+// The outbound requests are guarded by Transport, which names the resources by the hosts
+// or the dependency names, for the circuit breaking and isolation of the third-party APIs:
+//
+//	client := &http.Client{Transport: ahashttp.Transport(nil, ahashttp.WithDependency("payment"))}
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+)
+
+// errServerError marks the 5xx responses as the errors of the resource for circuit breaking.
+var errServerError = errors.New("server error response")
+
+type options struct {
+	resource func(r *http.Request) string
+	origin   func(r *http.Request) string
+	params   []adapter.Param
+	blocked  func(w http.ResponseWriter, r *http.Request, b *base.BlockError)
+}
+
+// Option is the option of Middleware and Route.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the request.
+func WithResourceExtractor(f func(r *http.Request) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// WithOriginExtractor extracts the origin (e.g. the caller app from a header) of the request
+// for the authority rules, see authority.WithOrigin. The origin is extracted from the header
+// authority.OriginHeader by default.
+func WithOriginExtractor(f func(r *http.Request) string) Option {
+	return func(o *options) {
+		o.origin = f
+	}
+}
+
+// WithParams extracts the hot-spot parameters from the query parameters and headers.
+// The path variables are not supported, as net/http doesn't resolve them.
+func WithParams(params ...adapter.Param) Option {
+	return func(o *options) {
+		o.params = append(o.params, params...)
+	}
+}
+
+// WithBlockHandler customizes the response of the blocked requests, which responds 429 with
+// Retry-After by default.
+func WithBlockHandler(f func(w http.ResponseWriter, r *http.Request, b *base.BlockError)) Option {
+	return func(o *options) {
+		o.blocked = f
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		resource: func(r *http.Request) string {
+			return resourcename.HTTP(r.Method, r.URL.Path)
+		},
+		origin: func(r *http.Request) string {
+			return authority.OriginFromHeader(r.Header)
+		},
+		blocked: DefaultBlockHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Middleware returns the middleware which guards the requests with Sentinel.
+func Middleware(opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+	return func(next http.Handler) http.Handler {
+		return guard(next, o)
+	}
+}
+
+// Route guards the handler of the route, whose requests are named by method and the route
+// pattern, e.g. "GET:/users/:id" of the pattern "/users/{id}".
+func Route(pattern string, h http.Handler, opts ...Option) http.Handler {
+	routed := func(r *http.Request) string {
+		return resourcename.HTTP(r.Method, pattern)
+	}
+	return guard(h, newOptions(append([]Option{WithResourceExtractor(routed)}, opts...)))
+}
+
+func guard(next http.Handler, o *options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := ""
+		if o.origin != nil {
+			origin = o.origin(r)
+		}
+		// The trusted origins bypass all protection.
+		if authority.IsTrusted(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		resource := o.resource(r)
+		entryOpts := []sentinel.EntryOption{
+			sentinel.WithTrafficType(base.Inbound),
+			sentinel.WithResourceType(base.ResTypeWeb),
+		}
+		if o.origin != nil {
+			entryOpts = append(entryOpts, authority.WithOrigin(origin))
+		}
+		if len(o.params) > 0 {
+			entryOpts = append(entryOpts, adapter.ParamOptions(o.params, func(source adapter.ParamSource, name string) string {
+				switch source {
+				case adapter.QueryParam:
+					return r.URL.Query().Get(name)
+				case adapter.HeaderParam:
+					return r.Header.Get(name)
+				}
+				return ""
+			})...)
+		}
+		e, b := sentinel.Entry(resource, entryOpts...)
+		if b != nil {
+			o.blocked(w, r, b)
+			return
+		}
+		defer e.Exit()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= http.StatusInternalServerError {
+			sentinel.TraceError(e, errServerError)
+		}
+	})
+}
+
+// DefaultBlockHandler responds 429 Too Many Requests with Retry-After to the blocked request.
+func DefaultBlockHandler(w http.ResponseWriter, _ *http.Request, b *base.BlockError) {
+	w.Header().Set("Retry-After", strconv.Itoa(adapter.RetryAfterSec(b)))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// statusRecorder records the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush supports the streaming responses if the underlying writer does.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports the protocol upgrades (e.g. WebSocket) if the underlying writer does.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer doesn't support hijacking")
+	}
+	// The hijacked connection is up to the handler, which is regarded as a success.
+	r.wroteHeader = true
+	return h.Hijack()
+}
+
+// Push supports the HTTP/2 server push if the underlying writer does.
+func (r *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	if p, ok := r.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
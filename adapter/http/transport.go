@@ -0,0 +1,87 @@
+package http
+
+import (
+	"net/http"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+)
+
+type transportOptions struct {
+	resource func(r *http.Request) string
+}
+
+// TransportOption is the option of Transport.
+type TransportOption func(*transportOptions)
+
+// WithDependency names all the requests of the transport by the dependency name.
+func WithDependency(name string) TransportOption {
+	return WithRequestResourceExtractor(func(*http.Request) string {
+		return name
+	})
+}
+
+// WithRequestResourceExtractor customizes the resource name of the outbound request.
+func WithRequestResourceExtractor(f func(r *http.Request) string) TransportOption {
+	return func(o *transportOptions) {
+		o.resource = f
+	}
+}
+
+type transport struct {
+	next http.RoundTripper
+	opts *transportOptions
+}
+
+// Transport wraps the round tripper (http.DefaultTransport if nil), whose requests are guarded
+// with Sentinel. The requests of a labeled context (see adapter.WithLabel) are named by the
+// label, and the others by the hosts (with the ports if any) by default. The blocked requests
+// fail with the *base.BlockError, and the failed requests and 5xx responses are the errors
+// of the resources. The app name is carried as the origin of the requests (see
+// authority.InjectHeader).
+func Transport(next http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	o := &transportOptions{
+		resource: func(r *http.Request) string {
+			return r.URL.Host
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &transport{next: next, opts: o}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resource := adapter.Label(r.Context())
+	if resource == "" {
+		resource = t.opts.resource(r)
+	}
+	e, b := sentinel.Entry(resource,
+		sentinel.WithTrafficType(base.Outbound),
+		sentinel.WithResourceType(base.ResTypeWeb))
+	if b != nil {
+		// The body should be closed by the round tripper, even on errors.
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, b
+	}
+	defer e.Exit()
+	if r.Header.Get(authority.OriginHeader) == "" {
+		// The round tripper should not modify the request.
+		r = r.Clone(r.Context())
+		authority.InjectHeader(r.Header)
+	}
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		sentinel.TraceError(e, err)
+	} else if resp.StatusCode >= http.StatusInternalServerError {
+		sentinel.TraceError(e, errServerError)
+	}
+	return resp, err
+}
@@ -0,0 +1,11 @@
+// Package kafka provides the Sentinel wrapper of the sarama consumer group handlers, which
+// throttles the messages of each topic by the flow rules of the resource of the topic (see
+// resourcename.MQ). The messages are held back rather than dropped once blocked, which stops
+// the fetching of the partition when the buffer is full, or pauses it at once with WithPauser:
+//
+//	handler := ahaskafka.WrapConsumerGroupHandler(h, ahaskafka.WithGroup(groupId), ahaskafka.WithPauser(group))
+//	err := group.Consume(ctx, topics, handler)
+//
+// The wrapper is a separate module requiring github.com/Shopify/sarama, so that the SDK
+// doesn't depend on it.
+package kafka
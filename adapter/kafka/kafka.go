@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+)
+
+// Pauser pauses and resumes the fetching of the partitions, e.g. sarama.ConsumerGroup (since v1.32).
+type Pauser interface {
+	Pause(partitions map[string][]int32)
+	Resume(partitions map[string][]int32)
+}
+
+type options struct {
+	group    string
+	resource func(msg *sarama.ConsumerMessage) string
+	interval time.Duration
+	pauser   Pauser
+}
+
+// Option is the option of WrapConsumerGroupHandler.
+type Option func(*options)
+
+// WithGroup names the resources by the consumer group and the topic, rather than the topic only.
+func WithGroup(group string) Option {
+	return func(o *options) {
+		o.group = group
+	}
+}
+
+// WithResourceExtractor customizes the resource name of the message.
+func WithResourceExtractor(f func(msg *sarama.ConsumerMessage) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// WithRetryInterval customizes the interval of the retries of the blocked messages,
+// which is adapter.DefaultRetryInterval by default.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithPauser pauses the fetching of the partition while its messages are blocked.
+func WithPauser(p Pauser) Option {
+	return func(o *options) {
+		o.pauser = p
+	}
+}
+
+type handler struct {
+	sarama.ConsumerGroupHandler
+	opts *options
+}
+
+// WrapConsumerGroupHandler wraps the handler, whose messages are throttled with Sentinel.
+func WrapConsumerGroupHandler(h sarama.ConsumerGroupHandler, opts ...Option) sarama.ConsumerGroupHandler {
+	o := &options{
+		interval: adapter.DefaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.resource == nil {
+		group := o.group
+		o.resource = func(msg *sarama.ConsumerMessage) string {
+			return resourcename.MQ(group, msg.Topic)
+		}
+	}
+	return &handler{ConsumerGroupHandler: h, opts: o}
+}
+
+func (h *handler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	messages := make(chan *sarama.ConsumerMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go h.throttle(sess.Context(), claim, messages, done)
+	return h.ConsumerGroupHandler.ConsumeClaim(sess, &throttledClaim{ConsumerGroupClaim: claim, messages: messages})
+}
+
+// throttle passes the messages of the claim to the handler, holding back the blocked ones.
+func (h *handler) throttle(ctx context.Context, claim sarama.ConsumerGroupClaim,
+	messages chan<- *sarama.ConsumerMessage, done <-chan struct{}) {
+	defer close(messages)
+	defer tools.PrintPanicStackV2("kafka message throttle")
+
+	var paused func(bool)
+	if h.opts.pauser != nil {
+		partitions := map[string][]int32{claim.Topic(): {claim.Partition()}}
+		paused = func(p bool) {
+			if p {
+				h.opts.pauser.Pause(partitions)
+			} else {
+				h.opts.pauser.Resume(partitions)
+			}
+		}
+	}
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return
+			}
+			e, err := adapter.EntryWait(ctx, h.opts.resource(msg), h.opts.interval, paused,
+				sentinel.WithTrafficType(base.Inbound),
+				sentinel.WithResourceType(base.ResTypeMQ))
+			if err != nil {
+				return
+			}
+			e.Exit()
+			select {
+			case messages <- msg:
+			case <-done:
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// throttledClaim replaces the messages of the claim with the throttled ones.
+type throttledClaim struct {
+	sarama.ConsumerGroupClaim
+	messages <-chan *sarama.ConsumerMessage
+}
+
+func (c *throttledClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
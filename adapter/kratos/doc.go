@@ -0,0 +1,14 @@
+// Package kratos provides the Sentinel middlewares of Kratos, which guard the server and
+// client invocations by the resources of the operations (e.g. "/helloworld.v1.Greeter/SayHello",
+// see resourcename.GRPC), of both the gRPC and HTTP transports:
+//
+//	srv := grpc.NewServer(grpc.Middleware(ahaskratos.Server()))
+//	conn, err := grpc.DialInsecure(ctx, grpc.WithMiddleware(ahaskratos.Client()))
+//
+// The blocked invocations fail with the Kratos error of 429 Too Many Requests by default.
+// The client middleware carries the app name by the request header authority.OriginMetadataKey,
+// which is the origin of the authority rules on the server side.
+//
+// The middlewares are a separate module requiring github.com/go-kratos/kratos/v2 (and a newer
+// Go version than the SDK), so that the SDK doesn't depend on it.
+package kratos
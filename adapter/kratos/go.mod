@@ -0,0 +1,11 @@
+module github.com/aliyun/aliyun-ahas-go-sdk/adapter/kratos
+
+go 1.16
+
+require (
+	github.com/alibaba/sentinel-golang v0.6.0
+	github.com/aliyun/aliyun-ahas-go-sdk v0.0.0
+	github.com/go-kratos/kratos/v2 v2.0.0
+)
+
+replace github.com/aliyun/aliyun-ahas-go-sdk => ../..
@@ -0,0 +1,99 @@
+package kratos
+
+import (
+	"context"
+	"net/http"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+// BlockReason is the reason of the Kratos errors of the blocked invocations.
+const BlockReason = "SENTINEL_BLOCKED"
+
+type options struct {
+	blocked func(ctx context.Context, resource string, b *base.BlockError) error
+}
+
+// Option is the option of the middlewares.
+type Option func(*options)
+
+// WithBlockFallback customizes the error of the blocked invocations.
+func WithBlockFallback(f func(ctx context.Context, resource string, b *base.BlockError) error) Option {
+	return func(o *options) {
+		o.blocked = f
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		blocked: DefaultBlockFallback,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// DefaultBlockFallback results in the Kratos error of 429 Too Many Requests.
+func DefaultBlockFallback(_ context.Context, _ string, b *base.BlockError) error {
+	return errors.New(http.StatusTooManyRequests, BlockReason, b.Error())
+}
+
+// Server returns the middleware which guards the server invocations with Sentinel.
+func Server(opts ...Option) middleware.Middleware {
+	o := newOptions(opts)
+	return func(h middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return h(ctx, req)
+			}
+			origin := authority.OriginFromCarrier(tr.RequestHeader())
+			// The trusted origins bypass all protection.
+			if authority.IsTrusted(origin) {
+				return h(ctx, req)
+			}
+			return guard(ctx, o, tr.Operation(), base.Inbound, req, h, authority.WithOrigin(origin))
+		}
+	}
+}
+
+// Client returns the middleware which guards the client invocations with Sentinel.
+func Client(opts ...Option) middleware.Middleware {
+	o := newOptions(opts)
+	return func(h middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromClientContext(ctx)
+			if !ok {
+				return h(ctx, req)
+			}
+			authority.InjectCarrier(tr.RequestHeader())
+			return guard(ctx, o, tr.Operation(), base.Outbound, req, h)
+		}
+	}
+}
+
+func guard(ctx context.Context, o *options, operation string, trafficType base.TrafficType,
+	req interface{}, h middleware.Handler, opts ...sentinel.EntryOption) (interface{}, error) {
+	resource := resourcename.GRPC(operation)
+	e, b := sentinel.Entry(resource, append([]sentinel.EntryOption{
+		sentinel.WithTrafficType(trafficType),
+		sentinel.WithResourceType(base.ResTypeRPC),
+	}, opts...)...)
+	if b != nil {
+		return nil, o.blocked(ctx, resource, b)
+	}
+	defer e.Exit()
+	reply, err := h(ctx, req)
+	// The errors of the 4xx codes are of the requests rather than the server.
+	if err != nil && errors.FromError(err).Code >= http.StatusInternalServerError {
+		sentinel.TraceError(e, err)
+	}
+	return reply, err
+}
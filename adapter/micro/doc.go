@@ -0,0 +1,16 @@
+// Package micro provides the Sentinel wrappers of go-micro, which guard the server handlers
+// and the client calls by the resources of the services and endpoints (e.g.
+// "greeter:Greeter.Hello", see resourcename.RPC):
+//
+//	service := micro.NewService(
+//		micro.WrapHandler(ahasmicro.NewHandlerWrapper()),
+//		micro.WrapClient(ahasmicro.NewClientWrapper()),
+//	)
+//
+// The blocked invocations fail with the go-micro error of 429 Too Many Requests by default.
+// The client wrapper carries the app name by the metadata authority.OriginMetadataKey, which
+// is the origin of the authority rules on the server side.
+//
+// The wrappers are a separate module requiring github.com/micro/go-micro/v2, so that the SDK
+// doesn't depend on it.
+package micro
@@ -0,0 +1,148 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/micro/go-micro/v2/client"
+	microerrors "github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/server"
+)
+
+type options struct {
+	blocked func(ctx context.Context, resource string, b *base.BlockError) error
+}
+
+// Option is the option of the wrappers.
+type Option func(*options)
+
+// WithBlockFallback customizes the error of the blocked invocations.
+func WithBlockFallback(f func(ctx context.Context, resource string, b *base.BlockError) error) Option {
+	return func(o *options) {
+		o.blocked = f
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		blocked: DefaultBlockFallback,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// DefaultBlockFallback results in the go-micro error of 429 Too Many Requests.
+func DefaultBlockFallback(_ context.Context, resource string, b *base.BlockError) error {
+	return microerrors.New(resource, b.Error(), http.StatusTooManyRequests)
+}
+
+// NewHandlerWrapper returns the wrapper which guards the handlers with Sentinel.
+func NewHandlerWrapper(opts ...Option) server.HandlerWrapper {
+	o := newOptions(opts)
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			md, _ := metadata.FromContext(ctx)
+			origin := authority.OriginFromCarrier(metadataCarrier(md))
+			// The trusted origins bypass all protection.
+			if authority.IsTrusted(origin) {
+				return h(ctx, req, rsp)
+			}
+			resource := resourcename.RPC(req.Service(), req.Endpoint())
+			return guard(ctx, o, resource, base.Inbound, func() error {
+				return h(ctx, req, rsp)
+			}, authority.WithOrigin(origin))
+		}
+	}
+}
+
+// NewClientWrapper returns the wrapper which guards the client calls with Sentinel.
+func NewClientWrapper(opts ...Option) client.Wrapper {
+	o := newOptions(opts)
+	return func(c client.Client) client.Client {
+		return &guardedClient{Client: c, opts: o}
+	}
+}
+
+type guardedClient struct {
+	client.Client
+	opts *options
+}
+
+func (c *guardedClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	ctx = withOrigin(ctx)
+	resource := resourcename.RPC(req.Service(), req.Endpoint())
+	return guard(ctx, c.opts, resource, base.Outbound, func() error {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	})
+}
+
+// withOrigin carries the local origin by the metadata of the outgoing call.
+func withOrigin(ctx context.Context) context.Context {
+	md, _ := metadata.FromContext(ctx)
+	// The metadata of the context is shared with the other calls, so it's copied.
+	carrier := make(metadataCarrier, len(md)+1)
+	for k, v := range md {
+		carrier[k] = v
+	}
+	authority.InjectCarrier(carrier)
+	return metadata.NewContext(ctx, metadata.Metadata(carrier))
+}
+
+// metadataCarrier is the origin carrier of the go-micro metadata, whose keys are
+// canonicalized differently by the transports.
+type metadataCarrier map[string]string
+
+func (c metadataCarrier) Get(key string) string {
+	if v, ok := c[key]; ok {
+		return v
+	}
+	for k, v := range c {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func guard(ctx context.Context, o *options, resource string, trafficType base.TrafficType, f func() error,
+	opts ...sentinel.EntryOption) error {
+	e, b := sentinel.Entry(resource, append([]sentinel.EntryOption{
+		sentinel.WithTrafficType(trafficType),
+		sentinel.WithResourceType(base.ResTypeRPC),
+	}, opts...)...)
+	if b != nil {
+		return o.blocked(ctx, resource, b)
+	}
+	defer e.Exit()
+	err := f()
+	if isServerError(err) {
+		sentinel.TraceError(e, err)
+	}
+	return err
+}
+
+// isServerError reports whether the error is of the server, rather than of the request
+// (the go-micro errors of the 4xx codes).
+func isServerError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var me *microerrors.Error
+	if errors.As(err, &me) && me.Code > 0 && me.Code < http.StatusInternalServerError {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,15 @@
+// Package redis provides the Sentinel hook of go-redis, which guards the commands by the
+// resources of the command names (e.g. "GET"), or of the key patterns with WithKeyPattern
+// (e.g. "GET user:*:profile", see resourcename.Redis):
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: addr})
+//	rdb.AddHook(ahasredis.NewHook())
+//	v, err := rdb.Get(adapter.WithLabel(ctx, "userProfile"), key).Result()
+//
+// The commands of a labeled context (see adapter.WithLabel) are guarded by the resource of
+// the label instead, and the pipelines by the resource "PIPELINE" if not labeled.
+// The blocked commands fail with the *base.BlockError.
+//
+// The hook is a separate module requiring github.com/go-redis/redis/v8, so that the SDK
+// doesn't depend on it.
+package redis
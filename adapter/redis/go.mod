@@ -0,0 +1,11 @@
+module github.com/aliyun/aliyun-ahas-go-sdk/adapter/redis
+
+go 1.13
+
+require (
+	github.com/alibaba/sentinel-golang v0.6.0
+	github.com/aliyun/aliyun-ahas-go-sdk v0.0.0
+	github.com/go-redis/redis/v8 v8.4.0
+)
+
+replace github.com/aliyun/aliyun-ahas-go-sdk => ../..
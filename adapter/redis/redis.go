@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/go-redis/redis/v8"
+)
+
+// PipelineResource is the resource of the pipelines which are not labeled.
+const PipelineResource = "PIPELINE"
+
+// entryKey is the key of the entry in the context of the command.
+type entryKey struct{}
+
+type options struct {
+	resource func(ctx context.Context, cmd redis.Cmder) string
+}
+
+// Option is the option of NewHook.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the command.
+func WithResourceExtractor(f func(ctx context.Context, cmd redis.Cmder) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// WithKeyPattern names the commands by the command names and the patterns of the keys.
+func WithKeyPattern() Option {
+	return WithResourceExtractor(func(ctx context.Context, cmd redis.Cmder) string {
+		if label := adapter.Label(ctx); label != "" {
+			return label
+		}
+		key := ""
+		if args := cmd.Args(); len(args) > 1 {
+			key, _ = args[1].(string)
+		}
+		return resourcename.Redis(cmd.Name(), key)
+	})
+}
+
+type hook struct {
+	opts *options
+}
+
+// NewHook returns the hook which guards the commands with Sentinel.
+func NewHook(opts ...Option) redis.Hook {
+	o := &options{
+		resource: commandResource,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &hook{opts: o}
+}
+
+func (h *hook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return entry(ctx, h.opts.resource(ctx, cmd))
+}
+
+func (h *hook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	exit(ctx, cmd.Err())
+	return nil
+}
+
+func (h *hook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	resource := adapter.Label(ctx)
+	if resource == "" {
+		resource = PipelineResource
+	}
+	return entry(ctx, resource)
+}
+
+func (h *hook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			err = cmdErr
+			break
+		}
+	}
+	exit(ctx, err)
+	return nil
+}
+
+// entry enters the resource, and carries the entry in the returned context.
+func entry(ctx context.Context, resource string) (context.Context, error) {
+	e, b := sentinel.Entry(resource,
+		sentinel.WithTrafficType(base.Outbound),
+		sentinel.WithResourceType(base.ResTypeCache))
+	if b != nil {
+		return ctx, b
+	}
+	return context.WithValue(ctx, entryKey{}, e), nil
+}
+
+// exit exits the entry of the context, if any, recording the error of the command.
+// The missing keys (redis.Nil) are not errors.
+func exit(ctx context.Context, err error) {
+	e, ok := ctx.Value(entryKey{}).(*base.SentinelEntry)
+	if !ok {
+		return
+	}
+	if err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled) {
+		sentinel.TraceError(e, err)
+	}
+	e.Exit()
+}
+
+// commandResource names the command by the label of the context, or the command name.
+func commandResource(ctx context.Context, cmd redis.Cmder) string {
+	if label := adapter.Label(ctx); label != "" {
+		return label
+	}
+	return resourcename.Redis(cmd.Name(), "")
+}
@@ -0,0 +1,12 @@
+// Package rocketmq provides the Sentinel wrapper of the rocketmq-client-go push consumer
+// functions, which throttles the messages of each topic by the flow rules of the resource of
+// the consumer group and the topic (see resourcename.MQ), the same as the Java SDK. The
+// messages are held back rather than dropped once blocked, and the consumer is suspended
+// meanwhile with WithSuspender:
+//
+//	err := c.Subscribe(topic, consumer.MessageSelector{},
+//		ahasrocketmq.WrapConsumeFunc(groupName, consume, ahasrocketmq.WithSuspender(c)))
+//
+// The wrapper is a separate module requiring github.com/apache/rocketmq-client-go/v2, so that
+// the SDK doesn't depend on it.
+package rocketmq
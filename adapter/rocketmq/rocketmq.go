@@ -0,0 +1,81 @@
+package rocketmq
+
+import (
+	"context"
+	"time"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+	"github.com/apache/rocketmq-client-go/v2/consumer"
+	"github.com/apache/rocketmq-client-go/v2/primitive"
+)
+
+// ConsumeFunc is the function of the push consumer subscriptions.
+type ConsumeFunc func(ctx context.Context, msgs ...*primitive.MessageExt) (consumer.ConsumeResult, error)
+
+// Suspender suspends and resumes the consumption, e.g. rocketmq.PushConsumer (since v2.1).
+type Suspender interface {
+	Suspend()
+	Resume()
+}
+
+type options struct {
+	interval  time.Duration
+	suspender Suspender
+}
+
+// Option is the option of WrapConsumeFunc.
+type Option func(*options)
+
+// WithRetryInterval customizes the interval of the retries of the blocked messages,
+// which is adapter.DefaultRetryInterval by default.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithSuspender suspends the consumer while the messages are blocked.
+func WithSuspender(s Suspender) Option {
+	return func(o *options) {
+		o.suspender = s
+	}
+}
+
+// WrapConsumeFunc wraps the function of the consumer group, whose messages are throttled with
+// Sentinel. The messages are retried later if the context is done while blocked.
+func WrapConsumeFunc(group string, f ConsumeFunc, opts ...Option) ConsumeFunc {
+	o := &options{
+		interval: adapter.DefaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var paused func(bool)
+	if o.suspender != nil {
+		paused = func(p bool) {
+			if p {
+				o.suspender.Suspend()
+			} else {
+				o.suspender.Resume()
+			}
+		}
+	}
+	return func(ctx context.Context, msgs ...*primitive.MessageExt) (consumer.ConsumeResult, error) {
+		if len(msgs) == 0 {
+			return f(ctx, msgs...)
+		}
+		// The messages of a batch are of the same queue, and so the same topic.
+		e, err := adapter.EntryWait(ctx, resourcename.MQ(group, msgs[0].Topic), o.interval, paused,
+			sentinel.WithTrafficType(base.Inbound),
+			sentinel.WithResourceType(base.ResTypeMQ),
+			sentinel.WithAcquireCount(uint32(len(msgs))))
+		if err != nil {
+			return consumer.ConsumeRetryLater, err
+		}
+		e.Exit()
+		return f(ctx, msgs...)
+	}
+}
@@ -0,0 +1,320 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+)
+
+type wrappedDriver struct {
+	driver.Driver
+	opts *options
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: c, opts: d.opts}, nil
+}
+
+type wrappedConnector struct {
+	driver.Connector
+	driver *wrappedDriver
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, opts: c.driver.opts}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector opens the connections of the drivers which don't implement driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver *wrappedDriver
+}
+
+func (c *dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// guard runs the statement in the entry of its resource, and records the error of it.
+func guard(ctx context.Context, o *options, query string, f func() error) error {
+	e, b := enter(ctx, o, query)
+	if b != nil {
+		return b
+	}
+	err := f()
+	exit(e, err)
+	return err
+}
+
+func enter(ctx context.Context, o *options, query string) (*base.SentinelEntry, *base.BlockError) {
+	return sentinel.Entry(o.resource(ctx, query),
+		sentinel.WithTrafficType(base.Outbound),
+		sentinel.WithResourceType(base.ResTypeDBSQL))
+}
+
+// exit records the error of the statement and exits its entry.
+func exit(e *base.SentinelEntry, err error) {
+	// The bad connections are retried by database/sql, and the canceled ones are up to the caller.
+	if err != nil && err != driver.ErrSkip && err != driver.ErrBadConn && !errors.Is(err, context.Canceled) {
+		sentinel.TraceError(e, err)
+	}
+	e.Exit()
+}
+
+// fallbackEntry is the entry of a statement which the connection skipped (driver.ErrSkip),
+// and which database/sql then runs by preparing it on the same connection. The prepared
+// statement runs in this entry rather than entering the resource again.
+type fallbackEntry struct {
+	query string
+	entry *base.SentinelEntry
+}
+
+// wrappedConn implements the optional interfaces of the connections, falling back to the
+// required methods (or driver.ErrSkip) if the wrapped connection doesn't implement them.
+type wrappedConn struct {
+	driver.Conn
+	opts *options
+	// fallback is only accessed by the holder of the connection, as database/sql never uses
+	// a connection concurrently.
+	fallback *fallbackEntry
+}
+
+// guardSkippable runs the statement like guard, while the entry of a skipped statement is
+// kept for its fallback.
+func (c *wrappedConn) guardSkippable(ctx context.Context, query string, f func() error) error {
+	c.releaseFallback()
+	e, b := enter(ctx, c.opts, query)
+	if b != nil {
+		return b
+	}
+	err := f()
+	if err == driver.ErrSkip {
+		c.fallback = &fallbackEntry{query: query, entry: e}
+		return err
+	}
+	exit(e, err)
+	return err
+}
+
+// takeFallback returns the entry of the skipped query, if it is the one being prepared.
+func (c *wrappedConn) takeFallback(query string) *base.SentinelEntry {
+	fb := c.fallback
+	if fb == nil {
+		return nil
+	}
+	c.fallback = nil
+	if fb.query != query {
+		fb.entry.Exit()
+		return nil
+	}
+	return fb.entry
+}
+
+// releaseFallback exits the entry of the skipped statement which has never been run.
+func (c *wrappedConn) releaseFallback() {
+	if c.fallback != nil {
+		c.fallback.entry.Exit()
+		c.fallback = nil
+	}
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		s   driver.Stmt
+		err error
+	)
+	fallback := c.takeFallback(query)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		if fallback != nil {
+			exit(fallback, err)
+		}
+		return nil, err
+	}
+	return &wrappedStmt{Stmt: s, query: query, opts: c.opts, fallback: fallback}, nil
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var r driver.Result
+	err := c.guardSkippable(ctx, query, func() (err error) {
+		r, err = ec.ExecContext(ctx, query, args)
+		return err
+	})
+	return r, err
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var rows driver.Rows
+	err := c.guardSkippable(ctx, query, func() (err error) {
+		rows, err = qc.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	c.releaseFallback()
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *wrappedConn) Close() error {
+	c.releaseFallback()
+	return c.Conn.Close()
+}
+
+type wrappedStmt struct {
+	driver.Stmt
+	query string
+	opts  *options
+	// fallback is the entry of the skipped statement, in which the first run of it runs.
+	fallback *base.SentinelEntry
+}
+
+// guard runs the statement in the entry of the skipped statement if any, or a new entry.
+func (s *wrappedStmt) guard(ctx context.Context, f func() error) error {
+	e := s.fallback
+	if e == nil {
+		return guard(ctx, s.opts, s.query, f)
+	}
+	s.fallback = nil
+	err := f()
+	exit(e, err)
+	return err
+}
+
+func (s *wrappedStmt) Close() error {
+	if s.fallback != nil {
+		s.fallback.Exit()
+		s.fallback = nil
+	}
+	return s.Stmt.Close()
+}
+
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	var r driver.Result
+	err := s.guard(context.Background(), func() (err error) {
+		r, err = s.Stmt.Exec(args)
+		return err
+	})
+	return r, err
+}
+
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	var rows driver.Rows
+	err := s.guard(context.Background(), func() (err error) {
+		rows, err = s.Stmt.Query(args)
+		return err
+	})
+	return rows, err
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Exec(values)
+	}
+	var r driver.Result
+	err := s.guard(ctx, func() (err error) {
+		r, err = ec.ExecContext(ctx, args)
+		return err
+	})
+	return r, err
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Query(values)
+	}
+	var rows driver.Rows
+	err := s.guard(ctx, func() (err error) {
+		rows, err = qc.QueryContext(ctx, args)
+		return err
+	})
+	return rows, err
+}
+
+func (s *wrappedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if nc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+// namedValuesToValues converts the arguments for the legacy statements, which support no names.
+func namedValuesToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(named))
+	for i, v := range named {
+		if v.Name != "" {
+			return nil, errors.New("driver does not support the use of named parameters")
+		}
+		values[i] = v.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,73 @@
+// Package sql provides the Sentinel wrapper of the database/sql drivers, which guards the
+// statements by the resources of their digests (see resourcename.SQL), so that the circuit
+// breaking rules of the slow request ratio shed the load off a struggling database:
+//
+//	db, err := ahassql.Open("mysql", dsn)
+//	rows, err := db.QueryContext(adapter.WithLabel(ctx, "listOrders"), query, uid)
+//
+// The statements of a labeled context (see adapter.WithLabel) are guarded by the resource of
+// the label instead.
+// The blocked statements fail with the *base.BlockError.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/adapter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
+)
+
+type options struct {
+	resource func(ctx context.Context, query string) string
+}
+
+// Option is the option of the driver wrapper.
+type Option func(*options)
+
+// WithResourceExtractor customizes the resource name of the statement.
+func WithResourceExtractor(f func(ctx context.Context, query string) string) Option {
+	return func(o *options) {
+		o.resource = f
+	}
+}
+
+// statementResource names the statement by the label of the context, or the digest of the query.
+func statementResource(ctx context.Context, query string) string {
+	if label := adapter.Label(ctx); label != "" {
+		return label
+	}
+	return resourcename.SQL(query)
+}
+
+// Wrap wraps the driver, whose statements are guarded with Sentinel.
+func Wrap(d driver.Driver, opts ...Option) driver.Driver {
+	o := &options{
+		resource: statementResource,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &wrappedDriver{Driver: d, opts: o}
+}
+
+// Open opens the database of the registered driver, whose statements are guarded with Sentinel.
+func Open(driverName, dataSourceName string, opts ...Option) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	// No connection is opened yet, so that closing the database is free.
+	_ = db.Close()
+	wrapped := Wrap(d, opts...).(*wrappedDriver)
+	if dc, ok := d.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(&wrappedConnector{Connector: c, driver: wrapped}), nil
+	}
+	return sql.OpenDB(&dsnConnector{dsn: dataSourceName, driver: wrapped}), nil
+}
@@ -0,0 +1,51 @@
+package adapter
+
+import (
+	"context"
+	"time"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+)
+
+// DefaultRetryInterval is the interval of the retries of EntryWait.
+const DefaultRetryInterval = 200 * time.Millisecond
+
+// EntryWait enters the resource like sentinel.Entry, but waits for the pass rather than fails
+// while the invocations are blocked, e.g. for the messages which shouldn't be dropped. The entry
+// is retried at the interval until the context is done, in which case the error of the context
+// is returned. The paused is called (if not nil) with true once blocked, and with false once
+// passed after that.
+func EntryWait(ctx context.Context, resource string, interval time.Duration, paused func(bool),
+	opts ...sentinel.EntryOption) (*base.SentinelEntry, error) {
+	if interval <= 0 {
+		interval = DefaultRetryInterval
+	}
+	var timer *time.Timer
+	for {
+		e, b := sentinel.Entry(resource, opts...)
+		if b == nil {
+			if timer != nil && paused != nil {
+				paused(false)
+			}
+			return e, nil
+		}
+		if timer == nil {
+			if paused != nil {
+				paused(true)
+			}
+			timer = time.NewTimer(interval)
+		} else {
+			timer.Reset(interval)
+		}
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if paused != nil {
+				paused(false)
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
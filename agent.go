@@ -0,0 +1,100 @@
+package ahas
+
+import (
+	"context"
+	"sync"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Options configures an Agent. All settings are passed explicitly: no config file
+// or system env is read.
+type Options struct {
+	// Config is the AHAS config. nil means config.NewDefaultConfig().
+	Config *config.Config
+	// SentinelConfig is the Sentinel config. nil means Sentinel is initialized by the host
+	// (e.g. the framework embedding AHAS), and the agent won't touch it.
+	SentinelConfig *sentinelConf.Entity
+	// Logger is the logger of AHAS, e.g. the logger of the host framework. nil means
+	// the default logger (stderr), and the ahas.log file won't be written.
+	Logger *zap.Logger
+	// CustomLogger is the logger of AHAS implemented with other logging libraries (e.g. logrus
+	// or slog), which takes precedence over Logger.
+	CustomLogger logger.Logger
+}
+
+// Agent is an AHAS instance with explicit lifecycle, which is intended for the frameworks
+// shipping AHAS protection as an optional feature. The agent keeps its state to itself: it
+// runs a Client with the config, so it never touches the config of InitAhas* nor writes any
+// file, and several agents (of disjoint apps, see Client) could run in a process. Sentinel and
+// the logger are process-global, and they are only initialized if the Options ask for it.
+type Agent struct {
+	opts Options
+
+	mux    sync.Mutex
+	client *Client
+}
+
+// NewAgent creates an agent. Nothing is started until Start is called.
+func NewAgent(opts Options) *Agent {
+	return &Agent{opts: opts}
+}
+
+// Start starts the agent. Cancelling the context stops the rule subscriptions, so it should
+// live as long as the agent; Close should still be called to release the other resources.
+func (a *Agent) Start(ctx context.Context) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.client != nil {
+		return errors.New("AHAS agent already started")
+	}
+	client, err := NewClient(a.opts.Config)
+	if err != nil {
+		return err
+	}
+	if a.opts.SentinelConfig != nil {
+		if err = sentinel.InitWithConfig(a.opts.SentinelConfig); err != nil {
+			return errors.Wrap(err, "failed to initialize Sentinel")
+		}
+	}
+	if a.opts.CustomLogger != nil {
+		logger.UseLogger(a.opts.CustomLogger)
+	} else if a.opts.Logger != nil {
+		logger.SetLogger(a.opts.Logger)
+	}
+	if err = client.Start(ctx); err != nil {
+		return err
+	}
+	a.client = client
+	return nil
+}
+
+// Client returns the client run by the agent, or nil if the agent is not started.
+func (a *Agent) Client() *Client {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.client
+}
+
+// Close stops the agent, after which it could be started again.
+func (a *Agent) Close() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.client == nil {
+		return nil
+	}
+	err := a.client.Close()
+	a.client = nil
+	return err
+}
+
+// Shutdown stops the agent like Close. The agent has nothing pending to flush, as the metrics
+// and block logs are only uploaded by InitAhas*, so the context is unused.
+func (a *Agent) Shutdown(_ context.Context) error {
+	return a.Close()
+}
@@ -0,0 +1,43 @@
+package ahas
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+func TestAgentRestart(t *testing.T) {
+	meta.SetCloudProvider(localCloud{})
+	g := startFakeGateway(t, 0)
+	defer g.Close()
+	conf := config.NewDefaultConfig()
+	conf.License = "agent"
+	conf.Endpoints = []string{g.ln.Addr().String()}
+	conf.Transport.Tls.InsecureSkipVerify = true
+	conf.DataSource.Mode = datasource.PushDeliveryMode
+	globalLicense := config.License()
+	a := NewAgent(Options{Config: conf})
+	defer a.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := a.Start(context.Background()); err != nil {
+			t.Fatalf("start #%d: %+v", i+1, err)
+		}
+		if err := a.Start(context.Background()); err == nil {
+			t.Fatal("the running agent started again")
+		}
+		if s := a.Client().State(); s != transport.StateConnected {
+			t.Fatalf("state: got %v, want %v", s, transport.StateConnected)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if config.License() != globalLicense {
+		t.Fatal("the agent touched the global config")
+	}
+}
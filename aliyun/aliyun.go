@@ -1,40 +1,49 @@
 package aliyun
 
 import (
-	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
 	"github.com/aliyun/aliyun-ahas-go-sdk/service"
 	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
 )
 
 const (
 	AliyuncsDomain = "aliyuncs.com"
 	EcsVpcUrl      = "http://100.100.100.200/latest/meta-data/"
+	EcsUserDataUrl = "http://100.100.100.200/latest/user-data"
 	CnPublic       = "cn-public"
 )
 
-var endpointMap = map[string]string{
-	"pre-cn-hangzhou":     "pre.proxy.ahas.aliyun.com:9527",
-	"prod-cn-hangzhou":    "proxy.ahas.cn-hangzhou.aliyuncs.com:9527",
-	"prod-cn-beijing":     "proxy.ahas.cn-beijing.aliyuncs.com:9527",
-	"prod-cn-shenzhen":    "proxy.ahas.cn-shenzhen.aliyuncs.com:9527",
-	"prod-cn-shanghai":    "proxy.ahas.cn-shanghai.aliyuncs.com:9527",
-	"prod-cn-zhangjiakou": "proxy.ahas.cn-zhangjiakou.aliyuncs.com:9527",
-	"prod-cn-public":      "ahas-proxy.aliyuncs.com:8848",
+var endpointMap = map[string][]string{
+	"pre-cn-hangzhou":     {"pre.proxy.ahas.aliyun.com:9527"},
+	"prod-cn-hangzhou":    {"proxy.ahas.cn-hangzhou.aliyuncs.com:9527"},
+	"prod-cn-beijing":     {"proxy.ahas.cn-beijing.aliyuncs.com:9527"},
+	"prod-cn-shenzhen":    {"proxy.ahas.cn-shenzhen.aliyuncs.com:9527"},
+	"prod-cn-shanghai":    {"proxy.ahas.cn-shanghai.aliyuncs.com:9527"},
+	"prod-cn-zhangjiakou": {"proxy.ahas.cn-zhangjiakou.aliyuncs.com:9527"},
+	"prod-cn-public":      {"ahas-proxy.aliyuncs.com:8848"},
 }
 
+// GetAhasProxyEndpoint returns the preferred AHAS gateway endpoint of the env-region key.
 func GetAhasProxyEndpoint(key string) (string, bool) {
 	v, ok := endpointMap[key]
-	return v, ok
+	if !ok || len(v) == 0 {
+		return "", false
+	}
+	return v[0], true
+}
+
+// GetAhasProxyEndpoints returns the AHAS gateway endpoints of the env-region key, in the order of preference.
+func GetAhasProxyEndpoints(key string) ([]string, bool) {
+	v, ok := endpointMap[key]
+	return append([]string(nil), v...), ok && len(v) > 0
 }
 
 var acmEndpointMap = map[string]string{
@@ -54,19 +63,29 @@ func GetAcmEndpoint(key string) (string, bool) {
 	return v, ok
 }
 
-var tlsEndpointMap = map[string]string{
-	"pre-cn-hangzhou":     "pre.proxy.ahas.tls.aliyuncs.com:9528",
-	"prod-cn-hangzhou":    "proxy.ahas.cn-hangzhou.tls.aliyuncs.com:9528",
-	"prod-cn-beijing":     "proxy.ahas.cn-beijing.tls.aliyuncs.com:9528",
-	"prod-cn-shenzhen":    "proxy.ahas.cn-shenzhen.tls.aliyuncs.com:9528",
-	"prod-cn-shanghai":    "proxy.ahas.cn-shanghai.tls.aliyuncs.com:9528",
-	"prod-cn-zhangjiakou": "proxy.ahas.cn-zhangjiakou.tls.aliyuncs.com:9528",
-	"prod-cn-public":      "proxy.ahas.cn-public.tls.aliyuncs.com:9528",
+var tlsEndpointMap = map[string][]string{
+	"pre-cn-hangzhou":     {"pre.proxy.ahas.tls.aliyuncs.com:9528"},
+	"prod-cn-hangzhou":    {"proxy.ahas.cn-hangzhou.tls.aliyuncs.com:9528"},
+	"prod-cn-beijing":     {"proxy.ahas.cn-beijing.tls.aliyuncs.com:9528"},
+	"prod-cn-shenzhen":    {"proxy.ahas.cn-shenzhen.tls.aliyuncs.com:9528"},
+	"prod-cn-shanghai":    {"proxy.ahas.cn-shanghai.tls.aliyuncs.com:9528"},
+	"prod-cn-zhangjiakou": {"proxy.ahas.cn-zhangjiakou.tls.aliyuncs.com:9528"},
+	"prod-cn-public":      {"proxy.ahas.cn-public.tls.aliyuncs.com:9528"},
 }
 
+// GetAhasProxyTlsEndpoint returns the preferred AHAS gateway TLS endpoint of the env-region key.
 func GetAhasProxyTlsEndpoint(key string) (string, bool) {
 	v, ok := tlsEndpointMap[key]
-	return v, ok
+	if !ok || len(v) == 0 {
+		return "", false
+	}
+	return v[0], true
+}
+
+// GetAhasProxyTlsEndpoints returns the AHAS gateway TLS endpoints of the env-region key, in the order of preference.
+func GetAhasProxyTlsEndpoints(key string) ([]string, bool) {
+	v, ok := tlsEndpointMap[key]
+	return append([]string(nil), v...), ok && len(v) > 0
 }
 
 var channel *Channel
@@ -96,37 +115,48 @@ func GetInstance() *Channel {
 }
 
 // RetrieveVpcMetadata retrieves the metadata of current ECS instance or container.
+// Each metadata request is retried on failure as configured by SetMetadataConfig,
+// and ErrMetadataDisabled is returned immediately if the metadata access is disabled.
 func RetrieveVpcMetadata() (*VpcEcsMetadata, error) {
 	vpcEcs := &VpcEcsMetadata{}
-	vpcEcs.VpcId = getVpcId()
-	if vpcEcs.VpcId == "" {
-		// retry
-		time.Sleep(time.Second)
-		vpcEcs.VpcId = getVpcId()
+	var err error
+	if vpcEcs.VpcId, err = requireMetadata("vpc-id"); err != nil {
+		return nil, err
 	}
-	if vpcEcs.VpcId == "" {
-		return nil, fmt.Errorf("get vpc id info failed")
+	if vpcEcs.RegionId, err = requireMetadata("region-id"); err != nil {
+		return nil, err
 	}
-	vpcEcs.RegionId = getRegionId()
-	if vpcEcs.RegionId == "" {
-		return nil, fmt.Errorf("failed to get regionId")
-	}
-	vpcEcs.Ip = getPrivateIpv4()
-	if vpcEcs.Ip == "" {
-		return nil, fmt.Errorf("get ecs ip info failed")
+	if vpcEcs.Ip, err = GetPrivateIpv4(); err != nil {
+		return nil, err
 	}
 	vpcEcs.HostName = getHostName()
-	vpcEcs.InstanceId = getInstanceId()
-	if vpcEcs.InstanceId == "" {
-		return nil, fmt.Errorf("get ecs id info failed")
+	if vpcEcs.InstanceId, err = requireMetadata("instance-id"); err != nil {
+		return nil, err
 	}
-	vpcEcs.Uid = getOwnerAccountId()
-	if vpcEcs.Uid == "" {
-		return nil, fmt.Errorf("get vpc uid info failed")
+	if vpcEcs.Uid, err = requireMetadata("owner-account-id"); err != nil {
+		return nil, err
 	}
+	vpcEcs.IsVpc = true
 	return vpcEcs, nil
 }
 
+// GetPrivateIpv4 returns the private IPv4 address of current ECS instance.
+func GetPrivateIpv4() (string, error) {
+	return requireMetadata("private-ipv4")
+}
+
+// requireMetadata gets the metadata of the given path, which must not be empty.
+func requireMetadata(path string) (string, error) {
+	v, err := fetchMetadata(EcsVpcUrl + path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get %s from ECS metadata", path)
+	}
+	if v == "" {
+		return "", errors.Errorf("%s is absent in ECS metadata", path)
+	}
+	return v, nil
+}
+
 func (channel *Channel) DoStart() error {
 	return nil
 }
@@ -174,59 +204,42 @@ func GetOssUrl(region, originalFilePath string, isPrivate bool) string {
 	}
 }
 
-//getVpcId
-func getVpcId() string {
-	return getRemoteMessage(EcsVpcUrl + "vpc-id")
-}
-
-//getPrivateIpv4
-func getPrivateIpv4() string {
-	return getRemoteMessage(EcsVpcUrl + "private-ipv4")
-}
-
-//getInstanceId
-func getInstanceId() string {
-	return getRemoteMessage(EcsVpcUrl + "instance-id")
-}
-
-//getOwnerAccountId
-func getOwnerAccountId() string {
-	return getRemoteMessage(EcsVpcUrl + "owner-account-id")
-}
-
 //getHostName
 func getHostName() string {
 	return getRemoteMessage(EcsVpcUrl + "hostname")
 }
 
-func getRegionId() string {
-	return getRemoteMessage(EcsVpcUrl + "region-id")
+// GetInstanceTag returns the value of the given tag of current ECS instance,
+// or empty string if the tag is absent.
+func GetInstanceTag(key string) string {
+	return getRemoteMessage(EcsVpcUrl + "tags/instance/" + key)
 }
 
-// get response message from url
-func getRemoteMessage(url string) string {
-	transport := http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return net.DialTimeout(network, addr, 2*time.Second)
-		},
+// GetUserDataProperties parses the user-data of current ECS instance as
+// "key=value" lines. Blank lines and lines starting with '#' are ignored.
+func GetUserDataProperties() map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(getRemoteMessage(EcsUserDataUrl), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		props[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), "\"'")
 	}
-	client := http.Client{
-		Transport: &transport,
-	}
-	resp, err := client.Get(url)
-	if err != nil {
-		logger.Warnf("Failed to get metadata from VPC: %s", err.Error())
-		return ""
-	}
-	defer resp.Body.Close()
-	bytes, err := ioutil.ReadAll(resp.Body)
+	return props
+}
+
+// getRemoteMessage gets the metadata of the url, or empty string on failure.
+func getRemoteMessage(url string) string {
+	result, err := fetchMetadata(url)
 	if err != nil {
-		logger.Warnf("Failed to read response when getting VPC metadata: %s", err.Error())
-		return ""
-	}
-	result := string(bytes)
-	if resp.StatusCode != 200 {
-		logger.Warnf("VPC metadata: the response code is %d, message is: %s", resp.StatusCode, result)
+		if err != ErrMetadataDisabled {
+			logger.Warnf("Failed to get ECS metadata: %v", err)
+		}
 		return ""
 	}
 	return result
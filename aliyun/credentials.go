@@ -0,0 +1,342 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	// StaticCredentialsProviderName provides the AccessKey pair in the config or the environment variables.
+	StaticCredentialsProviderName = "static"
+	// EcsRamRoleCredentialsProviderName provides the STS credentials of the RAM role of the ECS instance.
+	EcsRamRoleCredentialsProviderName = "ecsRamRole"
+	// OidcCredentialsProviderName provides the STS credentials of the RAM role assumed by the OIDC token,
+	// e.g. of the RRSA (RAM Roles for Service Accounts) of ACK.
+	OidcCredentialsProviderName = "oidc"
+
+	EcsRamRoleUrl      = EcsVpcUrl + "ram/security-credentials/"
+	DefaultStsEndpoint = "sts.aliyuncs.com"
+
+	DefaultRoleSessionName            = "ahas-go-sdk"
+	DefaultRoleSessionDurationSeconds = 3600
+	DefaultStsTimeoutMs               = 5000
+
+	// The environment variables of the credentials, which are used if absent in the config.
+	// The OIDC ones are injected into the pods by ACK once RRSA is enabled.
+	AccessKeyIdEnvKey     = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	AccessKeySecretEnvKey = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+	EcsRamRoleEnvKey      = "ALIBABA_CLOUD_ECS_METADATA"
+	RoleArnEnvKey         = "ALIBABA_CLOUD_ROLE_ARN"
+	OidcProviderArnEnvKey = "ALIBABA_CLOUD_OIDC_PROVIDER_ARN"
+	OidcTokenFileEnvKey   = "ALIBABA_CLOUD_OIDC_TOKEN_FILE"
+
+	// credentialsRefreshAhead is how long before the expiration the temporary credentials are refreshed.
+	credentialsRefreshAhead = 5 * time.Minute
+	stsApiVersion           = "2015-04-01"
+)
+
+// CredentialsConfig is the config of the Alibaba Cloud credentials of the SDK, which authenticate
+// the ACM requests if no AccessKey pair is configured for ACM (see datasource.Credential).
+type CredentialsConfig struct {
+	// Provider is the name of the credentials provider: StaticCredentialsProviderName,
+	// EcsRamRoleCredentialsProviderName or OidcCredentialsProviderName. If absent, the provider
+	// is chosen by the environment variables in the order of oidc, static and ecsRamRole, and
+	// no credentials are provided if none of them is present.
+	Provider string `yaml:"provider"`
+	// AccessKeyId and AccessKeySecret are the AccessKey pair of the static provider.
+	// AccessKeyIdEnvKey and AccessKeySecretEnvKey will be used if absent.
+	AccessKeyId     string `yaml:"accessKeyId"`
+	AccessKeySecret string `yaml:"accessKeySecret"`
+	// RoleName is the RAM role of the ECS instance. EcsRamRoleEnvKey will be used if absent,
+	// and the role attached to the instance is discovered from the metadata otherwise.
+	RoleName string `yaml:"roleName"`
+	// RoleArn, OidcProviderArn and OidcTokenFile are of the oidc provider. RoleArnEnvKey,
+	// OidcProviderArnEnvKey and OidcTokenFileEnvKey will be used if absent.
+	RoleArn         string `yaml:"roleArn"`
+	OidcProviderArn string `yaml:"oidcProviderArn"`
+	OidcTokenFile   string `yaml:"oidcTokenFile"`
+	// RoleSessionName is the session name of the assumed role. DefaultRoleSessionName will be used if absent.
+	RoleSessionName string `yaml:"roleSessionName"`
+	// DurationSeconds is the validity of the assumed role. DefaultRoleSessionDurationSeconds will be used if absent.
+	DurationSeconds int `yaml:"durationSeconds"`
+	// StsEndpoint is the endpoint of STS, e.g. the VPC one. DefaultStsEndpoint will be used if absent.
+	StsEndpoint string `yaml:"stsEndpoint"`
+}
+
+// Credentials is the credentials of the Alibaba Cloud API calls.
+type Credentials struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	// SecurityToken is the STS token of the temporary credentials, empty for the AccessKey pair.
+	SecurityToken string
+	// Expiration is the expiration of the temporary credentials, zero for the AccessKey pair.
+	Expiration time.Time
+}
+
+// Temporary indicates whether the credentials are the STS ones.
+func (c Credentials) Temporary() bool {
+	return c.SecurityToken != ""
+}
+
+// CredentialsProvider retrieves the credentials, which are cached until shortly before the expiration.
+type CredentialsProvider interface {
+	Name() string
+	Retrieve() (Credentials, error)
+}
+
+// ErrNoCredentials is returned if no credentials provider is configured or discovered.
+var ErrNoCredentials = errors.New("no Alibaba Cloud credentials configured")
+
+var (
+	credentialsMux      = &sync.Mutex{}
+	credentialsProvider CredentialsProvider
+	cachedCredentials   *Credentials
+)
+
+// SetCredentialsConfig sets the credentials provider by the config.
+func SetCredentialsConfig(c CredentialsConfig) error {
+	p, err := newCredentialsProvider(c)
+	if err != nil {
+		return err
+	}
+	SetCredentialsProvider(p)
+	if p != nil {
+		logger.Infof("Alibaba Cloud credentials provider: %s", p.Name())
+	}
+	return nil
+}
+
+// SetCredentialsProvider replaces the credentials provider, e.g. with a custom one. Nil means no credentials.
+func SetCredentialsProvider(p CredentialsProvider) {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	credentialsProvider = p
+	cachedCredentials = nil
+}
+
+// CredentialsProviderName returns the name of the current credentials provider, empty if none.
+func CredentialsProviderName() string {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	if credentialsProvider == nil {
+		return ""
+	}
+	return credentialsProvider.Name()
+}
+
+// GetCredentials returns the current credentials. The temporary credentials are refreshed a few
+// minutes before the expiration, and the cached ones are returned (with a warning) if the refresh
+// fails while they are still valid.
+func GetCredentials() (Credentials, error) {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	if credentialsProvider == nil {
+		return Credentials{}, ErrNoCredentials
+	}
+	now := time.Now()
+	if c := cachedCredentials; c != nil && (c.Expiration.IsZero() || now.Before(c.Expiration.Add(-credentialsRefreshAhead))) {
+		return *c, nil
+	}
+	c, err := credentialsProvider.Retrieve()
+	if err != nil {
+		if old := cachedCredentials; old != nil && now.Before(old.Expiration) {
+			logger.Warnf("Failed to refresh the %s credentials, using the current ones until %s: %+v",
+				credentialsProvider.Name(), old.Expiration.Format(time.RFC3339), err)
+			return *old, nil
+		}
+		return Credentials{}, errors.Wrapf(err, "failed to retrieve the %s credentials", credentialsProvider.Name())
+	}
+	cachedCredentials = &c
+	return c, nil
+}
+
+func newCredentialsProvider(c CredentialsConfig) (CredentialsProvider, error) {
+	if c.AccessKeyId == "" {
+		c.AccessKeyId, c.AccessKeySecret = os.Getenv(AccessKeyIdEnvKey), os.Getenv(AccessKeySecretEnvKey)
+	}
+	if c.RoleName == "" {
+		c.RoleName = os.Getenv(EcsRamRoleEnvKey)
+	}
+	if c.RoleArn == "" {
+		c.RoleArn = os.Getenv(RoleArnEnvKey)
+	}
+	if c.OidcProviderArn == "" {
+		c.OidcProviderArn = os.Getenv(OidcProviderArnEnvKey)
+	}
+	if c.OidcTokenFile == "" {
+		c.OidcTokenFile = os.Getenv(OidcTokenFileEnvKey)
+	}
+	if c.RoleSessionName == "" {
+		c.RoleSessionName = DefaultRoleSessionName
+	}
+	if c.DurationSeconds <= 0 {
+		c.DurationSeconds = DefaultRoleSessionDurationSeconds
+	}
+	if c.StsEndpoint == "" {
+		c.StsEndpoint = DefaultStsEndpoint
+	}
+	provider := c.Provider
+	if provider == "" {
+		switch {
+		case c.RoleArn != "" && c.OidcProviderArn != "" && c.OidcTokenFile != "":
+			provider = OidcCredentialsProviderName
+		case c.AccessKeyId != "":
+			provider = StaticCredentialsProviderName
+		case c.RoleName != "":
+			provider = EcsRamRoleCredentialsProviderName
+		default:
+			return nil, nil
+		}
+	}
+	switch provider {
+	case StaticCredentialsProviderName:
+		if c.AccessKeyId == "" || c.AccessKeySecret == "" {
+			return nil, errors.New("AccessKey pair is required by the static credentials provider")
+		}
+		return &staticCredentialsProvider{Credentials{AccessKeyId: c.AccessKeyId, AccessKeySecret: c.AccessKeySecret}}, nil
+	case EcsRamRoleCredentialsProviderName:
+		return &ecsRamRoleCredentialsProvider{roleName: c.RoleName}, nil
+	case OidcCredentialsProviderName:
+		if c.RoleArn == "" || c.OidcProviderArn == "" || c.OidcTokenFile == "" {
+			return nil, errors.New("roleArn, oidcProviderArn and oidcTokenFile are required by the oidc credentials provider")
+		}
+		return &oidcCredentialsProvider{conf: c, client: &http.Client{Timeout: DefaultStsTimeoutMs * time.Millisecond}}, nil
+	default:
+		return nil, errors.Errorf("unknown credentials provider: %s", provider)
+	}
+}
+
+type staticCredentialsProvider struct {
+	credentials Credentials
+}
+
+func (p *staticCredentialsProvider) Name() string {
+	return StaticCredentialsProviderName
+}
+
+func (p *staticCredentialsProvider) Retrieve() (Credentials, error) {
+	return p.credentials, nil
+}
+
+// stsCredentials is the STS credentials in the responses of the metadata service and STS.
+type stsCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	AccessKeySecret string `json:"AccessKeySecret"`
+	SecurityToken   string `json:"SecurityToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+func (c *stsCredentials) toCredentials() (Credentials, error) {
+	if c.AccessKeyId == "" || c.AccessKeySecret == "" || c.SecurityToken == "" {
+		return Credentials{}, errors.New("incomplete STS credentials")
+	}
+	expiration, err := time.Parse(time.RFC3339, c.Expiration)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "bad expiration of STS credentials")
+	}
+	return Credentials{
+		AccessKeyId:     c.AccessKeyId,
+		AccessKeySecret: c.AccessKeySecret,
+		SecurityToken:   c.SecurityToken,
+		Expiration:      expiration,
+	}, nil
+}
+
+// ecsRamRoleCredentialsProvider retrieves the STS credentials of the RAM role from the ECS metadata,
+// in the hardened mode if available (see MetadataConfig).
+type ecsRamRoleCredentialsProvider struct {
+	roleName string
+}
+
+func (p *ecsRamRoleCredentialsProvider) Name() string {
+	return EcsRamRoleCredentialsProviderName
+}
+
+func (p *ecsRamRoleCredentialsProvider) Retrieve() (Credentials, error) {
+	role := p.roleName
+	if role == "" {
+		roles, err := fetchMetadata(EcsRamRoleUrl)
+		if err != nil {
+			return Credentials{}, errors.Wrap(err, "failed to get the RAM role of the ECS instance")
+		}
+		if role = strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0]); role == "" {
+			return Credentials{}, errors.New("no RAM role attached to the ECS instance")
+		}
+		p.roleName = role
+	}
+	result, err := fetchMetadata(EcsRamRoleUrl + role)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "failed to get the credentials of RAM role %s", role)
+	}
+	var resp struct {
+		stsCredentials
+		Code string `json:"Code"`
+	}
+	if err = json.Unmarshal([]byte(result), &resp); err != nil {
+		return Credentials{}, errors.Wrapf(err, "bad credentials of RAM role %s", role)
+	}
+	if resp.Code != "Success" {
+		return Credentials{}, errors.Errorf("failed to get the credentials of RAM role %s, code: %s", role, resp.Code)
+	}
+	return resp.toCredentials()
+}
+
+// oidcCredentialsProvider assumes the RAM role with the OIDC token by STS AssumeRoleWithOIDC,
+// which is an anonymous call. The token file is read on each retrieval, as it's rotated by ACK.
+type oidcCredentialsProvider struct {
+	conf   CredentialsConfig
+	client *http.Client
+}
+
+func (p *oidcCredentialsProvider) Name() string {
+	return OidcCredentialsProviderName
+}
+
+func (p *oidcCredentialsProvider) Retrieve() (Credentials, error) {
+	token, err := ioutil.ReadFile(p.conf.OidcTokenFile)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "failed to read the OIDC token")
+	}
+	form := url.Values{}
+	form.Set("Action", "AssumeRoleWithOIDC")
+	form.Set("Format", "JSON")
+	form.Set("Version", stsApiVersion)
+	form.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	form.Set("RoleArn", p.conf.RoleArn)
+	form.Set("OIDCProviderArn", p.conf.OidcProviderArn)
+	form.Set("OIDCToken", strings.TrimSpace(string(token)))
+	form.Set("RoleSessionName", p.conf.RoleSessionName)
+	form.Set("DurationSeconds", strconv.Itoa(p.conf.DurationSeconds))
+	resp, err := p.client.PostForm("https://"+p.conf.StsEndpoint+"/", form)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var result struct {
+		Credentials stsCredentials `json:"Credentials"`
+		Code        string         `json:"Code"`
+		Message     string         `json:"Message"`
+	}
+	if err = json.Unmarshal(bs, &result); err != nil {
+		return Credentials{}, errors.Wrapf(err, "bad response of AssumeRoleWithOIDC, code: %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, errors.Errorf("failed to assume role %s with OIDC, code: %s, message: %s",
+			p.conf.RoleArn, result.Code, result.Message)
+	}
+	return result.Credentials.toCredentials()
+}
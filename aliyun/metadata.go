@@ -0,0 +1,180 @@
+package aliyun
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	EcsMetadataTokenUrl = "http://100.100.100.200/latest/api/token"
+
+	metadataTokenHeader    = "X-aliyun-ecs-metadata-token"
+	metadataTokenTtlHeader = "X-aliyun-ecs-metadata-token-ttl-seconds"
+
+	DefaultMetadataTimeoutMs       = 2000
+	DefaultMetadataRetries         = 2
+	DefaultMetadataTokenTtlSeconds = 21600
+
+	minMetadataRetryBackoff = 100 * time.Millisecond
+)
+
+// MetadataConfig is the config of the ECS instance metadata access.
+type MetadataConfig struct {
+	// Disabled indicates the metadata service is not accessible, e.g. outside ECS, so that
+	// the metadata is never requested and the related features degrade immediately.
+	Disabled bool `yaml:"disabled"`
+	// TimeoutMs is the timeout of each metadata request. DefaultMetadataTimeoutMs will be used if absent.
+	TimeoutMs uint64 `yaml:"timeoutMs"`
+	// Retries is the count of retries on failure. DefaultMetadataRetries will be used if absent,
+	// and negative means no retry.
+	Retries int `yaml:"retries"`
+	// HardenedOnly indicates whether to require the hardened mode (session token, a.k.a. IMDSv2),
+	// rather than falling back to the normal mode if the token is not available.
+	HardenedOnly bool `yaml:"hardenedOnly"`
+}
+
+// ErrMetadataDisabled is returned when the metadata access is disabled in the config.
+var ErrMetadataDisabled = errors.New("ECS metadata access is disabled")
+
+var (
+	metadataMux    = &sync.Mutex{}
+	metadataConfig = MetadataConfig{TimeoutMs: DefaultMetadataTimeoutMs, Retries: DefaultMetadataRetries}
+	metadataClient = newMetadataClient(DefaultMetadataTimeoutMs)
+	// The session token of the hardened mode, which is refreshed before it expires.
+	metadataToken         string
+	metadataTokenExpireAt time.Time
+)
+
+// SetMetadataConfig sets the config of the ECS instance metadata access.
+func SetMetadataConfig(c MetadataConfig) {
+	if c.TimeoutMs == 0 {
+		c.TimeoutMs = DefaultMetadataTimeoutMs
+	}
+	if c.Retries == 0 {
+		c.Retries = DefaultMetadataRetries
+	} else if c.Retries < 0 {
+		c.Retries = 0
+	}
+	metadataMux.Lock()
+	defer metadataMux.Unlock()
+	metadataConfig = c
+	metadataClient = newMetadataClient(c.TimeoutMs)
+	metadataToken = ""
+}
+
+func newMetadataClient(timeoutMs uint64) *http.Client {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	return &http.Client{
+		// The link-local metadata service is never accessed via the proxy, so Proxy is left nil.
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.DialTimeout(network, addr, timeout)
+			},
+		},
+		Timeout: timeout,
+	}
+}
+
+// sessionToken returns the session token of the hardened mode, or empty if not available.
+func sessionToken(client *http.Client, conf MetadataConfig) (string, error) {
+	metadataMux.Lock()
+	defer metadataMux.Unlock()
+	if metadataToken != "" && time.Now().Before(metadataTokenExpireAt) {
+		return metadataToken, nil
+	}
+	req, err := http.NewRequest(http.MethodPut, EcsMetadataTokenUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(metadataTokenTtlHeader, strconv.Itoa(DefaultMetadataTokenTtlSeconds))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("bad response code of metadata token: %d", resp.StatusCode)
+	}
+	metadataToken = string(bs)
+	// Refresh the token a minute ahead of the expiration.
+	metadataTokenExpireAt = time.Now().Add(DefaultMetadataTokenTtlSeconds*time.Second - time.Minute)
+	return metadataToken, nil
+}
+
+// fetchMetadata gets the metadata of the url with retries.
+func fetchMetadata(url string) (string, error) {
+	metadataMux.Lock()
+	conf, client := metadataConfig, metadataClient
+	metadataMux.Unlock()
+	if conf.Disabled {
+		return "", ErrMetadataDisabled
+	}
+
+	var lastErr error
+	backoff := minMetadataRetryBackoff
+	for i := 0; i <= conf.Retries; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var result string
+		var retryable bool
+		result, retryable, lastErr = fetchMetadataOnce(client, conf, url)
+		if lastErr == nil || !retryable {
+			return result, lastErr
+		}
+	}
+	return "", lastErr
+}
+
+func fetchMetadataOnce(client *http.Client, conf MetadataConfig, url string) (string, bool, error) {
+	token, err := sessionToken(client, conf)
+	if err != nil {
+		if conf.HardenedOnly {
+			return "", true, errors.Wrap(err, "failed to get metadata token")
+		}
+		logger.Debugf("Metadata token not available, falling back to the normal mode: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	if token != "" {
+		req.Header.Set(metadataTokenHeader, token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", true, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return string(bs), false, nil
+	case resp.StatusCode == http.StatusNotFound:
+		// The metadata (e.g. the instance tag) is absent, which is not an error worth retrying.
+		return "", false, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		// The token might have been revoked.
+		metadataMux.Lock()
+		metadataToken = ""
+		metadataMux.Unlock()
+	}
+	return "", resp.StatusCode >= 500 || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden,
+		errors.Errorf("bad response code of metadata: %d, message: %s", resp.StatusCode, string(bs))
+}
@@ -0,0 +1,147 @@
+package ahas
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+const (
+	minAsyncStartupBackoff = time.Second
+	maxAsyncStartupBackoff = time.Minute
+)
+
+var (
+	startupMux       = &sync.RWMutex{}
+	startupRunning   bool
+	startupLastError string
+	// degraded indicates whether AHAS failed to start and the local rules only are in effect.
+	degraded          bool
+	degradedListeners = make([]func(degraded bool), 0)
+)
+
+// OnDegradedModeChange registers the listener of entering and leaving the degraded mode, where
+// AHAS failed to start and only the local rules are in effect, see Config.DegradeOnFailure.
+// The listener is called synchronously, so it should return quickly.
+func OnDegradedModeChange(listener func(degraded bool)) {
+	startupMux.Lock()
+	defer startupMux.Unlock()
+	degradedListeners = append(degradedListeners, listener)
+}
+
+func setStartupStatus(running bool, err error) {
+	startupMux.Lock()
+	defer startupMux.Unlock()
+	startupRunning = running
+	if err != nil {
+		startupLastError = err.Error()
+	} else {
+		startupLastError = ""
+	}
+}
+
+func setDegraded(d bool) {
+	startupMux.Lock()
+	if degraded == d {
+		startupMux.Unlock()
+		return
+	}
+	degraded = d
+	listeners := degradedListeners
+	startupMux.Unlock()
+	for _, l := range listeners {
+		l(d)
+	}
+}
+
+func startupStatus() (running bool, lastError string, isDegraded bool) {
+	startupMux.RLock()
+	defer startupMux.RUnlock()
+	return startupRunning, startupLastError, degraded
+}
+
+// startAhasAsync applies the local rules, and then starts AHAS in the background, which
+// retries with backoff until succeeded or Shutdown is called. The cause is the error of
+// the failed synchronous startup (if any), with which AHAS runs in the degraded mode until
+// the startup succeeds.
+func startAhasAsync(cause error) error {
+	initMux.Lock()
+	defer initMux.Unlock()
+	if initShutdown != nil {
+		return errors.New("AHAS has already been started in current process")
+	}
+	datasource.LoadLocalRules(config.DataSourceConfig())
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var (
+		mux      sync.Mutex
+		shutdown func(ctx context.Context) error
+	)
+	setStartupStatus(true, cause)
+	if cause != nil {
+		logger.Warnf("Failed to start AHAS, running in degraded mode with the local rules only: %+v", cause)
+		setDegraded(true)
+	}
+	go func() {
+		defer close(done)
+		defer tools.PrintPanicStackV2("AHAS async startup")
+		backoff := minAsyncStartupBackoff
+		err := cause
+		for {
+			if err != nil {
+				setStartupStatus(true, err)
+				logger.Warnf("Failed to start AHAS, will retry in %v: %+v", backoff, err)
+				select {
+				case <-time.After(backoff):
+				case <-runCtx.Done():
+					setStartupStatus(false, nil)
+					return
+				}
+				if backoff *= 2; backoff > maxAsyncStartupBackoff {
+					backoff = maxAsyncStartupBackoff
+				}
+			}
+			var s func(ctx context.Context) error
+			if s, err = startAhasWithContext(runCtx, runCtx); err == nil {
+				mux.Lock()
+				shutdown = s
+				mux.Unlock()
+				setStartupStatus(false, nil)
+				if cause != nil {
+					logger.Info("AHAS started after retrying, leaving degraded mode")
+					setDegraded(false)
+				} else {
+					logger.Info("AHAS started in the background")
+				}
+				return
+			}
+			if runCtx.Err() != nil {
+				setStartupStatus(false, nil)
+				return
+			}
+		}
+	}()
+
+	initShutdown = func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		mux.Lock()
+		s := shutdown
+		mux.Unlock()
+		if s == nil {
+			return nil
+		}
+		return s(ctx)
+	}
+	return nil
+}
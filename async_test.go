@@ -0,0 +1,232 @@
+package ahas
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/gateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	connectAccepted = `{"code":200,"success":true,"result":{"tid":"tid","uid":"uid","aid":"aid","ak":"ak","sk":"sk"}}`
+	connectRejected = `{"code":500,"success":false,"error":"registration rejected"}`
+	requestAccepted = `{"code":200,"success":true,"result":"OK"}`
+)
+
+// fakeGateway is an AHAS gateway over TLS, which rejects the first registrations.
+type fakeGateway struct {
+	ln net.Listener
+
+	mux     sync.Mutex
+	rejects int
+	// rejectedConns are closed once the connections carrying the rejected registrations are closed.
+	rejectedConns []chan struct{}
+}
+
+func startFakeGateway(t *testing.T, rejects int) *fakeGateway {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &fakeGateway{ln: ln, rejects: rejects}
+	go g.serve()
+	return g
+}
+
+func (g *fakeGateway) Close() {
+	_ = g.ln.Close()
+}
+
+func (g *fakeGateway) serve() {
+	for {
+		c, err := g.ln.Accept()
+		if err != nil {
+			return
+		}
+		go g.handle(c)
+	}
+}
+
+func (g *fakeGateway) handle(c net.Conn) {
+	closed := make(chan struct{})
+	defer close(closed)
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for {
+		msg := gateway.NewAgwMessage()
+		if err := msg.Decode(br); err != nil {
+			return
+		}
+		if msg.MessageDirection() != gateway.MessageDirectionRequest {
+			continue
+		}
+		if msg.MessageType() == gateway.MessageTypeBiz {
+			msg.SetBody(g.respond(msg.HandlerName(), closed))
+		}
+		msg.SetMessageDirection(gateway.MessageDirectionResponse)
+		bs, _ := msg.Encode()
+		if _, err := c.Write(bs); err != nil {
+			return
+		}
+	}
+}
+
+func (g *fakeGateway) respond(handlerName string, closed chan struct{}) string {
+	if handlerName != transport.Connect {
+		return requestAccepted
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.rejects == 0 {
+		return connectAccepted
+	}
+	g.rejects--
+	g.rejectedConns = append(g.rejectedConns, closed)
+	return connectRejected
+}
+
+// awaitRejectedConnsClosed fails the test unless the connections carrying the rejected
+// registrations are closed in time.
+func (g *fakeGateway) awaitRejectedConnsClosed(t *testing.T) {
+	g.mux.Lock()
+	conns := g.rejectedConns
+	g.mux.Unlock()
+	if len(conns) == 0 {
+		t.Fatal("no registration rejected")
+	}
+	for _, closed := range conns {
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the connection of the failed startup is still open")
+		}
+	}
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake-gateway"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// localCloud is the environment of the test process.
+type localCloud struct{}
+
+func (localCloud) Name() string {
+	return "local"
+}
+
+func (localCloud) Resolve(_ string) (*meta.CloudEnv, error) {
+	return &meta.CloudEnv{RegionId: "cn-test", VpcId: "vpc-test", Ip: "127.0.0.1", HostName: "test", InstanceId: "test"}, nil
+}
+
+// setUpStartup configures AHAS to start against the gateway, in push mode to leave ACM out.
+// The returned function restores the transport options.
+func setUpStartup(t *testing.T, g *fakeGateway, f func(c *Config)) func() {
+	meta.SetCloudProvider(localCloud{})
+	conf := config.NewDefaultConfig()
+	conf.License = "test"
+	conf.Endpoints = []string{g.ln.Addr().String()}
+	conf.Transport.Tls.InsecureSkipVerify = true
+	conf.DataSource.Mode = datasource.PushDeliveryMode
+	f(conf)
+	if err := config.SetConfig(conf); err != nil {
+		t.Fatal(err)
+	}
+	// Keep the issued keys off the key file.
+	prev := defaultTransportOptions
+	defaultTransportOptions = []transport.Option{transport.WithIsolatedState()}
+	return func() {
+		defaultTransportOptions = prev
+	}
+}
+
+func awaitHealth(t *testing.T, desc string, cond func(h SdkHealth) bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond(Health()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", desc, Health())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestAsyncStartupRetriesAfterRegistrationFailure(t *testing.T) {
+	g := startFakeGateway(t, 1)
+	defer g.Close()
+	defer setUpStartup(t, g, func(c *Config) {
+		c.AsyncStartup = true
+	})()
+
+	if err := startInitAhas(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+	awaitHealth(t, "the connection", func(h SdkHealth) bool {
+		return h.connected && !h.Starting
+	})
+	g.awaitRejectedConnsClosed(t)
+}
+
+func TestDegradedModeRecovers(t *testing.T) {
+	g := startFakeGateway(t, 1)
+	defer g.Close()
+	defer setUpStartup(t, g, func(c *Config) {
+		c.DegradeOnFailure = true
+	})()
+	changes := make(chan bool, 4)
+	OnDegradedModeChange(func(degraded bool) {
+		select {
+		case changes <- degraded:
+		default:
+		}
+	})
+
+	if err := startInitAhas(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+	for _, want := range []bool{true, false} {
+		select {
+		case degraded := <-changes:
+			if degraded != want {
+				t.Fatalf("degraded: got %v, want %v", degraded, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal(errors.New("timed out waiting for the degraded mode change"))
+		}
+	}
+	awaitHealth(t, "the connection", func(h SdkHealth) bool {
+		return h.connected && !h.Degraded && h.StartupError == ""
+	})
+	g.awaitRejectedConnsClosed(t)
+}
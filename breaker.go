@@ -0,0 +1,13 @@
+package ahas
+
+import "github.com/aliyun/aliyun-ahas-go-sdk/sentinel/breaker"
+
+// CircuitBreakerState is the state transition of a circuit breaker.
+type CircuitBreakerState = breaker.StateRecord
+
+// OnCircuitBreakerStateChange registers the listener of the state transitions (Open, HalfOpen
+// and Closed) of the circuit breakers. The listener is called synchronously in the business
+// goroutines, so it must not block.
+func OnCircuitBreakerStateChange(listener func(s CircuitBreakerState)) {
+	breaker.AddListener(listener)
+}
@@ -0,0 +1,416 @@
+// Package chaos runs the fault injection experiments of AHAS Chaos initiated from the AHAS
+// console, e.g. the latency and errors injected to the Sentinel resources and the injection
+// points (see Inject), the CPU burn and the custom faults registered by the application (see
+// RegisterFault).
+//
+// The experiments could be started by the application as well (see StartLocal), e.g. in the
+// tests, which are not limited by the config and the toggle.
+//
+// The experiments are refused unless enabled in the local config (see Config.Enabled), and
+// the console could switch them off at any time by the feature.Chaos toggle, which stops the
+// running ones (including the CPU burn and the custom faults) as well. Each experiment
+// is limited in its duration, and all of them are stopped once the application is unhealthy.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/aliyun/aliyun-ahas-go-sdk/health"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxDurationMs is the default limit of the durations of the experiments.
+const DefaultMaxDurationMs = 30 * 60 * 1000
+
+type FaultKind string
+
+const (
+	// Latency delays the invocations of the targets by LatencyMs.
+	Latency FaultKind = "latency"
+	// Error fails the invocations of the targets with ErrorMessage.
+	Error FaultKind = "error"
+	// CPUBurn keeps CPUPercent of all the CPUs busy.
+	CPUBurn FaultKind = "cpuBurn"
+	// Custom runs the fault registered by the application as Fault.
+	Custom FaultKind = "custom"
+)
+
+type Config struct {
+	// Enabled indicates whether to accept the experiments, which is off by default.
+	Enabled bool `yaml:"enabled"`
+	// MaxDurationMs limits the durations of the experiments, DefaultMaxDurationMs if not positive.
+	MaxDurationMs int64 `yaml:"maxDurationMs"`
+	// AllowedKinds limits the kinds of the faults, which allows all if empty.
+	AllowedKinds []FaultKind `yaml:"allowedKinds"`
+	// KeepOnUnhealthy indicates whether to keep the experiments running while the application
+	// is unhealthy (see package health), rather than stopping all of them.
+	KeepOnUnhealthy bool `yaml:"keepOnUnhealthy"`
+}
+
+// Experiment is a fault injection experiment.
+type Experiment struct {
+	ID   string    `json:"id"`
+	Kind FaultKind `json:"kind"`
+	// Targets are the Sentinel resources (or the injection points, see Inject) of the latency
+	// and the errors, which targets all if empty.
+	Targets []string `json:"targets,omitempty"`
+	// Percent is the percentage of the invocations of the targets to inject, 100 if not positive.
+	Percent      int    `json:"percent,omitempty"`
+	LatencyMs    int64  `json:"latencyMs,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	CPUPercent   int    `json:"cpuPercent,omitempty"`
+	// Fault is the name of the custom fault, with the parameters of Params.
+	Fault  string            `json:"fault,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	// DurationMs is the duration of the experiment, after which it's stopped automatically.
+	DurationMs int64 `json:"durationMs"`
+}
+
+// ExperimentStatus is a running experiment.
+type ExperimentStatus struct {
+	Experiment
+	StartTimeMs uint64 `json:"startTimeMs"`
+	// EndTimeMs is zero for the local experiments without the duration.
+	EndTimeMs uint64 `json:"endTimeMs,omitempty"`
+	// Local indicates whether the experiment is started by the application, see StartLocal.
+	Local bool `json:"local,omitempty"`
+}
+
+// Status is the chaos status of the process reported to the AHAS console.
+type Status struct {
+	Enabled      bool               `json:"enabled"`
+	AllowedKinds []FaultKind        `json:"allowedKinds,omitempty"`
+	Faults       []string           `json:"faults,omitempty"`
+	Experiments  []ExperimentStatus `json:"experiments"`
+}
+
+// FaultFunc runs the custom fault with the parameters of the experiment until the context is
+// done, i.e. the experiment is stopped.
+type FaultFunc func(ctx context.Context, params map[string]string) error
+
+type experiment struct {
+	status ExperimentStatus
+	cancel context.CancelFunc
+	// timer stops the experiment after its duration, which is nil for the local ones without duration.
+	timer *time.Timer
+	// local indicates whether the experiment is started by the application, see StartLocal.
+	local bool
+}
+
+var (
+	mux         = &sync.RWMutex{}
+	conf        Config
+	experiments = make(map[string]*experiment)
+	faults      = make(map[string]FaultFunc)
+	// runningCount is the count of the experiments, which saves the injections from locking.
+	runningCount int32
+
+	healthOnce sync.Once
+)
+
+func init() {
+	feature.AddListener(func(name string, enabled bool) {
+		if name == feature.Chaos && !enabled {
+			if n := stopRemote(); n > 0 {
+				logger.Warnf("Stopped %d chaos experiments as the chaos feature is switched off", n)
+			}
+		}
+	})
+}
+
+// Configure sets the config of the experiments. The running experiments from the console which
+// are no longer allowed are stopped.
+func Configure(c Config) {
+	mux.Lock()
+	conf = c
+	ids := make([]string, 0)
+	for id, e := range experiments {
+		if !e.local && !allowedLocked(e.status.Kind) {
+			ids = append(ids, id)
+		}
+	}
+	mux.Unlock()
+	for _, id := range ids {
+		Stop(id)
+	}
+	if c.Enabled {
+		initSlot()
+		healthOnce.Do(func() {
+			health.AddListener(func(_, cur health.Snapshot) {
+				if cur.Status == health.Unhealthy && !currentConfig().KeepOnUnhealthy {
+					if n := StopAll(); n > 0 {
+						logger.Warnf("Stopped %d chaos experiments as the application is unhealthy: %s", n, cur.Reason)
+					}
+				}
+			})
+		})
+	}
+}
+
+func currentConfig() Config {
+	mux.RLock()
+	defer mux.RUnlock()
+	return conf
+}
+
+// Enabled returns whether the experiments are accepted, by both the local config and the
+// feature.Chaos toggle.
+func Enabled() bool {
+	return currentConfig().Enabled && feature.Enabled(feature.Chaos)
+}
+
+func allowedLocked(kind FaultKind) bool {
+	if !conf.Enabled {
+		return false
+	}
+	if len(conf.AllowedKinds) == 0 {
+		return true
+	}
+	for _, k := range conf.AllowedKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterFault registers the custom fault, which could be run by the experiments of the Custom
+// kind. The registered faults can't be overridden.
+func RegisterFault(name string, f FaultFunc) error {
+	if name == "" || f == nil {
+		return errors.New("empty fault name or function")
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if _, ok := faults[name]; ok {
+		return errors.Errorf("fault %s has already been registered", name)
+	}
+	faults[name] = f
+	return nil
+}
+
+func (e *Experiment) validate(local bool) error {
+	if e.ID == "" {
+		return errors.New("empty experiment id")
+	}
+	if e.DurationMs <= 0 && !local {
+		return errors.New("non-positive experiment duration")
+	}
+	if e.Percent > 100 {
+		return errors.Errorf("invalid percent: %d", e.Percent)
+	}
+	switch e.Kind {
+	case Latency:
+		if e.LatencyMs <= 0 {
+			return errors.New("non-positive latency")
+		}
+	case Error:
+	case CPUBurn:
+		if e.CPUPercent <= 0 || e.CPUPercent > 100 {
+			return errors.Errorf("invalid CPU percent: %d", e.CPUPercent)
+		}
+	case Custom:
+		if e.Fault == "" {
+			return errors.New("empty custom fault")
+		}
+	default:
+		return errors.Errorf("unknown fault kind: %s", e.Kind)
+	}
+	return nil
+}
+
+// Start starts the experiment, which is stopped automatically after its duration (limited by
+// Config.MaxDurationMs), or by Stop.
+func Start(exp Experiment) error {
+	return start(exp, false)
+}
+
+// StartLocal starts the experiment initiated by the application, e.g. in the tests, regardless
+// of the config and the feature.Chaos toggle. The experiment without the duration runs until
+// the returned stop (or Stop) is called.
+func StartLocal(exp Experiment) (stop func(), err error) {
+	if err = start(exp, true); err != nil {
+		return nil, err
+	}
+	return func() {
+		Stop(exp.ID)
+	}, nil
+}
+
+func start(exp Experiment, local bool) error {
+	if err := exp.validate(local); err != nil {
+		return err
+	}
+	if !local && !feature.Enabled(feature.Chaos) {
+		return errors.New("chaos experiments are switched off")
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if !local && !allowedLocked(exp.Kind) {
+		return errors.Errorf("chaos experiments of %s are not allowed", exp.Kind)
+	}
+	if _, ok := experiments[exp.ID]; ok {
+		return errors.Errorf("experiment %s is already running", exp.ID)
+	}
+	var fault FaultFunc
+	if exp.Kind == Custom {
+		if fault = faults[exp.Fault]; fault == nil {
+			return errors.Errorf("unknown custom fault: %s", exp.Fault)
+		}
+	}
+	maxDurationMs := conf.MaxDurationMs
+	if maxDurationMs <= 0 {
+		maxDurationMs = DefaultMaxDurationMs
+	}
+	if exp.DurationMs > maxDurationMs && !local {
+		exp.DurationMs = maxDurationMs
+	}
+	exp.Targets = append([]string(nil), exp.Targets...)
+
+	now := util.CurrentTimeMillis()
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &experiment{
+		status: ExperimentStatus{
+			Experiment:  exp,
+			StartTimeMs: now,
+			Local:       local,
+		},
+		cancel: cancel,
+		local:  local,
+	}
+	if exp.DurationMs > 0 {
+		e.status.EndTimeMs = now + uint64(exp.DurationMs)
+		e.timer = time.AfterFunc(time.Duration(exp.DurationMs)*time.Millisecond, func() {
+			Stop(exp.ID)
+		})
+	}
+	if local {
+		// The local experiments could target the Sentinel resources as well.
+		initSlot()
+	}
+	experiments[exp.ID] = e
+	atomic.AddInt32(&runningCount, 1)
+	switch exp.Kind {
+	case CPUBurn:
+		go burnCPU(ctx, exp.CPUPercent)
+	case Custom:
+		go runFault(ctx, exp.ID, fault, exp.Params)
+	}
+	logger.Infof("Chaos experiment started: %s", describe(&exp))
+	return nil
+}
+
+func runFault(ctx context.Context, id string, f FaultFunc, params map[string]string) {
+	defer tools.PrintPanicStackV2("chaos custom fault")
+	if err := f(ctx, params); err != nil && ctx.Err() == nil {
+		logger.Warnf("Chaos experiment %s failed: %+v", id, err)
+		Stop(id)
+	}
+}
+
+// Stop stops the experiment, and returns whether it's running.
+func Stop(id string) bool {
+	mux.Lock()
+	e, ok := experiments[id]
+	if ok {
+		delete(experiments, id)
+		atomic.AddInt32(&runningCount, -1)
+	}
+	mux.Unlock()
+	if !ok {
+		return false
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.cancel()
+	logger.Infof("Chaos experiment stopped: %s", id)
+	return true
+}
+
+// StopAll stops all the experiments, and returns the count of the stopped ones.
+func StopAll() int {
+	mux.RLock()
+	ids := make([]string, 0, len(experiments))
+	for id := range experiments {
+		ids = append(ids, id)
+	}
+	mux.RUnlock()
+	n := 0
+	for _, id := range ids {
+		if Stop(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// stopRemote stops the experiments from the console, and returns the count of the stopped ones.
+// The local experiments are not limited by the feature.Chaos toggle.
+func stopRemote() int {
+	mux.RLock()
+	ids := make([]string, 0, len(experiments))
+	for id, e := range experiments {
+		if !e.local {
+			ids = append(ids, id)
+		}
+	}
+	mux.RUnlock()
+	n := 0
+	for _, id := range ids {
+		if Stop(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// Experiments returns the running experiments in the order of the start time.
+func Experiments() []ExperimentStatus {
+	mux.RLock()
+	result := make([]ExperimentStatus, 0, len(experiments))
+	for _, e := range experiments {
+		result = append(result, e.status)
+	}
+	mux.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StartTimeMs != result[j].StartTimeMs {
+			return result[i].StartTimeMs < result[j].StartTimeMs
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// CurrentStatus returns the chaos status of the process.
+func CurrentStatus() Status {
+	mux.RLock()
+	names := make([]string, 0, len(faults))
+	for name := range faults {
+		names = append(names, name)
+	}
+	kinds := append([]FaultKind(nil), conf.AllowedKinds...)
+	mux.RUnlock()
+	sort.Strings(names)
+	return Status{
+		Enabled:      Enabled(),
+		AllowedKinds: kinds,
+		Faults:       names,
+		Experiments:  Experiments(),
+	}
+}
+
+func describe(e *Experiment) string {
+	return fmt.Sprintf("{id=%s, kind=%s, targets=[%s], percent=%d, durationMs=%d}",
+		e.ID, e.Kind, strings.Join(e.Targets, ","), e.Percent, e.DurationMs)
+}
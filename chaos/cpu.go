@@ -0,0 +1,38 @@
+package chaos
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+)
+
+// burnSliceMs is the time slice of the CPU burn, in which a CPU is busy for the percentage.
+const burnSliceMs = 100
+
+// burnCPU keeps the percentage of all the CPUs busy until the context is done.
+func burnCPU(ctx context.Context, percent int) {
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go burnOneCPU(ctx, percent)
+	}
+}
+
+func burnOneCPU(ctx context.Context, percent int) {
+	defer tools.PrintPanicStackV2("chaos CPU burn")
+
+	busy := time.Duration(burnSliceMs*percent/100) * time.Millisecond
+	idle := burnSliceMs*time.Millisecond - busy
+	for ctx.Err() == nil {
+		deadline := time.Now().Add(busy)
+		for time.Now().Before(deadline) {
+		}
+		if idle > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(idle):
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package chaos
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+)
+
+// BlockTypeChaos is the block type of the errors injected to the Sentinel resources,
+// which is beyond the built-in ones.
+const BlockTypeChaos = base.BlockType(65)
+
+var slotOnce sync.Once
+
+// initSlot registers the injector of the latency and the errors to the global Sentinel slot chain.
+func initSlot() {
+	slotOnce.Do(func() {
+		sentinel.GlobalSlotChain().AddRuleCheckSlotFirst(&injectSlot{})
+	})
+}
+
+// fault is the latency and the error injected to an invocation.
+type fault struct {
+	latency time.Duration
+	// err is the message of the injected error, if any.
+	err      string
+	hasError bool
+	// exp is the experiment injecting the error.
+	exp *Experiment
+}
+
+// FaultError is the error injected by the experiment.
+type FaultError struct {
+	ExperimentID string
+	Message      string
+}
+
+func (e *FaultError) Error() string {
+	return e.Message
+}
+
+// Inject injects the faults of the running experiments targeting the point, which should be
+// placed in the code paths to be experimented on, e.g.
+//
+//	if err := chaos.Inject("payment.charge"); err != nil {
+//		return err
+//	}
+//
+// The latency is slept, and the error (if any) is returned as the *FaultError. It's cheap
+// when no experiment is running.
+func Inject(point string) error {
+	return InjectContext(context.Background(), point)
+}
+
+// InjectContext is Inject whose latency is interrupted once the context is done, in which case
+// the error of the context is returned.
+func InjectContext(ctx context.Context, point string) error {
+	f := faultOf(point)
+	if f.latency > 0 {
+		timer := time.NewTimer(f.latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if f.hasError {
+		return &FaultError{ExperimentID: f.exp.ID, Message: f.message()}
+	}
+	return nil
+}
+
+func (f *fault) message() string {
+	if f.err == "" {
+		return "chaos error injected"
+	}
+	return f.err
+}
+
+// faultOf returns the faults of the running experiments injected to an invocation of the target.
+func faultOf(target string) (f fault) {
+	if atomic.LoadInt32(&runningCount) == 0 {
+		return
+	}
+	enabled := Enabled()
+	mux.RLock()
+	defer mux.RUnlock()
+	for _, e := range experiments {
+		exp := &e.status.Experiment
+		if exp.Kind != Latency && exp.Kind != Error || !matches(exp, target) {
+			continue
+		}
+		// The experiments from the console are paused once switched off.
+		if !e.local && !enabled {
+			continue
+		}
+		if exp.Percent > 0 && exp.Percent < 100 && rand.Intn(100) >= exp.Percent {
+			continue
+		}
+		if exp.Kind == Latency {
+			f.latency += time.Duration(exp.LatencyMs) * time.Millisecond
+		} else if !f.hasError {
+			f.hasError, f.err, f.exp = true, exp.ErrorMessage, exp
+		}
+	}
+	return
+}
+
+func matches(exp *Experiment, target string) bool {
+	if len(exp.Targets) == 0 {
+		return true
+	}
+	for _, t := range exp.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+type injectSlot struct {
+}
+
+func (s *injectSlot) Check(ctx *base.EntryContext) *base.TokenResult {
+	if ctx == nil || ctx.Resource == nil {
+		return base.NewTokenResultPass()
+	}
+	f := faultOf(ctx.Resource.Name())
+	if f.latency > 0 {
+		time.Sleep(f.latency)
+	}
+	if f.hasError {
+		return base.NewTokenResultBlockedWithCause(BlockTypeChaos, f.message(), nil, f.exp.ID)
+	}
+	return base.NewTokenResultPass()
+}
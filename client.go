@@ -0,0 +1,206 @@
+package ahas
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/heartbeat"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/handler"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
+)
+
+// Client is a connection to AHAS with a license of its own, e.g. for a sidecar or a gateway
+// protecting the apps of several tenants in one process. Each client keeps its own metadata,
+// keys, connection, heartbeat and rule subscription, so several clients could run side by side,
+// and along with InitAhas*.
+//
+// Sentinel is still process-global: the rules of all clients are loaded into the same Sentinel
+// keyed by the app, so the clients should host disjoint apps. If the process hosts the apps of
+// several clients, each client should list its apps in DataSource.Apps, so that the resources are
+// namespaced by the app (see datasource.AppResource) and the metrics of the other apps are not
+// reported with the license. The process-wide subsystems (the debug console, the metric exporter,
+// the block log shipping, the metric uploading, the notifier, chaos experiments and the feature
+// toggles) are only started by InitAhas*.
+type Client struct {
+	conf *config.Config
+
+	mux          sync.Mutex
+	cancel       context.CancelFunc
+	meta         *meta.Meta
+	tsp          *transport.Transport
+	stopBeat     func()
+	subscription *datasource.Subscription
+}
+
+// NewClient creates a client with the config, whose absent values are filled with the defaults.
+// Nothing is started until Start is called. The config is only kept by the client, and the
+// AsyncStartup, DegradeOnFailure, FailFast and Offline of it don't apply.
+func NewClient(c *config.Config) (*Client, error) {
+	conf, err := config.Normalize(c)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Offline {
+		return nil, errors.New("AHAS client can't run offline")
+	}
+	return &Client{conf: conf}, nil
+}
+
+// Start registers to AHAS and subscribes to the rules of the apps. It waits for the rule
+// subscription unless DataSource.NonBlockingStartup is set. Cancelling the context stops the
+// rule subscription, so it should live as long as the client; Close should still be called to
+// release the other resources. A closed client could be started again.
+func (c *Client) Start(ctx context.Context) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.tsp != nil {
+		return errors.New("AHAS client already started")
+	}
+	conf := c.conf
+	m, err := meta.NewMeta(conf.License, conf.Namespace, conf.Env, conf.Transport.Secure, metaOptions(conf)...)
+	if err != nil {
+		return err
+	}
+	pushMode := conf.DataSource.Mode == datasource.PushDeliveryMode
+	acmHost, ok := m.AcmHost()
+	if !ok && !pushMode {
+		return errors.New("no available ACM endpoint for region: " + m.RegionId())
+	}
+	tsp, err := startTransport(conf, m, transport.WithIsolatedState())
+	if err != nil {
+		return err
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	var subscription *datasource.Subscription
+	if pushMode {
+		subscription, err = datasource.SubscribePush(runCtx, conf.DataSource)
+	} else {
+		subscription, err = datasource.SubscribeAcm(runCtx, acmHost, conf.DataSource, m)
+	}
+	if err != nil {
+		cancel()
+		_ = tsp.Shutdown()
+		return errors.Wrap(err, "failed to subscribe to the rules")
+	}
+	c.registerHandlers(tsp, subscription)
+
+	beat := heartbeat.New(conf.Heartbeat, tsp)
+	for key, provider := range instanceParams(conf, tsp) {
+		beat.RegisterParamProvider(key, provider)
+	}
+	beat.RegisterParamProvider(tagsParam, jsonParam(conf.Tags))
+	beat.Start()
+	startIpRefresher(m, tsp)
+
+	c.cancel, c.meta, c.tsp, c.stopBeat, c.subscription = cancel, m, tsp, beat.Stop, subscription
+	logger.Infof("AHAS client of app %s started", m.AppName())
+	return nil
+}
+
+// registerHandlers registers the commands reporting the resources and the rules of the apps.
+func (c *Client) registerHandlers(tsp *transport.Transport, subscription *datasource.Subscription) {
+	filter := c.resourceFilter()
+	nodeHandler := transport.NewCommonHandler(&handler.ResourceNodeHandler{Filter: filter})
+	tsp.RegisterHandler(handler.GetResourceNodeCommandName, &nodeHandler)
+	metricHandler := transport.NewCommonHandler(
+		handler.NewFetchMetricHandlerWithEncoding(c.conf.Transport.Encoding).WithResourceFilter(filter))
+	tsp.RegisterHandler(handler.FetchMetricCommandName, &metricHandler)
+	rulesHandler := transport.NewCommonHandler(&handler.FetchRulesHandler{Apps: c.apps()})
+	tsp.RegisterHandler(handler.FetchRulesCommandName, &rulesHandler)
+	if c.conf.DataSource.Mode == datasource.PushDeliveryMode {
+		pushHandler := transport.NewCommonHandler(&handler.PushRulesHandler{Subscription: subscription})
+		tsp.RegisterHandler(handler.PushRulesCommandName, &pushHandler)
+	}
+}
+
+// apps returns the apps whose rules are subscribed, see datasource.Config.
+func (c *Client) apps() []string {
+	if apps := c.conf.DataSource.Apps; len(apps) > 0 {
+		return apps
+	}
+	if app := c.conf.DataSource.App; app != "" {
+		return []string{app}
+	}
+	return []string{sentinelConf.AppName()}
+}
+
+// resourceFilter selects the resources namespaced by the Apps, or nil (i.e. all resources)
+// if the resources are not namespaced.
+func (c *Client) resourceFilter() func(resource string) bool {
+	apps := c.conf.DataSource.Apps
+	if len(apps) == 0 {
+		return nil
+	}
+	return func(resource string) bool {
+		for _, app := range apps {
+			if strings.HasPrefix(resource, datasource.AppResource(app, "")) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Close unsubscribes the rules, stops the heartbeat and closes the connection to AHAS.
+// The rules already loaded into Sentinel are kept. It's safe to call Close multiple times.
+func (c *Client) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.tsp == nil {
+		return nil
+	}
+	c.cancel()
+	err := c.subscription.Close()
+	if err != nil {
+		err = errors.Wrap(err, "failed to close the rule subscription")
+	}
+	c.meta.StopIpRefresher()
+	c.stopBeat()
+	if tspErr := c.tsp.Shutdown(); tspErr != nil && err == nil {
+		err = tspErr
+	}
+	c.cancel, c.meta, c.tsp, c.stopBeat, c.subscription = nil, nil, nil, nil, nil
+	return err
+}
+
+// State returns the state of the connection to AHAS, which is StateDisconnected if the client
+// is not started.
+func (c *Client) State() transport.ConnState {
+	c.mux.Lock()
+	tsp := c.tsp
+	c.mux.Unlock()
+	if tsp == nil {
+		return transport.StateDisconnected
+	}
+	return tsp.State()
+}
+
+// LicenseStatus returns the status of the license of the client observed from the responses
+// of AHAS, and the message of the server if any.
+func (c *Client) LicenseStatus() (transport.LicenseStatus, string) {
+	c.mux.Lock()
+	tsp := c.tsp
+	c.mux.Unlock()
+	if tsp == nil {
+		return transport.LicenseUnknown, ""
+	}
+	return tsp.LicenseStatus()
+}
+
+// Subscriptions returns the status of the ACM subscriptions of the client, see Health.
+func (c *Client) Subscriptions() []datasource.SubscriptionStatus {
+	c.mux.Lock()
+	subscription := c.subscription
+	c.mux.Unlock()
+	if subscription == nil {
+		return nil
+	}
+	return subscription.Status()
+}
@@ -0,0 +1,60 @@
+package ahas
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+func newTestClient(t *testing.T, g *fakeGateway, license, app string) *Client {
+	conf := config.NewDefaultConfig()
+	conf.License = license
+	conf.Endpoints = []string{g.ln.Addr().String()}
+	conf.Transport.Tls.InsecureSkipVerify = true
+	conf.DataSource.Mode = datasource.PushDeliveryMode
+	conf.DataSource.Apps = []string{app}
+	c, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientsOfSeveralLicenses(t *testing.T) {
+	meta.SetCloudProvider(localCloud{})
+	g := startFakeGateway(t, 0)
+	defer g.Close()
+	a := newTestClient(t, g, "license-a", "app-a")
+	b := newTestClient(t, g, "license-b", "app-b")
+	for _, c := range []*Client{a, b} {
+		if err := c.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	for _, c := range []*Client{a, b} {
+		if s := c.State(); s != transport.StateConnected {
+			t.Fatalf("state: got %v, want %v", s, transport.StateConnected)
+		}
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := a.State(); s != transport.StateDisconnected {
+		t.Fatalf("state of the closed client: got %v, want %v", s, transport.StateDisconnected)
+	}
+	if s := b.State(); s != transport.StateConnected {
+		t.Fatalf("state of the other client: got %v, want %v", s, transport.StateConnected)
+	}
+	if err := a.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start the closed client again: %+v", err)
+	}
+	if s := a.State(); s != transport.StateConnected {
+		t.Fatalf("state of the restarted client: got %v, want %v", s, transport.StateConnected)
+	}
+}
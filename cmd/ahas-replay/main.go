@@ -0,0 +1,72 @@
+// Command ahas-replay replays an access log against the flow control and circuit breaking
+// rules (in the AHAS console format), and reports how many requests would be blocked:
+//
+//	ahas-replay -log access.csv -flow flow-rules.json -degrade degrade-rules.json
+//
+// See the replay package for the format of the access log.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/replay"
+)
+
+func main() {
+	logFile := flag.String("log", "", "the access log file")
+	flowFile := flag.String("flow", "", "the flow rule file")
+	degradeFile := flag.String("degrade", "", "the degrade (circuit breaking) rule file")
+	asJson := flag.Bool("json", false, "print the report in JSON")
+	flag.Parse()
+	if *logFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rules := replay.Rules{}
+	if *flowFile != "" {
+		data, err := ioutil.ReadFile(*flowFile)
+		if err != nil {
+			log.Fatalf("Failed to read flow rules: %+v", err)
+		}
+		if rules.Flow, err = datasource.ConvertFlowRules(string(data)); err != nil {
+			log.Fatalf("Failed to parse flow rules: %+v", err)
+		}
+	}
+	if *degradeFile != "" {
+		data, err := ioutil.ReadFile(*degradeFile)
+		if err != nil {
+			log.Fatalf("Failed to read degrade rules: %+v", err)
+		}
+		if rules.CircuitBreaking, err = datasource.ConvertCircuitBreakingRules(string(data)); err != nil {
+			log.Fatalf("Failed to parse degrade rules: %+v", err)
+		}
+	}
+
+	f, err := os.Open(*logFile)
+	if err != nil {
+		log.Fatalf("Failed to open access log: %+v", err)
+	}
+	defer f.Close()
+	entries, err := replay.ParseEntries(f)
+	if err != nil {
+		log.Fatalf("Failed to parse access log: %+v", err)
+	}
+
+	report := replay.Simulate(entries, rules)
+	if !*asJson {
+		fmt.Print(report.String())
+		return
+	}
+	bs, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal report: %+v", err)
+	}
+	fmt.Println(string(bs))
+}
@@ -0,0 +1,61 @@
+package ahas
+
+import (
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/handler"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
+)
+
+var (
+	commandMux     = &sync.Mutex{}
+	customCommands = make(map[string]handler.CommandFunc)
+)
+
+// RegisterCommandHandler registers the handler of a custom command initiated by the AHAS console,
+// which receives the parameters of the command and returns a JSON-serializable result. It could be
+// called before or after AHAS is started. The built-in commands and the registered ones can't be overridden.
+func RegisterCommandHandler(name string, h func(params map[string]string) (interface{}, error)) error {
+	if name == "" || h == nil {
+		return errors.New("empty command name or handler")
+	}
+	commandMux.Lock()
+	defer commandMux.Unlock()
+	if _, ok := customCommands[name]; ok || isBuiltinCommand(name) {
+		return errors.Errorf("command %s has already been registered", name)
+	}
+	customCommands[name] = h
+
+	transportMux.RLock()
+	t := activeTransport
+	transportMux.RUnlock()
+	if t != nil {
+		registerCustomCommand(t, name, h)
+	}
+	return nil
+}
+
+func isBuiltinCommand(name string) bool {
+	switch name {
+	case handler.GetResourceNodeCommandName, handler.FetchMetricCommandName, handler.SetFeatureCommandName,
+		handler.SetTrustedOriginsCommandName, handler.PushRulesCommandName, handler.FetchRulesCommandName,
+		handler.SetLogLevelCommandName, handler.StartChaosExperimentCommandName, handler.StopChaosExperimentCommandName,
+		handler.GetChaosStatusCommandName, handler.SetProtectionCommandName, transport.Ping:
+		return true
+	}
+	return false
+}
+
+func registerCustomCommands(tsp *transport.Transport) {
+	commandMux.Lock()
+	defer commandMux.Unlock()
+	for name, h := range customCommands {
+		registerCustomCommand(tsp, name, h)
+	}
+}
+
+func registerCustomCommand(tsp *transport.Transport, name string, h handler.CommandFunc) {
+	commandHandler := transport.NewCommonHandler(&handler.CustomCommandHandler{Func: h})
+	tsp.RegisterHandler(name, &commandHandler)
+}
@@ -1,16 +1,33 @@
 package config
 
 import (
-	"errors"
+	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/alibaba/sentinel-golang/core/config"
 	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+	"github.com/aliyun/aliyun-ahas-go-sdk/chaos"
+	"github.com/aliyun/aliyun-ahas-go-sdk/console"
+	"github.com/aliyun/aliyun-ahas-go-sdk/exporter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/health"
 	"github.com/aliyun/aliyun-ahas-go-sdk/heartbeat"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/notifier"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/blocklog"
 	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/hotparam"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/metriclog"
+	"github.com/aliyun/aliyun-ahas-go-sdk/topology"
 	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
@@ -24,17 +41,90 @@ const (
 	LicenseEnvKey     = "AHAS_LICENSE"
 	NamespaceEnvKey   = "AHAS_NAMESPACE"
 	EnvironmentEnvKey = "AHAS_ENV"
+	FailFastEnvKey    = "AHAS_FAIL_FAST"
+	OfflineEnvKey     = "AHAS_OFFLINE"
+
+	DiscoverFromInstanceEnvKey            = "AHAS_DISCOVER_FROM_INSTANCE"
+	DiscoverNamespaceFromKubernetesEnvKey = "AHAS_DISCOVER_NAMESPACE_FROM_KUBERNETES"
+
+	// Keys of the ECS instance tags (or user-data properties) which carry the AHAS settings.
+	LicenseInstanceTagKey   = "ahas-license"
+	NamespaceInstanceTagKey = "ahas-namespace"
 
 	ConfFileEnvKey = "AHAS_CONFIG_FILE_PATH"
 )
 
+// Config is the unified config of AHAS, which is loaded from the "ahas" section of the config
+// file (see InitConfigFromFile), or set programmatically (see SetConfig).
 type Config struct {
-	License    string            `yaml:"license"`
-	Namespace  string            `yaml:"namespace"`
-	Env        string            `yaml:"env"`
-	Transport  transport.Config  `yaml:"transport"`
-	Heartbeat  heartbeat.Config  `yaml:"heartbeat"`
-	DataSource datasource.Config `yaml:"datasource"`
+	// License is the license key of AHAS, which is required.
+	License string `yaml:"license"`
+	// Namespace is the namespace of the application in AHAS. DefaultNamespace will be used if absent,
+	// unless DiscoverNamespaceFromKubernetes is set.
+	Namespace string `yaml:"namespace"`
+	// Env is the deploy environment of AHAS, one of DeployEnvProd, DeployEnvPre and DeployEnvTest.
+	Env string `yaml:"env"`
+	// AppName is the name of the app registered to AHAS, whose rules are subscribed unless
+	// DataSource.Apps is present, e.g. the tenant app of an ahas.Client. The Sentinel app name
+	// will be used if absent.
+	AppName    string                `yaml:"appName"`
+	Transport  transport.Config      `yaml:"transport"`
+	Heartbeat  heartbeat.Config      `yaml:"heartbeat"`
+	DataSource datasource.Config     `yaml:"datasource"`
+	Console    console.Config        `yaml:"console"`
+	BlockLog   blocklog.ShipConfig   `yaml:"blockLog"`
+	Health     health.Config         `yaml:"health"`
+	Network    meta.NetworkConfig    `yaml:"network"`
+	Metadata   aliyun.MetadataConfig `yaml:"metadata"`
+	Cloud      meta.CloudConfig      `yaml:"cloud"`
+	// MetricUpload is the config of uploading the resource metrics to AHAS, rather than being fetched by AHAS.
+	MetricUpload metriclog.UploadConfig `yaml:"metricUpload"`
+	// Exporter is the config of the Prometheus metric exporter.
+	Exporter exporter.Config `yaml:"exporter"`
+	// HotParam is the config of reporting the hottest hot-spot parameter values.
+	HotParam hotparam.Config `yaml:"hotParam"`
+	// Log is the rotation and retention settings of the AHAS log file.
+	Log logger.FileConfig `yaml:"log"`
+	// Endpoints overrides the AHAS gateway endpoints (host:port) in the order of preference,
+	// e.g. for the private AHAS deployments, see meta.WithEndpoints.
+	Endpoints []string `yaml:"endpoints"`
+	// Tags is the custom tags of current instance reported to AHAS, see meta.SetTags.
+	Tags map[string]string `yaml:"tags"`
+	// Features is the feature toggles, see package feature for available features.
+	Features map[string]bool `yaml:"features"`
+	// FailFast indicates whether the initialization should fail when any critical
+	// subsystem (metadata, transport, data-source) cannot start.
+	FailFast bool `yaml:"failFast"`
+	// PanicOnFailure indicates whether to panic rather than return an error
+	// when the initialization fails in fail-fast mode.
+	PanicOnFailure bool `yaml:"panicOnFailure"`
+	// DiscoverFromInstance indicates whether to resolve the absent license and namespace
+	// from the ECS instance tags or user-data.
+	DiscoverFromInstance bool `yaml:"discoverFromInstance"`
+	// DiscoverNamespaceFromKubernetes indicates whether to use the Kubernetes namespace of the pod
+	// as the absent (or default) namespace. It's off by default, as the rules in the console are
+	// kept by namespace.
+	DiscoverNamespaceFromKubernetes bool `yaml:"discoverNamespaceFromKubernetes"`
+	// AsyncStartup indicates whether the Init functions return immediately, and AHAS is started
+	// in the background with the local rules applied meanwhile, retrying until succeeded.
+	AsyncStartup bool `yaml:"asyncStartup"`
+	// DegradeOnFailure indicates whether to run with the local rules only rather than failing
+	// the Init functions when AHAS can't be started (e.g. the license or the endpoints can't be
+	// resolved), and keep retrying in the background until succeeded.
+	DegradeOnFailure bool `yaml:"degradeOnFailure"`
+	// Offline indicates whether to use the SDK as a local Sentinel bootstrap only: the local rules
+	// (the snapshots and the environment variables) are applied, and no network calls are made to
+	// AHAS, i.e. the metadata, transport and data-source are never started.
+	Offline bool `yaml:"offline"`
+	// Chaos is the config of the fault injection experiments from the AHAS console.
+	Chaos chaos.Config `yaml:"chaos"`
+	// Topology is the config of reporting the outbound connections for the dependency topology.
+	Topology topology.Config `yaml:"topology"`
+	// Notifier is the config of posting the events of the protection to the webhooks.
+	Notifier notifier.Config `yaml:"notifier"`
+	// Credentials is the config of the Alibaba Cloud credentials, e.g. of the RAM role of the instance,
+	// which authenticate the ACM requests unless DataSource.Credential is present.
+	Credentials aliyun.CredentialsConfig `yaml:"credentials"`
 }
 
 func NewDefaultConfig() *Config {
@@ -49,13 +139,26 @@ func NewDefaultConfig() *Config {
 			PeriodMs: 5000,
 		},
 		DataSource: datasource.Config{
-			TimeoutMs:        datasource.DefaultTimeoutMs,
-			ListenIntervalMs: datasource.DefaultListenIntervalMs,
+			TimeoutMs:         datasource.DefaultTimeoutMs,
+			ListenIntervalMs:  datasource.DefaultListenIntervalMs,
+			Group:             datasource.AcmGroupId,
+			DataIdTemplate:    datasource.DefaultDataIdTemplate,
+			ParamsMaxCapacity: datasource.DefaultParamsMaxCapacity,
 		},
 	}
 }
 
-var localConf = NewDefaultConfig()
+var (
+	// confMux guards localConf, which is replaced wholesale rather than modified in place.
+	confMux   = &sync.RWMutex{}
+	localConf = NewDefaultConfig()
+)
+
+func current() *Config {
+	confMux.RLock()
+	defer confMux.RUnlock()
+	return localConf
+}
 
 func InitConfig() error {
 	return InitConfigFromFile("")
@@ -75,35 +178,109 @@ func resolveConfigFilePath(filePath string) string {
 	return config.DefaultConfigFilename
 }
 
+// InitConfigFromFile loads the config from the YAML (or JSON, by the .json extension) file, in the
+// string values of which the ${VAR} placeholders are replaced with the system env. The path is resolved from the system env
+// (AHAS_CONFIG_FILE_PATH, then SENTINEL_CONFIG_FILE_PATH) if absent.
 func InitConfigFromFile(p string) error {
-	filePath := resolveConfigFilePath(p)
-	err := loadConfFromYamlFile(filePath)
+	return update(func(c *Config) error {
+		filePath := resolveConfigFilePath(p)
+		if err := loadConfFromYamlFile(c, filePath); err != nil {
+			return err
+		}
+		loadConfFromSystemEnv(c)
+		if c.DiscoverFromInstance && !c.Offline {
+			aliyun.SetMetadataConfig(c.Metadata)
+			loadConfFromInstanceMetadata(c)
+		}
+		return nil
+	})
+}
+
+// SetConfig replaces the config programmatically, without reading any config file
+// or system env. A nil config means the default config.
+func SetConfig(c *Config) error {
+	conf, err := Normalize(c)
 	if err != nil {
 		return err
 	}
+	confMux.Lock()
+	defer confMux.Unlock()
+	localConf = conf
+	return nil
+}
+
+// Update modifies a copy of current config with the given function, and then replaces the
+// config with it, e.g. to override the loaded config programmatically.
+func Update(f func(c *Config)) error {
+	return update(func(c *Config) error {
+		f(c)
+		return nil
+	})
+}
 
-	loadConfFromSystemEnv()
-	if err = checkAndFillDefaultValues(); err != nil {
+// update replaces the config with a copy modified by the function, unless it fails or
+// the modified config is invalid.
+func update(f func(c *Config) error) error {
+	confMux.Lock()
+	defer confMux.Unlock()
+	conf := *localConf
+	if err := f(&conf); err != nil {
 		return err
 	}
-
+	if err := fillDefaultValues(&conf); err != nil {
+		return err
+	}
+	localConf = &conf
 	return nil
 }
 
-func checkAndFillDefaultValues() error {
-	if localConf.DataSource.TimeoutMs == 0 {
-		localConf.DataSource.TimeoutMs = datasource.DefaultTimeoutMs
+// Normalize returns a copy of the config with the absent values filled with the defaults, or
+// the error if the config is invalid, e.g. for the config of an ahas.Client which is not kept
+// by this package. A nil config means the default config.
+func Normalize(c *Config) (*Config, error) {
+	if c == nil {
+		c = NewDefaultConfig()
+	}
+	conf := *c
+	if err := fillDefaultValues(&conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+func fillDefaultValues(c *Config) error {
+	if c.DataSource.TimeoutMs == 0 {
+		c.DataSource.TimeoutMs = datasource.DefaultTimeoutMs
+	}
+	if c.DataSource.ListenIntervalMs == 0 {
+		c.DataSource.ListenIntervalMs = datasource.DefaultListenIntervalMs
+	}
+	if util.IsBlank(c.DataSource.Group) {
+		c.DataSource.Group = datasource.AcmGroupId
+	}
+	if util.IsBlank(c.DataSource.DataIdTemplate) {
+		c.DataSource.DataIdTemplate = datasource.DefaultDataIdTemplate
+	}
+	if c.DataSource.FallbackDelayMs == 0 {
+		c.DataSource.FallbackDelayMs = c.Transport.FallbackDelayMs
+	}
+	if c.DataSource.Proxy == "" {
+		c.DataSource.Proxy = c.Transport.Proxy
 	}
-	if localConf.DataSource.ListenIntervalMs == 0 {
-		localConf.DataSource.ListenIntervalMs = datasource.DefaultListenIntervalMs
+	if c.DataSource.App == "" {
+		c.DataSource.App = c.AppName
 	}
-	if localConf.DataSource.ListenIntervalMs < localConf.DataSource.TimeoutMs {
+	if c.DataSource.Https && !c.DataSource.Tls.Configured() {
+		// The TLS settings of the gateway apply to ACM as well, unless ACM has its own.
+		c.DataSource.Tls = c.Transport.Tls
+	}
+	if c.DataSource.ListenIntervalMs < c.DataSource.TimeoutMs {
 		return errors.New("DataSource.ListenIntervalMs should be greater than DataSource.TimeoutMs")
 	}
 	return nil
 }
 
-func loadConfFromYamlFile(filePath string) error {
+func loadConfFromYamlFile(c *Config, filePath string) error {
 	if filePath == config.DefaultConfigFilename {
 		if _, err := os.Stat(filePath); err != nil {
 			return nil
@@ -113,52 +290,220 @@ func loadConfFromYamlFile(filePath string) error {
 	if err != nil {
 		return err
 	}
+	// The config is parsed into the generic values first, whose strings are interpolated,
+	// and then mapped to the config by the YAML keys, which the JSON config file shares.
+	var tree interface{}
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		err = decoder.Decode(&tree)
+	} else {
+		err = yaml.Unmarshal(content, &tree)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse AHAS config file %s", filePath)
+	}
+	if content, err = yaml.Marshal(interpolateEnv(tree)); err != nil {
+		return err
+	}
 	data := &struct {
 		Version string
 		AHAS    *Config `yaml:"ahas"`
 	}{
-		AHAS: localConf,
+		AHAS: c,
 	}
-	err = yaml.Unmarshal(content, &data)
-	if err != nil {
+	if err = yaml.Unmarshal(content, &data); err != nil {
 		return err
 	}
 	logger.Infof("Resolving AHAS config from: %s", filePath)
 	return nil
 }
 
-func loadConfFromSystemEnv() {
+func loadConfFromSystemEnv(c *Config) {
 	if license := os.Getenv(LicenseEnvKey); !util.IsBlank(license) {
-		localConf.License = license
+		c.License = license
 	}
 	if namespace := os.Getenv(NamespaceEnvKey); !util.IsBlank(namespace) {
-		localConf.Namespace = namespace
+		c.Namespace = namespace
 	}
 	if ahasEnv := os.Getenv(EnvironmentEnvKey); !util.IsBlank(ahasEnv) {
-		localConf.Env = ahasEnv
+		c.Env = ahasEnv
+	}
+	if failFast, err := strconv.ParseBool(os.Getenv(FailFastEnvKey)); err == nil {
+		c.FailFast = failFast
+	}
+	if discover, err := strconv.ParseBool(os.Getenv(DiscoverFromInstanceEnvKey)); err == nil {
+		c.DiscoverFromInstance = discover
+	}
+	if discover, err := strconv.ParseBool(os.Getenv(DiscoverNamespaceFromKubernetesEnvKey)); err == nil {
+		c.DiscoverNamespaceFromKubernetes = discover
+	}
+	if offline, err := strconv.ParseBool(os.Getenv(OfflineEnvKey)); err == nil {
+		c.Offline = offline
+	}
+}
+
+// loadConfFromInstanceMetadata resolves the license and namespace from the ECS instance tags,
+// and then from the user-data. Explicitly configured values always take precedence.
+func loadConfFromInstanceMetadata(c *Config) {
+	needLicense := util.IsBlank(c.License)
+	needNamespace := util.IsBlank(c.Namespace) || c.Namespace == DefaultNamespace
+	if !needLicense && !needNamespace {
+		return
+	}
+	var userData map[string]string
+	resolve := func(tagKey, envKey string) string {
+		if v := aliyun.GetInstanceTag(tagKey); !util.IsBlank(v) {
+			return v
+		}
+		if userData == nil {
+			userData = aliyun.GetUserDataProperties()
+		}
+		if v := userData[envKey]; !util.IsBlank(v) {
+			return v
+		}
+		return userData[tagKey]
 	}
+	if needLicense {
+		if license := resolve(LicenseInstanceTagKey, LicenseEnvKey); !util.IsBlank(license) {
+			c.License = license
+			logger.Info("AHAS license resolved from instance metadata")
+		}
+	}
+	if needNamespace {
+		if namespace := resolve(NamespaceInstanceTagKey, NamespaceEnvKey); !util.IsBlank(namespace) {
+			c.Namespace = namespace
+			logger.Infof("AHAS namespace resolved from instance metadata: %s", namespace)
+		}
+	}
+}
+
+// Current returns a copy of current config.
+func Current() *Config {
+	conf := *current()
+	return &conf
 }
 
 func License() string {
-	return localConf.License
+	return current().License
 }
 
 func Namespace() string {
-	return localConf.Namespace
+	return current().Namespace
+}
+
+// DiscoverNamespaceFromKubernetes returns whether to use the Kubernetes namespace of the pod
+// as the absent (or default) namespace.
+func DiscoverNamespaceFromKubernetes() bool {
+	return current().DiscoverNamespaceFromKubernetes
+}
+
+// AppName returns the name of the app registered to AHAS, see Config.AppName.
+func AppName() string {
+	return current().AppName
 }
 
 func DeployEnv() string {
-	return localConf.Env
+	return current().Env
 }
 
 func TransportConfig() transport.Config {
-	return localConf.Transport
+	return current().Transport
 }
 
 func HeartbeatConfig() heartbeat.Config {
-	return localConf.Heartbeat
+	return current().Heartbeat
 }
 
 func DataSourceConfig() datasource.Config {
-	return localConf.DataSource
+	return current().DataSource
+}
+
+func FailFast() bool {
+	return current().FailFast
+}
+
+func PanicOnFailure() bool {
+	c := current()
+	return c.FailFast && c.PanicOnFailure
+}
+
+func ConsoleConfig() console.Config {
+	return current().Console
+}
+
+func Features() map[string]bool {
+	return current().Features
+}
+
+func BlockLogShipConfig() blocklog.ShipConfig {
+	return current().BlockLog
+}
+
+func MetricUploadConfig() metriclog.UploadConfig {
+	return current().MetricUpload
+}
+
+func ExporterConfig() exporter.Config {
+	return current().Exporter
+}
+
+func HotParamConfig() hotparam.Config {
+	return current().HotParam
+}
+
+func LogFileConfig() logger.FileConfig {
+	return current().Log
+}
+
+func HealthConfig() health.Config {
+	return current().Health
+}
+
+func ChaosConfig() chaos.Config {
+	return current().Chaos
+}
+
+func TopologyConfig() topology.Config {
+	return current().Topology
+}
+
+func NotifierConfig() notifier.Config {
+	return current().Notifier
+}
+
+func NetworkConfig() meta.NetworkConfig {
+	return current().Network
+}
+
+func MetadataConfig() aliyun.MetadataConfig {
+	return current().Metadata
+}
+
+func CredentialsConfig() aliyun.CredentialsConfig {
+	return current().Credentials
+}
+
+func CloudConfig() meta.CloudConfig {
+	return current().Cloud
+}
+
+func Endpoints() []string {
+	return current().Endpoints
+}
+
+func AsyncStartup() bool {
+	return current().AsyncStartup
+}
+
+func DegradeOnFailure() bool {
+	return current().DegradeOnFailure
+}
+
+func Offline() bool {
+	return current().Offline
+}
+
+func Tags() map[string]string {
+	return current().Tags
 }
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitConfigFromFileInterpolatesEnv(t *testing.T) {
+	// The values would break the config file if pasted into it.
+	const license = `li"cense: #1, {x}` + "\n" + `  namespace: evil`
+	os.Setenv("AHAS_TEST_LICENSE", license)
+	os.Setenv("AHAS_TEST_TIMEOUT", "4000")
+	defer os.Unsetenv("AHAS_TEST_LICENSE")
+	defer os.Unsetenv("AHAS_TEST_TIMEOUT")
+	defer SetConfig(nil)
+
+	dir, err := ioutil.TempDir("", "ahas-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"ahas.yaml": "ahas:\n" +
+			"  license: ${AHAS_TEST_LICENSE}\n" +
+			"  namespace: ns-${AHAS_TEST_NAMESPACE:-default}\n" +
+			"  transport:\n" +
+			"    timeout: ${AHAS_TEST_TIMEOUT}\n",
+		// The placeholders are quoted in JSON, even the ones of the numbers.
+		"ahas.json": `{"ahas": {` +
+			`"license": "${AHAS_TEST_LICENSE}",` +
+			`"namespace": "ns-${AHAS_TEST_NAMESPACE:-default}",` +
+			`"transport": {"timeout": "${AHAS_TEST_TIMEOUT}"}}}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetConfig(nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := InitConfigFromFile(path); err != nil {
+				t.Fatal(err)
+			}
+			if got := License(); got != license {
+				t.Errorf("license: got %q, want %q", got, license)
+			}
+			if got := Namespace(); got != "ns-default" {
+				t.Errorf("namespace: got %q, want %q", got, "ns-default")
+			}
+			if got := TransportConfig().TimeoutMs; got != 4000 {
+				t.Errorf("timeout: got %d, want 4000", got)
+			}
+		})
+	}
+}
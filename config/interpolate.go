@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// envPlaceholder matches ${VAR} and ${VAR:-default}.
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolateEnv replaces the ${VAR} placeholders in the string values of the parsed config
+// with the values of the system env, so that the secrets (e.g. the license) need not be written
+// in the config file. The default value after ":-" is used if the env is unset or empty.
+// The values are never parsed as a part of the config file, so they could contain any character.
+// A value which is only a placeholder is resolved to the number or the boolean it holds, e.g.
+// for the timeouts, and is kept as a string otherwise.
+func interpolateEnv(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, e := range v {
+			v[k] = interpolateEnv(e)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = interpolateEnv(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = interpolateEnv(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case string:
+		expanded := expandEnv(v)
+		if expanded == v || envPlaceholder.FindString(v) != v {
+			return expanded
+		}
+		if i, err := strconv.ParseInt(expanded, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(expanded, 64); err == nil {
+			return f
+		}
+		if expanded == "true" || expanded == "false" {
+			return expanded == "true"
+		}
+		return expanded
+	}
+	return v
+}
+
+func expandEnv(s string) string {
+	return envPlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		groups := envPlaceholder.FindStringSubmatch(placeholder)
+		name := groups[1]
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		if groups[2] != "" {
+			return groups[3]
+		}
+		logger.Warnf("System env %s referenced by AHAS config file is absent", name)
+		return ""
+	})
+}
@@ -0,0 +1,18 @@
+package ahas
+
+import "github.com/aliyun/aliyun-ahas-go-sdk/transport"
+
+// ConnectionState is the state of the connection to AHAS.
+type ConnectionState = transport.ConnState
+
+const (
+	Connecting   = transport.StateConnecting
+	Connected    = transport.StateConnected
+	Disconnected = transport.StateDisconnected
+)
+
+// OnConnectionStateChange registers the listener of the connection state changes, e.g. to
+// alert when AHAS is disconnected. The listener is called synchronously, so it should return quickly.
+func OnConnectionStateChange(listener func(from, to ConnectionState)) {
+	transport.RegisterStateListener(listener)
+}
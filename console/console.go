@@ -0,0 +1,368 @@
+// Package console provides a local debug console over unix socket, which speaks
+// a line-based text protocol and can be accessed via nc/socat, e.g.:
+//
+//	socat - UNIX-CONNECT:/tmp/ahas-1234.sock
+//
+// The same commands could also be served over HTTP on localhost (see Config.HTTPAddr),
+// similar to the command center of Sentinel, e.g.:
+//
+//	curl http://127.0.0.1:8719/rules?type=flow
+//	curl -X POST http://127.0.0.1:8719/protection?enabled=false
+package console
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/alibaba/sentinel-golang/core/stat"
+	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/blocklog"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/breaker"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+const defaultBlockTailLines = 20
+
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// SocketPath is the path of the unix socket. Default: ${TMPDIR}/ahas-${pid}.sock
+	// Note that unix sockets require Windows 10 (1803) or later on Windows.
+	SocketPath string `yaml:"socketPath"`
+	// HTTPAddr is the address of the admin HTTP endpoint, which must be a loopback address,
+	// e.g. 127.0.0.1:8719. The HTTP endpoint is disabled if absent.
+	HTTPAddr string `yaml:"httpAddr"`
+}
+
+// CommandFunc handles a console command with the given arguments and returns the output.
+type CommandFunc func(args []string) (string, error)
+
+type command struct {
+	usage   string
+	handler CommandFunc
+}
+
+var (
+	mux      = &sync.Mutex{}
+	listener net.Listener
+	commands = make(map[string]command)
+)
+
+const (
+	rulesUsage     = "rules list [flow|system|breaker|hotspot|authority]"
+	blockUsage     = "block tail [n]"
+	breakerUsage   = "breaker state"
+	logLevelUsage  = "loglevel [debug|info|warn|error]"
+	featuresUsage  = "features"
+	conflictsUsage = "conflicts"
+	rollbackUsage  = "rollback flow|system|degrade|param-flow|gateway-flow|authority [steps]"
+	metaUsage      = "meta"
+	metricsUsage   = "metrics [resource]"
+	protectUsage   = "protection [on|off]"
+)
+
+func init() {
+	RegisterCommand("help", "help", handleHelp)
+	RegisterCommand("rules", rulesUsage, handleRules)
+	RegisterCommand("block", blockUsage, handleBlock)
+	RegisterCommand("breaker", breakerUsage, handleBreaker)
+	RegisterCommand("loglevel", logLevelUsage, handleLogLevel)
+	RegisterCommand("features", featuresUsage, handleFeatures)
+	RegisterCommand("conflicts", conflictsUsage, handleConflicts)
+	RegisterCommand("rollback", rollbackUsage, handleRollback)
+	RegisterCommand("meta", metaUsage, handleMeta)
+	RegisterCommand("metrics", metricsUsage, handleMetrics)
+	RegisterCommand("protection", protectUsage, handleProtection)
+}
+
+// RegisterCommand registers a custom console command. Existing command with the same name will be replaced.
+func RegisterCommand(name, usage string, handler CommandFunc) {
+	mux.Lock()
+	defer mux.Unlock()
+	commands[name] = command{usage: usage, handler: handler}
+}
+
+// DefaultSocketPath returns the default unix socket path of current process.
+func DefaultSocketPath() string {
+	return filepath.Join(os.TempDir(), "ahas-"+strconv.Itoa(os.Getpid())+".sock")
+}
+
+// Start starts the debug console if enabled in the config.
+func Start(conf Config) error {
+	if !conf.Enabled || !feature.Enabled(feature.Console) {
+		return nil
+	}
+	path := conf.SocketPath
+	if path == "" {
+		path = DefaultSocketPath()
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if listener != nil {
+		return errors.New("debug console already started")
+	}
+	// Remove the stale socket file left by previous process.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove stale console socket")
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on console socket")
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		logger.Warnf("Failed to change mode of console socket <%s>: %+v", path, err)
+	}
+	if conf.HTTPAddr != "" {
+		if err = startHTTP(conf.HTTPAddr); err != nil {
+			_ = l.Close()
+			return err
+		}
+	}
+	listener = l
+	blocklog.Init()
+	breaker.Init()
+
+	go serve(l)
+	logger.Infof("AHAS debug console started at: %s", path)
+	return nil
+}
+
+// Stop closes the debug console.
+func Stop() error {
+	mux.Lock()
+	defer mux.Unlock()
+	if listener == nil {
+		return nil
+	}
+	err := listener.Close()
+	listener = nil
+	if httpErr := stopHTTP(); err == nil {
+		err = httpErr
+	}
+	return err
+}
+
+func serve(l net.Listener) {
+	defer tools.PrintPanicStackV2("debug console")
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			logger.Infof("AHAS debug console stopped: %v", err)
+			return
+		}
+		go handleConn(conn)
+	}
+}
+
+func handleConn(conn net.Conn) {
+	defer tools.PrintPanicStackV2("debug console connection")
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	w := bufio.NewWriter(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			return
+		}
+		out, err := execute(fields[0], fields[1:])
+		if err != nil {
+			out = "ERROR: " + err.Error()
+		}
+		if !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		if _, err = w.WriteString(out); err != nil {
+			return
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func execute(name string, args []string) (string, error) {
+	mux.Lock()
+	cmd, ok := commands[name]
+	mux.Unlock()
+	if !ok {
+		return "", errors.Errorf("unknown command: %s, type 'help' for usage", name)
+	}
+	return cmd.handler(args)
+}
+
+func handleHelp(_ []string) (string, error) {
+	mux.Lock()
+	defer mux.Unlock()
+	usages := make([]string, 0, len(commands)+1)
+	for _, cmd := range commands {
+		usages = append(usages, cmd.usage)
+	}
+	usages = append(usages, "quit")
+	sort.Strings(usages)
+	return strings.Join(usages, "\n"), nil
+}
+
+func handleRules(args []string) (string, error) {
+	if len(args) == 0 || args[0] != "list" {
+		return "", errors.New("usage: " + rulesUsage)
+	}
+	all := map[string]interface{}{
+		"flow":      flow.GetRules(),
+		"system":    system.GetRules(),
+		"breaker":   datasource.LoadedRules(datasource.CircuitBreakingRuleKind),
+		"hotspot":   datasource.LoadedRules(datasource.ParamFlowRuleKind),
+		"authority": authority.GetRules(),
+	}
+	var v interface{} = all
+	if len(args) > 1 {
+		var ok bool
+		if v, ok = all[args[1]]; !ok {
+			return "", errors.Errorf("unknown rule type: %s", args[1])
+		}
+	}
+	return toJson(v)
+}
+
+func handleBlock(args []string) (string, error) {
+	if len(args) == 0 || args[0] != "tail" {
+		return "", errors.New("usage: " + blockUsage)
+	}
+	n := defaultBlockTailLines
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+			return "", errors.Errorf("bad line count: %s", args[1])
+		}
+	}
+	b := strings.Builder{}
+	for _, e := range blocklog.Recent(n) {
+		b.WriteString(fmt.Sprintf("%d|%s|%s|%s\n", e.Timestamp, e.Resource, e.BlockType, e.Rule))
+	}
+	return b.String(), nil
+}
+
+func handleBreaker(args []string) (string, error) {
+	if len(args) == 0 || args[0] != "state" {
+		return "", errors.New("usage: " + breakerUsage)
+	}
+	return toJson(breaker.States())
+}
+
+func handleLogLevel(args []string) (string, error) {
+	if len(args) == 0 {
+		return logger.Level(), nil
+	}
+	if err := logger.SetLevel(args[0]); err != nil {
+		return "", err
+	}
+	return "OK", nil
+}
+
+func handleFeatures(_ []string) (string, error) {
+	return toJson(feature.All())
+}
+
+func handleMeta(_ []string) (string, error) {
+	return toJson(meta.Snapshot())
+}
+
+// ResourceMetrics is the snapshot of the statistics of a resource.
+type ResourceMetrics struct {
+	Resource    string  `json:"resource"`
+	PassQps     float64 `json:"passQps"`
+	BlockQps    float64 `json:"blockQps"`
+	CompleteQps float64 `json:"completeQps"`
+	ErrorQps    float64 `json:"errorQps"`
+	AvgRt       float64 `json:"avgRt"`
+	Concurrency int32   `json:"concurrency"`
+}
+
+func handleMetrics(args []string) (string, error) {
+	nodes := stat.ResourceNodeList()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ResourceName() < nodes[j].ResourceName()
+	})
+	result := make([]ResourceMetrics, 0, len(nodes))
+	for _, n := range nodes {
+		if len(args) > 0 && n.ResourceName() != args[0] {
+			continue
+		}
+		result = append(result, ResourceMetrics{
+			Resource:    n.ResourceName(),
+			PassQps:     n.GetQPS(base.MetricEventPass),
+			BlockQps:    n.GetQPS(base.MetricEventBlock),
+			CompleteQps: n.GetQPS(base.MetricEventComplete),
+			ErrorQps:    n.GetQPS(base.MetricEventError),
+			AvgRt:       n.AvgRT(),
+			Concurrency: n.CurrentGoroutineNum(),
+		})
+	}
+	return toJson(result)
+}
+
+func handleProtection(args []string) (string, error) {
+	if len(args) == 0 {
+		if datasource.ProtectionEnabled() {
+			return "on", nil
+		}
+		return "off", nil
+	}
+	switch args[0] {
+	case "on":
+		datasource.SetProtectionEnabled(true, "the debug console")
+	case "off":
+		datasource.SetProtectionEnabled(false, "the debug console")
+	default:
+		return "", errors.New("usage: " + protectUsage)
+	}
+	return "OK", nil
+}
+
+func handleConflicts(_ []string) (string, error) {
+	return toJson(datasource.RuleConflicts())
+}
+
+func handleRollback(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("usage: " + rollbackUsage)
+	}
+	steps := 1
+	if len(args) > 1 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+			return "", errors.Errorf("bad steps: %s", args[1])
+		}
+	}
+	if err := datasource.Rollback(datasource.RuleKind(args[0]), steps); err != nil {
+		return "", err
+	}
+	return "OK", nil
+}
+
+func toJson(v interface{}) (string, error) {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,216 @@
+package console
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+// maxCommandSize is the maximum size of the command line posted to /command.
+const maxCommandSize = 4096
+
+// httpServer is the admin HTTP endpoint, guarded by mux.
+var httpServer *http.Server
+
+// startHTTP starts the admin HTTP endpoint on the loopback address. It must be called with the mux held.
+func startHTTP(addr string) error {
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on console HTTP address")
+	}
+	s := &http.Server{Handler: loopbackHandler()}
+	httpServer = s
+	go func() {
+		defer tools.PrintPanicStackV2("debug console HTTP endpoint")
+		if err := s.Serve(l); err != nil && err != http.ErrServerClosed {
+			logger.Warnf("AHAS debug console HTTP endpoint stopped: %+v", err)
+		}
+	}()
+	logger.Infof("AHAS debug console HTTP endpoint started at: %s", l.Addr())
+	return nil
+}
+
+// stopHTTP closes the admin HTTP endpoint. It must be called with the mux held.
+func stopHTTP() error {
+	if httpServer == nil {
+		return nil
+	}
+	err := httpServer.Close()
+	httpServer = nil
+	return err
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrap(err, "bad console HTTP address")
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return errors.Errorf("console HTTP address must be a loopback address: %s", addr)
+}
+
+// HTTPHandler returns the HTTP handler of the console commands, to be mounted to the HTTP
+// server of the application rather than served by the dedicated endpoint (Config.HTTPAddr).
+// As the remote addresses behind the proxies all look like loopback ones, the requests must
+// carry the token in the header "Authorization: Bearer <token>", and an empty token rejects
+// all requests. The routes are:
+//
+//	GET  /rules[?type=flow|system|breaker|hotspot|authority]
+//	GET  /metrics[?resource=xxx]
+//	GET  /meta, /features, /breakers, /conflicts and /blocks[?n=20]
+//	GET  /loglevel, and POST /loglevel?level=debug|info|warn|error
+//	GET  /protection, and POST /protection?enabled=true|false (see datasource.SetProtectionEnabled)
+//	POST /command with the command line in the body, e.g. "rollback flow 1"
+//
+// The other GET /xxx requests run the command xxx without arguments, e.g. /health. The requests
+// from the browsers (i.e. with the Origin header) are rejected.
+func HTTPHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || !validToken(r, token) || r.Header.Get("Origin") != "" {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		serveCommand(w, r)
+	})
+}
+
+// loopbackHandler is the handler of the dedicated endpoint, which only accepts the requests
+// from the loopback addresses to a loopback host. The latter rejects the DNS rebinding, i.e.
+// the requests of the browsers to an attacker's domain resolved to the loopback address.
+func loopbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromLoopback(r) || !toLoopback(r) || r.Header.Get("Origin") != "" {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		serveCommand(w, r)
+	})
+}
+
+func serveCommand(w http.ResponseWriter, r *http.Request) {
+	name, args, err := httpCommand(r)
+	if err != nil {
+		http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	out, err := execute(name, args)
+	if err != nil {
+		http.Error(w, "ERROR: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if json.Valid([]byte(out)) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, _ = io.WriteString(w, out)
+}
+
+func validToken(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) == 1
+}
+
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func toLoopback(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// httpCommand maps the HTTP request to the console command.
+func httpCommand(r *http.Request) (string, []string, error) {
+	path := strings.Trim(r.URL.Path, "/")
+	query := r.URL.Query()
+	if r.Method == http.MethodPost {
+		switch path {
+		case "loglevel":
+			return "loglevel", []string{query.Get("level")}, nil
+		case "protection":
+			switch query.Get("enabled") {
+			case "true":
+				return "protection", []string{"on"}, nil
+			case "false":
+				return "protection", []string{"off"}, nil
+			}
+			return "", nil, errors.New("usage: POST /protection?enabled=true|false")
+		case "command":
+			bs, err := ioutil.ReadAll(io.LimitReader(r.Body, maxCommandSize))
+			if err != nil {
+				return "", nil, err
+			}
+			fields := strings.Fields(string(bs))
+			if len(fields) == 0 {
+				return "", nil, errors.New("empty command")
+			}
+			return fields[0], fields[1:], nil
+		}
+		return "", nil, errors.Errorf("unknown POST path: /%s", path)
+	}
+	if r.Method != http.MethodGet {
+		return "", nil, errors.Errorf("unsupported method: %s", r.Method)
+	}
+	var args []string
+	switch path {
+	case "":
+		return "help", nil, nil
+	case "rules":
+		args = []string{"list"}
+		if t := query.Get("type"); t != "" {
+			args = append(args, t)
+		}
+		return path, args, nil
+	case "metrics":
+		if res := query.Get("resource"); res != "" {
+			args = []string{res}
+		}
+		return path, args, nil
+	case "breakers":
+		return "breaker", []string{"state"}, nil
+	case "blocks":
+		args = []string{"tail"}
+		if n := query.Get("n"); n != "" {
+			args = append(args, n)
+		}
+		return "block", args, nil
+	}
+	return path, nil, nil
+}
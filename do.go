@@ -0,0 +1,132 @@
+package ahas
+
+import (
+	"context"
+	"time"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/pkg/errors"
+)
+
+// ErrCallTimeout is the error of the calls of Do exceeding the timeout of WithCallTimeout.
+var ErrCallTimeout = errors.New("call timeout")
+
+type doOptions struct {
+	entryOpts       []sentinel.EntryOption
+	timeout         time.Duration
+	fallbackOnError bool
+}
+
+// DoOption is the option of Do and DoContext.
+type DoOption func(*doOptions)
+
+// WithEntryOptions carries the options of the entry, e.g. the traffic type and the arguments.
+func WithEntryOptions(opts ...sentinel.EntryOption) DoOption {
+	return func(o *doOptions) {
+		o.entryOpts = append(o.entryOpts, opts...)
+	}
+}
+
+// WithCallTimeout limits the duration of the call, which fails with ErrCallTimeout once
+// exceeded. The context of the call is canceled then, while the call is not waited for.
+func WithCallTimeout(timeout time.Duration) DoOption {
+	return func(o *doOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithFallbackOnError invokes the fallback on the errors of the call as well, rather than
+// only on the blocks.
+func WithFallbackOnError() DoOption {
+	return func(o *doOptions) {
+		o.fallbackOnError = true
+	}
+}
+
+// Do calls the function guarded by the resource, see DoContext.
+func Do(resource string, fn func() error, fallback func(err error) error, opts ...DoOption) error {
+	return DoContext(context.Background(), resource, func(context.Context) error {
+		return fn()
+	}, fallback, opts...)
+}
+
+// DoContext calls the function guarded by the resource. Once blocked (e.g. by the flow rules,
+// or the circuit breaker is open), the fallback is invoked with the *base.BlockError instead.
+// The errors of the call are recorded for the circuit breakers of the error ratio and count.
+// A nil fallback results in the block error itself.
+func DoContext(ctx context.Context, resource string, fn func(ctx context.Context) error,
+	fallback func(err error) error, opts ...DoOption) error {
+	o := &doOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e, b := sentinel.Entry(resource, o.entryOpts...)
+	if b != nil {
+		if fallback != nil {
+			return fallback(b)
+		}
+		return b
+	}
+	err := guardedCall(ctx, e, fn, o.timeout)
+	if err != nil && o.fallbackOnError && fallback != nil {
+		return fallback(err)
+	}
+	return err
+}
+
+// guardedCall calls the function within the entry. The entry is exited even if the function
+// panics, which is recorded as an error of the resource before being propagated.
+func guardedCall(ctx context.Context, e *base.SentinelEntry, fn func(ctx context.Context) error,
+	timeout time.Duration) error {
+	defer e.Exit()
+	defer func() {
+		if p := recover(); p != nil {
+			sentinel.TraceError(e, errors.Errorf("panic: %v", p))
+			panic(p)
+		}
+	}()
+	err := call(ctx, fn, timeout)
+	// The cancellation is up to the caller, which is not an error of the resource.
+	if err != nil && !errors.Is(err, context.Canceled) {
+		sentinel.TraceError(e, err)
+	}
+	return err
+}
+
+func call(ctx context.Context, fn func(ctx context.Context) error, timeout time.Duration) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		err   error
+		panic interface{}
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{panic: p}
+			}
+		}()
+		done <- result{err: fn(ctx)}
+	}()
+	select {
+	case r := <-done:
+		if r.panic != nil {
+			// Panics in the caller goroutine, the same as without the timeout.
+			panic(r.panic)
+		}
+		return r.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrCallTimeout
+		}
+		return ctx.Err()
+	}
+}
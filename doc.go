@@ -0,0 +1,41 @@
+// Package ahas is the Go SDK of AHAS (Application High Availability Service), which
+// connects Sentinel to the AHAS console: the rules are pushed from the console, and the
+// metrics are fetched by the console.
+//
+// The public API of the SDK consists of:
+//
+//   - ahas: initialization (Init with the options, InitWithConfig, their context-aware
+//     versions, InitAhasDefault, InitAhasFromFile, NewAgent), graceful Shutdown, and the
+//     application-level switches and hooks (FeatureEnabled, SetProtectionEnabled,
+//     OverrideResource, SetAppHealth, Health, OnConnectionStateChange,
+//     OnLicenseStatusChange, OnDegradedModeChange, OnCircuitBreakerStateChange,
+//     RegisterHeartbeatExtension, RegisterCommandHandler), and the guarded calls with the
+//     fallbacks (Do, DoContext);
+//   - config, feature, health, console and exporter: the configuration, runtime controls
+//     and observability;
+//   - chaos: the fault injection experiments of AHAS Chaos (enabled by Config.Chaos), the
+//     injection points in code (Inject) and the local experiments (StartLocal);
+//   - topology: the outbound connections reported for the dependency topology (Latest);
+//   - notifier: the webhook notifications (e.g. DingTalk and Slack) of the rule updates, the
+//     circuit breakers opened and the system rules triggered (enabled by Config.Notifier);
+//   - sentinel/datasource: the rule data-source and its diagnostics (Rollback, RuleMetrics,
+//     RuleConflicts, Subscriptions, CurrentRules, Overrides), the local rules of the
+//     application (SetLocalFlowRules, etc.), and the conversion of the console rule format
+//     (ConvertFlowRules, etc.);
+//   - sentinel/authority, sentinel/paramkey, sentinel/resourcename, sentinel/blocklog and
+//     sentinel/otelbridge: the helpers for the integration with the business code;
+//   - adapter/http, adapter/gin, adapter/echo and adapter/fiber: the middlewares of net/http,
+//     Gin, Echo and Fiber, with the hot-spot parameters extracted by adapter, and the outbound
+//     Transport of net/http;
+//   - adapter/dubbo, adapter/micro and adapter/kratos: the server and client plugins of
+//     dubbo-go, go-micro and Kratos;
+//   - adapter/sql, adapter/gorm and adapter/redis: the database/sql driver wrapper, the GORM
+//     plugin and the go-redis hook;
+//   - adapter/kafka and adapter/rocketmq: the throttling of the sarama and rocketmq-client-go
+//     consumers;
+//   - sentinel/hotparam: the hottest parameter values of the hot-spot rules (Latest);
+//   - sentinel/replay: the offline simulation of the rules.
+//
+// The adapters of the third-party frameworks (all but adapter/http and adapter/sql) and
+// sentinel/otelbridge are separate modules, so that the SDK doesn't depend on the frameworks.
+package ahas
@@ -11,6 +11,7 @@ import (
 	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
 	"github.com/alibaba/sentinel-golang/util"
 	ahas "github.com/aliyun/aliyun-ahas-go-sdk"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/resourcename"
 )
 
 func main() {
@@ -58,11 +59,11 @@ func playSentinel() {
 	ch := make(chan struct{})
 	circuitbreaker.RegisterStateChangeListeners(&stateChangeTestListener{})
 
-	goWithEntry("GET:/foo/:id", base.Inbound, base.ResTypeWeb, 7)
-	goWithEntry("/grpc.testing.TestService/FooCall", base.Inbound, base.ResTypeRPC, 20)
+	goWithEntry(resourcename.HTTP("GET", "/foo/:id"), base.Inbound, base.ResTypeWeb, 7)
+	goWithEntry(resourcename.GRPC("/grpc.testing.TestService/FooCall"), base.Inbound, base.ResTypeRPC, 20)
 
 	for i := 0; i < 8; i++ {
-		goWithEntry("SELECT * FROM user WHERE id = ?", base.Outbound, base.ResTypeDBSQL, 15)
+		goWithEntry(resourcename.SQL("SELECT * FROM user WHERE id = ?"), base.Outbound, base.ResTypeDBSQL, 15)
 		go func() {
 			for {
 				e, b := sentinel.Entry("some-test", sentinel.WithTrafficType(base.Inbound))
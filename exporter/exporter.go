@@ -0,0 +1,212 @@
+// Package exporter exposes the Sentinel metrics and the SDK internals in the Prometheus
+// text exposition format, e.g. for the teams not using the AHAS console:
+//
+//	curl http://localhost:9464/metrics
+//
+// Handler could also be mounted to the HTTP server of the application.
+package exporter
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/stat"
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/heartbeat"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/breaker"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultAddr = ":9464"
+	DefaultPath = "/metrics"
+
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Addr is the listening address of the exporter. DefaultAddr will be used if absent.
+	Addr string `yaml:"addr"`
+	// Path is the HTTP path of the metrics. DefaultPath will be used if absent.
+	Path string `yaml:"path"`
+}
+
+type gauge struct {
+	help  string
+	value func() float64
+}
+
+var (
+	mux    = &sync.Mutex{}
+	server *http.Server
+
+	gaugeMux = &sync.RWMutex{}
+	gauges   = make(map[string]gauge)
+)
+
+// RegisterGauge registers a custom gauge without labels, which is evaluated on each scrape.
+// Existing gauge with the same name will be replaced.
+func RegisterGauge(name, help string, value func() float64) {
+	gaugeMux.Lock()
+	defer gaugeMux.Unlock()
+	gauges[name] = gauge{help: help, value: value}
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func Handler() http.Handler {
+	breaker.Init()
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		b := newWriter()
+		writeResourceMetrics(b)
+		writeBreakerStates(b)
+		writeRuleMetrics(b)
+		writeSdkMetrics(b)
+		writeCustomGauges(b)
+		_, _ = w.Write(b.Bytes())
+	})
+}
+
+// Start starts serving the metrics if enabled in the config.
+func Start(conf Config) error {
+	if !conf.Enabled {
+		return nil
+	}
+	addr, path := conf.Addr, conf.Path
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	if path == "" {
+		path = DefaultPath
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if server != nil {
+		return errors.New("metric exporter already started")
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on metric exporter address")
+	}
+	serveMux := http.NewServeMux()
+	serveMux.Handle(path, Handler())
+	s := &http.Server{Handler: serveMux}
+	server = s
+	go func() {
+		defer tools.PrintPanicStackV2("metric exporter")
+		if err := s.Serve(l); err != nil && err != http.ErrServerClosed {
+			logger.Warnf("Metric exporter stopped: %+v", err)
+		}
+	}()
+	logger.Infof("AHAS metric exporter started at: %s%s", l.Addr(), path)
+	return nil
+}
+
+// Stop closes the metric exporter.
+func Stop() error {
+	mux.Lock()
+	defer mux.Unlock()
+	if server == nil {
+		return nil
+	}
+	err := server.Close()
+	server = nil
+	return err
+}
+
+func writeResourceMetrics(b *writer) {
+	nodes := stat.ResourceNodeList()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ResourceName() < nodes[j].ResourceName()
+	})
+	events := []struct {
+		name  string
+		help  string
+		event base.MetricEvent
+	}{
+		{"sentinel_resource_pass_qps", "The passed requests per second of the resource.", base.MetricEventPass},
+		{"sentinel_resource_block_qps", "The blocked requests per second of the resource.", base.MetricEventBlock},
+		{"sentinel_resource_complete_qps", "The completed requests per second of the resource.", base.MetricEventComplete},
+		{"sentinel_resource_error_qps", "The requests with exceptions per second of the resource.", base.MetricEventError},
+	}
+	for _, e := range events {
+		b.header(e.name, e.help, "gauge")
+		for _, n := range nodes {
+			b.sample(e.name, n.GetQPS(e.event), "resource", n.ResourceName())
+		}
+	}
+	b.header("sentinel_resource_avg_rt_ms", "The average response time of the resource in milliseconds.", "gauge")
+	for _, n := range nodes {
+		b.sample("sentinel_resource_avg_rt_ms", n.AvgRT(), "resource", n.ResourceName())
+	}
+	b.header("sentinel_resource_concurrency", "The concurrent requests of the resource.", "gauge")
+	for _, n := range nodes {
+		b.sample("sentinel_resource_concurrency", float64(n.CurrentGoroutineNum()), "resource", n.ResourceName())
+	}
+}
+
+func writeBreakerStates(b *writer) {
+	b.header("sentinel_circuit_breaker_state", "The current state of the circuit breaker, "+
+		"which is 1 with the state label. Breakers never changed state (i.e. closed) are absent.", "gauge")
+	for _, s := range breaker.States() {
+		b.sample("sentinel_circuit_breaker_state", 1,
+			"resource", s.Resource, "rule_id", s.RuleId, "strategy", s.Strategy, "state", s.State)
+	}
+}
+
+func writeRuleMetrics(b *writer) {
+	metrics := datasource.RuleMetrics()
+	kinds := make([]string, 0, len(metrics))
+	for kind := range metrics {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	b.header("ahas_rules", "The count of the currently applied rules.", "gauge")
+	for _, kind := range kinds {
+		b.sample("ahas_rules", float64(metrics[datasource.RuleKind(kind)].RuleCount), "kind", kind)
+	}
+	b.header("ahas_rule_load_failures_total", "The count of the rule payloads failed to be decoded or loaded.", "counter")
+	for _, kind := range kinds {
+		m := metrics[datasource.RuleKind(kind)]
+		b.sample("ahas_rule_load_failures_total", float64(m.LoadFailure+m.ParseFailure), "kind", kind)
+	}
+}
+
+func writeSdkMetrics(b *writer) {
+	b.header("ahas_heartbeat_lag_seconds", "The seconds since the latest successful heartbeat, "+
+		"absent if no heartbeat has succeeded.", "gauge")
+	if last := heartbeat.LastSuccessMs(); last > 0 {
+		b.sample("ahas_heartbeat_lag_seconds", float64(int64(util.CurrentTimeMillis())-last)/1000)
+	}
+	b.header("ahas_acm_subscribed", "Whether the ACM listener of the rule dataId has been registered.", "gauge")
+	for _, s := range datasource.Subscriptions() {
+		v := 0.0
+		if s.Subscribed {
+			v = 1
+		}
+		b.sample("ahas_acm_subscribed", v, "data_id", s.DataId)
+	}
+}
+
+func writeCustomGauges(b *writer) {
+	gaugeMux.RLock()
+	defer gaugeMux.RUnlock()
+	names := make([]string, 0, len(gauges))
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g := gauges[name]
+		b.header(name, g.help, "gauge")
+		b.sample(name, g.value())
+	}
+}
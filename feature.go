@@ -0,0 +1,9 @@
+package ahas
+
+import "github.com/aliyun/aliyun-ahas-go-sdk/feature"
+
+// FeatureEnabled checks whether the named feature (see package feature) is active
+// in current build and deployment.
+func FeatureEnabled(name string) bool {
+	return feature.Enabled(name)
+}
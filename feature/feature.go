@@ -0,0 +1,163 @@
+// Package feature provides the feature gates of the SDK subsystems. Whether a feature
+// is active is combined from the build (compile-time tags), the local config and
+// the toggles pushed from the AHAS console, with the latter taking precedence.
+package feature
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	Console           = "console"
+	RuleExpression    = "ruleExpression"
+	GatewayFlow       = "gatewayFlow"
+	MetricCompression = "metricCompression"
+	Zstd              = "zstd"
+	BreakerReport     = "breakerReport"
+	// Chaos switches the chaos experiments, which are accepted only if enabled locally as well.
+	Chaos = "chaos"
+)
+
+var (
+	mux = &sync.RWMutex{}
+	// compiled indicates the features built into current binary.
+	compiled = map[string]bool{
+		Console:           true,
+		RuleExpression:    true,
+		GatewayFlow:       true,
+		MetricCompression: true,
+		BreakerReport:     true,
+		Chaos:             true,
+	}
+	// defaults is the default state of compiled features.
+	defaults = map[string]bool{
+		Console:           true,
+		RuleExpression:    true,
+		GatewayFlow:       true,
+		MetricCompression: true,
+		Zstd:              true,
+		BreakerReport:     true,
+		Chaos:             true,
+	}
+	configured = make(map[string]bool)
+	remote     = make(map[string]bool)
+
+	listenerMux = &sync.Mutex{}
+	listeners   []Listener
+)
+
+// Listener is notified of the changes of the feature states.
+type Listener func(name string, enabled bool)
+
+// AddListener registers the listener of the feature state changes, e.g. to stop the running
+// work of a feature once it's switched off.
+func AddListener(l Listener) {
+	listenerMux.Lock()
+	defer listenerMux.Unlock()
+	listeners = append(listeners, l)
+}
+
+// update applies the change to the toggles with the mux held, and notifies the listeners
+// of the changed features afterwards.
+func update(change func()) {
+	mux.Lock()
+	before := make(map[string]bool, len(compiled))
+	for name := range compiled {
+		before[name] = enabledLocked(name)
+	}
+	change()
+	changed := make(map[string]bool)
+	for name := range compiled {
+		if enabled := enabledLocked(name); enabled != before[name] {
+			changed[name] = enabled
+		}
+	}
+	mux.Unlock()
+	if len(changed) == 0 {
+		return
+	}
+	listenerMux.Lock()
+	ls := append([]Listener(nil), listeners...)
+	listenerMux.Unlock()
+	for name, enabled := range changed {
+		for _, l := range ls {
+			l(name, enabled)
+		}
+	}
+}
+
+// SetCompiled marks the feature as built into (or excluded from) current binary.
+// It's expected to be called in init() of the files guarded by build tags.
+func SetCompiled(name string, built bool) {
+	update(func() {
+		compiled[name] = built
+	})
+}
+
+// SetConfigured replaces the feature toggles from the local config.
+func SetConfigured(toggles map[string]bool) {
+	update(func() {
+		configured = make(map[string]bool, len(toggles))
+		for k, v := range toggles {
+			configured[k] = v
+		}
+	})
+}
+
+// SetRemote sets the feature toggle pushed from the AHAS console.
+func SetRemote(name string, enabled bool) {
+	update(func() {
+		remote[name] = enabled
+	})
+}
+
+// ClearRemote removes the feature toggle pushed from the AHAS console.
+func ClearRemote(name string) {
+	update(func() {
+		delete(remote, name)
+	})
+}
+
+// Enabled checks whether the feature is active in current build and deployment.
+func Enabled(name string) bool {
+	mux.RLock()
+	defer mux.RUnlock()
+	return enabledLocked(name)
+}
+
+func enabledLocked(name string) bool {
+	if !compiled[name] {
+		return false
+	}
+	if v, ok := remote[name]; ok {
+		return v
+	}
+	if v, ok := configured[name]; ok {
+		return v
+	}
+	return defaults[name]
+}
+
+// All returns the states of all compiled features.
+func All() map[string]bool {
+	mux.RLock()
+	defer mux.RUnlock()
+	result := make(map[string]bool, len(compiled))
+	for name := range compiled {
+		result[name] = enabledLocked(name)
+	}
+	return result
+}
+
+// Names returns the names of all compiled features in order.
+func Names() []string {
+	mux.RLock()
+	defer mux.RUnlock()
+	names := make([]string, 0, len(compiled))
+	for name := range compiled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -9,19 +9,16 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
 
 const (
 	connectTimeoutSec = 5
+	// failbackCheckInterval is the interval to check the health of the preferred gateway after failing over.
+	failbackCheckInterval = 30 * time.Second
 )
 
-var createOnce sync.Once
-var poolInstance *ConnectionPool
-
 type AgwConn struct {
 	connId   uint32
 	conn     *net.Conn
@@ -68,7 +65,7 @@ func (c *AgwConn) close() {
 	}
 	logInfof("[AGW] Close connection, connId : %d", c.connId)
 
-	c.pool.remove(c.connId)
+	c.pool.remove(c)
 	(*c.conn).Close()
 
 	connClosedMsg := NewAgwMessage()
@@ -87,28 +84,55 @@ type ConnectionPool struct {
 	pool sync.Map
 	lock sync.Mutex
 	size uint32
+	// client is the owner of the pool, whose config the connections are made with.
+	client *AgwClient
+	// active is the index of the gateway which new connections are made to, guarded by lock.
+	active int
+	// failingBack indicates whether the failback checker is running, guarded by lock.
+	failingBack bool
+	// closed indicates whether the pool is closed, when no connections are made, guarded by lock.
+	closed bool
+	// done is closed to stop the failback checker when the pool is closed, guarded by lock.
+	done chan struct{}
 }
 
-func getConnectionPoolInstance(size uint32) *ConnectionPool {
-
-	if size <= 0 {
-		return nil
+func newConnectionPool(size uint32, client *AgwClient) *ConnectionPool {
+	p := &ConnectionPool{
+		ring:   newRing(),
+		size:   size,
+		client: client,
+		closed: true,
+		done:   make(chan struct{}),
 	}
-
-	if poolInstance != nil {
-		return poolInstance
+	for i := uint32(0); i < size; i++ {
+		p.ring.add(i)
 	}
-	createOnce.Do(func() {
-		if poolInstance == nil {
-			poolInstance = new(ConnectionPool)
-			poolInstance.ring = newRing()
-			poolInstance.size = size
-			for i := uint32(0); i < size; i++ {
-				poolInstance.ring.add(i)
-			}
+	return p
+}
+
+// open allows the pool to make connections, which is closed initially.
+func (p *ConnectionPool) open() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = false
+}
+
+// closeAll closes all connections and stops the failback checker. The reader coroutines clean the
+// closed connections up, and fail the pending calls on them.
+func (p *ConnectionPool) closeAll() {
+	p.lock.Lock()
+	p.closed = true
+	close(p.done)
+	p.done = make(chan struct{})
+	p.active = 0
+	p.lock.Unlock()
+	p.pool.Range(func(k, v interface{}) bool {
+		if c, ok := v.(*AgwConn); ok {
+			(*c.conn).Close()
 		}
+		p.pool.Delete(k)
+		return true
 	})
-	return poolInstance
 }
 
 func (p *ConnectionPool) get() (*AgwConn, error) {
@@ -130,6 +154,9 @@ func (p *ConnectionPool) get() (*AgwConn, error) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if p.closed {
+		return nil, errors.New("the connection pool has been closed")
+	}
 	if conn, ok := p.pool.Load(connId); ok {
 		if value, ok := conn.(*AgwConn); ok {
 			return value, nil
@@ -138,29 +165,10 @@ func (p *ConnectionPool) get() (*AgwConn, error) {
 		}
 	}
 
-	gatewayIp := GetAgwClientInstance().config.GatewayIp
-	gatewayPort := GetAgwClientInstance().config.GatewayPort
-	var conn net.Conn
-	var err error
-	// tls conn or not
-	if GetAgwClientInstance().config.TlsFlag {
-		conn, err = getTlsConn(gatewayIp, gatewayPort)
-		// retry once
-		if err != nil {
-			logger.Warnf("[AGW] Get TLS connection err, %v, retry again", err)
-			err := checkOrDownloadCert()
-			if err != nil {
-				return nil, err
-			}
-			conn, err = getTlsConn(gatewayIp, gatewayPort)
-		}
-	} else {
-		conn, err = net.DialTimeout("tcp", fmt.Sprintf("%s:%d", gatewayIp, gatewayPort), connectTimeoutSec*time.Second)
-	}
+	conn, err := p.dial(connId)
 	if err != nil {
 		return nil, err
 	}
-	logInfof("AGW connect [%s:%d] success, connectionId: %d", gatewayIp, gatewayPort, connId)
 
 	agwConn := &AgwConn{
 		connId: connId,
@@ -175,7 +183,97 @@ func (p *ConnectionPool) get() (*AgwConn, error) {
 	return agwConn, nil
 }
 
-func getTlsConn(gatewayIp string, gatewayPort uint32) (net.Conn, error) {
+// dial connects to the active gateway, and fails over to the next gateways in order
+// if it is unavailable. It should be called with the lock held.
+func (p *ConnectionPool) dial(connId uint32) (net.Conn, error) {
+	gateways := p.client.gateways()
+	var lastErr error
+	for i := 0; i < len(gateways); i++ {
+		idx := (p.active + i) % len(gateways)
+		gateway := gateways[idx]
+		conn, err := p.client.dialGateway(gateway)
+		if err != nil {
+			logWarnf("[AGW] Failed to connect [%s:%d]: %v", gateway.Ip, gateway.Port, err)
+			lastErr = err
+			continue
+		}
+		logInfof("AGW connect [%s:%d] success, connectionId: %d", gateway.Ip, gateway.Port, connId)
+		if idx != p.active {
+			logWarnf("[AGW] Failed over to the gateway [%s:%d]", gateway.Ip, gateway.Port)
+			p.active = idx
+		}
+		if p.active != 0 && !p.failingBack {
+			p.failingBack = true
+			go p.runFailbackChecker(gateways[0], p.done)
+		}
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+// runFailbackChecker checks the health of the preferred gateway periodically after failing over,
+// and reconnects to it once it is healthy again, until done is closed.
+func (p *ConnectionPool) runFailbackChecker(preferred GatewayAddr, done <-chan struct{}) {
+	defer func() {
+		p.lock.Lock()
+		p.failingBack = false
+		p.lock.Unlock()
+	}()
+	for {
+		if !sleepOrStop(failbackCheckInterval, done) {
+			return
+		}
+		conn, err := p.client.dialConn(preferred.Ip, preferred.Port)
+		if err != nil {
+			logDebugf("[AGW] The preferred gateway [%s:%d] is still unavailable: %v", preferred.Ip, preferred.Port, err)
+			continue
+		}
+		conn.Close()
+		logInfof("[AGW] The preferred gateway [%s:%d] is available again, failing back", preferred.Ip, preferred.Port)
+		p.lock.Lock()
+		p.active = 0
+		p.lock.Unlock()
+		// Close the connections to the backup gateway, which will be reconnected to the preferred one on demand.
+		// The reader coroutines clean the closed connections up.
+		p.pool.Range(func(_, v interface{}) bool {
+			if c, ok := v.(*AgwConn); ok {
+				(*c.conn).Close()
+			}
+			return true
+		})
+		return
+	}
+}
+
+func (c *AgwClient) dialGateway(gateway GatewayAddr) (net.Conn, error) {
+	if !c.config.TlsFlag {
+		return c.dialConn(gateway.Ip, gateway.Port)
+	}
+	if custom := c.config.TlsConfig; custom != nil {
+		conf := custom.Clone()
+		if conf.ServerName == "" {
+			conf.ServerName = gateway.Ip
+		}
+		return c.handshakeTls(gateway.Ip, gateway.Port, conf)
+	}
+	conn, err := c.getTlsConn(gateway.Ip, gateway.Port)
+	// retry once
+	if err != nil {
+		logger.Warnf("[AGW] Get TLS connection err, %v, retry again", err)
+		if err := checkOrDownloadCert(c.config.ClientRegionId, c.config.ClientInVpc); err != nil {
+			return nil, err
+		}
+		conn, err = c.getTlsConn(gateway.Ip, gateway.Port)
+	}
+	return conn, err
+}
+
+// dialConn connects to the gateway with the connect timeout.
+func (c *AgwClient) dialConn(host string, port uint32) (net.Conn, error) {
+	return dialGatewayConn(c.config.Proxy, host, port, connectTimeoutSec*time.Second, c.config.FallbackDelay)
+}
+
+func (c *AgwClient) getTlsConn(gatewayIp string, gatewayPort uint32) (net.Conn, error) {
 	certFile, err := os.OpenFile(CertPath, os.O_RDONLY, 0664)
 	if err != nil {
 		return nil, fmt.Errorf("open cert file failed, %v", err)
@@ -193,25 +291,57 @@ func getTlsConn(gatewayIp string, gatewayPort uint32) (net.Conn, error) {
 		InsecureSkipVerify: true,
 		RootCAs:            certPool,
 	}
-	dialer := &net.Dialer{Timeout: connectTimeoutSec * time.Second}
-	return tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("%s:%d", gatewayIp, gatewayPort), conf)
+	return c.handshakeTls(gatewayIp, gatewayPort, conf)
 }
 
-func (p *ConnectionPool) remove(connId uint32) {
-	p.pool.Delete(connId)
+func (c *AgwClient) handshakeTls(gatewayIp string, gatewayPort uint32, conf *tls.Config) (net.Conn, error) {
+	deadline := time.Now().Add(connectTimeoutSec * time.Second)
+	rawConn, err := c.dialConn(gatewayIp, gatewayPort)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(rawConn, conf)
+	// The handshake shares the connect timeout.
+	if err = conn.SetDeadline(deadline); err == nil {
+		err = conn.Handshake()
+	}
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
 
+// remove removes the connection from the pool, unless it has been replaced by a new one.
+func (p *ConnectionPool) remove(c *AgwConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if v, ok := p.pool.Load(c.connId); ok && v == c {
+		p.pool.Delete(c.connId)
+	}
+}
+
+// StringIpToUint64 converts the IPv4 address to the client IP of the AGW messages, or 0 if it's
+// not an IPv4 address, which the AGW protocol can't carry.
 func StringIpToUint64(ip string) uint64 {
-	ipSegs := strings.Split(ip, ".")
-	var ipUint64 uint64 = 0
-	var pos uint = 24
-	var tmp uint64 = 0
-	for _, ipSeg := range ipSegs {
-		tempInt, _ := strconv.Atoi(ipSeg)
-		tmp = uint64(tempInt)
-		tmp = tmp << pos
-		ipUint64 = ipUint64 | tmp
-		pos -= 8
-	}
-	return ipUint64
+	ip4 := net.ParseIP(ip).To4()
+	if ip4 == nil {
+		return 0
+	}
+	return uint64(ip4[0])<<24 | uint64(ip4[1])<<16 | uint64(ip4[2])<<8 | uint64(ip4[3])
+}
+
+// checkClientIp checks that the client IP is an IPv4 address, as required by the AGW protocol.
+func checkClientIp(ip string) error {
+	if ip == "" {
+		return errors.New("ip can not be blank")
+	}
+	if net.ParseIP(ip).To4() == nil {
+		return fmt.Errorf("ip %s is not an IPv4 address, which is required by the AGW protocol", ip)
+	}
+	return nil
 }
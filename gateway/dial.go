@@ -0,0 +1,137 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultFallbackDelay is the delay before racing the next address when connecting
+// to the gateway, as recommended by RFC 8305 (Happy Eyeballs v2).
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialDualStack connects to the gateway host. When the host resolves to multiple
+// addresses (e.g. both IPv4 and IPv6), the addresses of the two families are tried
+// alternately, and the next attempt starts once the previous one fails or the
+// fallback delay elapses. The first established connection wins.
+// A negative fallback delay disables racing, and the addresses are tried one by one.
+func dialDualStack(host string, port uint32, timeout, fallbackDelay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialContextDualStack(ctx, host, strconv.FormatUint(uint64(port), 10), fallbackDelay)
+	if err != nil {
+		return nil, err
+	}
+	logInfof("[AGW] Connected to gateway %s via %s", host, conn.RemoteAddr())
+	return conn, nil
+}
+
+// DualStackDialContext returns the dial function which races the addresses of the host the
+// same as the gateway connections (see DefaultFallbackDelay), e.g. for the HTTP transport
+// of the ACM client. Only the TCP networks are raced.
+func DualStackDialContext(fallbackDelay time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || network != "tcp" {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}
+		return dialContextDualStack(ctx, host, port, fallbackDelay)
+	}
+}
+
+func dialContextDualStack(ctx context.Context, host, portStr string, fallbackDelay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if ip := net.ParseIP(host); ip != nil {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(host, portStr))
+	}
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := interleaveAddrs(ipAddrs, portStr)
+	if fallbackDelay == 0 {
+		fallbackDelay = DefaultFallbackDelay
+	}
+
+	dialer := &net.Dialer{}
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			c, err := dialer.DialContext(ctx, "tcp", addr)
+			results <- dialResult{conn: c, err: err}
+		}()
+	}
+	start()
+	var firstErr error
+	for {
+		var fallback <-chan time.Time
+		if next < len(addrs) && fallbackDelay > 0 {
+			fallback = time.After(fallbackDelay)
+		}
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// Abort and close the other attempts.
+				cancel()
+				go closeLateConns(results, pending)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				start()
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		case <-fallback:
+			start()
+		}
+	}
+}
+
+func closeLateConns(results <-chan dialResult, pending int) {
+	for i := 0; i < pending; i++ {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
+
+// interleaveAddrs orders the addresses by alternating the address families,
+// starting with the family of the first resolved address.
+func interleaveAddrs(ipAddrs []net.IPAddr, port string) []string {
+	var primary, secondary []string
+	for _, a := range ipAddrs {
+		addr := net.JoinHostPort(a.IP.String(), port)
+		if (a.IP.To4() == nil) == (ipAddrs[0].IP.To4() == nil) {
+			primary = append(primary, addr)
+		} else {
+			secondary = append(secondary, addr)
+		}
+	}
+	result := make([]string, 0, len(ipAddrs))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			result = append(result, primary[i])
+		}
+		if i < len(secondary) {
+			result = append(result, secondary[i])
+		}
+	}
+	return result
+}
@@ -1,13 +1,14 @@
 package gateway
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
-	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
 	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -33,21 +34,47 @@ type AgwConfig struct {
 	// add for tls
 	ClientEnv      string
 	ClientRegionId string
+	ClientInVpc    bool
 	TlsFlag        bool
-	Timeout        time.Duration
+	// TlsConfig is the custom TLS config, which is used rather than the server certificate
+	// downloaded from AHAS if not nil.
+	TlsConfig *tls.Config
+	Timeout   time.Duration
+	// Failovers is the ordered backup gateways, which are connected to if the preferred one
+	// (GatewayIp:GatewayPort) is unavailable, until the preferred one is healthy again.
+	Failovers []GatewayAddr
+	// Proxy resolves the proxy to connect to the gateway. ProxyFromEnvironment is used if nil.
+	Proxy ProxyFunc
+	// FallbackDelay is the delay before racing the next gateway address (Happy Eyeballs).
+	// Zero means DefaultFallbackDelay, and negative disables racing.
+	FallbackDelay time.Duration
+}
+
+// GatewayAddr is the address of an AHAS gateway.
+type GatewayAddr struct {
+	Ip   string
+	Port uint32
 }
 
 type AgwClient struct {
-	config      AgwConfig
+	config AgwConfig
+	// identityMux guards the client IP and process flag, which change if the IP changes.
+	identityMux sync.RWMutex
+	// stateMux guards the initialized state, which is reset by Close so that the client
+	// could be initialized again.
+	stateMux    sync.Mutex
 	initialized bool
-	pool        *ConnectionPool
-	timeout     uint32
+	// stopCh stops the heartbeat coroutine of current initialization.
+	stopCh  chan struct{}
+	pool    *ConnectionPool
+	timeout uint32
+
+	handlerMux sync.RWMutex
+	handlers   map[string]AgwHandler
 }
 
 var instance *AgwClient
 var cLock sync.Mutex
-var initOnce sync.Once
-var handlers = make(map[string]AgwHandler)
 
 func GetAgwClientInstance() *AgwClient {
 
@@ -62,21 +89,30 @@ func GetAgwClientInstance() *AgwClient {
 		return instance
 	}
 
-	instance = &AgwClient{
-		initialized: false,
-		pool:        getConnectionPoolInstance(2),
-	}
+	instance = NewAgwClient()
 
 	return instance
 }
 
+// NewAgwClient creates a client with its own connections and handlers, which is independent
+// of the shared instance (see GetAgwClientInstance).
+func NewAgwClient() *AgwClient {
+	c := &AgwClient{
+		handlers: make(map[string]AgwHandler),
+	}
+	c.pool = newConnectionPool(2, c)
+	return c
+}
+
 func (c *AgwClient) Init(config AgwConfig) error {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
 	if c.initialized {
 		return errors.New("dup init")
 	}
 
-	if config.ClientIp == "" {
-		return errors.New("ip can not be blank")
+	if err := checkClientIp(config.ClientIp); err != nil {
+		return err
 	}
 
 	if config.ClientProcessFlag == "" {
@@ -87,24 +123,71 @@ func (c *AgwClient) Init(config AgwConfig) error {
 		return errors.New("vpcId can not be blank")
 	}
 	// check or download the cert if not exists
-	if config.TlsFlag {
-		err := checkOrDownloadCert()
+	if config.TlsFlag && config.TlsConfig == nil {
+		err := checkOrDownloadCert(config.ClientRegionId, config.ClientInVpc)
 		if err != nil {
 			return err
 		}
 	}
-	initOnce.Do(func() {
-		c.config = config
-		c.timeout = uint32(c.config.Timeout.Milliseconds())
-		c.initialized = true
-		go runHeartBeatCoroutine(c)
-	})
+	c.config = config
+	c.timeout = uint32(c.config.Timeout.Milliseconds())
+	c.stopCh = make(chan struct{})
+	c.initialized = true
+	c.pool.open()
+	go runHeartBeatCoroutine(c, c.stopCh)
 
 	return nil
 }
 
-func (c *AgwClient) Call(outerReqId string, rpcMetadata RpcMetadata, jsonParam string) (string, error) {
+// Close stops the heartbeat and closes the connections to the gateway, which fails the
+// pending calls. The client could be initialized again afterwards.
+func (c *AgwClient) Close() {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
 	if !c.initialized {
+		return
+	}
+	close(c.stopCh)
+	c.pool.closeAll()
+	c.initialized = false
+}
+
+func (c *AgwClient) isInitialized() bool {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
+	return c.initialized
+}
+
+// gateways returns the preferred gateway followed by the backup ones.
+func (c *AgwClient) gateways() []GatewayAddr {
+	gateways := make([]GatewayAddr, 0, len(c.config.Failovers)+1)
+	gateways = append(gateways, GatewayAddr{Ip: c.config.GatewayIp, Port: c.config.GatewayPort})
+	return append(gateways, c.config.Failovers...)
+}
+
+// SetClientIdentity updates the IP and the process flag of the client, e.g. after the IP changes.
+func (c *AgwClient) SetClientIdentity(ip, processFlag string) error {
+	if err := checkClientIp(ip); err != nil {
+		return err
+	}
+	if processFlag == "" {
+		return errors.New("processFlag can not be blank")
+	}
+	c.identityMux.Lock()
+	defer c.identityMux.Unlock()
+	c.config.ClientIp = ip
+	c.config.ClientProcessFlag = processFlag
+	return nil
+}
+
+func (c *AgwClient) clientIdentity() (ip, processFlag string) {
+	c.identityMux.RLock()
+	defer c.identityMux.RUnlock()
+	return c.config.ClientIp, c.config.ClientProcessFlag
+}
+
+func (c *AgwClient) Call(outerReqId string, rpcMetadata RpcMetadata, jsonParam string) (string, error) {
+	if !c.isInitialized() {
 		return "", errors.New("the client has not be initialized")
 	}
 
@@ -112,7 +195,8 @@ func (c *AgwClient) Call(outerReqId string, rpcMetadata RpcMetadata, jsonParam s
 		return "", errors.New("reqId can not be blank")
 	}
 
-	tsUtil := newTimestampUtilV2(outerReqId, c.config.ClientVpcId, c.config.ClientProcessFlag, c.config.ClientIp)
+	clientIp, processFlag := c.clientIdentity()
+	tsUtil := newTimestampUtilV2(outerReqId, c.config.ClientVpcId, processFlag, clientIp)
 	tsUtil.mark("client_call_gateway")
 
 	var response *AgwMessage
@@ -171,15 +255,16 @@ func (c *AgwClient) innerCall(reqId uint64, outerReqId string, rpcMetadata RpcMe
 		return nil, err
 	}
 
+	clientIp, processFlag := c.clientIdentity()
 	msg := NewAgwMessage()
 	msg.SetReqId(reqId)
 	msg.SetMessageType(MessageTypeBiz)
 	msg.SetMessageDirection(MessageDirectionRequest)
-	msg.SetClientIp(StringIpToUint64(c.config.ClientIp))
+	msg.SetClientIp(StringIpToUint64(clientIp))
 	msg.SetClientVpcId(c.config.ClientVpcId)
 	msg.SetServerName(rpcMetadata.ServerName)
 	msg.SetTimeoutMs(c.timeout)
-	msg.SetClientProcessFlag(c.config.ClientProcessFlag)
+	msg.SetClientProcessFlag(processFlag)
 	msg.SetConnectionId(conn.connId)
 	msg.SetHandlerName(rpcMetadata.HandlerName)
 	msg.SetOuterReqId(outerReqId)
@@ -199,19 +284,28 @@ func (c *AgwClient) AddHandler(handlerName string, handler AgwHandler) error {
 	}
 
 	logInfof("Adding handler to AgwClient: %s", handlerName)
-	handlers[handlerName] = handler
+	c.handlerMux.Lock()
+	c.handlers[handlerName] = handler
+	c.handlerMux.Unlock()
 
 	return nil
 }
 
-var CertPath = path.Join(os.TempDir(), ".server.cert")
+func (c *AgwClient) handler(handlerName string) (AgwHandler, bool) {
+	c.handlerMux.RLock()
+	defer c.handlerMux.RUnlock()
+	handler, ok := c.handlers[handlerName]
+	return handler, ok
+}
+
+var CertPath = filepath.Join(os.TempDir(), ".server.cert")
 
-func checkOrDownloadCert() error {
+func checkOrDownloadCert(regionId string, inVpc bool) error {
 	if tools.IsExist(CertPath) {
 		return nil
 	}
 	remoteFilePath := path.Join(tools.Constant.OSAgentRemotePath, "cert", "sChat.pem")
-	err := aliyun.Download(CertPath, meta.RegionId(), remoteFilePath, meta.IsPrivate())
+	err := aliyun.Download(CertPath, regionId, remoteFilePath, inVpc)
 	if err != nil {
 		return fmt.Errorf("download cert failed, %v", err)
 	}
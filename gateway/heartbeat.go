@@ -13,7 +13,8 @@ const (
 	EachLoopSleepMs      = 20000
 )
 
-func runHeartBeatCoroutine(this *AgwClient) {
+// runHeartBeatCoroutine sends the heartbeats on all connections until stopCh is closed.
+func runHeartBeatCoroutine(this *AgwClient, stopCh <-chan struct{}) {
 	if this == nil {
 		logWarn("AgwClient is null, exit heartbeat coroutine")
 		return
@@ -25,19 +26,22 @@ func runHeartBeatCoroutine(this *AgwClient) {
 
 			if err != nil {
 				logWarnf("get connection error:%s", err.Error())
-				time.Sleep(time.Millisecond * ErrorSleepMs)
+				if !sleepOrStop(time.Millisecond*ErrorSleepMs, stopCh) {
+					return
+				}
 				continue
 			}
 
+			clientIp, processFlag := this.clientIdentity()
 			msg := NewAgwMessage()
 			msg.SetReqId(generateId())
 			msg.SetMessageType(MessageTypeHeartbeat)
 			msg.SetMessageDirection(MessageDirectionRequest)
-			msg.SetClientIp(StringIpToUint64(this.config.ClientIp))
+			msg.SetClientIp(StringIpToUint64(clientIp))
 			msg.SetClientVpcId(this.config.ClientVpcId)
 			msg.SetServerName(HeartbeatServerName)
 			msg.SetTimeoutMs(HeartbeatTimeoutMs)
-			msg.SetClientProcessFlag(this.config.ClientProcessFlag)
+			msg.SetClientProcessFlag(processFlag)
 			msg.SetConnectionId(conn.connId)
 			msg.SetHandlerName(HeartbeatHandlerName)
 			msg.SetOuterReqId("noReqIdForHB")
@@ -45,12 +49,28 @@ func runHeartBeatCoroutine(this *AgwClient) {
 
 			err = conn.write(msg)
 			if err != nil {
-				time.Sleep(time.Millisecond * ErrorSleepMs)
+				if !sleepOrStop(time.Millisecond*ErrorSleepMs, stopCh) {
+					return
+				}
 				continue
 			}
 		}
 
-		time.Sleep(time.Millisecond * EachLoopSleepMs)
+		if !sleepOrStop(time.Millisecond*EachLoopSleepMs, stopCh) {
+			return
+		}
 	}
 
 }
+
+// sleepOrStop sleeps for the duration, and returns false if stopped meanwhile.
+func sleepOrStop(d time.Duration, stopCh <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stopCh:
+		return false
+	}
+}
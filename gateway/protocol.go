@@ -194,7 +194,7 @@ func NewAgwMessage() *AgwMessage {
 func (m *AgwMessage) Decode(br *bufio.Reader) error {
 	defer func() {
 		if err := recover(); err != nil {
-			logError("[AgwMessage] Decode recover err: %v, stack: %s", err, debug.Stack())
+			logErrorf("[AgwMessage] Decode recover err: %v, stack: %s", err, debug.Stack())
 		}
 	}()
 
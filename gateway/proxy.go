@@ -0,0 +1,208 @@
+package gateway
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ProxyFunc returns the proxy URL to connect to the gateway address (host:port), or nil for direct connection.
+type ProxyFunc func(addr string) (*url.URL, error)
+
+// ProxyFromEnvironment resolves the proxy from the HTTPS_PROXY and NO_PROXY environment variables.
+func ProxyFromEnvironment(addr string) (*url.URL, error) {
+	return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+}
+
+// dialGatewayConn connects to the gateway directly, or via the proxy if any.
+// ProxyFromEnvironment is used if proxy is nil.
+func dialGatewayConn(proxy ProxyFunc, host string, port uint32, timeout, fallbackDelay time.Duration) (net.Conn, error) {
+	addr := net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+	if proxy == nil {
+		proxy = ProxyFromEnvironment
+	}
+	proxyURL, err := proxy(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the proxy, %v", err)
+	}
+	if proxyURL == nil {
+		return dialDualStack(host, port, timeout, fallbackDelay)
+	}
+	return dialViaProxy(proxyURL, addr, timeout)
+}
+
+// dialViaProxy connects to the address via the HTTP (CONNECT) or SOCKS5 proxy.
+func dialViaProxy(proxyURL *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "443")
+		case "socks5", "socks5h":
+			proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "1080")
+		default:
+			proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "80")
+		}
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the proxy %s, %v", proxyAddr, err)
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	switch proxyURL.Scheme {
+	case "http", "":
+		err = httpConnect(conn, proxyURL, addr)
+	case "https":
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		conn = tlsConn
+		err = httpConnect(conn, proxyURL, addr)
+	case "socks5", "socks5h":
+		err = socks5Connect(conn, proxyURL, addr)
+	default:
+		err = fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+	}
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func httpConnect(conn net.Conn, proxyURL *url.URL, addr string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("failed to send CONNECT to the proxy, %v", err)
+	}
+	// The gateway never speaks first, so nothing is buffered beyond the response.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fmt.Errorf("failed to read CONNECT response of the proxy, %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+func socks5Connect(conn net.Conn, proxyURL *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+	methods := []byte{0x00}
+	if proxyURL.User != nil {
+		methods = append(methods, 0x02)
+	}
+	if _, err = conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("failed to read SOCKS5 greeting, %v", err)
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if err = socks5Authenticate(conn, proxyURL.User); err != nil {
+			return err
+		}
+	default:
+		return errors.New("no acceptable SOCKS5 authentication method")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, 0x01), ip4...)
+		} else {
+			req = append(append(req, 0x04), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("too long host name: %s", host)
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+	head := make([]byte, 4)
+	if _, err = io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("failed to read SOCKS5 reply, %v", err)
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("SOCKS5 proxy refused to connect to %s, code: %d", addr, head[1])
+	}
+	// Skip the bound address and port.
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		l := make([]byte, 1)
+		if _, err = io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		skip = int(l[0]) + 2
+	default:
+		return fmt.Errorf("bad SOCKS5 address type: %d", head[3])
+	}
+	_, err = io.CopyN(ioutil.Discard, conn, int64(skip))
+	return err
+}
+
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	name := user.Username()
+	password, _ := user.Password()
+	if len(name) > 255 || len(password) > 255 {
+		return errors.New("too long SOCKS5 username or password")
+	}
+	req := []byte{0x01, byte(len(name))}
+	req = append(append(req, name...), byte(len(password)))
+	req = append(req, password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("failed to read SOCKS5 authentication reply, %v", err)
+	}
+	if reply[1] != 0x00 {
+		return errors.New("SOCKS5 authentication failed")
+	}
+	return nil
+}
@@ -40,7 +40,7 @@ func runReaderCoroutine(conn *AgwConn) {
 			msg.SetMessageDirection(MessageDirectionResponse)
 			go conn.write(msg)
 		} else {
-			logWarnf("AGW unknown msg, type : %d, direction : %d, msg:%", msg.MessageType(), msg.MessageDirection(), msg)
+			logWarnf("AGW unknown msg, type : %d, direction : %d, msg: %+v", msg.MessageType(), msg.MessageDirection(), msg)
 		}
 
 	}
@@ -53,7 +53,7 @@ func handleRequest(msg *AgwMessage, conn *AgwConn) {
 	tsUtil.mark("gateway_call_client")
 
 	handlerName := msg.HandlerName()
-	handler, ok := handlers[handlerName]
+	handler, ok := conn.pool.client.handler(handlerName)
 	if !ok {
 		logWarnf("AGW cannot get client handler by handlerName:%s, reqId:%d, outerReqId:%s",
 			handlerName, msg.ReqId(), msg.OuterReqId())
@@ -75,7 +75,7 @@ func handleRequest(msg *AgwMessage, conn *AgwConn) {
 	tsUtil.mark("after_handle")
 
 	if err != nil {
-		logWarnf("AGW executing client handler wrong, reqId:%d, outerReqId:%s", msg.ReqId(), msg.OuterReqId(), err.Error())
+		logWarnf("AGW executing client handler wrong, reqId:%d, outerReqId:%s, err: %s", msg.ReqId(), msg.OuterReqId(), err.Error())
 
 		msg.SetInnerCode(8035)
 		msg.SetInnerMsg(fmt.Sprintf("executing client handler wrong : %s", err.Error()))
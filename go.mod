@@ -6,6 +6,7 @@ require (
 	github.com/alibaba/sentinel-golang v0.6.0
 	github.com/buger/jsonparser v0.0.0-20191204142016-1a29609e0929 // indirect
 	github.com/golang/mock v1.4.0 // indirect
+	github.com/klauspost/compress v1.11.4
 	github.com/nacos-group/nacos-sdk-go v1.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/satori/go.uuid v1.2.1-0.20181028125025-b2ce2384e17b
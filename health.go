@@ -0,0 +1,163 @@
+package ahas
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/console"
+	"github.com/aliyun/aliyun-ahas-go-sdk/health"
+	"github.com/aliyun/aliyun-ahas-go-sdk/heartbeat"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+func init() {
+	console.RegisterCommand("health", "health", func(_ []string) (string, error) {
+		bs, err := json.MarshalIndent(Health(), "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(bs), nil
+	})
+}
+
+const (
+	Healthy   = health.Healthy
+	Degraded  = health.Degraded
+	Unhealthy = health.Unhealthy
+)
+
+// SetAppHealth reports the health status of the application, which is carried in
+// heartbeats and may gate the console-initiated actions (see health.Config).
+func SetAppHealth(status health.Status, reason string) {
+	health.Set(status, reason)
+}
+
+// SdkHealth is the status of the AHAS SDK itself, e.g. for the liveness/readiness probes.
+type SdkHealth struct {
+	// Started indicates whether AHAS has been started.
+	Started bool `json:"started"`
+	// Starting indicates whether AHAS is being started in the background, see Config.AsyncStartup.
+	Starting bool `json:"starting"`
+	// StartupError is the error of the latest failed startup attempt in the background, if any.
+	StartupError string `json:"startupError,omitempty"`
+	// Degraded indicates whether AHAS failed to start and only the local rules are in effect,
+	// see Config.DegradeOnFailure.
+	Degraded bool `json:"degraded"`
+	// ProtectionDisabled indicates whether all the rules are switched off, see SetProtectionEnabled.
+	ProtectionDisabled bool `json:"protectionDisabled"`
+	// Connection is the state of the connection to AHAS.
+	Connection string `json:"connection"`
+	// License is the latest known status of the license, see LicenseStatus.
+	License string `json:"license"`
+	// LicenseMessage is the message of the server if the license is rejected.
+	LicenseMessage string `json:"licenseMessage,omitempty"`
+	// LastHeartbeatMs is the timestamp of the latest successful heartbeat, 0 if none.
+	LastHeartbeatMs int64 `json:"lastHeartbeatMs"`
+	// DeliveryMode is the delivery mode of the rules.
+	DeliveryMode string `json:"deliveryMode"`
+	// Subscriptions is the status of the ACM subscriptions of the rule dataIds.
+	Subscriptions []datasource.SubscriptionStatus `json:"subscriptions"`
+	// LastRuleUpdateMs is the timestamp of the latest successfully applied rules, 0 if none.
+	LastRuleUpdateMs uint64 `json:"lastRuleUpdateMs"`
+
+	connected bool
+}
+
+// Ready indicates whether the protection is actually active, i.e. AHAS is connected and
+// all rule dataIds have been subscribed (in ACM delivery mode).
+func (h SdkHealth) Ready() bool {
+	if h.DeliveryMode == offlineDeliveryMode {
+		return h.Started
+	}
+	if !h.Started || !h.connected {
+		return false
+	}
+	if h.DeliveryMode == datasource.PushDeliveryMode {
+		return true
+	}
+	if len(h.Subscriptions) == 0 {
+		return false
+	}
+	for _, s := range h.Subscriptions {
+		if !s.Subscribed {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	transportMux    = &sync.RWMutex{}
+	activeTransport *transport.Transport
+)
+
+func setActiveTransport(t *transport.Transport) {
+	transportMux.Lock()
+	defer transportMux.Unlock()
+	activeTransport = t
+}
+
+// Health returns the status of the AHAS SDK.
+func Health() SdkHealth {
+	transportMux.RLock()
+	t := activeTransport
+	transportMux.RUnlock()
+
+	h := SdkHealth{
+		Started:          t != nil,
+		Connection:       transport.StateDisconnected.String(),
+		LastHeartbeatMs:  heartbeat.LastSuccessMs(),
+		DeliveryMode:     ruleDeliveryMode(),
+		LastRuleUpdateMs: datasource.LastRuleAppliedMs(),
+
+		ProtectionDisabled: !datasource.ProtectionEnabled(),
+	}
+	h.Starting, h.StartupError, h.Degraded = startupStatus()
+	license, licenseMessage := transport.CurrentLicenseStatus()
+	h.License, h.LicenseMessage = license.String(), licenseMessage
+	if t != nil {
+		state := t.State()
+		h.Connection = state.String()
+		h.connected = state == transport.StateConnected
+	}
+	if h.DeliveryMode == offlineDeliveryMode {
+		h.Started = isRunning()
+	}
+	if h.DeliveryMode == datasource.AcmDeliveryMode {
+		h.Subscriptions = datasource.Subscriptions()
+	}
+	return h
+}
+
+// transportConnectedGauge is the exported gauge of the connection state, see transportConnected.
+const transportConnectedGauge = "ahas_transport_connected"
+
+func transportConnected() float64 {
+	transportMux.RLock()
+	t := activeTransport
+	transportMux.RUnlock()
+	if t != nil && t.State() == transport.StateConnected {
+		return 1
+	}
+	return 0
+}
+
+// offlineDeliveryMode means only the local rules are applied, see Config.Offline.
+const offlineDeliveryMode = "offline"
+
+func ruleDeliveryMode() string {
+	if config.Offline() {
+		return offlineDeliveryMode
+	}
+	return deliveryModeOf(config.DataSourceConfig())
+}
+
+// deliveryModeOf returns the mode of delivering the rules with the data-source config.
+func deliveryModeOf(conf datasource.Config) string {
+	if conf.Mode != "" {
+		return conf.Mode
+	}
+	return datasource.AcmDeliveryMode
+}
@@ -0,0 +1,119 @@
+// Package health keeps the health status reported by the application. The status is
+// carried in heartbeats, and could gate the actions initiated from the AHAS console
+// (e.g. chaos experiments and rule pushes) while the application is not healthy.
+package health
+
+import (
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/pkg/errors"
+)
+
+type Status int32
+
+const (
+	Healthy Status = iota
+	Degraded
+	Unhealthy
+)
+
+func (s Status) String() string {
+	switch s {
+	case Healthy:
+		return "Healthy"
+	case Degraded:
+		return "Degraded"
+	case Unhealthy:
+		return "Unhealthy"
+	default:
+		return "Undefined"
+	}
+}
+
+type Config struct {
+	// RefuseActionsWhenDegraded indicates whether to refuse the actions initiated from
+	// the AHAS console (e.g. chaos experiments) while the application is not healthy.
+	RefuseActionsWhenDegraded bool `yaml:"refuseActionsWhenDegraded"`
+	// DeferRulePushesWhenDegraded indicates whether to defer applying the pushed rules
+	// until the application recovers.
+	DeferRulePushesWhenDegraded bool `yaml:"deferRulePushesWhenDegraded"`
+}
+
+// Snapshot is the current health status with the reason.
+type Snapshot struct {
+	Status Status `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// SinceMs is the timestamp when the status was set.
+	SinceMs uint64 `json:"sinceMs"`
+}
+
+// Listener is notified when the health status changes.
+type Listener func(prev, cur Snapshot)
+
+var (
+	mux       = &sync.RWMutex{}
+	conf      Config
+	current   = Snapshot{Status: Healthy}
+	listeners = make([]Listener, 0)
+)
+
+// Configure sets the gating policies.
+func Configure(c Config) {
+	mux.Lock()
+	defer mux.Unlock()
+	conf = c
+}
+
+// Set sets the health status of the application.
+func Set(status Status, reason string) {
+	mux.Lock()
+	prev := current
+	current = Snapshot{Status: status, Reason: reason, SinceMs: util.CurrentTimeMillis()}
+	cur := current
+	ls := make([]Listener, len(listeners))
+	copy(ls, listeners)
+	mux.Unlock()
+
+	if prev.Status == cur.Status {
+		return
+	}
+	for _, l := range ls {
+		l(prev, cur)
+	}
+}
+
+// Current returns the current health status.
+func Current() Snapshot {
+	mux.RLock()
+	defer mux.RUnlock()
+	return current
+}
+
+// AddListener adds the listener of health status changes.
+func AddListener(l Listener) {
+	if l == nil {
+		return
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	listeners = append(listeners, l)
+}
+
+// CheckAction returns an error if the console-initiated action should be refused
+// under current health status.
+func CheckAction(action string) error {
+	mux.RLock()
+	defer mux.RUnlock()
+	if !conf.RefuseActionsWhenDegraded || current.Status == Healthy {
+		return nil
+	}
+	return errors.Errorf("action <%s> refused as the application is %s: %s", action, current.Status, current.Reason)
+}
+
+// ShouldDeferRulePush reports whether the pushed rules should be deferred under current health status.
+func ShouldDeferRulePush() bool {
+	mux.RLock()
+	defer mux.RUnlock()
+	return conf.DeferRulePushesWhenDegraded && current.Status != Healthy
+}
@@ -0,0 +1,60 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+// extensionsParam is the heartbeat param carrying the extensions as a JSON object.
+const extensionsParam = "ext"
+
+// ExtensionProvider provides the value of a heartbeat extension, which must be JSON-serializable.
+type ExtensionProvider func() (interface{}, error)
+
+var (
+	extensionMux = &sync.RWMutex{}
+	extensions   = make(map[string]ExtensionProvider)
+)
+
+// RegisterExtension registers the provider of a key/value extension carried in each heartbeat
+// (e.g. the loaded rule versions or the runtime stats). Unlike the params, the extensions are
+// namespaced in a single param, so they never override the built-in params. An existing
+// extension with the same key will be replaced.
+func RegisterExtension(key string, provider ExtensionProvider) {
+	extensionMux.Lock()
+	defer extensionMux.Unlock()
+	extensions[key] = provider
+}
+
+// UnregisterExtension removes the extension of the key.
+func UnregisterExtension(key string) {
+	extensionMux.Lock()
+	defer extensionMux.Unlock()
+	delete(extensions, key)
+}
+
+func fillExtensions(request *transport.Request) {
+	extensionMux.RLock()
+	defer extensionMux.RUnlock()
+	if len(extensions) == 0 {
+		return
+	}
+	values := make(map[string]interface{}, len(extensions))
+	for key, provider := range extensions {
+		value, err := provider()
+		if err != nil {
+			logger.Warnf("Failed to provide heartbeat extension %s: %+v", key, err)
+			continue
+		}
+		values[key] = value
+	}
+	bs, err := json.Marshal(values)
+	if err != nil {
+		logger.Warnf("Failed to encode heartbeat extensions: %+v", err)
+		return
+	}
+	request.Params[extensionsParam] = string(bs)
+}
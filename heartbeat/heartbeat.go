@@ -1,24 +1,90 @@
 package heartbeat
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/util"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
-	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
 	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
 	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
-	"time"
+	"github.com/pkg/errors"
 )
 
 const (
 	DefaultPeriodMs uint64 = 5000
+	// DefaultServerlessPeriodMs is the default heartbeat period in serverless runtimes, which is
+	// shorter, as the instances are short-lived and might be frozen between the invocations.
+	DefaultServerlessPeriodMs uint64 = 2000
 )
 
 type Config struct {
 	PeriodMs uint64 `yaml:"period"`
+	// ServerlessPeriodMs is the heartbeat period in serverless runtimes, which takes precedence
+	// over PeriodMs. DefaultServerlessPeriodMs will be used if absent.
+	ServerlessPeriodMs uint64 `yaml:"serverlessPeriod"`
+	// ReportRuleMetrics indicates whether to carry the rule-load metrics in the heartbeat.
+	ReportRuleMetrics bool `yaml:"reportRuleMetrics"`
+	// Compress indicates whether to gzip the heartbeat payloads, e.g. for bandwidth-constrained
+	// edge deployments. It takes effect only if the gateway supports it, which is negotiated on registration.
+	Compress bool `yaml:"compress"`
+}
+
+// ParamProvider provides the value of an extra heartbeat param.
+type ParamProvider func() (string, error)
+
+// ErrOmitParam is returned by the ParamProvider to leave the param out of the heartbeat.
+var ErrOmitParam = errors.New("heartbeat param omitted")
+
+var (
+	providerMux    = &sync.RWMutex{}
+	paramProviders = make(map[string]ParamProvider)
+)
+
+// RegisterParamProvider registers the provider of an extra param carried in each heartbeat.
+func RegisterParamProvider(key string, provider ParamProvider) {
+	providerMux.Lock()
+	defer providerMux.Unlock()
+	paramProviders[key] = provider
 }
 
+// UnregisterParamProvider removes the provider of the param.
+func UnregisterParamProvider(key string) {
+	providerMux.Lock()
+	defer providerMux.Unlock()
+	delete(paramProviders, key)
+}
+
+func fillProvidedParams(request *transport.Request, providers map[string]ParamProvider) {
+	for key, provider := range providers {
+		value, err := provider()
+		if err == ErrOmitParam {
+			continue
+		}
+		if err != nil {
+			logger.Warnf("Failed to provide heartbeat param %s: %+v", key, err)
+			continue
+		}
+		request.Params[key] = value
+	}
+}
+
+// disconnectThreshold is the count of consecutive heartbeat failures to consider the connection dropped.
+const disconnectThreshold = 3
+
 type heartbeat struct {
-	period time.Duration
+	period   time.Duration
+	compress bool
+	stopCh   chan struct{}
 	*transport.Transport
+
+	// providers are the params of this heartbeat only, which take precedence over the global ones.
+	providerMux sync.RWMutex
+	providers   map[string]ParamProvider
+
+	// failures is the count of consecutive failures, only accessed in the heartbeat goroutine.
+	failures int
 }
 
 // New heartbeat
@@ -26,28 +92,76 @@ func New(config Config, trans *transport.Transport) *heartbeat {
 	if config.PeriodMs == 0 {
 		config.PeriodMs = DefaultPeriodMs
 	}
+	if trans.Metadata().Serverless() != nil {
+		config.PeriodMs = config.ServerlessPeriodMs
+		if config.PeriodMs == 0 {
+			config.PeriodMs = DefaultServerlessPeriodMs
+		}
+	}
 	handler := &GetPingHandler().AgwRequestHandler
 	trans.RegisterHandler(transport.Ping, handler)
 	return &heartbeat{
 		period:    time.Duration(config.PeriodMs) * time.Millisecond,
+		compress:  config.Compress,
+		stopCh:    make(chan struct{}),
 		Transport: trans,
+		providers: make(map[string]ParamProvider),
 	}
 }
 
+// RegisterParamProvider registers the provider of an extra param carried in the heartbeats
+// of this heartbeat service only, e.g. of an ahas.Client.
+func (beat *heartbeat) RegisterParamProvider(key string, provider ParamProvider) *heartbeat {
+	beat.providerMux.Lock()
+	defer beat.providerMux.Unlock()
+	beat.providers[key] = provider
+	return beat
+}
+
+func (beat *heartbeat) fillParams(request *transport.Request) {
+	providerMux.RLock()
+	fillProvidedParams(request, paramProviders)
+	providerMux.RUnlock()
+	beat.providerMux.RLock()
+	fillProvidedParams(request, beat.providers)
+	beat.providerMux.RUnlock()
+}
+
 //Start heartbeat service
 func (beat *heartbeat) Start() *heartbeat {
 	ticker := time.NewTicker(beat.period)
 	go func() {
 		defer tools.PrintPanicStack()
-		for range ticker.C {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-beat.stopCh:
+				return
+			}
+			// The heartbeats resume once the transport reconnects.
+			if beat.State() != transport.StateConnected {
+				continue
+			}
 			uri := transport.NewUri(transport.Topology, transport.Heartbeat)
+			if beat.compress && beat.Supports(transport.CapabilityRequestCompress) {
+				uri.CompressVersion = transport.RequestCompress
+			}
 			request := transport.NewRequest()
+			beat.fillParams(request)
+			fillExtensions(request)
 			beat.sendHeartbeat(uri, request)
 		}
 	}()
+	m := beat.Metadata()
 	logger.Infof("AGW heartbeat service started successfully, cid: %s, ver: %s, vpcId: %s",
-		meta.Cid(), meta.CurrentVersion(), meta.VpcId())
-	return nil
+		m.Cid(), m.Version(), m.VpcId())
+	return beat
+}
+
+// Stop stops the heartbeat service. It should be called only once.
+func (beat *heartbeat) Stop() {
+	close(beat.stopCh)
 }
 
 // sendHeartbeat
@@ -56,8 +170,13 @@ func (beat *heartbeat) sendHeartbeat(uri transport.Uri, request *transport.Reque
 	if err != nil {
 		logger.Warnf("Send heartbeat failed: %s", err.Error())
 		beat.record(false)
+		if beat.failures >= disconnectThreshold {
+			beat.failures = 0
+			beat.Disconnected(err)
+		}
 		return
 	}
+	beat.ObserveLicense(response)
 	if !response.Success {
 		logger.Errorf("AGW heartbeat bad response: %+v", response)
 		beat.record(false)
@@ -67,10 +186,41 @@ func (beat *heartbeat) sendHeartbeat(uri transport.Uri, request *transport.Reque
 }
 
 func (beat *heartbeat) record(success bool) {
-	// TODO: record snapshot of heartbeat result.
+	if success {
+		beat.failures = 0
+	} else {
+		beat.failures++
+	}
+	if beat.Isolated() {
+		return
+	}
+	now := util.CurrentTimeMillis()
+	lastResult.Store(HBSnapshot{Timestamp: int64(now), Success: success})
+	if success {
+		atomic.StoreInt64(&lastSuccessMs, int64(now))
+	}
 }
 
+// HBSnapshot is the result of a heartbeat.
 type HBSnapshot struct {
 	Timestamp int64
 	Success   bool
 }
+
+var (
+	lastResult    atomic.Value
+	lastSuccessMs int64
+)
+
+// LastResult returns the result of the latest heartbeat over the process-wide transport (i.e. not
+// isolated, see transport.WithIsolatedState), and false if no heartbeat has been sent.
+func LastResult() (HBSnapshot, bool) {
+	s, ok := lastResult.Load().(HBSnapshot)
+	return s, ok
+}
+
+// LastSuccessMs returns the timestamp of the latest successful heartbeat over the process-wide
+// transport, 0 if none.
+func LastSuccessMs() int64 {
+	return atomic.LoadInt64(&lastSuccessMs)
+}
@@ -0,0 +1,15 @@
+package ahas
+
+import "github.com/aliyun/aliyun-ahas-go-sdk/heartbeat"
+
+// RegisterHeartbeatExtension registers the provider of a key/value extension carried in each
+// heartbeat to AHAS, e.g. the build version or custom runtime stats. The value must be
+// JSON-serializable. An existing extension with the same key will be replaced.
+func RegisterHeartbeatExtension(key string, provider func() (interface{}, error)) {
+	heartbeat.RegisterExtension(key, provider)
+}
+
+// UnregisterHeartbeatExtension removes the heartbeat extension of the key.
+func UnregisterHeartbeatExtension(key string) {
+	heartbeat.UnregisterExtension(key)
+}
@@ -1,93 +1,586 @@
 package ahas
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 
 	sentinel "github.com/alibaba/sentinel-golang/api"
 	"github.com/alibaba/sentinel-golang/logging"
 	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+	"github.com/aliyun/aliyun-ahas-go-sdk/chaos"
 	"github.com/aliyun/aliyun-ahas-go-sdk/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/console"
+	"github.com/aliyun/aliyun-ahas-go-sdk/exporter"
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/aliyun/aliyun-ahas-go-sdk/health"
 	"github.com/aliyun/aliyun-ahas-go-sdk/heartbeat"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
 	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/notifier"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/blocklog"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/breaker"
 	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
 	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/handler"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/hotparam"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/metriclog"
 	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/aliyun/aliyun-ahas-go-sdk/topology"
 	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
 	"github.com/pkg/errors"
 )
 
+// Config is the unified config of AHAS, see config.Config for the details of each field.
+type Config = config.Config
+
+// NewDefaultConfig returns the default config, on which at least the license should be set.
+func NewDefaultConfig() *Config {
+	return config.NewDefaultConfig()
+}
+
+// Init initializes Sentinel and AHAS with the config file (resolved from the system env
+// if absent), and then the options override the loaded config, e.g.:
+//
+//	ahas.Init(ahas.WithLicense(license), ahas.WithNamespace("prod-ns"))
+//
+// Init without options is the same as InitAhasDefault. In fail-fast mode, it will panic
+// on failure if PanicOnFailure is enabled.
+func Init(opts ...Option) error {
+	return InitWithContext(context.Background(), opts...)
+}
+
+// InitWithContext initializes AHAS like Init. The context bounds the initialization (e.g. the
+// metadata resolution, the registration and the rule subscription in fail-fast mode), and once
+// it is done before the initialization completes, the started subsystems are stopped in the
+// background. It doesn't bound the lifetime of AHAS, which lasts until Shutdown.
+func InitWithContext(ctx context.Context, opts ...Option) error {
+	return panicOnFailure(initAhas(ctx, newInitOptions(opts)))
+}
+
+// InitWithConfig initializes Sentinel and AHAS with the given config, without reading the
+// AHAS config file or system env. Sentinel is initialized first, then the metadata of current
+// instance is resolved, the transport is registered to AHAS, and finally the rules are subscribed.
+// A nil config means the default config. In fail-fast mode, it will panic on failure if
+// PanicOnFailure is enabled.
+func InitWithConfig(c *Config) error {
+	return InitWithConfigContext(context.Background(), c)
+}
+
+// InitWithConfigContext is the context-aware version of InitWithConfig, see InitWithContext.
+func InitWithConfigContext(ctx context.Context, c *Config) error {
+	return panicOnFailure(initAhasWithConfig(ctx, c))
+}
+
+// Shutdown gracefully stops AHAS started by the Init functions, e.g. on the termination of
+// the pod: the pending metrics and block logs are flushed first, then the heartbeat, the rule
+// subscriptions, the transport and the other subsystems are stopped. The context bounds the
+// flushing, after which the subsystems are stopped anyway.
+func Shutdown(ctx context.Context) error {
+	initMux.Lock()
+	shutdown := initShutdown
+	initShutdown = nil
+	initMux.Unlock()
+	if shutdown == nil {
+		return nil
+	}
+	return shutdown(ctx)
+}
+
 func InitAhasDefault() error {
 	return InitAhasFromFile("")
 }
 
-func InitAhasFromFile(filename string) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			var ok bool
-			err, ok = r.(error)
-			if !ok {
-				err = fmt.Errorf("%v", r)
-			}
+// InitAhasFromFile initializes AHAS with the given config file. In fail-fast mode,
+// it will panic on failure if PanicOnFailure is enabled.
+func InitAhasFromFile(filename string) error {
+	return Init(WithConfigFile(filename))
+}
+
+func panicOnFailure(err error) error {
+	if err != nil && config.PanicOnFailure() {
+		panic(err)
+	}
+	return err
+}
+
+// recoverInitError converts the panic during the initialization into the error.
+func recoverInitError(err *error) {
+	if r := recover(); r != nil {
+		var ok bool
+		*err, ok = r.(error)
+		if !ok {
+			*err = fmt.Errorf("%v", r)
 		}
-	}()
-	if err = sentinel.InitWithConfigFile(filename); err != nil {
+	}
+}
+
+func initAhasWithConfig(ctx context.Context, c *Config) (err error) {
+	defer recoverInitError(&err)
+	if err = sentinel.InitDefault(); err != nil {
 		return err
 	}
 	if err = logger.InitLoggerDefault(); err != nil {
 		return err
 	}
-	if err = config.InitConfigFromFile(filename); err != nil {
+	if err = config.SetConfig(c); err != nil {
 		return err
 	}
-	var m *meta.Meta
-	m, err = meta.InitMetadata(config.License(), config.Namespace(),
-		config.DeployEnv(), config.TransportConfig().Secure)
+	if err = logger.InitFileLogger(config.LogFileConfig()); err != nil {
+		return err
+	}
+	return startInitAhas(ctx)
+}
+
+func initAhas(ctx context.Context, o *initOptions) (err error) {
+	defer recoverInitError(&err)
+	if err = sentinel.InitWithConfigFile(o.configFile); err != nil {
+		return err
+	}
+	if err = logger.InitLoggerDefault(); err != nil {
+		return err
+	}
+	if err = config.InitConfigFromFile(o.configFile); err != nil {
+		return err
+	}
+	if len(o.overrides) > 0 {
+		if err = config.Update(o.apply); err != nil {
+			return err
+		}
+	}
+	// Reopen the log file with the rotation settings of the config.
+	if err = logger.InitFileLogger(config.LogFileConfig()); err != nil {
+		return err
+	}
+	if o.logger != nil {
+		logger.UseLogger(o.logger)
+	}
+	return startInitAhas(ctx)
+}
+
+var (
+	initMux = &sync.Mutex{}
+	// initShutdown stops AHAS started by the Init functions, which is nil if not started.
+	initShutdown func(ctx context.Context) error
+)
+
+// startInitAhas starts AHAS for the Init functions, which runs until Shutdown.
+func startInitAhas(ctx context.Context) error {
+	if config.AsyncStartup() {
+		return startAhasAsync(nil)
+	}
+	shutdown, err := startAhasWithContext(ctx, context.Background())
 	if err != nil {
+		if config.DegradeOnFailure() && ctx.Err() == nil {
+			return startAhasAsync(err)
+		}
 		return err
 	}
+	initMux.Lock()
+	initShutdown = shutdown
+	initMux.Unlock()
+	return nil
+}
+
+// startAhasWithContext starts AHAS like startAhas, which lasts until the runCtx is done or
+// the returned function is called. The startup is aborted once the startCtx is done before
+// it completes, and then the subsystems started meanwhile are stopped in the background.
+func startAhasWithContext(startCtx, runCtx context.Context) (shutdown func(ctx context.Context) error, err error) {
+	runCtx, cancel := context.WithCancel(runCtx)
+	type result struct {
+		shutdown func(ctx context.Context) error
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		defer func() {
+			done <- r
+		}()
+		defer recoverInitError(&r.err)
+		r.shutdown, r.err = startAhas(runCtx)
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			cancel()
+			return nil, r.err
+		}
+		return func(ctx context.Context) error {
+			defer cancel()
+			return r.shutdown(ctx)
+		}, nil
+	case <-startCtx.Done():
+		cancel()
+		go func() {
+			defer tools.PrintPanicStackV2("aborted AHAS startup")
+			if r := <-done; r.err == nil {
+				_ = r.shutdown(context.Background())
+			}
+		}()
+		return nil, errors.Wrap(startCtx.Err(), "AHAS startup aborted")
+	}
+}
+
+var (
+	runningMux = &sync.Mutex{}
+	running    bool
+)
+
+// startAhas starts all AHAS subsystems with the loaded config, and returns the function
+// to flush the pending data (bounded by its context) and stop them. AHAS can only be started
+// once at a time in a process, as the state of Sentinel (and thus AHAS) is process-global.
+func startAhas(ctx context.Context) (stop func(ctx context.Context) error, err error) {
+	runningMux.Lock()
+	defer runningMux.Unlock()
+	if running {
+		return nil, errors.New("AHAS has already been started in current process")
+	}
+
+	feature.SetConfigured(config.Features())
+	health.Configure(config.HealthConfig())
+	chaos.Configure(config.ChaosConfig())
+	if config.Offline() {
+		return startOffline(), nil
+	}
+	meta.SetNetworkConfig(config.NetworkConfig())
+	aliyun.SetMetadataConfig(config.MetadataConfig())
+	if err = aliyun.SetCredentialsConfig(config.CredentialsConfig()); err != nil {
+		return nil, err
+	}
+	if cloud := config.CloudConfig(); cloud.Provider != "" {
+		if err = meta.SetCloudConfig(cloud); err != nil {
+			return nil, err
+		}
+	}
+	if tags := config.Tags(); len(tags) > 0 {
+		meta.SetTags(tags)
+	}
+	conf := config.Current()
+	var m *meta.Meta
+	m, err = meta.InitMetadata(conf.License, conf.Namespace, conf.Env, conf.Transport.Secure, metaOptions(conf)...)
+	if err != nil {
+		return nil, err
+	}
 
 	aliyunChannel := aliyun.GetInstance()
 	if err = aliyunChannel.Start(); err != nil {
-		return err
+		return nil, err
 	}
 
-	tools.InitConstant(config.DeployEnv(), m.RegionId())
+	tools.InitConstant(m.DeployEnv(), m.RegionId())
 
-	acmHost, ok := aliyun.GetAcmEndpoint(m.RegionId())
-	if !ok {
-		return errors.New("no available ACM endpoint for region: " + m.RegionId())
+	pushMode := config.DataSourceConfig().Mode == datasource.PushDeliveryMode
+	acmHost, ok := m.AcmHost()
+	if !ok && !pushMode {
+		return nil, errors.New("no available ACM endpoint for region: " + m.RegionId())
 	}
 
 	// Initialize AHAS transport module.
-	tc := config.TransportConfig()
 	var tsp *transport.Transport
-	if tsp, err = transport.New(&tc, m); err != nil {
-		return err
-	}
-	if tsp, err = tsp.Start(); err != nil {
-		return err
+	if tsp, err = startTransport(conf, m, defaultTransportOptions...); err != nil {
+		return nil, err
 	}
 	registerTransportHandlers(tsp)
+	setActiveTransport(tsp)
+	if err = console.Start(config.ConsoleConfig()); err != nil {
+		logger.Warnf("Failed to start AHAS debug console: %+v", err)
+	}
+	exporter.RegisterGauge(transportConnectedGauge, "Whether AHAS is connected.", transportConnected)
+	if err = exporter.Start(config.ExporterConfig()); err != nil {
+		logger.Warnf("Failed to start AHAS metric exporter: %+v", err)
+	}
+	blocklog.StartShipper(config.BlockLogShipConfig(), tsp)
+	breaker.StartReporter(tsp)
+	hotparam.StartReporter(config.HotParamConfig(), tsp)
+	topology.StartReporter(config.TopologyConfig(), tsp)
+	if err = notifier.Start(config.NotifierConfig()); err != nil {
+		logger.Warnf("Failed to start AHAS notifier: %+v", err)
+	}
+	if err = metriclog.StartUploader(config.MetricUploadConfig(), tsp); err != nil {
+		logger.Warnf("Failed to start AHAS metric uploader: %+v", err)
+	}
 	// Initialize heartbeat task.
-	heartbeat.New(config.HeartbeatConfig(), tsp).Start()
+	beat := heartbeat.New(config.HeartbeatConfig(), tsp)
+	for key, provider := range instanceParams(conf, tsp) {
+		beat.RegisterParamProvider(key, provider)
+	}
+	beat.RegisterParamProvider(tagsParam, tagsProvider)
+	if config.HeartbeatConfig().ReportRuleMetrics {
+		beat.RegisterParamProvider(ruleMetricsParam, ruleMetricsProvider)
+	}
+	beat.RegisterParamProvider(appHealthParam, appHealthProvider)
+	if config.ChaosConfig().Enabled {
+		beat.RegisterParamProvider(chaosParam, chaosProvider)
+	}
+	beat.Start()
+	startIpRefresher(m, tsp)
+
+	// teardown stops all the started subsystems, both on stopping and on the failures below.
+	teardown := func() {
+		chaos.StopAll()
+		if err := datasource.Close(); err != nil {
+			logger.Warnf("Failed to close ACM data source: %+v", err)
+		}
+		m.StopIpRefresher()
+		beat.Stop()
+		blocklog.StopShipper()
+		breaker.StopReporter()
+		hotparam.StopReporter()
+		topology.StopReporter()
+		notifier.Stop()
+		metriclog.StopUploader()
+		if err := console.Stop(); err != nil {
+			logger.Warnf("Failed to stop AHAS debug console: %+v", err)
+		}
+		if err := exporter.Stop(); err != nil {
+			logger.Warnf("Failed to stop AHAS metric exporter: %+v", err)
+		}
+		if err := tsp.Shutdown(); err != nil {
+			logger.Warnf("Failed to shutdown AHAS transport: %+v", err)
+		}
+		setActiveTransport(nil)
+	}
+	stop = func(ctx context.Context) error {
+		flushErr := flushPending(ctx)
+		teardown()
+		runningMux.Lock()
+		running = false
+		runningMux.Unlock()
+		return flushErr
+	}
+	if pushMode {
+		if err = datasource.InitPush(ctx, config.DataSourceConfig()); err != nil {
+			teardown()
+			return nil, errors.Wrap(err, "failed to initialize push data source")
+		}
+		pushHandler := transport.NewCommonHandler(&handler.PushRulesHandler{})
+		tsp.RegisterHandler(handler.PushRulesCommandName, &pushHandler)
+		if !tsp.Supports(transport.CapabilityRulePush) {
+			logger.Warn("The AHAS server doesn't support pushing the rules, only the local rules are applied until it does")
+		}
+	} else if config.FailFast() {
+		// The data-source is a critical subsystem, so wait for it in fail-fast mode.
+		if err = datasource.InitAcmWithContext(ctx, acmHost, config.DataSourceConfig(), m); err != nil {
+			teardown()
+			return nil, errors.Wrap(err, "failed to initialize ACM data source")
+		}
+	} else {
+		go initializeAcmDataSource(ctx, acmHost, m)
+	}
+	running = true
+	return stop, nil
+}
 
-	go initializeAcmDataSource(acmHost, m)
+// startOffline applies the local rules (the snapshots and the environment variables) only,
+// without any network calls to AHAS. It must be called with the runningMux held.
+func startOffline() (stop func(ctx context.Context) error) {
+	datasource.LoadLocalRules(config.DataSourceConfig())
+	hotparam.StartReporter(config.HotParamConfig(), nil)
+	if err := console.Start(config.ConsoleConfig()); err != nil {
+		logger.Warnf("Failed to start AHAS debug console: %+v", err)
+	}
+	if err := notifier.Start(config.NotifierConfig()); err != nil {
+		logger.Warnf("Failed to start AHAS notifier: %+v", err)
+	}
+	running = true
+	logger.Info("AHAS started in offline mode, only the local rules are applied")
+	return func(ctx context.Context) error {
+		chaos.StopAll()
+		hotparam.StopReporter()
+		notifier.Stop()
+		if err := console.Stop(); err != nil {
+			logger.Warnf("Failed to stop AHAS debug console: %+v", err)
+		}
+		runningMux.Lock()
+		running = false
+		runningMux.Unlock()
+		return nil
+	}
+}
+
+func isRunning() bool {
+	runningMux.Lock()
+	defer runningMux.Unlock()
+	return running
+}
 
+// flushPending uploads the pending metrics and block logs before stopping.
+func flushPending(ctx context.Context) error {
+	if err := metriclog.FlushUploader(ctx); err != nil {
+		return errors.Wrap(err, "failed to flush metrics")
+	}
+	if err := blocklog.FlushShipper(ctx); err != nil {
+		return errors.Wrap(err, "failed to flush block logs")
+	}
 	return nil
 }
 
-func initializeAcmDataSource(acmHost string, m *meta.Meta) {
+func initializeAcmDataSource(ctx context.Context, acmHost string, m *meta.Meta) {
 	defer tools.PrintPanicStackV2("failed to init ACM data-source")
-	err := datasource.InitAcm(acmHost, config.DataSourceConfig(), m)
+	err := datasource.InitAcmWithContext(ctx, acmHost, config.DataSourceConfig(), m)
 	if err != nil {
-		logging.Errorf("Failed to initialize ACM data source: %+v")
+		logging.Errorf("Failed to initialize ACM data source: %+v", err)
 	}
 }
 
+const (
+	// ruleMetricsParam is the heartbeat param carrying the rule-load metrics.
+	ruleMetricsParam = "ruleMetrics"
+	// appHealthParam is the heartbeat param carrying the health status reported by the application.
+	appHealthParam = "appHealth"
+	// ruleDeliveryParam is the heartbeat param telling the console how to deliver the rules, which
+	// is carried only if the server acknowledges transport.CapabilityRulePush.
+	ruleDeliveryParam = "ruleDelivery"
+	// ipv6Param is the heartbeat param carrying the additional IPv6 address in dual-stack IP policy.
+	ipv6Param = "ipv6"
+	// kubernetesParam is the heartbeat param carrying the pod metadata.
+	kubernetesParam = "k8s"
+	// containerIdParam is the heartbeat param carrying the container ID.
+	containerIdParam = "containerId"
+	// tagsParam is the heartbeat param carrying the custom tags.
+	tagsParam = "tags"
+	// serverlessParam is the heartbeat param carrying the metadata of the serverless runtime.
+	serverlessParam = "serverless"
+	// chaosParam is the heartbeat param registering the process to AHAS Chaos with the chaos status.
+	chaosParam = "chaos"
+)
+
+func ruleMetricsProvider() (string, error) {
+	bs, err := json.Marshal(datasource.RuleMetrics())
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func appHealthProvider() (string, error) {
+	bs, err := json.Marshal(health.Current())
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func chaosProvider() (string, error) {
+	bs, err := json.Marshal(chaos.CurrentStatus())
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func tagsProvider() (string, error) {
+	return jsonParam(meta.Tags())()
+}
+
+// jsonParam provides the param of the value encoded as JSON.
+func jsonParam(v interface{}) heartbeat.ParamProvider {
+	return func() (string, error) {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(bs), nil
+	}
+}
+
+// instanceParams returns the heartbeat params describing the instance registered by the
+// transport, which are carried by the heartbeats of both InitAhas* and each Client.
+func instanceParams(conf *config.Config, tsp *transport.Transport) map[string]heartbeat.ParamProvider {
+	m := tsp.Metadata()
+	mode := deliveryModeOf(conf.DataSource)
+	params := map[string]heartbeat.ParamProvider{
+		// The delivery mode is carried only if the server supports pushing the rules,
+		// as the other servers deliver the rules by ACM anyway.
+		ruleDeliveryParam: func() (string, error) {
+			if !tsp.Supports(transport.CapabilityRulePush) {
+				return "", heartbeat.ErrOmitParam
+			}
+			return mode, nil
+		},
+	}
+	if k8s := m.Kubernetes(); k8s != nil {
+		params[kubernetesParam] = jsonParam(k8s)
+	}
+	if serverless := m.Serverless(); serverless != nil {
+		params[serverlessParam] = jsonParam(serverless)
+	}
+	if containerId := m.ContainerId(); containerId != "" {
+		params[containerIdParam] = func() (string, error) {
+			return containerId, nil
+		}
+	}
+	if conf.Network.IpPolicy == meta.DualStack {
+		params[ipv6Param] = func() (string, error) {
+			return m.Ipv6(), nil
+		}
+	}
+	return params
+}
+
+// metaOptions returns the options of the metadata with the config.
+func metaOptions(conf *config.Config) []meta.Option {
+	opts := []meta.Option{meta.WithEndpoints(conf.Endpoints...), meta.WithAppName(conf.AppName)}
+	if conf.DiscoverNamespaceFromKubernetes {
+		opts = append(opts, meta.WithKubernetesNamespace())
+	}
+	return opts
+}
+
+// defaultTransportOptions are the options of the transport started by InitAhas*.
+var defaultTransportOptions []transport.Option
+
+// startTransport creates the transport with the metadata and registers to AHAS. The transport
+// is shut down if the registration fails, so that the startup could be retried.
+func startTransport(conf *config.Config, m *meta.Meta, opts ...transport.Option) (*transport.Transport, error) {
+	tc := conf.Transport
+	tsp, err := transport.New(&tc, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tsp.Start(); err != nil {
+		_ = tsp.Shutdown()
+		return nil, err
+	}
+	return tsp, nil
+}
+
+// startIpRefresher registers to AHAS again once the IP of the metadata changes.
+func startIpRefresher(m *meta.Meta, tsp *transport.Transport) {
+	m.StartIpRefresher(func() {
+		if err := tsp.Reconnect(); err != nil {
+			logger.Warnf("Failed to register to AHAS again after the IP changed: %+v", err)
+		}
+	})
+}
+
 func registerTransportHandlers(tsp *transport.Transport) {
 	cnHandler := transport.NewCommonHandler(&handler.ResourceNodeHandler{})
 	tsp.RegisterHandler(handler.GetResourceNodeCommandName, &cnHandler)
-	metricHandler := transport.NewCommonHandler(handler.NewFetchMetricHandler())
+	metricHandler := transport.NewCommonHandler(handler.NewFetchMetricHandlerWithEncoding(config.TransportConfig().Encoding))
 	tsp.RegisterHandler(handler.FetchMetricCommandName, &metricHandler)
+	featureHandler := transport.NewCommonHandler(&handler.HealthGuardedHandler{
+		Action:  handler.SetFeatureCommandName,
+		Handler: &handler.SetFeatureHandler{},
+	})
+	tsp.RegisterHandler(handler.SetFeatureCommandName, &featureHandler)
+	trustedOriginsHandler := transport.NewCommonHandler(&handler.SetTrustedOriginsHandler{})
+	tsp.RegisterHandler(handler.SetTrustedOriginsCommandName, &trustedOriginsHandler)
+	rulesHandler := transport.NewCommonHandler(&handler.FetchRulesHandler{})
+	tsp.RegisterHandler(handler.FetchRulesCommandName, &rulesHandler)
+	logLevelHandler := transport.NewCommonHandler(&handler.SetLogLevelHandler{})
+	tsp.RegisterHandler(handler.SetLogLevelCommandName, &logLevelHandler)
+	startChaosHandler := transport.NewCommonHandler(&handler.HealthGuardedHandler{
+		Action:  handler.StartChaosExperimentCommandName,
+		Handler: &handler.StartChaosExperimentHandler{},
+	})
+	tsp.RegisterHandler(handler.StartChaosExperimentCommandName, &startChaosHandler)
+	stopChaosHandler := transport.NewCommonHandler(&handler.StopChaosExperimentHandler{})
+	tsp.RegisterHandler(handler.StopChaosExperimentCommandName, &stopChaosHandler)
+	protectionHandler := transport.NewCommonHandler(&handler.SetProtectionHandler{})
+	tsp.RegisterHandler(handler.SetProtectionCommandName, &protectionHandler)
+	chaosStatusHandler := transport.NewCommonHandler(&handler.GetChaosStatusHandler{})
+	tsp.RegisterHandler(handler.GetChaosStatusCommandName, &chaosStatusHandler)
+	registerCustomCommands(tsp)
 }
@@ -0,0 +1,26 @@
+package ahas
+
+import "github.com/aliyun/aliyun-ahas-go-sdk/transport"
+
+// LicenseStatus is the status of the license (and the AHAS service of the account).
+type LicenseStatus = transport.LicenseStatus
+
+// LicenseError is returned by Init (or reported by Health in the async startup) when the
+// registration is rejected for the license, which could be told by errors.As.
+type LicenseError = transport.LicenseError
+
+const (
+	LicenseUnknown          = transport.LicenseUnknown
+	LicenseValid            = transport.LicenseValid
+	LicenseInvalid          = transport.LicenseInvalid
+	LicenseExpired          = transport.LicenseExpired
+	LicenseOverQuota        = transport.LicenseOverQuota
+	LicenseServiceNotOpened = transport.LicenseServiceNotOpened
+)
+
+// OnLicenseStatusChange registers the listener of the license status changes, e.g. to alert the
+// owners when the license expires, after which the rules are no longer updated. The listener is
+// called synchronously, so it should return quickly.
+func OnLicenseStatusChange(listener func(status LicenseStatus, message string)) {
+	transport.RegisterLicenseListener(listener)
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Field is a key/value pair of the structured logs.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F creates the field of the structured logs.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger is the structured logger of the SDK, which could be implemented with the logger
+// of the application (e.g. zap, logrus or slog), so that the SDK logs land in its pipeline.
+// The levels below the global Sentinel log level are filtered before calling the Logger.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// loggerHolder wraps the Logger, as atomic.Value can't store nil or different concrete types.
+type loggerHolder struct {
+	l Logger
+}
+
+var current atomic.Value
+
+// UseLogger replaces the AHAS logger with the given implementation. A nil logger disables
+// the AHAS logs.
+func UseLogger(l Logger) {
+	current.Store(loggerHolder{l: l})
+}
+
+func currentLogger() Logger {
+	h, _ := current.Load().(loggerHolder)
+	return h.l
+}
+
+// NewZapLogger adapts the zap logger to the Logger.
+func NewZapLogger(l *zap.Logger) Logger {
+	return &zapLogger{l: l}
+}
+
+type zapLogger struct {
+	l *zap.Logger
+}
+
+func zapFields(fields []Field) []zap.Field {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make([]zap.Field, 0, len(fields))
+	for _, f := range fields {
+		result = append(result, zap.Any(f.Key, f.Value))
+	}
+	return result
+}
+
+func (z *zapLogger) Debug(msg string, fields ...Field) {
+	z.l.Debug(msg, zapFields(fields)...)
+}
+
+func (z *zapLogger) Info(msg string, fields ...Field) {
+	z.l.Info(msg, zapFields(fields)...)
+}
+
+func (z *zapLogger) Warn(msg string, fields ...Field) {
+	z.l.Warn(msg, zapFields(fields)...)
+}
+
+func (z *zapLogger) Error(msg string, fields ...Field) {
+	z.l.Error(msg, zapFields(fields)...)
+}
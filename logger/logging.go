@@ -1,9 +1,12 @@
 package logger
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/alibaba/sentinel-golang/core/config"
 	"github.com/alibaba/sentinel-golang/logging"
@@ -14,10 +17,36 @@ import (
 
 const (
 	AhasLogFile = "ahas.log"
+
+	DefaultMaxSizeMb  = 20
+	DefaultMaxBackups = 3
+	DefaultMaxAgeDays = 7
 )
 
+// FileConfig is the rotation and retention settings of the AHAS log file.
+type FileConfig struct {
+	// Dir is the directory of the log file. The log directory of Sentinel will be used if absent.
+	Dir string `yaml:"dir"`
+	// MaxSizeMb is the maximum size in megabytes of the log file before it gets rotated.
+	// DefaultMaxSizeMb will be used if absent.
+	MaxSizeMb int `yaml:"maxSizeMb"`
+	// MaxBackups is the maximum count of the rotated files to retain. DefaultMaxBackups will be
+	// used if absent, and negative means retaining all files (still subject to MaxAgeDays).
+	MaxBackups int `yaml:"maxBackups"`
+	// MaxAgeDays is the maximum days to retain the rotated files. DefaultMaxAgeDays will be used
+	// if absent, and negative means no age limit.
+	MaxAgeDays int `yaml:"maxAgeDays"`
+	// Compress indicates whether to gzip the rotated files.
+	Compress bool `yaml:"compress"`
+}
+
 var (
-	ahasLogger *zap.Logger
+	// level is the level of the AHAS log file, which could be changed at runtime by SetLevel.
+	level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	fileMux = &sync.Mutex{}
+	// fileWriter is the writer of current log file, which is closed when replaced.
+	fileWriter *lumberjack.Logger
 )
 
 func init() {
@@ -26,7 +55,7 @@ func init() {
 		log.Fatalf("can't initialize zap logger: %v", err)
 	}
 	defer l.Sync()
-	ahasLogger = l
+	SetLogger(l)
 }
 
 func toZapLevel(level logging.Level) zapcore.Level {
@@ -49,30 +78,105 @@ func toZapLevel(level logging.Level) zapcore.Level {
 }
 
 func InitLoggerDefault() error {
-	logDir := config.LogBaseDir()
+	return InitFileLogger(FileConfig{})
+}
+
+// InitFileLogger makes the AHAS logs written to the ahas.log file, which is rotated by size
+// and retained by count and age. The previous log file (if any) is closed.
+func InitFileLogger(conf FileConfig) error {
+	logDir := conf.Dir
+	if logDir == "" {
+		logDir = config.LogBaseDir()
+	}
 	if logDir == "" {
 		return nil
 	}
 	logDir = addSeparatorIfNeeded(logDir)
 	path := logDir + AhasLogFile
 
-	w := zapcore.AddSync(&lumberjack.Logger{
+	w := &lumberjack.Logger{
 		Filename:   path,
-		MaxSize:    20, // megabytes
-		MaxBackups: 3,
-		MaxAge:     7, // days
-	})
+		MaxSize:    positiveOr(conf.MaxSizeMb, DefaultMaxSizeMb),
+		MaxBackups: retention(conf.MaxBackups, DefaultMaxBackups),
+		MaxAge:     retention(conf.MaxAgeDays, DefaultMaxAgeDays),
+		Compress:   conf.Compress,
+	}
+	level.SetLevel(toZapLevel(logging.GetGlobalLoggerLevel()))
 	core := zapcore.NewCore(
 		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
-		w,
-		toZapLevel(logging.GetGlobalLoggerLevel()),
+		zapcore.AddSync(w),
+		level,
 	)
-	logger := zap.New(core)
-	ahasLogger = logger
+	SetLogger(zap.New(core))
 
+	fileMux.Lock()
+	previous := fileWriter
+	fileWriter = w
+	fileMux.Unlock()
+	if previous != nil {
+		return previous.Close()
+	}
 	return nil
 }
 
+func positiveOr(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// retention resolves the retention limit, where 0 means the default and negative means no limit,
+// while lumberjack takes 0 as no limit.
+func retention(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// SetLogger replaces the AHAS logger, e.g. with the logger of the host framework.
+// A nil logger disables the AHAS logs. See UseLogger for other logger implementations.
+func SetLogger(l *zap.Logger) {
+	if l == nil {
+		UseLogger(nil)
+		return
+	}
+	UseLogger(NewZapLogger(l))
+}
+
+var levelNames = map[logging.Level]string{
+	logging.DebugLevel: "debug",
+	logging.InfoLevel:  "info",
+	logging.WarnLevel:  "warn",
+	logging.ErrorLevel: "error",
+}
+
+// SetLevel changes the level of the AHAS and Sentinel logs at runtime, which is one of
+// debug, info, warn and error. The level of the logger set by SetLogger is not affected.
+func SetLevel(name string) error {
+	for l, n := range levelNames {
+		if n == strings.ToLower(name) {
+			logging.SetGlobalLoggerLevel(l)
+			level.SetLevel(toZapLevel(l))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level: %s", name)
+}
+
+// Level returns the name of current log level.
+func Level() string {
+	current := logging.GetGlobalLoggerLevel()
+	if name, ok := levelNames[current]; ok {
+		return name
+	}
+	return strconv.Itoa(int(current))
+}
+
 func addSeparatorIfNeeded(path string) string {
 	s := string(os.PathSeparator)
 	if !strings.HasSuffix(path, s) {
@@ -81,86 +185,113 @@ func addSeparatorIfNeeded(path string) string {
 	return path
 }
 
+// enabled returns the logger if the level is enabled, or nil otherwise.
+func enabled(l logging.Level) Logger {
+	if l < logging.GetGlobalLoggerLevel() {
+		return nil
+	}
+	return currentLogger()
+}
+
 func Debug(v ...interface{}) {
-	if logging.DebugLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.DebugLevel); l != nil {
+		l.Debug(fmt.Sprint(v...))
 	}
-	ahasLogger.Sugar().Debug(v...)
 }
 
 func Debugf(format string, v ...interface{}) {
-	if logging.DebugLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.DebugLevel); l != nil {
+		l.Debug(fmt.Sprintf(format, v...))
+	}
+}
+
+// Debugw logs the message with the structured fields.
+func Debugw(msg string, fields ...Field) {
+	if l := enabled(logging.DebugLevel); l != nil {
+		l.Debug(msg, fields...)
 	}
-	ahasLogger.Sugar().Debugf(format, v...)
 }
 
 func Info(v ...interface{}) {
-	if logging.InfoLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.InfoLevel); l != nil {
+		l.Info(fmt.Sprint(v...))
 	}
-	ahasLogger.Sugar().Info(v...)
 }
 
 func Infof(format string, v ...interface{}) {
-	if logging.InfoLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.InfoLevel); l != nil {
+		l.Info(fmt.Sprintf(format, v...))
+	}
+}
+
+// Infow logs the message with the structured fields.
+func Infow(msg string, fields ...Field) {
+	if l := enabled(logging.InfoLevel); l != nil {
+		l.Info(msg, fields...)
 	}
-	ahasLogger.Sugar().Infof(format, v...)
 }
 
 func Warn(v ...interface{}) {
-	if logging.WarnLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.WarnLevel); l != nil {
+		l.Warn(fmt.Sprint(v...))
 	}
-	ahasLogger.Sugar().Warn(v...)
 }
 
 func Warnf(format string, v ...interface{}) {
-	if logging.WarnLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.WarnLevel); l != nil {
+		l.Warn(fmt.Sprintf(format, v...))
+	}
+}
+
+// Warnw logs the message with the structured fields.
+func Warnw(msg string, fields ...Field) {
+	if l := enabled(logging.WarnLevel); l != nil {
+		l.Warn(msg, fields...)
 	}
-	ahasLogger.Sugar().Warnf(format, v...)
 }
 
 func Error(v ...interface{}) {
-	if logging.ErrorLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.ErrorLevel); l != nil {
+		l.Error(fmt.Sprint(v...))
 	}
-	ahasLogger.Sugar().Error(v...)
 }
 
 func Errorf(format string, v ...interface{}) {
-	if logging.ErrorLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.ErrorLevel); l != nil {
+		l.Error(fmt.Sprintf(format, v...))
+	}
+}
+
+// Errorw logs the message with the structured fields.
+func Errorw(msg string, fields ...Field) {
+	if l := enabled(logging.ErrorLevel); l != nil {
+		l.Error(msg, fields...)
 	}
-	ahasLogger.Sugar().Errorf(format, v...)
 }
 
+// Fatal logs the message at error level, and then exits the process if the level is enabled.
 func Fatal(v ...interface{}) {
-	if logging.FatalLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.FatalLevel); l != nil {
+		l.Error(fmt.Sprint(v...))
+		os.Exit(1)
 	}
-	ahasLogger.Sugar().Fatal(v...)
 }
 
+// Fatalf logs the message at error level, and then exits the process if the level is enabled.
 func Fatalf(format string, v ...interface{}) {
-	if logging.FatalLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
-	}
-	ahasLogger.Sugar().Fatalf(format, v...)
+	Fatal(fmt.Sprintf(format, v...))
 }
 
+// Panic logs the message at error level, and then panics if the level is enabled.
 func Panic(v ...interface{}) {
-	if logging.PanicLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
+	if l := enabled(logging.PanicLevel); l != nil {
+		msg := fmt.Sprint(v...)
+		l.Error(msg)
+		panic(msg)
 	}
-	ahasLogger.Sugar().Panic(v...)
 }
 
+// Panicf logs the message at error level, and then panics if the level is enabled.
 func Panicf(format string, v ...interface{}) {
-	if logging.PanicLevel < logging.GetGlobalLoggerLevel() || ahasLogger == nil {
-		return
-	}
-	ahasLogger.Sugar().Panicf(format, v...)
+	Panic(fmt.Sprintf(format, v...))
 }
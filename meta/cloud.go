@@ -0,0 +1,226 @@
+package meta
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+	"github.com/pkg/errors"
+)
+
+// Names of the built-in cloud providers.
+const (
+	EcsProviderName     = "ecs"
+	LicenseProviderName = "license"
+	StaticProviderName  = "static"
+)
+
+// CloudEnv is the environment of current instance resolved by a CloudProvider.
+type CloudEnv struct {
+	// InVpc indicates whether the instance registers by the uid (in VPC), rather than the license.
+	InVpc      bool
+	RegionId   string
+	VpcId      string
+	Uid        string
+	Ip         string
+	Ipv6       string
+	HostName   string
+	InstanceId string
+}
+
+// CloudProvider resolves the environment of current instance, which is used to register to AHAS.
+type CloudProvider interface {
+	// Name returns the name of the provider.
+	Name() string
+	// Resolve resolves the environment. The license is empty if absent in the config.
+	Resolve(license string) (*CloudEnv, error)
+}
+
+// IpResolver is optionally implemented by a CloudProvider to resolve the reported IP cheaply
+// in the IP refresher. Otherwise, the whole environment is resolved again.
+type IpResolver interface {
+	ResolveIp() (ip string, ipv6 string, err error)
+}
+
+// CloudConfig is the config of the cloud provider.
+type CloudConfig struct {
+	// Provider is the name of the built-in provider: EcsProviderName, LicenseProviderName or
+	// StaticProviderName. If absent, the ECS provider is used without a license, and the
+	// license provider is used otherwise.
+	Provider string `yaml:"provider"`
+	// Static is the explicitly configured environment for StaticProviderName.
+	Static StaticProvider `yaml:"static"`
+}
+
+var (
+	cloudMux      = &sync.RWMutex{}
+	cloudProvider CloudProvider
+)
+
+// SetCloudConfig sets the built-in cloud provider by the config, which should be called before InitMetadata.
+func SetCloudConfig(c CloudConfig) error {
+	var p CloudProvider
+	switch name := strings.ToLower(strings.TrimSpace(c.Provider)); name {
+	case "":
+	case EcsProviderName:
+		p = EcsProvider{}
+	case LicenseProviderName:
+		p = LicenseProvider{}
+	case StaticProviderName:
+		static := c.Static
+		p = &static
+	default:
+		return errors.Errorf("unknown cloud provider: %s", c.Provider)
+	}
+	SetCloudProvider(p)
+	return nil
+}
+
+// SetCloudProvider sets the (user-supplied) cloud provider, which should be called before InitMetadata.
+// Nil means choosing the built-in provider automatically, see CloudConfig.
+func SetCloudProvider(p CloudProvider) {
+	cloudMux.Lock()
+	defer cloudMux.Unlock()
+	cloudProvider = p
+}
+
+func currentCloudProvider(license string) CloudProvider {
+	cloudMux.RLock()
+	p := cloudProvider
+	cloudMux.RUnlock()
+	if p != nil {
+		return p
+	}
+	if license == "" {
+		return EcsProvider{}
+	}
+	return LicenseProvider{}
+}
+
+// EcsProvider resolves the environment from the metadata of the Alibaba Cloud ECS instance.
+type EcsProvider struct{}
+
+func (EcsProvider) Name() string {
+	return EcsProviderName
+}
+
+func (EcsProvider) Resolve(license string) (*CloudEnv, error) {
+	vpcEcs, err := aliyun.RetrieveVpcMetadata()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find AHAS license, and the instance metadata is unavailable")
+	}
+	ip, err := resolveVpcIp(vpcEcs.Ip)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudEnv{
+		InVpc:      true,
+		RegionId:   vpcEcs.RegionId,
+		VpcId:      vpcEcs.VpcId,
+		Uid:        vpcEcs.Uid,
+		Ip:         ip,
+		HostName:   vpcEcs.HostName,
+		InstanceId: vpcEcs.InstanceId,
+	}, nil
+}
+
+func (EcsProvider) ResolveIp() (string, string, error) {
+	if ip, err := explicitHostIp(); ip != "" || err != nil {
+		return ip, "", err
+	}
+	ip, err := aliyun.GetPrivateIpv4()
+	return ip, "", err
+}
+
+// resolveVpcIp returns the IP specified via HostIpEnvKey if any, or the given IP from the ECS metadata.
+func resolveVpcIp(metadataIp string) (string, error) {
+	ip, err := explicitHostIp()
+	if ip != "" || err != nil {
+		return ip, err
+	}
+	return metadataIp, nil
+}
+
+// LicenseProvider resolves the environment of the instances outside Alibaba Cloud VPC (e.g. on-premises),
+// which register by the license, and get the uid from the server.
+type LicenseProvider struct{}
+
+func (LicenseProvider) Name() string {
+	return LicenseProviderName
+}
+
+func (LicenseProvider) Resolve(license string) (*CloudEnv, error) {
+	if license == "" {
+		return nil, errors.New("AHAS license is required outside Alibaba Cloud VPC")
+	}
+	ip, ipv6, err := resolvePrivateIp()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot resolve private IP")
+	}
+	hostName := resolveHostName()
+	return &CloudEnv{
+		RegionId:   aliyun.CnPublic,
+		VpcId:      license,
+		Ip:         ip,
+		Ipv6:       ipv6,
+		HostName:   hostName,
+		InstanceId: hostName,
+	}, nil
+}
+
+func (LicenseProvider) ResolveIp() (string, string, error) {
+	return resolvePrivateIp()
+}
+
+// StaticProvider resolves the environment from the explicit config, e.g. for the hybrid-cloud
+// instances which could not access the ECS metadata but register by the uid. It falls back
+// to the license mode if the uid is absent.
+type StaticProvider struct {
+	RegionId string `yaml:"regionId"`
+	VpcId    string `yaml:"vpcId"`
+	Uid      string `yaml:"uid"`
+	// InstanceId is the ID of current instance, which is the hostname if absent.
+	InstanceId string `yaml:"instanceId"`
+}
+
+func (p *StaticProvider) Name() string {
+	return StaticProviderName
+}
+
+func (p *StaticProvider) Resolve(license string) (*CloudEnv, error) {
+	if p.Uid == "" {
+		env, err := LicenseProvider{}.Resolve(license)
+		if err != nil {
+			return nil, errors.Wrap(err, "either uid or license is required by the static cloud provider")
+		}
+		if p.InstanceId != "" {
+			env.InstanceId = p.InstanceId
+		}
+		return env, nil
+	}
+	if p.RegionId == "" || p.VpcId == "" {
+		return nil, errors.New("regionId and vpcId are required by the static cloud provider with uid")
+	}
+	ip, ipv6, err := resolvePrivateIp()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot resolve private IP")
+	}
+	env := &CloudEnv{
+		InVpc:      true,
+		RegionId:   p.RegionId,
+		VpcId:      p.VpcId,
+		Uid:        p.Uid,
+		Ip:         ip,
+		Ipv6:       ipv6,
+		HostName:   resolveHostName(),
+		InstanceId: p.InstanceId,
+	}
+	if env.InstanceId == "" {
+		env.InstanceId = env.HostName
+	}
+	return env, nil
+}
+
+func (p *StaticProvider) ResolveIp() (string, string, error) {
+	return resolvePrivateIp()
+}
@@ -0,0 +1,39 @@
+package meta
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+)
+
+// containerIdPattern matches the container ID (64 hex characters) in the cgroup paths of the
+// common runtimes, e.g. /docker/<id>, /kubepods/.../<id>, docker-<id>.scope, cri-containerd-<id>.scope
+// and crio-<id>.scope.
+var containerIdPattern = regexp.MustCompile(`(?:^|[/\-:])([0-9a-f]{64})(?:\.scope)?(?:/|$)`)
+
+// mountContainerIdPattern matches the container ID in the mount sources of /proc/self/mountinfo,
+// which is the fallback for cgroup v2 with cgroup namespace, where /proc/self/cgroup is "0::/".
+var mountContainerIdPattern = regexp.MustCompile(`/containers/([0-9a-f]{64})/`)
+
+// resolveContainerId resolves the ID of current container, or returns empty if not in a container.
+func resolveContainerId() string {
+	if id := scanFirstMatch("/proc/self/cgroup", containerIdPattern); id != "" {
+		return id
+	}
+	return scanFirstMatch("/proc/self/mountinfo", mountContainerIdPattern)
+}
+
+func scanFirstMatch(path string, pattern *regexp.Regexp) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if m := pattern.FindStringSubmatch(scanner.Text()); len(m) > 1 {
+			return m[1]
+		}
+	}
+	return ""
+}
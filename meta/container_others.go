@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package meta
+
+// resolveContainerId returns empty, as the container ID is only resolved from cgroup on Linux.
+func resolveContainerId() string {
+	return ""
+}
@@ -1,46 +1,94 @@
 package meta
 
+import (
+	"context"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+)
+
+// Meta is the metadata of current instance. The fields resolved on creation are immutable,
+// and the ones assigned by the server (uid, tid, cid) or refreshed in the background (ip, ipv6)
+// are guarded by mux, so a Meta could be shared among goroutines safely.
 type Meta struct {
 	license   string
 	namespace string
 	deployEnv string
 
-	inVpc        bool
-	regionId     string
-	vpcId        string
-	ip           string
-	hostName     string
-	pid          string
-	cid          string
-	tid          string
-	instanceId   string
-	uid          string
-	version      string
-	ahasEndpoint string
-
-	tidChan chan string
+	inVpc      bool
+	regionId   string
+	vpcId      string
+	hostName   string
+	pid        string
+	instanceId string
+	version    string
+	// ahasEndpoints is the AHAS gateway endpoints in the order of preference.
+	ahasEndpoints []string
+	// acmHost is the explicitly specified host of the ACM address server, if any.
+	acmHost    string
+	kubernetes *KubernetesInfo
+	// containerId is the ID of current container, which differs from cid (assigned by AHAS).
+	containerId string
+	serverless  *ServerlessInfo
+	// provider is the cloud provider which resolved the environment.
+	provider CloudProvider
+	// appName is the name of the app registered to AHAS, empty for the Sentinel app.
+	appName string
+
+	mux  sync.RWMutex
+	ip   string
+	ipv6 string
+	uid  string
+	cid  string
+	tid  string
+
+	// tidReady is closed once the tid is assigned by the server for the first time.
+	tidReady chan struct{}
+	tidOnce  sync.Once
+
+	// refresherStopCh stops the IP refresher, which is nil if the refresher is not running.
+	refresherMux    sync.Mutex
+	refresherStopCh chan struct{}
 
 	debugging bool
 }
 
-func (m *Meta) TidChan() chan string {
-	return m.tidChan
+// WaitForTid waits until the tid is assigned by the server (on registration) and returns it.
+// It returns immediately if the tid has already been assigned, so any number of subsystems
+// could wait for the registration at any time.
+func (m *Meta) WaitForTid(ctx context.Context) (string, error) {
+	select {
+	case <-m.tidReady:
+		return m.Tid(), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
 }
 
 func (m *Meta) SetUid(uid string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
 	m.uid = uid
 }
 
 func (m *Meta) SetTid(tid string) {
+	m.mux.Lock()
 	m.tid = tid
-	m.tidChan <- tid
+	m.mux.Unlock()
+	m.tidOnce.Do(func() {
+		close(m.tidReady)
+	})
 }
 
 func (m *Meta) SetCid(cid string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
 	m.cid = cid
 }
 
 func (m *Meta) Cid() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
 	return m.cid
 }
 
@@ -53,10 +101,14 @@ func (m *Meta) InstanceId() string {
 }
 
 func (m *Meta) Tid() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
 	return m.tid
 }
 
 func (m *Meta) Uid() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
 	return m.uid
 }
 
@@ -64,8 +116,26 @@ func (m *Meta) Pid() string {
 	return m.pid
 }
 
+// AhasEndpoint returns the preferred AHAS gateway endpoint.
 func (m *Meta) AhasEndpoint() string {
-	return m.ahasEndpoint
+	if len(m.ahasEndpoints) == 0 {
+		return ""
+	}
+	return m.ahasEndpoints[0]
+}
+
+// AcmHost returns the host of the ACM address server, which is the explicitly specified one,
+// or the built-in one of the region.
+func (m *Meta) AcmHost() (string, bool) {
+	if m.acmHost != "" {
+		return m.acmHost, true
+	}
+	return aliyun.GetAcmEndpoint(m.regionId)
+}
+
+// AhasEndpoints returns the AHAS gateway endpoints in the order of preference.
+func (m *Meta) AhasEndpoints() []string {
+	return append([]string(nil), m.ahasEndpoints...)
 }
 
 func (m *Meta) HostName() string {
@@ -73,9 +143,25 @@ func (m *Meta) HostName() string {
 }
 
 func (m *Meta) Ip() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
 	return m.ip
 }
 
+// Ipv6 returns the additionally reported IPv6 address in DualStack policy.
+func (m *Meta) Ipv6() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.ipv6
+}
+
+func (m *Meta) setIp(ip, ipv6 string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.ip = ip
+	m.ipv6 = ipv6
+}
+
 func (m *Meta) VpcId() string {
 	return m.vpcId
 }
@@ -87,3 +173,55 @@ func (m *Meta) RegionId() string {
 func (m *Meta) InVpc() bool {
 	return m.inVpc
 }
+
+func (m *Meta) License() string {
+	return m.license
+}
+
+func (m *Meta) Namespace() string {
+	return m.namespace
+}
+
+func (m *Meta) DeployEnv() string {
+	return m.deployEnv
+}
+
+// AppName returns the name of the app registered to AHAS, or empty for the Sentinel app, see WithAppName.
+func (m *Meta) AppName() string {
+	return m.appName
+}
+
+// Kubernetes returns the metadata of the pod, or nil if not running in Kubernetes.
+func (m *Meta) Kubernetes() *KubernetesInfo {
+	return m.kubernetes
+}
+
+// ContainerId returns the ID of current container resolved from cgroup, or empty if not in a container.
+func (m *Meta) ContainerId() string {
+	return m.containerId
+}
+
+// Serverless returns the metadata of the serverless runtime, or nil if not running in a serverless runtime.
+func (m *Meta) Serverless() *ServerlessInfo {
+	return m.serverless
+}
+
+// InstanceType returns Container if running in a container, or Host otherwise.
+func (m *Meta) InstanceType() int {
+	if m.containerId != "" {
+		return Container
+	}
+	return Host
+}
+
+// CloudProvider returns the name of the cloud provider which resolved the environment.
+func (m *Meta) CloudProvider() string {
+	if m.provider == nil {
+		return ""
+	}
+	return m.provider.Name()
+}
+
+func (m *Meta) DebugEnabled() bool {
+	return m.debugging
+}
@@ -0,0 +1,89 @@
+package meta
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// Environment variables of the pod info, which should be exposed via the downward API, e.g.
+	//
+	//	env:
+	//	- name: POD_NAME
+	//	  valueFrom:
+	//	    fieldRef:
+	//	      fieldPath: metadata.name
+	PodNameEnvKey      = "POD_NAME"
+	PodNamespaceEnvKey = "POD_NAMESPACE"
+	NodeNameEnvKey     = "NODE_NAME"
+	// PodLabelsPathEnvKey is the path of the downward API volume file of the pod labels.
+	// DefaultPodLabelsPath will be used if absent.
+	PodLabelsPathEnvKey = "POD_LABELS_PATH"
+
+	DefaultPodLabelsPath = "/etc/podinfo/labels"
+)
+
+// KubernetesInfo is the metadata of the pod, which lets the AHAS console group the instances by workload.
+type KubernetesInfo struct {
+	PodName      string            `json:"podName"`
+	PodNamespace string            `json:"podNamespace"`
+	NodeName     string            `json:"nodeName,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// resolveKubernetesInfo resolves the pod metadata, or returns nil if not running in Kubernetes.
+func resolveKubernetesInfo() *KubernetesInfo {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		if _, err := os.Stat(serviceAccountDir); err != nil {
+			return nil
+		}
+	}
+	info := &KubernetesInfo{
+		PodName:      os.Getenv(PodNameEnvKey),
+		PodNamespace: os.Getenv(PodNamespaceEnvKey),
+		NodeName:     os.Getenv(NodeNameEnvKey),
+	}
+	if info.PodName == "" {
+		// The hostname is the pod name by default.
+		info.PodName = resolveHostName()
+	}
+	if info.PodNamespace == "" {
+		if bs, err := ioutil.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+			info.PodNamespace = strings.TrimSpace(string(bs))
+		}
+	}
+	path := os.Getenv(PodLabelsPathEnvKey)
+	if path == "" {
+		path = DefaultPodLabelsPath
+	}
+	info.Labels = readPodLabels(path)
+	return info
+}
+
+// readPodLabels reads the labels from the downward API volume file, with one key="value" per line.
+func readPodLabels(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	labels := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value, err := strconv.Unquote(kv[1])
+		if err != nil {
+			value = kv[1]
+		}
+		labels[strings.TrimSpace(kv[0])] = value
+	}
+	return labels
+}
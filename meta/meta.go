@@ -1,9 +1,9 @@
 package meta
 
 import (
-	"net"
 	"os"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
@@ -20,106 +20,175 @@ const (
 	Container
 )
 
-var metadata = &Meta{
-	version: CurrentSdkVersion,
-	tidChan: make(chan string, 5),
+// defaultMeta keeps the *Meta of the SDK started by InitMetadata, which backs the package-level accessors.
+var defaultMeta atomic.Value
+
+func init() {
+	defaultMeta.Store(newEmptyMeta())
+}
+
+func newEmptyMeta() *Meta {
+	return &Meta{
+		version:  CurrentSdkVersion,
+		tidReady: make(chan struct{}),
+	}
+}
+
+// Default returns the metadata initialized by InitMetadata, which is empty before that.
+func Default() *Meta {
+	return defaultMeta.Load().(*Meta)
+}
+
+// InitMetadata resolves the metadata of current instance by NewMeta, and makes it the default one
+// backing the package-level accessors.
+func InitMetadata(license, namespace, env string, secureTransport bool, opts ...Option) (*Meta, error) {
+	m, err := NewMeta(license, namespace, env, secureTransport, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defaultMeta.Store(m)
+	return m, nil
 }
 
-func InitMetadata(license, namespace, env string, secureTransport bool) (*Meta, error) {
+// NewMeta resolves the metadata of current instance. Each SDK instance in the process should
+// keep its own Meta, which is safe for concurrent use. The absent namespace is resolved from
+// NamespaceEnvKey (and the Kubernetes namespace with WithKubernetesNamespace), and the absent env
+// from EnvironmentEnvKey.
+func NewMeta(license, namespace, env string, secureTransport bool, opts ...Option) (*Meta, error) {
+	o := newOptions(opts)
+	metadata := newEmptyMeta()
 	metadata.license = license
-	metadata.namespace = namespace
-	metadata.deployEnv = env
+	metadata.kubernetes = resolveKubernetesInfo()
+	metadata.namespace = resolveNamespace(namespace, metadata.kubernetes, o.kubernetesNamespace)
+	metadata.deployEnv = resolveDeployEnv(env)
+	env = metadata.deployEnv
 
-	if license == "" {
-		vpcEcs, err := aliyun.RetrieveVpcMetadata()
-		if err != nil || vpcEcs.Uid == "" {
-			return nil, errors.New("cannot find AHAS license")
+	provider := currentCloudProvider(license)
+	cloudEnv, err := provider.Resolve(license)
+	if err != nil {
+		return nil, err
+	}
+	metadata.provider = provider
+	metadata.inVpc = cloudEnv.InVpc
+	metadata.regionId = cloudEnv.RegionId
+	metadata.vpcId = cloudEnv.VpcId
+	metadata.uid = cloudEnv.Uid
+	metadata.setIp(cloudEnv.Ip, cloudEnv.Ipv6)
+	metadata.hostName = cloudEnv.HostName
+	metadata.instanceId = cloudEnv.InstanceId
+	metadata.pid = resolveProcessId()
+
+	metadata.containerId = resolveContainerId()
+	if metadata.serverless = resolveServerlessInfo(); metadata.serverless != nil {
+		// The hostname of the serverless instances is meaningless, so prefer the instance ID of the runtime.
+		if metadata.serverless.InstanceId != "" {
+			metadata.instanceId = metadata.serverless.InstanceId
 		}
-		metadata.regionId = vpcEcs.RegionId
-		metadata.inVpc = true
-		metadata.vpcId = vpcEcs.VpcId
-		metadata.ip = vpcEcs.Ip
-		metadata.hostName = vpcEcs.HostName
-		metadata.pid = resolveProcessId()
-		metadata.instanceId = vpcEcs.InstanceId
-		metadata.uid = vpcEcs.Uid
-	} else {
-		metadata.regionId = aliyun.CnPublic
-		metadata.inVpc = false
-		metadata.vpcId = license
-		ip, err := resolvePrivateIp()
-		if err != nil {
-			return nil, errors.Wrap(err, "cannot resolve private IP")
+		// The region of the public (license) mode is kept for the endpoint selection.
+		if metadata.serverless.RegionId == "" && metadata.inVpc {
+			metadata.serverless.RegionId = metadata.regionId
 		}
-		metadata.ip = ip
-		metadata.hostName = resolveHostName()
-		metadata.pid = resolveProcessId()
-		metadata.instanceId = resolveHostName()
-		// Get UID from server side
-		metadata.uid = ""
+		logger.Infof("Running in serverless runtime: %s", metadata.serverless.Runtime)
 	}
 
+	metadata.acmHost = o.acmHost
+	metadata.appName = o.appName
+	if len(o.endpoints) > 0 {
+		metadata.ahasEndpoints = o.endpoints
+		return metadata, nil
+	}
 	envKey := env + "-" + metadata.regionId
-	var endpoint string
+	var endpoints []string
 	var envSupported bool
 	if secureTransport {
-		endpoint, envSupported = aliyun.GetAhasProxyTlsEndpoint(envKey)
+		endpoints, envSupported = aliyun.GetAhasProxyTlsEndpoints(envKey)
 	} else {
-		endpoint, envSupported = aliyun.GetAhasProxyEndpoint(envKey)
+		endpoints, envSupported = aliyun.GetAhasProxyEndpoints(envKey)
 	}
 
-	if !envSupported || endpoint == "" {
+	if !envSupported || len(endpoints) == 0 {
 		logger.Warn("No available AHAS endpoint, env not supported: " + envKey)
 		return nil, errors.New("No available AHAS endpoint, env not supported: " + envKey)
 	}
-	metadata.ahasEndpoint = endpoint
-	metadata.version = CurrentSdkVersion
+	metadata.ahasEndpoints = endpoints
 
 	return metadata, nil
 }
 
+// The package-level accessors below are the shims of the default Meta, see Default.
+
 func License() string {
-	return metadata.license
+	return Default().License()
 }
 
 func Namespace() string {
-	return metadata.namespace
+	return Default().Namespace()
 }
 
 func DeployEnv() string {
-	return metadata.deployEnv
+	return Default().DeployEnv()
 }
 
 func IsPrivate() bool {
-	return metadata.inVpc
+	return Default().InVpc()
 }
 
 func CurrentVersion() string {
-	return metadata.version
+	return Default().Version()
 }
 
 func RegionId() string {
-	return metadata.regionId
+	return Default().RegionId()
 }
 
 func VpcId() string {
-	return metadata.vpcId
+	return Default().VpcId()
 }
 
 func Pid() string {
-	return metadata.pid
+	return Default().Pid()
 }
 
 func Cid() string {
-	return metadata.cid
+	return Default().Cid()
 }
 
 func LocalIp() string {
-	return metadata.ip
+	return Default().Ip()
+}
+
+// LocalIpv6 returns the additionally reported IPv6 address in DualStack policy.
+func LocalIpv6() string {
+	return Default().Ipv6()
+}
+
+// Kubernetes returns the metadata of the pod, or nil if not running in Kubernetes.
+func Kubernetes() *KubernetesInfo {
+	return Default().Kubernetes()
+}
+
+// ContainerId returns the ID of current container resolved from cgroup, or empty if not in a container.
+func ContainerId() string {
+	return Default().ContainerId()
+}
+
+// Serverless returns the metadata of the serverless runtime, or nil if not running in a serverless runtime.
+func Serverless() *ServerlessInfo {
+	return Default().Serverless()
+}
+
+// InstanceType returns Container if running in a container, or Host otherwise.
+func InstanceType() int {
+	return Default().InstanceType()
 }
 
 func DebugEnabled() bool {
-	return metadata.debugging
+	return Default().DebugEnabled()
+}
+
+// Snapshot returns the snapshot of the default Meta, for diagnostics.
+func Snapshot() MetaSnapshot {
+	return Default().Snapshot()
 }
 
 func resolveProcessId() string {
@@ -134,41 +203,3 @@ func resolveHostName() string {
 	}
 	return name
 }
-
-func resolvePrivateIp() (string, error) {
-	ifs, err := net.Interfaces()
-	if err != nil {
-		return "", err
-	}
-	for _, i := range ifs {
-		if i.Flags&net.FlagUp == 0 {
-			continue
-		}
-		if i.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-		addrs, err := i.Addrs()
-		if err != nil {
-			logger.Warnf("Failed to list addresses of the interface <%s>: %v", i.Name, err)
-			continue
-		}
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
-			if ip == nil || ip.IsLoopback() {
-				continue
-			}
-			ip = ip.To4()
-			if ip == nil {
-				continue
-			}
-			return ip.String(), nil
-		}
-	}
-	return "", errors.New("Cannot get host ip address")
-}
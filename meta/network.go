@@ -0,0 +1,201 @@
+package meta
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/pkg/errors"
+)
+
+// IpPolicy is the policy to choose the reported IP address among IPv4 and IPv6.
+type IpPolicy string
+
+const (
+	// PreferIPv4 reports the IPv4 address, or the IPv6 one if there's no IPv4 address (default).
+	PreferIPv4 IpPolicy = "ipv4"
+	// PreferIPv6 reports the IPv6 address, or the IPv4 one if there's no IPv6 address.
+	// As AHAS transport identifies the client by an IPv4 address, it only applies to the
+	// offline mode; use DualStack to report the IPv6 address of a dual-stack host.
+	PreferIPv6 IpPolicy = "ipv6"
+	// DualStack reports the IPv4 address as PreferIPv4 does, and the IPv6 address additionally.
+	DualStack IpPolicy = "dual"
+)
+
+// HostIpEnvKey is the environment variable which overrides the reported IP address explicitly.
+const HostIpEnvKey = "AHAS_HOST_IP"
+
+type NetworkConfig struct {
+	IpPolicy IpPolicy `yaml:"ipPolicy"`
+	// Interfaces is the names of the network interfaces to select the IP from (e.g. eth0),
+	// in the order of preference. All interfaces are candidates if absent.
+	Interfaces []string `yaml:"interfaces"`
+	// Cidrs is the allowlist of the selected IP (e.g. 10.0.0.0/8). All IPs are allowed if absent.
+	Cidrs []string `yaml:"cidrs"`
+	// IpRefreshIntervalMs is the interval to check whether the reported IP has changed,
+	// DefaultIpRefreshIntervalMs will be used if absent.
+	IpRefreshIntervalMs uint64 `yaml:"ipRefreshIntervalMs"`
+	// DisableIpRefresh indicates whether to keep the IP resolved at startup forever.
+	DisableIpRefresh bool `yaml:"disableIpRefresh"`
+}
+
+// ipAllowed checks whether the IP is in the CIDR allowlist.
+func (c *NetworkConfig) ipAllowed(ip net.IP) bool {
+	if len(c.Cidrs) == 0 {
+		return true
+	}
+	for _, cidr := range c.Cidrs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			logger.Warnf("Ignoring bad CIDR <%s> in network config: %v", cidr, err)
+			continue
+		}
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidateInterfaces returns the interfaces to select the IP from, in the order of preference.
+func (c *NetworkConfig) candidateInterfaces() ([]net.Interface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil || len(c.Interfaces) == 0 {
+		return ifs, err
+	}
+	result := make([]net.Interface, 0, len(c.Interfaces))
+	for _, name := range c.Interfaces {
+		for _, i := range ifs {
+			if i.Name == name {
+				result = append(result, i)
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.Errorf("none of the configured network interfaces %v is found", c.Interfaces)
+	}
+	return result, nil
+}
+
+var (
+	networkMux    = &sync.RWMutex{}
+	networkConfig = NetworkConfig{IpPolicy: PreferIPv4}
+)
+
+// SetNetworkConfig sets the network config, which should be called before InitMetadata.
+func SetNetworkConfig(c NetworkConfig) {
+	if c.IpPolicy == "" {
+		c.IpPolicy = PreferIPv4
+	}
+	networkMux.Lock()
+	defer networkMux.Unlock()
+	networkConfig = c
+}
+
+func currentNetworkConfig() NetworkConfig {
+	networkMux.RLock()
+	defer networkMux.RUnlock()
+	return networkConfig
+}
+
+// resolvePrivateIp resolves the reported IP address under the IP policy, and the additional
+// IPv6 address in DualStack policy.
+func resolvePrivateIp() (ip string, ipv6 string, err error) {
+	if ip, err = explicitHostIp(); ip != "" || err != nil {
+		return ip, "", err
+	}
+	conf := currentNetworkConfig()
+	v4, v6, err := resolveInterfaceIps(&conf)
+	if err != nil {
+		return "", "", err
+	}
+	switch policy := conf.IpPolicy; policy {
+	case PreferIPv6:
+		ip = firstNonEmpty(v6, v4)
+	case DualStack:
+		ip = firstNonEmpty(v4, v6)
+		if v4 != "" {
+			ipv6 = v6
+		}
+	default:
+		if policy != PreferIPv4 {
+			logger.Warnf("Unknown IP policy <%s>, using %s", policy, PreferIPv4)
+		}
+		ip = firstNonEmpty(v4, v6)
+	}
+	if ip == "" {
+		return "", "", errors.New("Cannot get host ip address")
+	}
+	return ip, ipv6, nil
+}
+
+// explicitHostIp returns the IP specified via HostIpEnvKey, if any.
+func explicitHostIp() (string, error) {
+	host := strings.TrimSpace(os.Getenv(HostIpEnvKey))
+	if host == "" {
+		return "", nil
+	}
+	if net.ParseIP(host) == nil {
+		return "", errors.Errorf("bad IP in %s: %s", HostIpEnvKey, host)
+	}
+	return host, nil
+}
+
+// resolveInterfaceIps returns the first allowed IPv4 address and the first allowed
+// global IPv6 address of the active non-loopback candidate interfaces.
+func resolveInterfaceIps(conf *NetworkConfig) (v4 string, v6 string, err error) {
+	ifs, err := conf.candidateInterfaces()
+	if err != nil {
+		return "", "", err
+	}
+	for _, i := range ifs {
+		if i.Flags&net.FlagUp == 0 {
+			continue
+		}
+		if i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := i.Addrs()
+		if err != nil {
+			logger.Warnf("Failed to list addresses of the interface <%s>: %v", i.Name, err)
+			continue
+		}
+		for _, addr := range addrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+			if ip == nil || ip.IsLoopback() || !conf.ipAllowed(ip) {
+				continue
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				if v4 == "" {
+					v4 = ip4.String()
+				}
+				continue
+			}
+			// Link-local IPv6 addresses are not routable across hosts.
+			if v6 == "" && ip.IsGlobalUnicast() {
+				v6 = ip.String()
+			}
+		}
+		if v4 != "" && v6 != "" {
+			break
+		}
+	}
+	return v4, v6, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
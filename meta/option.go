@@ -0,0 +1,121 @@
+package meta
+
+import (
+	"os"
+	"strings"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// Environment variables which override the endpoints resolved from the built-in tables,
+// e.g. for the private AHAS deployments.
+const (
+	// EndpointEnvKey is the AHAS gateway endpoints (host:port), separated by commas in the order of preference.
+	EndpointEnvKey = "AHAS_ENDPOINT"
+	// AcmHostEnvKey is the host of the ACM address server.
+	AcmHostEnvKey = "AHAS_ACM_HOST"
+)
+
+// Environment variables of the namespace and the deploy environment, which are resolved if
+// absent in NewMeta. They are the same as the ones of the config package.
+const (
+	NamespaceEnvKey   = "AHAS_NAMESPACE"
+	EnvironmentEnvKey = "AHAS_ENV"
+
+	DefaultNamespace = "default"
+	DefaultDeployEnv = "prod"
+)
+
+// resolveNamespace resolves the absent namespace from NamespaceEnvKey. If fromKubernetes is set,
+// the absent (or default) namespace is then resolved from the namespace of the Kubernetes pod.
+// DefaultNamespace is used if none is available.
+func resolveNamespace(namespace string, k8s *KubernetesInfo, fromKubernetes bool) string {
+	if namespace == "" {
+		namespace = strings.TrimSpace(os.Getenv(NamespaceEnvKey))
+	}
+	if namespace != "" && (namespace != DefaultNamespace || !fromKubernetes) {
+		return namespace
+	}
+	if fromKubernetes && k8s != nil && k8s.PodNamespace != "" {
+		logger.Infof("AHAS namespace resolved from the Kubernetes namespace: %s", k8s.PodNamespace)
+		return k8s.PodNamespace
+	}
+	return DefaultNamespace
+}
+
+// resolveDeployEnv resolves the absent deploy environment from EnvironmentEnvKey.
+// DefaultDeployEnv is used if absent.
+func resolveDeployEnv(env string) string {
+	if env != "" {
+		return env
+	}
+	if env = strings.TrimSpace(os.Getenv(EnvironmentEnvKey)); env != "" {
+		return env
+	}
+	return DefaultDeployEnv
+}
+
+type options struct {
+	endpoints           []string
+	acmHost             string
+	kubernetesNamespace bool
+	appName             string
+}
+
+// Option is the option of InitMetadata and NewMeta.
+type Option func(*options)
+
+// WithEndpoints overrides the AHAS gateway endpoints (host:port) in the order of preference,
+// which takes precedence over EndpointEnvKey.
+func WithEndpoints(endpoints ...string) Option {
+	return func(o *options) {
+		o.endpoints = endpoints
+	}
+}
+
+// WithAcmHost overrides the host of the ACM address server, which takes precedence over AcmHostEnvKey.
+func WithAcmHost(host string) Option {
+	return func(o *options) {
+		o.acmHost = host
+	}
+}
+
+// WithKubernetesNamespace makes the namespace of the Kubernetes pod the absent (or default)
+// namespace. The existing deployments are kept in DefaultNamespace without it.
+func WithKubernetesNamespace() Option {
+	return func(o *options) {
+		o.kubernetesNamespace = true
+	}
+}
+
+// WithAppName sets the name of the app registered to AHAS, e.g. of the tenant app of an ahas.Client.
+// The Sentinel app name is registered if absent.
+func WithAppName(name string) Option {
+	return func(o *options) {
+		o.appName = name
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.endpoints) == 0 {
+		o.endpoints = splitEndpoints(os.Getenv(EndpointEnvKey))
+	}
+	if o.acmHost == "" {
+		o.acmHost = strings.TrimSpace(os.Getenv(AcmHostEnvKey))
+	}
+	return o
+}
+
+func splitEndpoints(s string) []string {
+	endpoints := make([]string, 0)
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
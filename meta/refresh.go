@@ -0,0 +1,90 @@
+package meta
+
+import (
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+)
+
+// DefaultIpRefreshIntervalMs is the default interval to check whether the reported IP has changed.
+const DefaultIpRefreshIntervalMs uint64 = 30000
+
+// resolveCurrentIp resolves the reported IP addresses again, by the cloud provider which resolved them.
+func (m *Meta) resolveCurrentIp() (string, string, error) {
+	if r, ok := m.provider.(IpResolver); ok {
+		return r.ResolveIp()
+	}
+	env, err := m.provider.Resolve(m.license)
+	if err != nil {
+		return "", "", err
+	}
+	return env.Ip, env.Ipv6, nil
+}
+
+// refreshIp resolves the reported IP addresses again, and returns whether they have changed.
+func (m *Meta) refreshIp() (bool, error) {
+	ip, ipv6, err := m.resolveCurrentIp()
+	if err != nil {
+		return false, err
+	}
+	oldIp, oldIpv6 := m.Ip(), m.Ipv6()
+	if ip == oldIp && ipv6 == oldIpv6 {
+		return false, nil
+	}
+	m.setIp(ip, ipv6)
+	logger.Infof("The reported IP has changed from <%s> to <%s>", oldIp, ip)
+	return true, nil
+}
+
+// StartIpRefresher starts the background task which periodically resolves the reported IP
+// addresses again (e.g. after the DHCP renewal), and calls onChange after they change, so that
+// the instance could register again rather than reporting the stale IP forever. It does nothing
+// if the refresher is disabled in the network config, or has already been started.
+func (m *Meta) StartIpRefresher(onChange func()) {
+	conf := currentNetworkConfig()
+	if conf.DisableIpRefresh {
+		return
+	}
+	interval := conf.IpRefreshIntervalMs
+	if interval == 0 {
+		interval = DefaultIpRefreshIntervalMs
+	}
+	m.refresherMux.Lock()
+	defer m.refresherMux.Unlock()
+	if m.refresherStopCh != nil {
+		return
+	}
+	stopCh := make(chan struct{})
+	m.refresherStopCh = stopCh
+	go func() {
+		defer tools.PrintPanicStackV2("IP refresher")
+		ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				return
+			}
+			changed, err := m.refreshIp()
+			if err != nil {
+				logger.Warnf("Failed to refresh the reported IP: %+v", err)
+				continue
+			}
+			if changed {
+				onChange()
+			}
+		}
+	}()
+}
+
+// StopIpRefresher stops the background task started by StartIpRefresher.
+func (m *Meta) StopIpRefresher() {
+	m.refresherMux.Lock()
+	defer m.refresherMux.Unlock()
+	if m.refresherStopCh != nil {
+		close(m.refresherStopCh)
+		m.refresherStopCh = nil
+	}
+}
@@ -0,0 +1,73 @@
+package meta
+
+import "os"
+
+// ServerlessRuntime is the kind of the serverless runtime.
+type ServerlessRuntime string
+
+const (
+	// FunctionCompute is Alibaba Cloud Function Compute (FC).
+	FunctionCompute ServerlessRuntime = "fc"
+	// ServerlessAppEngine is Alibaba Cloud Serverless App Engine (SAE).
+	ServerlessAppEngine ServerlessRuntime = "sae"
+	// ElasticContainerInstance is Alibaba Cloud Elastic Container Instance (ECI).
+	ElasticContainerInstance ServerlessRuntime = "eci"
+)
+
+// Environment variables set by the serverless runtimes.
+const (
+	FcFunctionNameEnvKey = "FC_FUNCTION_NAME"
+	FcServiceNameEnvKey  = "FC_SERVICE_NAME"
+	FcRegionEnvKey       = "FC_REGION"
+	FcInstanceIdEnvKey   = "FC_INSTANCE_ID"
+
+	SaeAppIdEnvKey      = "SAE_APP_ID"
+	SaeInstanceIdEnvKey = "SAE_INSTANCE_ID"
+	SaeRegionEnvKey     = "SAE_REGION_ID"
+
+	EciIdEnvKey     = "ECI_ID"
+	EciRegionEnvKey = "ECI_REGION_ID"
+)
+
+// ServerlessInfo is the metadata of the serverless runtime.
+type ServerlessInfo struct {
+	Runtime ServerlessRuntime `json:"runtime"`
+	// Name is the name of the function (as "service/function") in FC, or the app ID in SAE.
+	Name       string `json:"name,omitempty"`
+	InstanceId string `json:"instanceId,omitempty"`
+	RegionId   string `json:"regionId,omitempty"`
+}
+
+// resolveServerlessInfo detects the serverless runtime from its environment variables,
+// or returns nil if not running in a serverless runtime. FC is checked first, as SAE
+// and FC instances might be backed by ECI.
+func resolveServerlessInfo() *ServerlessInfo {
+	if function := os.Getenv(FcFunctionNameEnvKey); function != "" {
+		name := function
+		if service := os.Getenv(FcServiceNameEnvKey); service != "" {
+			name = service + "/" + function
+		}
+		return &ServerlessInfo{
+			Runtime:    FunctionCompute,
+			Name:       name,
+			InstanceId: os.Getenv(FcInstanceIdEnvKey),
+			RegionId:   os.Getenv(FcRegionEnvKey),
+		}
+	}
+	if appId := os.Getenv(SaeAppIdEnvKey); appId != "" {
+		return &ServerlessInfo{
+			Runtime:    ServerlessAppEngine,
+			Name:       appId,
+			InstanceId: os.Getenv(SaeInstanceIdEnvKey),
+			RegionId:   os.Getenv(SaeRegionEnvKey),
+		}
+	}
+	if eciId := os.Getenv(EciIdEnvKey); eciId != "" {
+		return &ServerlessInfo{
+			Runtime:    ElasticContainerInstance,
+			InstanceId: eciId,
+			RegionId:   os.Getenv(EciRegionEnvKey),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package meta
+
+import "encoding/json"
+
+// MetaSnapshot is the resolved metadata of an instance at a point in time, for diagnostics
+// (e.g. why the instance is not in the AHAS console). The license is masked.
+type MetaSnapshot struct {
+	Version       string            `json:"version"`
+	License       string            `json:"license,omitempty"`
+	Namespace     string            `json:"namespace"`
+	DeployEnv     string            `json:"deployEnv"`
+	CloudProvider string            `json:"cloudProvider"`
+	InVpc         bool              `json:"inVpc"`
+	RegionId      string            `json:"regionId"`
+	VpcId         string            `json:"vpcId"`
+	AhasEndpoints []string          `json:"ahasEndpoints"`
+	AcmHost       string            `json:"acmHost,omitempty"`
+	Ip            string            `json:"ip"`
+	Ipv6          string            `json:"ipv6,omitempty"`
+	HostName      string            `json:"hostName"`
+	Pid           string            `json:"pid"`
+	InstanceId    string            `json:"instanceId"`
+	Uid           string            `json:"uid"`
+	Tid           string            `json:"tid"`
+	Cid           string            `json:"cid"`
+	ContainerId   string            `json:"containerId,omitempty"`
+	Kubernetes    *KubernetesInfo   `json:"kubernetes,omitempty"`
+	Serverless    *ServerlessInfo   `json:"serverless,omitempty"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	// Registered indicates whether the instance has registered to AHAS (i.e. the tid is assigned).
+	Registered bool `json:"registered"`
+}
+
+// JSON returns the snapshot in indented JSON.
+func (s MetaSnapshot) JSON() string {
+	bs, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(bs)
+}
+
+// Snapshot returns the snapshot of the metadata.
+func (m *Meta) Snapshot() MetaSnapshot {
+	s := MetaSnapshot{
+		Version:       m.version,
+		License:       maskLicense(m.license),
+		Namespace:     m.namespace,
+		DeployEnv:     m.deployEnv,
+		CloudProvider: m.CloudProvider(),
+		InVpc:         m.inVpc,
+		RegionId:      m.regionId,
+		VpcId:         m.vpcId,
+		AhasEndpoints: m.AhasEndpoints(),
+		HostName:      m.hostName,
+		Pid:           m.pid,
+		InstanceId:    m.instanceId,
+		ContainerId:   m.containerId,
+		Kubernetes:    m.kubernetes,
+		Serverless:    m.serverless,
+		Tags:          Tags(),
+	}
+	s.AcmHost, _ = m.AcmHost()
+	m.mux.RLock()
+	s.Ip, s.Ipv6 = m.ip, m.ipv6
+	s.Uid, s.Tid, s.Cid = m.uid, m.tid, m.cid
+	m.mux.RUnlock()
+	s.Registered = s.Tid != ""
+	if !m.inVpc {
+		// The vpcId is the license in license mode.
+		s.VpcId = s.License
+	}
+	return s
+}
+
+// maskLicense keeps the first and last 4 characters of the license only.
+func maskLicense(license string) string {
+	if len(license) <= 8 {
+		if license == "" {
+			return ""
+		}
+		return "****"
+	}
+	return license[:4] + "****" + license[len(license)-4:]
+}
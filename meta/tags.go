@@ -0,0 +1,42 @@
+package meta
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+var (
+	tagsMux = &sync.RWMutex{}
+	tags    map[string]string
+)
+
+// SetTags sets the custom tags of current instance (e.g. team, service tier and zone), which are
+// reported in the heartbeats, so that the instances could be filtered and targeted by tags in the
+// AHAS console. It replaces the previous tags, and tags with blank keys are ignored.
+func SetTags(t map[string]string) {
+	m := make(map[string]string, len(t))
+	for k, v := range t {
+		key := strings.TrimSpace(k)
+		if key == "" {
+			logger.Warnf("Ignoring the tag with blank key, value: %s", v)
+			continue
+		}
+		m[key] = v
+	}
+	tagsMux.Lock()
+	defer tagsMux.Unlock()
+	tags = m
+}
+
+// Tags returns a copy of the custom tags of current instance.
+func Tags() map[string]string {
+	tagsMux.RLock()
+	defer tagsMux.RUnlock()
+	m := make(map[string]string, len(tags))
+	for k, v := range tags {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,311 @@
+// Package notifier posts the events of the protection (the rule updates, the circuit breakers
+// opened and the system rules triggered) to the webhooks in JSON, so that the teams get the
+// real-time alerts, e.g. by the DingTalk or Slack robots:
+//
+//	notifier:
+//	  enabled: true
+//	  webhooks:
+//	    - url: https://oapi.dingtalk.com/robot/send?access_token=xxx
+//	      template: dingtalk
+//	      events: [breakerOpened, systemRuleTriggered]
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/blocklog"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/breaker"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+type EventType string
+
+const (
+	// EventRuleUpdated is the successful update of the rules of a kind, except the first load.
+	EventRuleUpdated EventType = "ruleUpdated"
+	// EventBreakerOpened is the transition of a circuit breaker to open.
+	EventBreakerOpened EventType = "breakerOpened"
+	// EventSystemRuleTriggered is the requests blocked by a system rule, which is notified at
+	// most once per Config.SystemRuleIntervalMs per resource.
+	EventSystemRuleTriggered EventType = "systemRuleTriggered"
+)
+
+const (
+	DefaultTimeoutMs            = 3000
+	DefaultQueueSize            = 256
+	DefaultSystemRuleIntervalMs = 60 * 1000
+
+	// maxResponseSize is the maximum size of the webhook response read for the diagnostics.
+	maxResponseSize = 4096
+)
+
+type Config struct {
+	Enabled  bool            `yaml:"enabled"`
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+	// SystemRuleIntervalMs is the minimum interval of the EventSystemRuleTriggered events of a resource.
+	// DefaultSystemRuleIntervalMs will be used if absent.
+	SystemRuleIntervalMs uint64 `yaml:"systemRuleIntervalMs"`
+	// QueueSize is the capacity of pending events. Events are dropped once the queue is full.
+	// DefaultQueueSize will be used if absent.
+	QueueSize int `yaml:"queueSize"`
+}
+
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Template is the format of the request body: TemplateJSON (by default), TemplateDingTalk or TemplateSlack.
+	Template string `yaml:"template"`
+	// Secret signs the requests of the DingTalk robots secured by the signature.
+	Secret string `yaml:"secret"`
+	// Events are the types of the events posted to the webhook, all the events if empty.
+	Events []EventType `yaml:"events"`
+	// TimeoutMs is the timeout of the requests. DefaultTimeoutMs will be used if absent.
+	TimeoutMs uint64 `yaml:"timeoutMs"`
+}
+
+// Event is the event posted to the webhooks.
+type Event struct {
+	Type      EventType `json:"type"`
+	App       string    `json:"app"`
+	Resource  string    `json:"resource,omitempty"`
+	Message   string    `json:"message"`
+	Timestamp uint64    `json:"timestamp"`
+	// Detail is the record of the event, e.g. datasource.RuleUpdate and breaker.StateRecord.
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+type webhook struct {
+	conf     WebhookConfig
+	template template
+	events   map[EventType]bool
+	client   *http.Client
+}
+
+func (w *webhook) accepts(t EventType) bool {
+	return len(w.events) == 0 || w.events[t]
+}
+
+type notifier struct {
+	conf     Config
+	webhooks []*webhook
+	queue    chan Event
+	stopCh   chan struct{}
+
+	systemMux  sync.Mutex
+	systemLast map[string]uint64
+}
+
+var (
+	notifyMux = &sync.Mutex{}
+	// currentNotifier is the running notifier, and the event listeners are registered only once.
+	currentNotifier *notifier
+	activeNotifier  atomic.Value
+	listenOnce      sync.Once
+)
+
+// Start starts posting the events to the webhooks. The events are dropped rather than blocking
+// the business goroutines when the webhooks are slow.
+func Start(conf Config) error {
+	if !conf.Enabled {
+		return nil
+	}
+	notifyMux.Lock()
+	defer notifyMux.Unlock()
+	if currentNotifier != nil {
+		return nil
+	}
+	if conf.SystemRuleIntervalMs == 0 {
+		conf.SystemRuleIntervalMs = DefaultSystemRuleIntervalMs
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = DefaultQueueSize
+	}
+	webhooks := make([]*webhook, 0, len(conf.Webhooks))
+	for _, wc := range conf.Webhooks {
+		if wc.URL == "" {
+			return errors.New("empty webhook url")
+		}
+		tpl, ok := templates[wc.Template]
+		if !ok {
+			return errors.Errorf("unknown webhook template: %s", wc.Template)
+		}
+		if wc.TimeoutMs == 0 {
+			wc.TimeoutMs = DefaultTimeoutMs
+		}
+		w := &webhook{
+			conf:     wc,
+			template: tpl,
+			events:   make(map[EventType]bool, len(wc.Events)),
+			client:   &http.Client{Timeout: time.Duration(wc.TimeoutMs) * time.Millisecond},
+		}
+		for _, t := range wc.Events {
+			w.events[t] = true
+		}
+		webhooks = append(webhooks, w)
+	}
+	if len(webhooks) == 0 {
+		return errors.New("no webhooks configured")
+	}
+	n := &notifier{
+		conf:       conf,
+		webhooks:   webhooks,
+		queue:      make(chan Event, conf.QueueSize),
+		stopCh:     make(chan struct{}),
+		systemLast: make(map[string]uint64),
+	}
+	currentNotifier = n
+	activeNotifier.Store(n)
+	listenOnce.Do(func() {
+		datasource.AddRuleUpdateListener(onRuleUpdate)
+		breaker.AddListener(onBreakerStateChange)
+		blocklog.Init()
+		blocklog.AddListener(onBlocked)
+	})
+	go n.run()
+	logger.Infof("Notifier started with %d webhooks", len(webhooks))
+	return nil
+}
+
+// Stop stops the running notifier. Pending events are discarded.
+func Stop() {
+	notifyMux.Lock()
+	defer notifyMux.Unlock()
+	if currentNotifier == nil {
+		return
+	}
+	close(currentNotifier.stopCh)
+	currentNotifier = nil
+	activeNotifier.Store((*notifier)(nil))
+}
+
+// Notify posts the event (e.g. of the application) to the webhooks of the running notifier.
+// The app and the timestamp of the event are filled if absent.
+func Notify(e Event) {
+	n, _ := activeNotifier.Load().(*notifier)
+	if n == nil {
+		return
+	}
+	if e.App == "" {
+		e.App = sentinelConf.AppName()
+	}
+	if e.Timestamp == 0 {
+		e.Timestamp = util.CurrentTimeMillis()
+	}
+	select {
+	case n.queue <- e:
+	default:
+		logger.Warnf("Notifier queue is full, dropped the %s event: %s", e.Type, e.Message)
+	}
+}
+
+func onRuleUpdate(u datasource.RuleUpdate) {
+	// The rules loaded at startup are not news.
+	if u.Initial {
+		return
+	}
+	Notify(Event{
+		Type:      EventRuleUpdated,
+		App:       u.App,
+		Message:   fmt.Sprintf("The %s rules of app %s are updated, %d rules in effect", u.Kind, u.App, u.RuleCount),
+		Timestamp: u.Timestamp,
+		Detail:    u,
+	})
+}
+
+func onBreakerStateChange(r breaker.StateRecord) {
+	open := circuitbreaker.Open
+	if r.State != open.String() {
+		return
+	}
+	Notify(Event{
+		Type:      EventBreakerOpened,
+		Resource:  r.Resource,
+		Message:   fmt.Sprintf("The circuit breaker (%s) of resource <%s> is opened from %s", r.Strategy, r.Resource, r.PreviousState),
+		Timestamp: r.Timestamp,
+		Detail:    r,
+	})
+}
+
+func onBlocked(e blocklog.Event) {
+	if e.BlockType != base.BlockTypeSystemFlow.String() {
+		return
+	}
+	n, _ := activeNotifier.Load().(*notifier)
+	if n == nil || !n.allowSystemEvent(e.Resource, e.Timestamp) {
+		return
+	}
+	Notify(Event{
+		Type:      EventSystemRuleTriggered,
+		Resource:  e.Resource,
+		Message:   fmt.Sprintf("The system rule is triggered, blocking the requests of resource <%s>: %s", e.Resource, e.Rule),
+		Timestamp: e.Timestamp,
+		Detail:    e,
+	})
+}
+
+// allowSystemEvent throttles the EventSystemRuleTriggered events of the resource.
+func (n *notifier) allowSystemEvent(resource string, now uint64) bool {
+	n.systemMux.Lock()
+	defer n.systemMux.Unlock()
+	if last, ok := n.systemLast[resource]; ok && now < last+n.conf.SystemRuleIntervalMs {
+		return false
+	}
+	n.systemLast[resource] = now
+	return true
+}
+
+func (n *notifier) run() {
+	defer tools.PrintPanicStackV2("webhook notifier")
+	for {
+		select {
+		case e := <-n.queue:
+			for _, w := range n.webhooks {
+				if !w.accepts(e.Type) {
+					continue
+				}
+				if err := w.post(e); err != nil {
+					logger.Warnf("Failed to post the %s event to webhook %s: %+v", e.Type, redactURL(w.conf.URL), err)
+				}
+			}
+		case <-n.stopCh:
+			return
+		}
+	}
+}
+
+func (w *webhook) post(e Event) error {
+	body, err := w.template.render(e)
+	if err != nil {
+		return err
+	}
+	target := w.conf.URL
+	if w.conf.Secret != "" {
+		target = signDingTalkURL(target, w.conf.Secret, time.Now())
+	}
+	resp, err := w.client.Post(target, "application/json; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("bad response, status: %d, body: %s", resp.StatusCode, msg)
+	}
+	if w.template.check != nil {
+		return w.template.check(msg)
+	}
+	return nil
+}
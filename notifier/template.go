@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TemplateJSON posts the Event as is.
+	TemplateJSON = "json"
+	// TemplateDingTalk posts the text message of the DingTalk robots.
+	TemplateDingTalk = "dingtalk"
+	// TemplateSlack posts the text message of the Slack incoming webhooks.
+	TemplateSlack = "slack"
+)
+
+// template renders the request body of the event, and checks the response body (optional).
+type template struct {
+	render func(e Event) ([]byte, error)
+	check  func(body []byte) error
+}
+
+var templates = map[string]template{
+	"":               {render: renderJSON},
+	TemplateJSON:     {render: renderJSON},
+	TemplateDingTalk: {render: renderDingTalk, check: checkDingTalk},
+	TemplateSlack:    {render: renderSlack},
+}
+
+func renderJSON(e Event) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// text is the plain text of the event for the chat robots. It's prefixed with "[AHAS]", which
+// could be the keyword of the DingTalk robots secured by the keywords.
+func text(e Event) string {
+	at := time.Unix(0, int64(e.Timestamp)*int64(time.Millisecond)).Format("2006-01-02 15:04:05")
+	return fmt.Sprintf("[AHAS] %s\napp: %s\ntime: %s\n%s", e.Type, e.App, at, e.Message)
+}
+
+func renderDingTalk(e Event) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": text(e)},
+	})
+}
+
+// checkDingTalk checks the error code, as the DingTalk robots respond 200 to the failures as well.
+func checkDingTalk(body []byte) error {
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return errors.Wrap(err, "bad DingTalk response")
+	}
+	if resp.ErrCode != 0 {
+		return errors.Errorf("DingTalk error, code: %d, message: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+func renderSlack(e Event) ([]byte, error) {
+	return json.Marshal(map[string]string{"text": text(e)})
+}
+
+// signDingTalkURL appends the signature of the DingTalk robots secured by the signature.
+func signDingTalkURL(rawURL, secret string, now time.Time) string {
+	ts := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+}
+
+// redactURL removes the query of the webhook URL, which usually carries the access token.
+func redactURL(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
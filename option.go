@@ -0,0 +1,125 @@
+package ahas
+
+import (
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+type initOptions struct {
+	configFile string
+	logger     logger.Logger
+	// overrides modify the config loaded from the config file and system env.
+	overrides []func(c *Config)
+}
+
+func newInitOptions(opts []Option) *initOptions {
+	o := &initOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *initOptions) apply(c *Config) {
+	for _, f := range o.overrides {
+		f(c)
+	}
+}
+
+func (o *initOptions) override(f func(c *Config)) {
+	o.overrides = append(o.overrides, f)
+}
+
+// Option is the option of Init.
+type Option func(*initOptions)
+
+// WithConfigFile sets the path of the config file, which is resolved from the system env if absent.
+func WithConfigFile(path string) Option {
+	return func(o *initOptions) {
+		o.configFile = path
+	}
+}
+
+// WithLicense sets the license of AHAS, which takes precedence over the config file and AHAS_LICENSE.
+func WithLicense(license string) Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.License = license
+		})
+	}
+}
+
+// WithNamespace sets the namespace of the application, which takes precedence over the config file
+// and AHAS_NAMESPACE.
+func WithNamespace(namespace string) Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.Namespace = namespace
+		})
+	}
+}
+
+// WithEnv sets the deploy environment of AHAS, which takes precedence over the config file and AHAS_ENV.
+func WithEnv(env string) Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.Env = env
+		})
+	}
+}
+
+// WithSecureTransport makes the connection to the AHAS gateway encrypted with TLS.
+func WithSecureTransport() Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.Transport.Secure = true
+		})
+	}
+}
+
+// WithEndpoint overrides the AHAS gateway endpoints (host:port) in the order of preference,
+// e.g. for the private AHAS deployments.
+func WithEndpoint(endpoints ...string) Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.Endpoints = endpoints
+		})
+	}
+}
+
+// WithAsyncStartup makes Init return immediately, and AHAS is started in the background,
+// see Config.AsyncStartup.
+func WithAsyncStartup() Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.AsyncStartup = true
+		})
+	}
+}
+
+// WithDegradeOnFailure makes Init run with the local rules only rather than failing when AHAS
+// can't be started, see Config.DegradeOnFailure.
+func WithDegradeOnFailure() Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.DegradeOnFailure = true
+		})
+	}
+}
+
+// WithOffline makes the SDK a local Sentinel bootstrap without any network calls to AHAS,
+// see Config.Offline.
+func WithOffline() Option {
+	return func(o *initOptions) {
+		o.override(func(c *Config) {
+			c.Offline = true
+		})
+	}
+}
+
+// WithLogger makes the AHAS logs written to the given logger rather than the ahas.log file,
+// see logger.NewZapLogger for the zap logger.
+func WithLogger(l logger.Logger) Option {
+	return func(o *initOptions) {
+		o.logger = l
+	}
+}
@@ -0,0 +1,42 @@
+package ahas
+
+import (
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+)
+
+// SetProtectionEnabled switches all the rules on or off at once, e.g. in the emergencies where
+// a mis-set rule is blocking the legitimate traffic. While switched off, no rules are enforced,
+// but the statistics are still collected. The AHAS console could switch it as well.
+func SetProtectionEnabled(enabled bool) {
+	datasource.SetProtectionEnabled(enabled, "the application")
+}
+
+// ProtectionEnabled returns whether the rules are switched on.
+func ProtectionEnabled() bool {
+	return datasource.ProtectionEnabled()
+}
+
+// OverrideMode is the mode of a runtime override of a resource, see OverrideResource.
+type OverrideMode = datasource.OverrideMode
+
+const (
+	// OverrideOff exempts the resource from all the rules.
+	OverrideOff = datasource.OverrideOff
+	// OverrideMonitorOnly doesn't enforce the rules of the resource, but keeps evaluating them
+	// against the traffic, see datasource.Overrides for the results.
+	OverrideMonitorOnly = datasource.OverrideMonitorOnly
+)
+
+// OverrideResource temporarily exempts the resource from the enforcement of its rules, without
+// editing the rules in the console, e.g. in the emergencies where a rule is blocking the
+// legitimate traffic of the resource. The override expires after ttl automatically.
+func OverrideResource(resource string, mode OverrideMode, ttl time.Duration) error {
+	return datasource.OverrideResource(resource, mode, ttl, "the application")
+}
+
+// ClearOverride clears the override of the resource before it expires.
+func ClearOverride(resource string) bool {
+	return datasource.ClearOverride(resource, "the application")
+}
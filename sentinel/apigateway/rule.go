@@ -0,0 +1,234 @@
+// Package apigateway provides the API gateway flow control, which is converted to
+// the hot-spot parameter flow control under the hood. Gateways should extract the
+// parameters of each request via ParseParameters and pass them to the Sentinel entry:
+//
+//	args := apigateway.ParseParameters(routeId, requestItem)
+//	e, b := sentinel.Entry(routeId, sentinel.WithTrafficType(base.Inbound),
+//		sentinel.WithResourceType(base.ResTypeAPIGateway), sentinel.WithArgs(args...))
+package apigateway
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultParam is the parameter value for rules without param item.
+	DefaultParam = "$D"
+	// NotMatchParam is the parameter value when the parsed value does not match the pattern.
+	NotMatchParam = "$NM"
+
+	defaultParamsMaxCapacity = 500
+)
+
+type ResourceMode int32
+
+const (
+	ResourceModeRouteId ResourceMode = iota
+	ResourceModeCustomApiName
+)
+
+type ParseStrategy int32
+
+const (
+	ParseStrategyClientIp ParseStrategy = iota
+	ParseStrategyHost
+	ParseStrategyHeader
+	ParseStrategyUrlParam
+	ParseStrategyCookie
+)
+
+type MatchStrategy int32
+
+const (
+	MatchStrategyExact MatchStrategy = iota
+	MatchStrategyPrefix
+	MatchStrategyRegex
+	MatchStrategyContains
+)
+
+type ParamItem struct {
+	ParseStrategy ParseStrategy
+	// FieldName is the name of header, URL parameter or cookie.
+	FieldName     string
+	Pattern       string
+	MatchStrategy MatchStrategy
+
+	regex *regexp.Regexp
+}
+
+// Rule is the gateway flow rule, which applies to a route or a custom API group.
+type Rule struct {
+	Id                string
+	Resource          string
+	ResourceMode      ResourceMode
+	MetricType        hotspot.MetricType
+	Count             float64
+	IntervalSec       int64
+	ControlBehavior   hotspot.ControlBehavior
+	Burst             int64
+	MaxQueueingTimeMs int64
+	// ParamsMaxCapacity is the max count of the parameter values to keep statistics for.
+	// 500 will be used if absent.
+	ParamsMaxCapacity int64
+	// ParamItem is optional. Absent param item means flow control on the whole resource.
+	ParamItem *ParamItem
+}
+
+// RequestItem provides the attributes of a gateway request for parameter parsing.
+type RequestItem interface {
+	ClientIp() string
+	Host() string
+	Header(key string) string
+	UrlParam(key string) string
+	Cookie(key string) string
+}
+
+var (
+	rulesMux      = &sync.RWMutex{}
+	resourceRules = make(map[string][]*Rule)
+)
+
+// LoadRules replaces all gateway flow rules, and returns the converted hot-spot
+// parameter flow rules, which should be loaded into the hot-spot rule manager.
+func LoadRules(rules []*Rule) ([]*hotspot.Rule, error) {
+	m := make(map[string][]*Rule)
+	converted := make([]*hotspot.Rule, 0, len(rules))
+	for _, r := range rules {
+		if r == nil || r.Resource == "" {
+			continue
+		}
+		if err := r.ParamItem.compile(); err != nil {
+			return nil, errors.Wrapf(err, "bad gateway flow rule, resource: %s", r.Resource)
+		}
+		idx := len(m[r.Resource])
+		m[r.Resource] = append(m[r.Resource], r)
+		converted = append(converted, r.toParamFlowRule(idx))
+	}
+	rulesMux.Lock()
+	resourceRules = m
+	rulesMux.Unlock()
+	return converted, nil
+}
+
+// GetRules returns all gateway flow rules.
+func GetRules() []Rule {
+	rulesMux.RLock()
+	defer rulesMux.RUnlock()
+	result := make([]Rule, 0)
+	for _, rules := range resourceRules {
+		for _, r := range rules {
+			result = append(result, *r)
+		}
+	}
+	return result
+}
+
+// ParseParameters parses the parameters of the request for all gateway flow rules
+// of the resource. The position of each value matches the param index of the converted rule.
+func ParseParameters(resource string, req RequestItem) []interface{} {
+	rulesMux.RLock()
+	rules := resourceRules[resource]
+	rulesMux.RUnlock()
+	if len(rules) == 0 || req == nil {
+		return nil
+	}
+	params := make([]interface{}, 0, len(rules))
+	for _, r := range rules {
+		params = append(params, r.ParamItem.parse(req))
+	}
+	return params
+}
+
+func (r *Rule) toParamFlowRule(paramIndex int) *hotspot.Rule {
+	durationInSec := r.IntervalSec
+	if durationInSec <= 0 {
+		durationInSec = 1
+	}
+	capacity := r.ParamsMaxCapacity
+	if capacity <= 0 {
+		capacity = defaultParamsMaxCapacity
+	}
+	rule := &hotspot.Rule{
+		ID:                r.Id,
+		Resource:          r.Resource,
+		MetricType:        r.MetricType,
+		ControlBehavior:   r.ControlBehavior,
+		ParamIndex:        paramIndex,
+		Threshold:         r.Count,
+		MaxQueueingTimeMs: r.MaxQueueingTimeMs,
+		BurstCount:        r.Burst,
+		DurationInSec:     durationInSec,
+		ParamsMaxCapacity: capacity,
+		SpecificItems:     make([]hotspot.SpecificValue, 0),
+	}
+	if r.ParamItem != nil && r.ParamItem.Pattern != "" {
+		// Requests not matching the pattern should never be blocked.
+		rule.SpecificItems = append(rule.SpecificItems, hotspot.SpecificValue{
+			ValKind:   hotspot.KindString,
+			ValStr:    NotMatchParam,
+			Threshold: math.MaxInt32,
+		})
+	}
+	return rule
+}
+
+func (p *ParamItem) compile() error {
+	if p == nil || p.Pattern == "" || p.MatchStrategy != MatchStrategyRegex {
+		return nil
+	}
+	regex, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return err
+	}
+	p.regex = regex
+	return nil
+}
+
+func (p *ParamItem) parse(req RequestItem) string {
+	if p == nil {
+		return DefaultParam
+	}
+	var value string
+	switch p.ParseStrategy {
+	case ParseStrategyClientIp:
+		value = req.ClientIp()
+	case ParseStrategyHost:
+		value = req.Host()
+	case ParseStrategyHeader:
+		value = req.Header(p.FieldName)
+	case ParseStrategyUrlParam:
+		value = req.UrlParam(p.FieldName)
+	case ParseStrategyCookie:
+		value = req.Cookie(p.FieldName)
+	default:
+		return DefaultParam
+	}
+	if p.Pattern == "" {
+		return value
+	}
+	if p.matches(value) {
+		return value
+	}
+	return NotMatchParam
+}
+
+func (p *ParamItem) matches(value string) bool {
+	switch p.MatchStrategy {
+	case MatchStrategyExact:
+		return value == p.Pattern
+	case MatchStrategyPrefix:
+		return strings.HasPrefix(value, p.Pattern)
+	case MatchStrategyRegex:
+		return p.regex != nil && p.regex.MatchString(value)
+	case MatchStrategyContains:
+		return strings.Contains(value, p.Pattern)
+	default:
+		return false
+	}
+}
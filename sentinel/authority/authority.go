@@ -0,0 +1,176 @@
+// Package authority provides the origin black/white list control, as sentinel-golang
+// has no authority rules yet. The origin of the invocation should be carried via WithOrigin:
+//
+//	e, b := sentinel.Entry(resource, authority.WithOrigin(callerApp))
+//
+// The trusted origins pushed from the AHAS console bypass all protection, see IsTrusted.
+//
+// The origins are propagated across the services by the HTTP header, the gRPC metadata and
+// the Dubbo attachments, which the callers inject (e.g. InjectHeader) as the app name and the
+// callees extract (e.g. OriginFromHeader). The adapters (see the adapter packages) do both.
+package authority
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/pkg/errors"
+)
+
+// originAttachmentKey is the key of the origin in the entry attachments.
+type originAttachmentKey struct{}
+
+// BlockTypeAuthority is the block type of the authority rules, which is beyond the built-in ones.
+const BlockTypeAuthority = base.BlockType(64)
+
+type Strategy int32
+
+const (
+	// WhiteList only allows the listed origins.
+	WhiteList Strategy = iota
+	// BlackList rejects the listed origins.
+	BlackList
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case WhiteList:
+		return "WhiteList"
+	case BlackList:
+		return "BlackList"
+	default:
+		return "Undefined"
+	}
+}
+
+// Rule limits the origins which are allowed to access the resource.
+type Rule struct {
+	ID       string   `json:"id,omitempty"`
+	Resource string   `json:"resource"`
+	Strategy Strategy `json:"strategy"`
+	// LimitApps is the origins in the list.
+	LimitApps []string `json:"limitApps"`
+}
+
+func (r *Rule) String() string {
+	return fmt.Sprintf("{id=%s, resource=%s, strategy=%s, limitApps=%v}", r.ID, r.Resource, r.Strategy, r.LimitApps)
+}
+
+func (r *Rule) ResourceName() string {
+	return r.Resource
+}
+
+func (r *Rule) isValid() error {
+	if r.Resource == "" {
+		return errors.New("empty resource")
+	}
+	if r.Strategy != WhiteList && r.Strategy != BlackList {
+		return errors.Errorf("unknown strategy: %d", r.Strategy)
+	}
+	return nil
+}
+
+// passCheck reports whether the origin is allowed by the rule. Invocations
+// without origin are always allowed.
+func (r *Rule) passCheck(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	contains := false
+	for _, app := range r.LimitApps {
+		if strings.TrimSpace(app) == origin {
+			contains = true
+			break
+		}
+	}
+	if r.Strategy == BlackList {
+		return !contains
+	}
+	return contains
+}
+
+var (
+	rulesMux = &sync.RWMutex{}
+	ruleMap  = make(map[string][]*Rule)
+	initOnce sync.Once
+)
+
+// Init registers the authority checker to the global Sentinel slot chain.
+func Init() {
+	initOnce.Do(func() {
+		sentinel.GlobalSlotChain().AddRuleCheckSlotFirst(&checkSlot{})
+	})
+}
+
+// WithOrigin carries the origin (e.g. the caller app) of the invocation.
+func WithOrigin(origin string) sentinel.EntryOption {
+	return sentinel.WithAttachment(originAttachmentKey{}, origin)
+}
+
+// LoadRules replaces all authority rules. Invalid rules are skipped and reported in the error.
+func LoadRules(rules []*Rule) (bool, error) {
+	m := make(map[string][]*Rule)
+	invalid := make([]string, 0)
+	for _, r := range rules {
+		if r == nil {
+			continue
+		}
+		if err := r.isValid(); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", r, err.Error()))
+			continue
+		}
+		m[r.Resource] = append(m[r.Resource], r)
+	}
+	if len(m) > 0 {
+		Init()
+	}
+	rulesMux.Lock()
+	ruleMap = m
+	rulesMux.Unlock()
+	if len(invalid) > 0 {
+		return true, errors.Errorf("invalid authority rules: %s", strings.Join(invalid, "; "))
+	}
+	return true, nil
+}
+
+// GetRules returns all loaded authority rules.
+func GetRules() []Rule {
+	rulesMux.RLock()
+	defer rulesMux.RUnlock()
+	result := make([]Rule, 0)
+	for _, rs := range ruleMap {
+		for _, r := range rs {
+			result = append(result, *r)
+		}
+	}
+	return result
+}
+
+func getRulesOfResource(resource string) []*Rule {
+	rulesMux.RLock()
+	defer rulesMux.RUnlock()
+	return ruleMap[resource]
+}
+
+type checkSlot struct {
+}
+
+func (s *checkSlot) Check(ctx *base.EntryContext) *base.TokenResult {
+	if ctx == nil || ctx.Resource == nil || ctx.Input == nil {
+		return base.NewTokenResultPass()
+	}
+	rules := getRulesOfResource(ctx.Resource.Name())
+	if len(rules) == 0 {
+		return base.NewTokenResultPass()
+	}
+	origin, _ := ctx.Input.Attachments[originAttachmentKey{}].(string)
+	for _, r := range rules {
+		if !r.passCheck(origin) {
+			return base.NewTokenResultBlockedWithCause(BlockTypeAuthority, "authority check failed, origin: "+origin, r, origin)
+		}
+	}
+	return base.NewTokenResultPass()
+}
@@ -0,0 +1,77 @@
+package authority
+
+import (
+	"net/http"
+	"strings"
+
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+)
+
+const (
+	// OriginHeader is the HTTP header carrying the origin of the requests, which is set by
+	// the clients and thus could be forged, see IsTrusted.
+	OriginHeader = "X-AHAS-Origin"
+	// OriginMetadataKey is the gRPC metadata key carrying the origin, in lower case as gRPC requires.
+	OriginMetadataKey = "x-ahas-origin"
+	// OriginAttachmentKey is the Dubbo attachment carrying the origin, the same as the Java SDK.
+	OriginAttachmentKey = "dubboApplication"
+)
+
+// LocalOrigin returns the origin of the outgoing calls of this process, which is the app name.
+func LocalOrigin() string {
+	return sentinelConf.AppName()
+}
+
+// OriginFromHeader returns the origin carried by the HTTP header, or empty if absent.
+func OriginFromHeader(h http.Header) string {
+	return strings.TrimSpace(h.Get(OriginHeader))
+}
+
+// InjectHeader carries the local origin by the HTTP header, unless an origin is carried already.
+func InjectHeader(h http.Header) {
+	if h.Get(OriginHeader) == "" {
+		h.Set(OriginHeader, LocalOrigin())
+	}
+}
+
+// OriginFromMetadata returns the origin carried by the gRPC metadata (metadata.MD), or empty if absent.
+func OriginFromMetadata(md map[string][]string) string {
+	if values := md[OriginMetadataKey]; len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+// InjectMetadata carries the local origin by the gRPC metadata (metadata.MD), unless an origin
+// is carried already.
+func InjectMetadata(md map[string][]string) {
+	if len(md[OriginMetadataKey]) == 0 {
+		md[OriginMetadataKey] = []string{LocalOrigin()}
+	}
+}
+
+// Carrier is the string key-value carrier of the origin, e.g. the transport headers of Kratos
+// and the metadata of go-micro. The origin is carried by OriginMetadataKey, the same as the
+// gRPC metadata, which matches OriginHeader in the case-insensitive HTTP headers as well.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// OriginFromCarrier returns the origin carried by the carrier, or empty if absent.
+func OriginFromCarrier(c Carrier) string {
+	return strings.TrimSpace(c.Get(OriginMetadataKey))
+}
+
+// InjectCarrier carries the local origin by the carrier, unless an origin is carried already.
+func InjectCarrier(c Carrier) {
+	if c.Get(OriginMetadataKey) == "" {
+		c.Set(OriginMetadataKey, LocalOrigin())
+	}
+}
+
+// OriginFromAttachments returns the origin carried by the Dubbo attachments, or empty if absent.
+func OriginFromAttachments(attachments map[string]interface{}) string {
+	origin, _ := attachments[OriginAttachmentKey].(string)
+	return strings.TrimSpace(origin)
+}
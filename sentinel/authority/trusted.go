@@ -0,0 +1,58 @@
+package authority
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedOrigins is the set of origins pushed from the AHAS console, which bypass all protection.
+var trustedOrigins atomic.Value
+
+func init() {
+	trustedOrigins.Store(make(map[string]struct{}))
+}
+
+// SetTrustedOrigins replaces the trusted origins, e.g. the health checkers and internal probes,
+// which should not be throttled during incidents. An empty list clears the trusted origins.
+func SetTrustedOrigins(origins []string) {
+	m := make(map[string]struct{}, len(origins))
+	for _, o := range origins {
+		if o = strings.TrimSpace(o); o != "" {
+			m[o] = struct{}{}
+		}
+	}
+	trustedOrigins.Store(m)
+}
+
+// TrustedOrigins returns the current trusted origins in order.
+func TrustedOrigins() []string {
+	m := trustedOrigins.Load().(map[string]struct{})
+	result := make([]string, 0, len(m))
+	for o := range m {
+		result = append(result, o)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// IsTrusted reports whether the origin is trusted. The adapters consult it before the
+// Sentinel entry, and bypass all checks (flow control, circuit breaking, etc.) for trusted
+// origins, as the slot chain could not skip the other checks:
+//
+//	if authority.IsTrusted(origin) {
+//		return next(req)
+//	}
+//	e, b := sentinel.Entry(resource, authority.WithOrigin(origin))
+//
+// Note that the propagated origins (e.g. OriginHeader) are set by the callers, so any client
+// could claim a trusted origin. The services exposed to the untrusted clients should extract
+// the origins from the authenticated identities instead (e.g. the WithOriginExtractor
+// options of the adapters), or not trust any origins.
+func IsTrusted(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	_, ok := trustedOrigins.Load().(map[string]struct{})[origin]
+	return ok
+}
@@ -0,0 +1,129 @@
+// Package blocklog records the recent block events of Sentinel in memory.
+package blocklog
+
+import (
+	"sync"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/util"
+)
+
+const DefaultCapacity = 1024
+
+type Event struct {
+	Timestamp uint64 `json:"timestamp"`
+	Resource  string `json:"resource"`
+	BlockType string `json:"blockType"`
+	Rule      string `json:"rule,omitempty"`
+}
+
+// Listener is notified of each block event. It must not block.
+type Listener func(e Event)
+
+var (
+	events   = newEventRing(DefaultCapacity)
+	initOnce sync.Once
+
+	listenerMux = &sync.RWMutex{}
+	listeners   = make([]Listener, 0)
+)
+
+// Init registers the block event recorder to the global Sentinel slot chain.
+func Init() {
+	initOnce.Do(func() {
+		sentinel.GlobalSlotChain().AddStatSlotLast(&recordSlot{})
+	})
+}
+
+// Recent returns at most n latest block events, in chronological order.
+func Recent(n int) []Event {
+	return events.latest(n)
+}
+
+// Record appends a block event manually.
+func Record(e Event) {
+	events.add(e)
+	notify(e)
+}
+
+// AddListener adds the listener of block events.
+func AddListener(l Listener) {
+	if l == nil {
+		return
+	}
+	listenerMux.Lock()
+	defer listenerMux.Unlock()
+	listeners = append(listeners, l)
+}
+
+func notify(e Event) {
+	listenerMux.RLock()
+	defer listenerMux.RUnlock()
+	for _, l := range listeners {
+		l(e)
+	}
+}
+
+type recordSlot struct {
+}
+
+func (s *recordSlot) OnEntryPassed(_ *base.EntryContext) {
+}
+
+func (s *recordSlot) OnEntryBlocked(ctx *base.EntryContext, blockError *base.BlockError) {
+	if ctx == nil || ctx.Resource == nil || blockError == nil {
+		return
+	}
+	e := Event{
+		Timestamp: util.CurrentTimeMillis(),
+		Resource:  ctx.Resource.Name(),
+		BlockType: blockError.BlockType().String(),
+	}
+	if rule := blockError.TriggeredRule(); rule != nil {
+		e.Rule = rule.String()
+	}
+	Record(e)
+}
+
+func (s *recordSlot) OnCompleted(_ *base.EntryContext) {
+}
+
+// eventRing is a fixed-size ring buffer of block events.
+type eventRing struct {
+	mux  sync.RWMutex
+	buf  []Event
+	next int
+	full bool
+}
+
+func newEventRing(capacity int) *eventRing {
+	return &eventRing{buf: make([]Event, capacity)}
+}
+
+func (r *eventRing) add(e Event) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *eventRing) latest(n int) []Event {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	size := r.next
+	if r.full {
+		size = len(r.buf)
+	}
+	if n <= 0 || n > size {
+		n = size
+	}
+	result := make([]Event, 0, n)
+	for i := n; i > 0; i-- {
+		result = append(result, r.buf[(r.next-i+len(r.buf))%len(r.buf)])
+	}
+	return result
+}
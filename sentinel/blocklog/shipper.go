@@ -0,0 +1,294 @@
+package blocklog
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	ShipServerName = "Sentinel"
+	// ShipHandlerName is the upstream handler receiving the block events, which is only
+	// invoked if the server acknowledges transport.CapabilityBlockLog.
+	ShipHandlerName = "blockLog"
+
+	DefaultShipSampleRate      = 0.1
+	DefaultShipBatchSize       = 100
+	DefaultShipFlushIntervalMs = 5000
+	DefaultShipQueueSize       = 1000
+
+	maxShipBackoff = 60 * time.Second
+)
+
+type ShipConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SampleRate is the ratio (0, 1] of block events to ship.
+	SampleRate float64 `yaml:"sampleRate"`
+	// BatchSize is the maximum number of events in one request.
+	BatchSize int `yaml:"batchSize"`
+	// FlushIntervalMs is the maximum interval to ship a partial batch.
+	FlushIntervalMs uint64 `yaml:"flushIntervalMs"`
+	// QueueSize is the capacity of pending events. Events are dropped once the queue is full.
+	QueueSize int `yaml:"queueSize"`
+}
+
+type shipper struct {
+	conf    ShipConfig
+	tsp     *transport.Transport
+	queue   chan Event
+	dropped uint64
+	rand    *rand.Rand
+	randMux sync.Mutex
+	stopped int32
+	flushCh chan chan error
+	stopCh  chan struct{}
+}
+
+var (
+	shipMux = &sync.Mutex{}
+	// currentShipper is the running shipper, and the block event listener is registered only once.
+	currentShipper *shipper
+	activeShipper  atomic.Value
+	listenOnce     sync.Once
+)
+
+// StartShipper starts shipping the sampled block events to the AHAS backend in batches.
+// The failed batch is kept and retried with backoff. When the backend is slow or unavailable,
+// the pending queue fills up and new events are dropped (and counted) rather than blocking
+// the business goroutines.
+func StartShipper(conf ShipConfig, tsp *transport.Transport) {
+	if !conf.Enabled || tsp == nil {
+		return
+	}
+	shipMux.Lock()
+	defer shipMux.Unlock()
+	if currentShipper != nil {
+		return
+	}
+	if conf.SampleRate <= 0 || conf.SampleRate > 1 {
+		conf.SampleRate = DefaultShipSampleRate
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = DefaultShipBatchSize
+	}
+	if conf.FlushIntervalMs == 0 {
+		conf.FlushIntervalMs = DefaultShipFlushIntervalMs
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = DefaultShipQueueSize
+	}
+	s := &shipper{
+		conf:    conf,
+		tsp:     tsp,
+		queue:   make(chan Event, conf.QueueSize),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		flushCh: make(chan chan error),
+		stopCh:  make(chan struct{}),
+	}
+	currentShipper = s
+	activeShipper.Store(s)
+	Init()
+	listenOnce.Do(func() {
+		AddListener(offerToCurrentShipper)
+	})
+	go s.run()
+	logger.Infof("Block log shipper started, sampleRate: %.2f, batchSize: %d", conf.SampleRate, conf.BatchSize)
+}
+
+// StopShipper stops the running shipper. Pending events are discarded.
+func StopShipper() {
+	shipMux.Lock()
+	defer shipMux.Unlock()
+	if currentShipper == nil {
+		return
+	}
+	atomic.StoreInt32(&currentShipper.stopped, 1)
+	close(currentShipper.stopCh)
+	currentShipper = nil
+}
+
+// FlushShipper ships the pending events at once, e.g. before the process exits.
+// The context bounds the waiting for the shipping.
+func FlushShipper(ctx context.Context) error {
+	shipMux.Lock()
+	s := currentShipper
+	shipMux.Unlock()
+	if s == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	select {
+	case s.flushCh <- done:
+	case <-s.stopCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func offerToCurrentShipper(e Event) {
+	if s, ok := activeShipper.Load().(*shipper); ok {
+		s.offer(e)
+	}
+}
+
+func (s *shipper) sampled() bool {
+	if s.conf.SampleRate >= 1 {
+		return true
+	}
+	s.randMux.Lock()
+	defer s.randMux.Unlock()
+	return s.rand.Float64() < s.conf.SampleRate
+}
+
+// offer enqueues the event without blocking.
+func (s *shipper) offer(e Event) {
+	if atomic.LoadInt32(&s.stopped) == 1 || !s.sampled() {
+		return
+	}
+	select {
+	case s.queue <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+func (s *shipper) run() {
+	defer tools.PrintPanicStackV2("block log shipper")
+
+	interval := time.Duration(s.conf.FlushIntervalMs) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, s.conf.BatchSize)
+	backoff := time.Duration(0)
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) < s.conf.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case done := <-s.flushCh:
+			var err error
+			batch, err = s.flush(batch)
+			done <- err
+			continue
+		case <-s.stopCh:
+			return
+		}
+		if err := s.ship(batch); err != nil {
+			// Back off on failure. The queue keeps absorbing (or dropping) events meanwhile.
+			if backoff == 0 {
+				backoff = interval
+			} else if backoff *= 2; backoff > maxShipBackoff {
+				backoff = maxShipBackoff
+			}
+			logger.Warnf("Failed to ship %d block logs, retry after %v: %+v", len(batch), backoff, err)
+			batch = s.trim(batch)
+			select {
+			case <-time.After(backoff):
+			case <-s.stopCh:
+				return
+			}
+			// The batch is kept, and retried with the next event or tick.
+			continue
+		}
+		backoff = 0
+		batch = batch[:0]
+	}
+}
+
+// trim drops (and counts) the oldest events of the failed batch beyond the queue size,
+// so that the retried batch doesn't grow without bound.
+func (s *shipper) trim(batch []Event) []Event {
+	overflow := len(batch) - s.conf.QueueSize
+	if overflow <= 0 {
+		return batch
+	}
+	atomic.AddUint64(&s.dropped, uint64(overflow))
+	return append(batch[:0], batch[overflow:]...)
+}
+
+// flush ships the batch and the queued events in batches, and returns the events failed to
+// ship, which are kept for retry.
+func (s *shipper) flush(batch []Event) ([]Event, error) {
+	for {
+	drain:
+		for len(batch) < s.conf.BatchSize {
+			select {
+			case e := <-s.queue:
+				batch = append(batch, e)
+			default:
+				break drain
+			}
+		}
+		if len(batch) == 0 {
+			return batch, nil
+		}
+		if err := s.ship(batch); err != nil {
+			return s.trim(batch), err
+		}
+		if len(batch) < s.conf.BatchSize {
+			return batch[:0], nil
+		}
+		batch = batch[:0]
+	}
+}
+
+// ship ships the batch, which is discarded if the server doesn't support the block logs.
+func (s *shipper) ship(batch []Event) (err error) {
+	if !s.tsp.SupportsUpstream(transport.CapabilityBlockLog) {
+		return nil
+	}
+	bs, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	dropped := atomic.SwapUint64(&s.dropped, 0)
+	defer func() {
+		if err != nil {
+			// The dropped count is reported with the retry.
+			atomic.AddUint64(&s.dropped, dropped)
+		}
+	}()
+	request := transport.NewRequest()
+	request.AddParam("logs", string(bs))
+	request.AddParam("dropped", strconv.FormatUint(dropped, 10))
+	request.AddParam("sampleRate", strconv.FormatFloat(s.conf.SampleRate, 'f', -1, 64))
+	uri := transport.NewUri(ShipServerName, ShipHandlerName)
+	uri.CompressVersion = transport.AllCompress
+	response, err := s.tsp.Invoke(uri, request)
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return &shipError{response: response}
+	}
+	return nil
+}
+
+type shipError struct {
+	response *transport.Response
+}
+
+func (e *shipError) Error() string {
+	return "bad response, code: " + strconv.Itoa(int(e.response.Code)) + ", error: " + e.response.Error
+}
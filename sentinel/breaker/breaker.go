@@ -0,0 +1,106 @@
+// Package breaker tracks the latest states of the circuit breakers.
+package breaker
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/util"
+)
+
+type StateRecord struct {
+	Resource string `json:"resource"`
+	RuleId   string `json:"ruleId"`
+	Strategy string `json:"strategy"`
+	State    string `json:"state"`
+	// PreviousState is the state before the transition.
+	PreviousState string `json:"previousState,omitempty"`
+	Timestamp     uint64 `json:"timestamp"`
+}
+
+// Listener is notified of each state transition of the circuit breakers. It must not block.
+type Listener func(r StateRecord)
+
+var (
+	mux      = &sync.RWMutex{}
+	states   = make(map[string]StateRecord)
+	initOnce sync.Once
+
+	listenerMux = &sync.RWMutex{}
+	listeners   = make([]Listener, 0)
+)
+
+// AddListener adds the listener of the state transitions, and starts tracking the states.
+func AddListener(l Listener) {
+	if l == nil {
+		return
+	}
+	Init()
+	listenerMux.Lock()
+	defer listenerMux.Unlock()
+	listeners = append(listeners, l)
+}
+
+func notify(r StateRecord) {
+	listenerMux.RLock()
+	defer listenerMux.RUnlock()
+	for _, l := range listeners {
+		l(r)
+	}
+}
+
+// Init registers the state tracker as a circuit breaker state change listener.
+func Init() {
+	initOnce.Do(func() {
+		circuitbreaker.RegisterStateChangeListeners(&stateTracker{})
+	})
+}
+
+// States returns the latest known states of the circuit breakers, sorted by resource.
+// Circuit breakers which have never changed state (i.e. still closed) are absent.
+func States() []StateRecord {
+	mux.RLock()
+	defer mux.RUnlock()
+	result := make([]StateRecord, 0, len(states))
+	for _, s := range states {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Resource == result[j].Resource {
+			return result[i].RuleId < result[j].RuleId
+		}
+		return result[i].Resource < result[j].Resource
+	})
+	return result
+}
+
+func record(rule circuitbreaker.Rule, prev, state circuitbreaker.State) {
+	r := StateRecord{
+		Resource:      rule.Resource,
+		RuleId:        rule.Id,
+		Strategy:      rule.Strategy.String(),
+		State:         state.String(),
+		PreviousState: prev.String(),
+		Timestamp:     util.CurrentTimeMillis(),
+	}
+	mux.Lock()
+	states[rule.Resource+"|"+rule.Id] = r
+	mux.Unlock()
+	notify(r)
+}
+
+type stateTracker struct {
+}
+
+func (t *stateTracker) OnTransformToClosed(prev circuitbreaker.State, rule circuitbreaker.Rule) {
+	record(rule, prev, circuitbreaker.Closed)
+}
+
+func (t *stateTracker) OnTransformToOpen(prev circuitbreaker.State, rule circuitbreaker.Rule, _ interface{}) {
+	record(rule, prev, circuitbreaker.Open)
+}
+
+func (t *stateTracker) OnTransformToHalfOpen(prev circuitbreaker.State, rule circuitbreaker.Rule) {
+	record(rule, prev, circuitbreaker.HalfOpen)
+}
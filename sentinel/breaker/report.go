@@ -0,0 +1,129 @@
+package breaker
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
+)
+
+const (
+	ReportServerName = "Sentinel"
+	// ReportHandlerName is the upstream handler receiving the state transitions, which is
+	// only invoked if the server acknowledges transport.CapabilityBreakerEvent.
+	ReportHandlerName = "breakerEvent"
+
+	reportQueueSize = 256
+)
+
+type reporter struct {
+	tsp    *transport.Transport
+	queue  chan StateRecord
+	stopCh chan struct{}
+}
+
+var (
+	reportMux       = &sync.Mutex{}
+	currentReporter *reporter
+	activeReporter  atomic.Value
+	reportOnce      sync.Once
+)
+
+// StartReporter starts reporting the state transitions of the circuit breakers to AHAS, so that
+// the console could show when the breakers tripped. The transitions are dropped rather than
+// blocking the business goroutines when AHAS is slow.
+func StartReporter(tsp *transport.Transport) {
+	if tsp == nil {
+		return
+	}
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if currentReporter != nil {
+		return
+	}
+	r := &reporter{
+		tsp:    tsp,
+		queue:  make(chan StateRecord, reportQueueSize),
+		stopCh: make(chan struct{}),
+	}
+	currentReporter = r
+	activeReporter.Store(r)
+	reportOnce.Do(func() {
+		AddListener(offerToCurrentReporter)
+	})
+	go r.run()
+}
+
+// StopReporter stops the running reporter. Pending transitions are discarded.
+func StopReporter() {
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if currentReporter == nil {
+		return
+	}
+	close(currentReporter.stopCh)
+	currentReporter = nil
+	activeReporter.Store((*reporter)(nil))
+}
+
+func offerToCurrentReporter(rec StateRecord) {
+	r, _ := activeReporter.Load().(*reporter)
+	if r == nil || !feature.Enabled(feature.BreakerReport) {
+		return
+	}
+	select {
+	case r.queue <- rec:
+	default:
+		logger.Warnf("Circuit breaker state report queue is full, dropped: %+v", rec)
+	}
+}
+
+func (r *reporter) run() {
+	defer tools.PrintPanicStackV2("circuit breaker state reporter")
+	for {
+		select {
+		case rec := <-r.queue:
+			// Report the transitions piled up meanwhile together.
+			batch := []StateRecord{rec}
+		drain:
+			for len(batch) < reportQueueSize {
+				select {
+				case rec = <-r.queue:
+					batch = append(batch, rec)
+				default:
+					break drain
+				}
+			}
+			if err := r.report(batch); err != nil {
+				logger.Warnf("Failed to report %d circuit breaker state transitions: %+v", len(batch), err)
+			}
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *reporter) report(batch []StateRecord) error {
+	if !r.tsp.SupportsUpstream(transport.CapabilityBreakerEvent) {
+		return nil
+	}
+	bs, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	request := transport.NewRequest()
+	request.AddParam("events", string(bs))
+	response, err := r.tsp.Invoke(transport.NewUri(ReportServerName, ReportHandlerName), request)
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.Errorf("bad response, code: %d, error: %s", response.Code, response.Error)
+	}
+	return nil
+}
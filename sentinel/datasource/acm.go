@@ -1,20 +1,23 @@
 package datasource
 
 import (
-	"encoding/json"
+	"context"
+	"sync"
 	"time"
 
 	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
-	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
 	"github.com/alibaba/sentinel-golang/core/flow"
-	"github.com/alibaba/sentinel-golang/core/hotspot"
 	"github.com/alibaba/sentinel-golang/core/system"
 	sentinelLogger "github.com/alibaba/sentinel-golang/logging"
+	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
 	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/apigateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
 	"github.com/nacos-group/nacos-sdk-go/clients"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
-	"github.com/nacos-group/nacos-sdk-go/vo"
 	"github.com/pkg/errors"
 )
 
@@ -25,188 +28,615 @@ const (
 	SystemRuleDataIdPrefix          = "system-rule-"
 	CircuitBreakingRuleDataIdPrefix = "degrade-rule-"
 	ParamFlowRuleDataIdPrefix       = "param-flow-rule-"
+	GatewayFlowRuleDataIdPrefix     = "gateway-flow-rule-"
+	AuthorityRuleDataIdPrefix       = "authority-rule-"
+
+	// AppResourceSeparator separates the app name and the resource name in namespaced resources.
+	AppResourceSeparator = ":"
 )
 
-func formFlowRuleDataId(userId, namespace, appName string) string {
-	return FlowRuleDataIdPrefix + userId + "-" + namespace + "-" + appName
+var (
+	flowRuleSet = registerGuardedRuleSet(newGuardedRuleSet("flow", func(rules []interface{}) error {
+		arr := make([]*flow.FlowRule, 0, len(rules))
+		for _, r := range rules {
+			arr = append(arr, r.(*flow.FlowRule))
+		}
+		_, err := flow.LoadRules(arr)
+		return err
+	}).withShadowChecker(flowRuleWouldBlock).withConflictDetection(FlowRuleKind, flowRuleConflictKey))
+	systemRuleSet = registerGuardedRuleSet(newGuardedRuleSet("system", func(rules []interface{}) error {
+		arr := make([]*system.SystemRule, 0, len(rules))
+		for _, r := range rules {
+			arr = append(arr, r.(*system.SystemRule))
+		}
+		_, err := system.LoadRules(arr)
+		return err
+	}).withConflictDetection(SystemRuleKind, systemRuleConflictKey))
+	circuitBreakingRuleSet = registerGuardedRuleSet(newGuardedRuleSet("circuit breaking", func(rules []interface{}) error {
+		arr := make([]*circuitbreaker.Rule, 0, len(rules))
+		for _, r := range rules {
+			arr = append(arr, r.(*circuitbreaker.Rule))
+		}
+		_, err := circuitbreaker.LoadRules(arr)
+		return err
+	}).withShadowChecker(circuitBreakingRuleWouldBlock).withConflictDetection(CircuitBreakingRuleKind, circuitBreakingRuleConflictKey))
+	paramFlowRuleSet = registerGuardedRuleSet(newGuardedRuleSet("hot-spot parameter flow", loadParamFlowRules).withConflictDetection(ParamFlowRuleKind, paramFlowRuleConflictKey))
+	authorityRuleSet = registerGuardedRuleSet(newGuardedRuleSet("authority", func(rules []interface{}) error {
+		arr := make([]*authority.Rule, 0, len(rules))
+		for _, r := range rules {
+			arr = append(arr, r.(*authority.Rule))
+		}
+		_, err := authority.LoadRules(arr)
+		return err
+	}))
+)
+
+// LoadedRules returns the rules of the given type currently loaded into Sentinel, which
+// Sentinel itself only exposes by resource for some types (e.g. the circuit breaking rules).
+func LoadedRules(kind RuleKind) []interface{} {
+	var s *guardedRuleSet
+	switch kind {
+	case FlowRuleKind:
+		s = flowRuleSet
+	case SystemRuleKind:
+		s = systemRuleSet
+	case CircuitBreakingRuleKind:
+		s = circuitBreakingRuleSet
+	case ParamFlowRuleKind:
+		s = paramFlowRuleSet
+	case AuthorityRuleKind:
+		s = authorityRuleSet
+	default:
+		return nil
+	}
+	return s.loadedRules()
 }
 
-func formSystemRuleDataId(userId, namespace, appName string) string {
-	return SystemRuleDataIdPrefix + userId + "-" + namespace + "-" + appName
+// InitAcm initializes the ACM data-source and subscribes to all rule dataIds.
+func InitAcm(acmHost string, conf Config, m *meta.Meta) error {
+	return InitAcmWithContext(context.Background(), acmHost, conf, m)
 }
 
-func formCircuitBreakingRuleDataId(userId, namespace, appName string) string {
-	return CircuitBreakingRuleDataIdPrefix + userId + "-" + namespace + "-" + appName
+// InitAcmWithContext initializes the ACM data-source like InitAcm. The context bounds
+// the waiting for AHAS transport, and the background re-subscription will stop
+// once the context is done. Any previously initialized data-source will be closed.
+func InitAcmWithContext(ctx context.Context, acmHost string, conf Config, m *meta.Meta) error {
+	sources, err := conf.sources()
+	if err != nil {
+		return err
+	}
+	return initAcm(ctx, processSubscription, acmHost, conf, m, sources)
 }
 
-func formParamFlowRuleDataId(userId, namespace, appName string) string {
-	return ParamFlowRuleDataIdPrefix + userId + "-" + namespace + "-" + appName
+// InitAcmForApps initializes the ACM data-source which subscribes to the rules of
+// all given apps, for processes hosting multiple logical apps. The resources of the
+// rules are namespaced by the app name, see AppResource.
+func InitAcmForApps(apps []string, acmHost string, conf Config, m *meta.Meta) error {
+	return InitAcmForAppsWithContext(context.Background(), apps, acmHost, conf, m)
 }
 
-func InitAcm(acmHost string, conf Config, m *meta.Meta) error {
-	ch := m.TidChan()
-	select {
-	case <-ch:
-		break
-	case <-time.After(30 * time.Second):
-		return errors.New("wait AHAS transport timeout")
+// InitAcmForAppsWithContext is the context-aware version of InitAcmForApps.
+func InitAcmForAppsWithContext(ctx context.Context, apps []string, acmHost string, conf Config, m *meta.Meta) error {
+	sources, err := appSources(apps)
+	if err != nil {
+		return err
+	}
+	return initAcm(ctx, processSubscription, acmHost, conf, m, sources)
+}
+
+// LoadLocalRules applies the local rules (the snapshots and the environment variables) of
+// current app at once, e.g. to protect the application before AHAS is reachable. They are
+// loaded again, and overridden by the delivered rules, once the data-source is initialized.
+func LoadLocalRules(conf Config) {
+	applyConfig(conf)
+	sources, err := conf.sources()
+	if err != nil {
+		logger.Warnf("Failed to load local rules: %+v", err)
+		return
+	}
+	composite := newCompositeSource(conf.SnapshotDir)
+	for _, src := range sources {
+		for _, h := range src.handlers() {
+			composite.loadLocal(src.app, h.kind, h.onChange)
+		}
+	}
+}
+
+func appSources(apps []string) ([]*ruleSource, error) {
+	if len(apps) == 0 {
+		return nil, errors.New("empty app list")
+	}
+	sources := make([]*ruleSource, 0, len(apps))
+	seen := make(map[string]bool)
+	for _, app := range apps {
+		if app == "" || seen[app] {
+			continue
+		}
+		seen[app] = true
+		sources = append(sources, &ruleSource{app: app, resourcePrefix: AppResource(app, "")})
+	}
+	return sources, nil
+}
+
+// applyConfig applies the config shared by all rule kinds.
+func applyConfig(conf Config) {
+	setShadowPeriod(time.Duration(conf.ShadowPeriodMs) * time.Millisecond)
+	setParamsMaxCapacity(conf.paramsMaxCapacity())
+	setRuleHistorySize(conf.ruleHistorySize())
+	setAllowEmptyRuleClear(conf.AllowEmptyRuleClear)
+	SetLocalRulePolicy(conf.LocalRulePolicy)
+}
+
+func initAcm(ctx context.Context, s *Subscription, acmHost string, conf Config, m *meta.Meta, sources []*ruleSource) error {
+	if !conf.NonBlockingStartup {
+		return initAcmOnce(ctx, s, acmHost, conf, m, sources)
+	}
+	go func() {
+		defer tools.PrintPanicStackV2("ACM data source startup")
+		backoff := minStartupRetryBackoff
+		for {
+			err := initAcmOnce(ctx, s, acmHost, conf, m, sources)
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			logger.Warnf("Failed to initialize ACM data source, will retry in %v: %+v", backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > maxStartupRetryBackoff {
+				backoff = maxStartupRetryBackoff
+			}
+		}
+	}()
+	return nil
+}
+
+func initAcmOnce(ctx context.Context, s *Subscription, acmHost string, conf Config, m *meta.Meta, sources []*ruleSource) error {
+	timeout := conf.startupTimeout()
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	_, err := m.WaitForTid(waitCtx)
+	cancel()
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		return errors.Errorf("wait AHAS transport timeout after %v", timeout)
+	} else if err != nil {
+		return err
 	}
 
 	clientConfig := constant.ClientConfig{
 		TimeoutMs:      conf.TimeoutMs,
 		ListenInterval: conf.ListenIntervalMs,
 		NamespaceId:    m.Tid(),
-		Endpoint:       acmHost + ":8080",
+		Endpoint:       conf.acmEndpoint(acmHost),
+		RegionId:       conf.Credential.RegionId,
+		OpenKMS:        conf.Credential.OpenKMS,
+
+		CacheDir:            conf.Nacos.CacheDir,
+		LogDir:              conf.Nacos.LogDir,
+		LogLevel:            conf.Nacos.LogLevel,
+		NotLoadCacheAtStart: conf.Nacos.NotLoadCacheAtStart,
 	}
-	configClient, err := clients.CreateConfigClient(map[string]interface{}{
+	var signed bool
+	clientConfig.AccessKey, clientConfig.SecretKey, signed = conf.Credential.keys()
+	if conf.Credential.OpenKMS && clientConfig.AccessKey == "" {
+		if signed {
+			return errors.Errorf("AccessKey is required to decrypt KMS-encrypted configs, the STS credentials of "+
+				"provider %s are not applicable, as the Nacos client decrypts with the AccessKey pair only",
+				aliyun.CredentialsProviderName())
+		}
+		return errors.New("AccessKey is required to decrypt KMS-encrypted configs")
+	}
+	if signed {
+		logger.Infof("ACM requests are signed with the credentials of provider %s", aliyun.CredentialsProviderName())
+	}
+	properties := map[string]interface{}{
 		"clientConfig": clientConfig,
-	})
-	if err != nil {
-		return err
 	}
-
-	flowRuleDataId := formFlowRuleDataId(m.Uid(), meta.Namespace(), sentinelConf.AppName())
-	err = configClient.ListenConfig(vo.ConfigParam{
-		Group:  AcmGroupId,
-		DataId: flowRuleDataId,
-		OnChange: func(namespace, group, dataId, data string) {
-			onFlowRuleChange(data)
-		},
-	})
+	agent, err := newAcmHttpAgent(signed, conf)
 	if err != nil {
 		return err
 	}
-	// Add system rule config listener.
-	err = configClient.ListenConfig(vo.ConfigParam{
-		Group:  AcmGroupId,
-		DataId: formSystemRuleDataId(m.Uid(), meta.Namespace(), sentinelConf.AppName()),
-		OnChange: func(namespace, group, dataId, data string) {
-			onSystemRuleChange(data)
-		},
-	})
+	properties["httpAgent"] = agent
+	configClient, err := clients.CreateConfigClient(properties)
 	if err != nil {
 		return err
 	}
-	// Add circuit breaking rule config listener.
-	err = configClient.ListenConfig(vo.ConfigParam{
-		Group:  AcmGroupId,
-		DataId: formCircuitBreakingRuleDataId(m.Uid(), meta.Namespace(), sentinelConf.AppName()),
-		OnChange: func(namespace, group, dataId, data string) {
-			onCircuitBreakingRuleChange(data)
-		},
-	})
-	if err != nil {
-		return err
+	applyConfig(conf)
+	ds := newAcmDataSource(ctx, configClient)
+	if err = s.install(ds, nil); err != nil {
+		logger.Warnf("Failed to close previous ACM data source: %+v", err)
 	}
-	// Add param flow rule config listener.
-	err = configClient.ListenConfig(vo.ConfigParam{
-		Group:  AcmGroupId,
-		DataId: formParamFlowRuleDataId(m.Uid(), meta.Namespace(), sentinelConf.AppName()),
-		OnChange: func(namespace, group, dataId, data string) {
-			onParamFlowRuleChange(data)
-		},
-	})
-	if err != nil {
-		return err
+
+	group := conf.group()
+	uid, namespace := m.Uid(), m.Namespace()
+	// Apply the rules in the background, so that rapid pushes neither block the ACM listener nor thrash LoadRules.
+	pipeline := newRulePipeline(ds.ctx, conf.debounceWindow())
+	go pipeline.run()
+	composite := newCompositeSource(conf.SnapshotDir)
+	subscriptions := make([]*acmSubscription, 0, len(sources)*6)
+	for _, src := range sources {
+		for _, h := range src.handlers() {
+			composite.loadLocal(src.app, h.kind, h.onChange)
+			dataId := conf.formDataId(h.dataIdPrefix, uid, namespace, src.app)
+			subscriptions = append(subscriptions, &acmSubscription{
+				group:    group,
+				dataId:   dataId,
+				onChange: pipeline.debounced(dataId, composite.sourced(src.app, h.kind, AcmSourcePriority, h.onChange)),
+			})
+			if conf.OverrideLabel != "" {
+				overrideDataId := dataId + "-" + conf.OverrideLabel
+				subscriptions = append(subscriptions, &acmSubscription{
+					group:    group,
+					dataId:   overrideDataId,
+					onChange: pipeline.debounced(overrideDataId, composite.overridden(src.app, h.kind, h.onChange)),
+				})
+			}
+		}
+	}
+	ds.track(subscriptions)
+	failed := make([]*acmSubscription, 0)
+	for _, sub := range subscriptions {
+		if err := ds.listenWithRetry(sub, initialListenRetryTimes); err != nil {
+			if ds.ctx.Err() != nil {
+				return ds.ctx.Err()
+			}
+			logger.Warnf("Failed to register ACM listener for dataId %s, will retry in background: %+v", sub.dataId, err)
+			failed = append(failed, sub)
+		}
+	}
+	if len(failed) > 0 {
+		// The data-source has been installed and keeps retrying the failed listeners, so it's
+		// not a failure of the initialization, which would otherwise be retried (or torn down
+		// in fail-fast mode) with another config client.
+		go ds.superviseFailedSubscriptions(failed)
+		logger.Warnf("ACM data source initialized partially, %d of %d listeners failed to register and are retrying in background",
+			len(failed), len(subscriptions))
+		return nil
 	}
 
 	sentinelLogger.Info("ACM data source initialized successfully")
-	logger.Infof("ACM data source initialized successfully, flow dataId: %s", flowRuleDataId)
+	logger.Infof("ACM data source initialized successfully, group: %s, flow dataId: %s", group, subscriptions[0].dataId)
 	return nil
 }
 
-func onFlowRuleChange(data string) {
-	sentinelLogger.Infof("ACM data received for flow rules: %v", data)
-	d := &struct {
-		Version string
-		Data    []*LegacyFlowRule
-	}{}
-	err := json.Unmarshal([]byte(data), d)
+// Close cancels all ACM listeners of current data-source and releases the config client,
+// or stops receiving the pushed rules in push mode. It's safe to call Close multiple times.
+func Close() error {
+	return processSubscription.Close()
+}
+
+var (
+	gatewayRulesMux = &sync.Mutex{}
+	gatewayRules    = make(map[string][]*apigateway.Rule)
+)
+
+// AppResource returns the namespaced resource name of the app, which is used by
+// the rules subscribed via InitAcmForApps.
+func AppResource(app, resource string) string {
+	return app + AppResourceSeparator + resource
+}
+
+// gatewayRuleSource is the source key of the parameter flow rules converted from gateway flow rules.
+const gatewayRuleSource = "$gateway"
+
+// ruleSource represents the rules of an app.
+type ruleSource struct {
+	app string
+	// resourcePrefix is prepended to the resource names of the rules (if not empty).
+	resourcePrefix string
+}
+
+type ruleHandler struct {
+	kind         RuleKind
+	dataIdPrefix string
+	onChange     func(data string)
+}
+
+// handlers returns the handlers of all rule kinds of the source, in the order of subscription.
+func (src *ruleSource) handlers() []ruleHandler {
+	return []ruleHandler{
+		{kind: FlowRuleKind, dataIdPrefix: FlowRuleDataIdPrefix, onChange: src.onFlowRuleChange},
+		{kind: SystemRuleKind, dataIdPrefix: SystemRuleDataIdPrefix, onChange: src.onSystemRuleChange},
+		{kind: CircuitBreakingRuleKind, dataIdPrefix: CircuitBreakingRuleDataIdPrefix, onChange: src.onCircuitBreakingRuleChange},
+		{kind: ParamFlowRuleKind, dataIdPrefix: ParamFlowRuleDataIdPrefix, onChange: src.onParamFlowRuleChange},
+		{kind: GatewayFlowRuleKind, dataIdPrefix: GatewayFlowRuleDataIdPrefix, onChange: src.onGatewayFlowRuleChange},
+		{kind: AuthorityRuleKind, dataIdPrefix: AuthorityRuleDataIdPrefix, onChange: src.onAuthorityRuleChange},
+	}
+}
+
+func (src *ruleSource) namespaced(resource string) string {
+	if src.resourcePrefix == "" || resource == "" {
+		return resource
+	}
+	return src.resourcePrefix + resource
+}
+
+func (src *ruleSource) onFlowRuleChange(data string) {
+	sentinelLogger.Infof("ACM data received for flow rules of app %s: %v", src.app, data)
+	recordRuleReceived(FlowRuleKind)
+	if payloadUnchanged(src.app, FlowRuleKind, data) {
+		sentinelLogger.Infof("Skipping reloading flow rules of app %s as the content is unchanged", src.app)
+		recordRuleUnchanged(FlowRuleKind)
+		return
+	}
+	if src.deferIfDegraded(FlowRuleKind, data, src.onFlowRuleChange) {
+		return
+	}
+	decoded, err := decodeRulePayload(FlowRuleKind, data)
 	if err != nil {
 		sentinelLogger.Errorf("Failed to parse flow rules: %+v", err)
+		recordRuleParseFailure(FlowRuleKind)
 		return
 	}
-	arr := make([]*flow.FlowRule, 0)
-	for _, r := range d.Data {
-		if rule := r.ToGoRule(); rule != nil {
-			arr = append(arr, rule)
+	rules, ok := decoded.([]*LegacyFlowRule)
+	if !ok {
+		sentinelLogger.Errorf("Failed to parse flow rules: unexpected decoded type %T", decoded)
+		recordRuleParseFailure(FlowRuleKind)
+		return
+	}
+	arr := make([]guardedRule, 0)
+	for _, r := range rules {
+		rule := r.ToGoRule()
+		if rule == nil {
+			continue
+		}
+		cond, err := compileRuleExpression(r.Expression)
+		if err != nil {
+			sentinelLogger.Errorf("Ignoring flow rule with bad expression, resource: %s, err: %+v", r.Resource, err)
+			continue
 		}
+		rule.Resource = src.namespaced(rule.Resource)
+		rule.RefResource = src.namespaced(rule.RefResource)
+		arr = append(arr, guardedRule{rule: rule, resource: rule.Resource, condition: cond})
 	}
-	_, err = flow.LoadRules(arr)
+	err = flowRuleSet.update(src.app, arr)
 	if err != nil {
 		sentinelLogger.Errorf("Failed to load flow rules: %+v", err)
+		recordRuleLoad(FlowRuleKind, src.app, 0, err)
 		return
 	}
+	recordRuleLoad(FlowRuleKind, src.app, len(arr), nil)
+	markPayloadApplied(src.app, FlowRuleKind, data)
+	recordAppliedPayload(src.app, FlowRuleKind, data, src.onFlowRuleChange)
 }
 
-func onSystemRuleChange(data string) {
-	sentinelLogger.Infof("ACM data received for system rules: %v", data)
-	d := &struct {
-		Version string
-		Data    []*LegacySystemRule
-	}{}
-	err := json.Unmarshal([]byte(data), d)
+func (src *ruleSource) onSystemRuleChange(data string) {
+	sentinelLogger.Infof("ACM data received for system rules of app %s: %v", src.app, data)
+	recordRuleReceived(SystemRuleKind)
+	if payloadUnchanged(src.app, SystemRuleKind, data) {
+		sentinelLogger.Infof("Skipping reloading system rules of app %s as the content is unchanged", src.app)
+		recordRuleUnchanged(SystemRuleKind)
+		return
+	}
+	if src.deferIfDegraded(SystemRuleKind, data, src.onSystemRuleChange) {
+		return
+	}
+	decoded, err := decodeRulePayload(SystemRuleKind, data)
 	if err != nil {
 		sentinelLogger.Errorf("Failed to parse system rules: %+v", err)
+		recordRuleParseFailure(SystemRuleKind)
 		return
 	}
-	arr := make([]*system.SystemRule, 0)
-	for _, r := range d.Data {
+	rules, ok := decoded.([]*LegacySystemRule)
+	if !ok {
+		sentinelLogger.Errorf("Failed to parse system rules: unexpected decoded type %T", decoded)
+		recordRuleParseFailure(SystemRuleKind)
+		return
+	}
+	arr := make([]guardedRule, 0)
+	for _, r := range rules {
 		if rule := r.ToGoRule(); rule != nil {
-			arr = append(arr, rule)
+			arr = append(arr, guardedRule{rule: rule})
 		}
 	}
-	_, err = system.LoadRules(arr)
+	err = systemRuleSet.update(src.app, arr)
 	if err != nil {
 		sentinelLogger.Errorf("Failed to load system rules: %+v", err)
+		recordRuleLoad(SystemRuleKind, src.app, 0, err)
 		return
 	}
+	recordRuleLoad(SystemRuleKind, src.app, len(arr), nil)
+	markPayloadApplied(src.app, SystemRuleKind, data)
+	recordAppliedPayload(src.app, SystemRuleKind, data, src.onSystemRuleChange)
 }
 
-func onCircuitBreakingRuleChange(data string) {
-	sentinelLogger.Infof("ACM data received for circuit breaking rules: %v", data)
-	d := &struct {
-		Version string
-		Data    []*LegacyDegradeRule
-	}{}
-	err := json.Unmarshal([]byte(data), d)
+func (src *ruleSource) onCircuitBreakingRuleChange(data string) {
+	sentinelLogger.Infof("ACM data received for circuit breaking rules of app %s: %v", src.app, data)
+	recordRuleReceived(CircuitBreakingRuleKind)
+	if payloadUnchanged(src.app, CircuitBreakingRuleKind, data) {
+		sentinelLogger.Infof("Skipping reloading circuit breaking rules of app %s as the content is unchanged", src.app)
+		recordRuleUnchanged(CircuitBreakingRuleKind)
+		return
+	}
+	if src.deferIfDegraded(CircuitBreakingRuleKind, data, src.onCircuitBreakingRuleChange) {
+		return
+	}
+	decoded, err := decodeRulePayload(CircuitBreakingRuleKind, data)
 	if err != nil {
 		sentinelLogger.Errorf("Failed to parse legacy degrade rules: %+v", err)
+		recordRuleParseFailure(CircuitBreakingRuleKind)
 		return
 	}
-	arr := make([]*circuitbreaker.Rule, 0)
-	for _, r := range d.Data {
-		if rule := r.ToGoRule(); rule != nil {
-			arr = append(arr, rule)
+	rules, ok := decoded.([]*LegacyDegradeRule)
+	if !ok {
+		sentinelLogger.Errorf("Failed to parse legacy degrade rules: unexpected decoded type %T", decoded)
+		recordRuleParseFailure(CircuitBreakingRuleKind)
+		return
+	}
+	arr := make([]guardedRule, 0)
+	for _, r := range rules {
+		rule := r.ToGoRule()
+		if rule == nil {
+			continue
+		}
+		cond, err := compileRuleExpression(r.Expression)
+		if err != nil {
+			sentinelLogger.Errorf("Ignoring circuit breaking rule with bad expression, resource: %s, err: %+v", r.Resource, err)
+			continue
 		}
+		rule.Resource = src.namespaced(rule.Resource)
+		arr = append(arr, guardedRule{rule: rule, resource: rule.Resource, condition: cond})
 	}
-	_, err = circuitbreaker.LoadRules(arr)
+	err = circuitBreakingRuleSet.update(src.app, arr)
 	if err != nil {
-		sentinelLogger.Errorf("Failed to load flow rules: %+v", err)
+		sentinelLogger.Errorf("Failed to load circuit breaking rules: %+v", err)
+		recordRuleLoad(CircuitBreakingRuleKind, src.app, 0, err)
 		return
 	}
+	recordRuleLoad(CircuitBreakingRuleKind, src.app, len(arr), nil)
+	markPayloadApplied(src.app, CircuitBreakingRuleKind, data)
+	recordAppliedPayload(src.app, CircuitBreakingRuleKind, data, src.onCircuitBreakingRuleChange)
 }
 
-func onParamFlowRuleChange(data string) {
-	sentinelLogger.Infof("ACM data received for hot-spot parameter flow rules: %v", data)
-	d := &struct {
-		Version string
-		Data    []*LegacyParamFlowRule
-	}{}
-	err := json.Unmarshal([]byte(data), d)
+func (src *ruleSource) onParamFlowRuleChange(data string) {
+	sentinelLogger.Infof("ACM data received for hot-spot parameter flow rules of app %s: %v", src.app, data)
+	recordRuleReceived(ParamFlowRuleKind)
+	if payloadUnchanged(src.app, ParamFlowRuleKind, data) {
+		sentinelLogger.Infof("Skipping reloading hot-spot parameter flow rules of app %s as the content is unchanged", src.app)
+		recordRuleUnchanged(ParamFlowRuleKind)
+		return
+	}
+	if src.deferIfDegraded(ParamFlowRuleKind, data, src.onParamFlowRuleChange) {
+		return
+	}
+	decoded, err := decodeRulePayload(ParamFlowRuleKind, data)
 	if err != nil {
 		sentinelLogger.Errorf("Failed to parse legacy param flow rules: %+v", err)
+		recordRuleParseFailure(ParamFlowRuleKind)
+		return
+	}
+	rules, ok := decoded.([]*LegacyParamFlowRule)
+	if !ok {
+		sentinelLogger.Errorf("Failed to parse legacy param flow rules: unexpected decoded type %T", decoded)
+		recordRuleParseFailure(ParamFlowRuleKind)
+		return
+	}
+	arr := make([]guardedRule, 0)
+	for _, r := range rules {
+		rule := r.ToGoRule()
+		if rule == nil {
+			continue
+		}
+		rule.Resource = src.namespaced(rule.Resource)
+		if r.ParamKey != "" {
+			arr = append(arr, guardedRule{rule: &keyedParamFlowRule{Rule: rule, ParamKey: r.ParamKey}, resource: rule.Resource})
+			continue
+		}
+		arr = append(arr, guardedRule{rule: rule, resource: rule.Resource})
+	}
+	err = paramFlowRuleSet.update(src.app, arr)
+	if err != nil {
+		sentinelLogger.Errorf("Failed to load hot-spot parameter flow rules: %+v", err)
+		recordRuleLoad(ParamFlowRuleKind, src.app, 0, err)
 		return
 	}
-	arr := make([]*hotspot.Rule, 0)
-	for _, r := range d.Data {
+	recordRuleLoad(ParamFlowRuleKind, src.app, len(arr), nil)
+	markPayloadApplied(src.app, ParamFlowRuleKind, data)
+	recordAppliedPayload(src.app, ParamFlowRuleKind, data, src.onParamFlowRuleChange)
+}
+
+func (src *ruleSource) onAuthorityRuleChange(data string) {
+	sentinelLogger.Infof("ACM data received for authority rules of app %s: %v", src.app, data)
+	recordRuleReceived(AuthorityRuleKind)
+	if payloadUnchanged(src.app, AuthorityRuleKind, data) {
+		sentinelLogger.Infof("Skipping reloading authority rules of app %s as the content is unchanged", src.app)
+		recordRuleUnchanged(AuthorityRuleKind)
+		return
+	}
+	if src.deferIfDegraded(AuthorityRuleKind, data, src.onAuthorityRuleChange) {
+		return
+	}
+	decoded, err := decodeRulePayload(AuthorityRuleKind, data)
+	if err != nil {
+		sentinelLogger.Errorf("Failed to parse legacy authority rules: %+v", err)
+		recordRuleParseFailure(AuthorityRuleKind)
+		return
+	}
+	rules, ok := decoded.([]*LegacyAuthorityRule)
+	if !ok {
+		sentinelLogger.Errorf("Failed to parse legacy authority rules: unexpected decoded type %T", decoded)
+		recordRuleParseFailure(AuthorityRuleKind)
+		return
+	}
+	arr := make([]guardedRule, 0)
+	for _, r := range rules {
 		if rule := r.ToGoRule(); rule != nil {
+			rule.Resource = src.namespaced(rule.Resource)
+			arr = append(arr, guardedRule{rule: rule, resource: rule.Resource})
+		}
+	}
+	err = authorityRuleSet.update(src.app, arr)
+	if err != nil {
+		sentinelLogger.Errorf("Failed to load authority rules: %+v", err)
+		recordRuleLoad(AuthorityRuleKind, src.app, 0, err)
+		return
+	}
+	recordRuleLoad(AuthorityRuleKind, src.app, len(arr), nil)
+	markPayloadApplied(src.app, AuthorityRuleKind, data)
+	recordAppliedPayload(src.app, AuthorityRuleKind, data, src.onAuthorityRuleChange)
+}
+
+func (src *ruleSource) onGatewayFlowRuleChange(data string) {
+	sentinelLogger.Infof("ACM data received for gateway flow rules of app %s: %v", src.app, data)
+	recordRuleReceived(GatewayFlowRuleKind)
+	if payloadUnchanged(src.app, GatewayFlowRuleKind, data) {
+		sentinelLogger.Infof("Skipping reloading gateway flow rules of app %s as the content is unchanged", src.app)
+		recordRuleUnchanged(GatewayFlowRuleKind)
+		return
+	}
+	if src.deferIfDegraded(GatewayFlowRuleKind, data, src.onGatewayFlowRuleChange) {
+		return
+	}
+	if !feature.Enabled(feature.GatewayFlow) {
+		sentinelLogger.Warn("Gateway flow rules are ignored as the feature is disabled")
+		return
+	}
+	decoded, err := decodeRulePayload(GatewayFlowRuleKind, data)
+	if err != nil {
+		sentinelLogger.Errorf("Failed to parse legacy gateway flow rules: %+v", err)
+		recordRuleParseFailure(GatewayFlowRuleKind)
+		return
+	}
+	rules, ok := decoded.([]*LegacyGatewayFlowRule)
+	if !ok {
+		sentinelLogger.Errorf("Failed to parse legacy gateway flow rules: unexpected decoded type %T", decoded)
+		recordRuleParseFailure(GatewayFlowRuleKind)
+		return
+	}
+	arr := make([]*apigateway.Rule, 0)
+	for _, r := range rules {
+		if rule := r.ToGoRule(); rule != nil {
+			rule.Resource = src.namespaced(rule.Resource)
 			arr = append(arr, rule)
 		}
 	}
-	_, err = hotspot.LoadRules(arr)
+
+	gatewayRulesMux.Lock()
+	defer gatewayRulesMux.Unlock()
+	gatewayRules[src.app] = arr
+	all := make([]*apigateway.Rule, 0)
+	for _, rules := range gatewayRules {
+		all = append(all, rules...)
+	}
+	paramRules, err := apigateway.LoadRules(all)
 	if err != nil {
-		sentinelLogger.Errorf("Failed to load hot-spot parameter flow rules: %+v", err)
+		sentinelLogger.Errorf("Failed to load gateway flow rules: %+v", err)
+		recordRuleLoad(GatewayFlowRuleKind, src.app, 0, err)
+		return
+	}
+	// Gateway flow rules are converted to hot-spot parameter flow rules.
+	converted := make([]guardedRule, 0, len(paramRules))
+	for _, r := range paramRules {
+		converted = append(converted, guardedRule{rule: r, resource: r.Resource})
+	}
+	err = paramFlowRuleSet.update(gatewayRuleSource, converted)
+	if err != nil {
+		sentinelLogger.Errorf("Failed to load gateway flow rules: %+v", err)
+		recordRuleLoad(GatewayFlowRuleKind, src.app, 0, err)
 		return
 	}
+	recordRuleLoad(GatewayFlowRuleKind, src.app, len(arr), nil)
+	markPayloadApplied(src.app, GatewayFlowRuleKind, data)
+	recordAppliedPayload(src.app, GatewayFlowRuleKind, data, src.onGatewayFlowRuleChange)
 }
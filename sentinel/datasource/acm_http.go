@@ -0,0 +1,184 @@
+package datasource
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+	"github.com/aliyun/aliyun-ahas-go-sdk/gateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/nacos-group/nacos-sdk-go/common/http_agent"
+	"github.com/pkg/errors"
+)
+
+// The headers of the ACM requests signed by the AccessKey pair (or the STS credentials).
+const (
+	acmTimestampHeader     = "Timestamp"
+	acmAccessKeyHeader     = "Spas-AccessKey"
+	acmSignatureHeader     = "Spas-Signature"
+	acmSecurityTokenHeader = "Spas-SecurityToken"
+)
+
+// acmHttpAgent is the HTTP agent of the embedded Nacos client, which always talks plain HTTP.
+// The addresses of the ACM hosts are raced the same as the gateway ones (Happy Eyeballs).
+// If signed, the requests to the config servers are signed with the credentials of the SDK
+// (see aliyun.GetCredentials) on each request, so that the STS credentials of the RAM roles
+// are carried with their security token, and refreshed before they expire. If https, the
+// requests are sent over HTTPS instead. The requests go through the proxy of Config.Proxy.
+type acmHttpAgent struct {
+	transport http.RoundTripper
+	signed    bool
+	https     bool
+}
+
+var _ http_agent.IHttpAgent = (*acmHttpAgent)(nil)
+
+func newAcmHttpAgent(signed bool, conf Config) (*acmHttpAgent, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = gateway.DualStackDialContext(time.Duration(conf.FallbackDelayMs) * time.Millisecond)
+	proxy, err := conf.httpProxy()
+	if err != nil {
+		return nil, err
+	}
+	t.Proxy = proxy
+	if conf.Https {
+		tlsConf, err := conf.Tls.Build()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid TLS config of ACM")
+		}
+		t.TLSClientConfig = tlsConf
+	}
+	return &acmHttpAgent{transport: t, signed: signed, https: conf.Https}, nil
+}
+
+// httpProxy returns the proxy of the ACM requests, see Config.Proxy.
+func (c *Config) httpProxy() (func(*http.Request) (*url.URL, error), error) {
+	switch c.Proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case transport.DirectProxy:
+		return nil, nil
+	}
+	u, err := url.Parse(c.Proxy)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("bad proxy URL of ACM: %s", c.Proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+func (a *acmHttpAgent) Request(method string, path string, header http.Header, timeoutMs uint64,
+	params map[string]string) (*http.Response, error) {
+	if a.https && strings.HasPrefix(path, "http://") {
+		path = "https://" + strings.TrimPrefix(path, "http://")
+	}
+	form := url.Values{}
+	for k, v := range params {
+		if v != "" {
+			form.Set(k, v)
+		}
+	}
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		if len(form) > 0 {
+			if strings.Contains(path, "?") {
+				path += "&" + form.Encode()
+			} else {
+				path += "?" + form.Encode()
+			}
+		}
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+	request, err := http.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		request.Header = header
+	}
+	if a.signed {
+		if err = signAcmRequest(request.Header, params); err != nil {
+			return nil, err
+		}
+	}
+	client := &http.Client{
+		Transport: a.transport,
+		Timeout:   time.Duration(timeoutMs) * time.Millisecond,
+	}
+	return client.Do(request)
+}
+
+func (a *acmHttpAgent) RequestOnlyResult(method string, path string, header http.Header, timeoutMs uint64,
+	params map[string]string) string {
+	response, err := a.Request(method, path, header, timeoutMs, params)
+	if err != nil {
+		logger.Warnf("Failed to request ACM %s %s: %+v", method, path, err)
+		return ""
+	}
+	defer response.Body.Close()
+	bs, err := ioutil.ReadAll(response.Body)
+	if err != nil || response.StatusCode != http.StatusOK {
+		logger.Warnf("Failed to request ACM %s %s, status: %d, error: %v", method, path, response.StatusCode, err)
+		return ""
+	}
+	return string(bs)
+}
+
+func (a *acmHttpAgent) Get(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodGet, path, header, timeoutMs, params)
+}
+
+func (a *acmHttpAgent) Post(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodPost, path, header, timeoutMs, params)
+}
+
+func (a *acmHttpAgent) Delete(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodDelete, path, header, timeoutMs, params)
+}
+
+func (a *acmHttpAgent) Put(path string, header http.Header, timeoutMs uint64, params map[string]string) (*http.Response, error) {
+	return a.Request(http.MethodPut, path, header, timeoutMs, params)
+}
+
+// signAcmRequest signs the request to the config servers (the ones with the timestamp header)
+// with the current credentials, the same as the Nacos client signs with the AccessKey pair:
+// HmacSHA1 of "{tenant}+{group}+{timestamp}". The requests to the address server are not signed.
+// The headers are set by the keys of the Nacos client, which aren't in the canonical form.
+func signAcmRequest(header http.Header, params map[string]string) error {
+	timestamp := ""
+	if v := header[acmTimestampHeader]; len(v) > 0 {
+		timestamp = v[0]
+	}
+	if timestamp == "" {
+		return nil
+	}
+	creds, err := aliyun.GetCredentials()
+	if err != nil {
+		return errors.Wrap(err, "failed to get the credentials of ACM")
+	}
+	resource := params["group"]
+	if params["tenant"] != "" {
+		resource = params["tenant"] + "+" + resource
+	}
+	if resource != "" {
+		resource += "+"
+	}
+	mac := hmac.New(sha1.New, []byte(creds.AccessKeySecret))
+	mac.Write([]byte(resource + timestamp))
+	header[acmAccessKeyHeader] = []string{creds.AccessKeyId}
+	header[acmSignatureHeader] = []string{base64.StdEncoding.EncodeToString(mac.Sum(nil))}
+	if creds.Temporary() {
+		header[acmSecurityTokenHeader] = []string{creds.SecurityToken}
+	} else {
+		delete(header, acmSecurityTokenHeader)
+	}
+	return nil
+}
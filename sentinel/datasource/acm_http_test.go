@@ -0,0 +1,48 @@
+package datasource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcmHttpAgentProxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case proxied <- r.URL.String():
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	agent, err := newAcmHttpAgent(false, Config{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := agent.Get("http://acm.invalid/diamond-server/diamond", nil, 3000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	select {
+	case u := <-proxied:
+		if want := "http://acm.invalid/diamond-server/diamond"; u != want {
+			t.Fatalf("proxied URL: got %s, want %s", u, want)
+		}
+	default:
+		t.Fatal("the request didn't go through the proxy")
+	}
+
+	if _, err = newAcmHttpAgent(false, Config{Proxy: "://bad"}); err == nil {
+		t.Fatal("bad proxy URL accepted")
+	}
+	direct, err := newAcmHttpAgent(false, Config{Proxy: "direct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.transport.(*http.Transport).Proxy != nil {
+		t.Fatal("the proxy is not disabled")
+	}
+}
@@ -0,0 +1,220 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/pkg/errors"
+)
+
+const (
+	initialListenRetryTimes = 3
+
+	minListenRetryBackoff = 1 * time.Second
+	maxListenRetryBackoff = 60 * time.Second
+)
+
+type acmSubscription struct {
+	group    string
+	dataId   string
+	onChange func(data string)
+
+	mux            sync.Mutex
+	subscribed     bool
+	lastErr        error
+	lastReceivedMs uint64
+}
+
+func (s *acmSubscription) configParam() vo.ConfigParam {
+	return vo.ConfigParam{
+		Group:  s.group,
+		DataId: s.dataId,
+		OnChange: func(namespace, group, dataId, data string) {
+			s.mux.Lock()
+			s.lastReceivedMs = util.CurrentTimeMillis()
+			s.mux.Unlock()
+			s.onChange(data)
+		},
+	}
+}
+
+func (s *acmSubscription) setResult(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.subscribed = err == nil
+	s.lastErr = err
+}
+
+func (s *acmSubscription) status() SubscriptionStatus {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	status := SubscriptionStatus{
+		Group:          s.group,
+		DataId:         s.dataId,
+		Subscribed:     s.subscribed,
+		LastReceivedMs: s.lastReceivedMs,
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+	return status
+}
+
+// SubscriptionStatus is the status of the ACM subscription of a rule dataId.
+type SubscriptionStatus struct {
+	Group  string `json:"group"`
+	DataId string `json:"dataId"`
+	// Subscribed indicates whether the listener has been registered successfully.
+	Subscribed bool `json:"subscribed"`
+	// LastError is the error of the latest failed registration, if not subscribed.
+	LastError string `json:"lastError,omitempty"`
+	// LastReceivedMs is the timestamp of the latest received payload, 0 if none.
+	LastReceivedMs uint64 `json:"lastReceivedMs"`
+}
+
+// Subscriptions returns the status of the ACM subscriptions of current data-source, or
+// nil if the ACM data-source is not initialized (yet).
+func Subscriptions() []SubscriptionStatus {
+	return processSubscription.Status()
+}
+
+func (ds *acmDataSource) status() []SubscriptionStatus {
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+	result := make([]SubscriptionStatus, 0, len(ds.subscriptions))
+	for _, sub := range ds.subscriptions {
+		result = append(result, sub.status())
+	}
+	return result
+}
+
+// acmDataSource holds the config client and the registered subscriptions,
+// so that they could be released on close.
+type acmDataSource struct {
+	client config_client.IConfigClient
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mux        sync.Mutex
+	registered []*acmSubscription
+	// subscriptions are all subscriptions to register, including the failed ones.
+	subscriptions []*acmSubscription
+}
+
+func newAcmDataSource(ctx context.Context, client config_client.IConfigClient) *acmDataSource {
+	ctx, cancel := context.WithCancel(ctx)
+	return &acmDataSource{
+		client:     client,
+		ctx:        ctx,
+		cancel:     cancel,
+		registered: make([]*acmSubscription, 0),
+	}
+}
+
+func (ds *acmDataSource) listen(sub *acmSubscription) error {
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+	if ds.ctx.Err() != nil {
+		return ds.ctx.Err()
+	}
+	if err := ds.client.ListenConfig(sub.configParam()); err != nil {
+		sub.setResult(err)
+		return err
+	}
+	sub.setResult(nil)
+	ds.registered = append(ds.registered, sub)
+	return nil
+}
+
+// track keeps the subscriptions to register, for reporting their status.
+func (ds *acmDataSource) track(subscriptions []*acmSubscription) {
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+	ds.subscriptions = subscriptions
+}
+
+// sleep waits for the given duration, and returns false if the data-source has been closed.
+func (ds *acmDataSource) sleep(d time.Duration) bool {
+	select {
+	case <-ds.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// nextBackoff doubles the given backoff, capped at maxListenRetryBackoff.
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxListenRetryBackoff {
+		return maxListenRetryBackoff
+	}
+	return backoff
+}
+
+// listenWithRetry registers the listener of the subscription, retrying with
+// exponential backoff for at most maxTimes attempts.
+func (ds *acmDataSource) listenWithRetry(sub *acmSubscription, maxTimes int) error {
+	var err error
+	backoff := minListenRetryBackoff
+	for i := 0; i < maxTimes; i++ {
+		if err = ds.listen(sub); err == nil {
+			return nil
+		}
+		logger.Warnf("Failed to listen ACM config (dataId: %s, attempt: %d): %+v", sub.dataId, i+1, err)
+		if i < maxTimes-1 {
+			if !ds.sleep(backoff) {
+				return ds.ctx.Err()
+			}
+			backoff = nextBackoff(backoff)
+		}
+	}
+	return err
+}
+
+// superviseFailedSubscriptions keeps re-establishing the failed subscriptions
+// in background until all of them have been registered or the data-source is closed.
+func (ds *acmDataSource) superviseFailedSubscriptions(failed []*acmSubscription) {
+	defer tools.PrintPanicStackV2("ACM subscription supervisor")
+
+	backoff := minListenRetryBackoff
+	for len(failed) > 0 {
+		if !ds.sleep(backoff) {
+			return
+		}
+		remaining := make([]*acmSubscription, 0, len(failed))
+		for _, sub := range failed {
+			if err := ds.listen(sub); err != nil {
+				logger.Warnf("Failed to re-subscribe ACM config (dataId: %s): %+v", sub.dataId, err)
+				remaining = append(remaining, sub)
+				continue
+			}
+			logger.Infof("ACM config re-subscribed successfully, dataId: %s", sub.dataId)
+		}
+		failed = remaining
+		backoff = nextBackoff(backoff)
+	}
+}
+
+func (ds *acmDataSource) close() error {
+	ds.cancel()
+
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+	var lastErr error
+	for _, sub := range ds.registered {
+		if err := ds.client.CancelListenConfig(sub.configParam()); err != nil {
+			logger.Warnf("Failed to cancel ACM listener, dataId: %s, err: %+v", sub.dataId, err)
+			lastErr = errors.Wrap(err, "failed to cancel ACM listener")
+		}
+	}
+	ds.registered = ds.registered[:0]
+	logger.Info("ACM data source closed")
+	return lastErr
+}
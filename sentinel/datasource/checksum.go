@@ -0,0 +1,38 @@
+package datasource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+var (
+	checksumMux = &sync.Mutex{}
+	// appliedChecksums keeps the checksum of the last applied payload per app and rule kind.
+	appliedChecksums = make(map[string]string)
+)
+
+func payloadChecksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func checksumKey(app string, kind RuleKind) string {
+	return app + "|" + string(kind)
+}
+
+// payloadUnchanged reports whether the payload is identical to the last applied one,
+// as ACM may re-deliver the same content, which should not reset the rule state.
+func payloadUnchanged(app string, kind RuleKind, data string) bool {
+	checksumMux.Lock()
+	defer checksumMux.Unlock()
+	last, ok := appliedChecksums[checksumKey(app, kind)]
+	return ok && last == payloadChecksum(data)
+}
+
+// markPayloadApplied records the checksum of the successfully applied payload.
+func markPayloadApplied(app string, kind RuleKind, data string) {
+	checksumMux.Lock()
+	defer checksumMux.Unlock()
+	appliedChecksums[checksumKey(app, kind)] = payloadChecksum(data)
+}
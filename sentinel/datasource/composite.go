@@ -0,0 +1,244 @@
+package datasource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	sentinelLogger "github.com/alibaba/sentinel-golang/logging"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// SourcePriority is the precedence of a rule source. For each app and rule kind,
+// the payload from the source with the highest priority wins, and the lower ones
+// take over while the higher ones have offered no payload, e.g. the snapshot before
+// ACM delivers any rules. Only the override source is withdrawn once its config is
+// deleted. The deletion of the other configs results in an empty payload instead,
+// which wins as well and is handled by Config.AllowEmptyRuleClear. So the deleted
+// ACM config never falls back to its own stale snapshot.
+type SourcePriority int
+
+const (
+	// SnapshotSourcePriority is the priority of the local file snapshots of the rules
+	// last received from ACM, which keep the rules flowing while ACM is unavailable.
+	SnapshotSourcePriority SourcePriority = 0
+	// AcmSourcePriority is the priority of the rules pushed via ACM, or over the AHAS
+	// transport in push mode (the primary source).
+	AcmSourcePriority SourcePriority = 100
+	// OverrideSourcePriority is the priority of the rules of the override dataId for the
+	// deployment label (see Config.OverrideLabel), which fall back to the base rules if absent.
+	OverrideSourcePriority SourcePriority = 150
+	// EnvSourcePriority is the priority of the rules overridden via environment variables
+	// (see RuleEnvKey), which always take precedence.
+	EnvSourcePriority SourcePriority = 200
+)
+
+func (p SourcePriority) String() string {
+	switch p {
+	case SnapshotSourcePriority:
+		return "snapshot"
+	case AcmSourcePriority:
+		return "remote"
+	case OverrideSourcePriority:
+		return "override"
+	case EnvSourcePriority:
+		return "env"
+	default:
+		return "undefined"
+	}
+}
+
+// RuleEnvKey returns the environment variable which overrides the rules of the app and kind,
+// e.g. AHAS_RULES_MY_APP_PARAM_FLOW for the hot-spot parameter flow rules of app "my-app".
+func RuleEnvKey(app string, kind RuleKind) string {
+	sanitize := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r - 'a' + 'A'
+			}
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return '_'
+		}, s)
+	}
+	return "AHAS_RULES_" + sanitize(app) + "_" + sanitize(string(kind))
+}
+
+// allowEmptyRuleClear is the configured Config.AllowEmptyRuleClear.
+var allowEmptyRuleClear int32
+
+func setAllowEmptyRuleClear(allowed bool) {
+	var v int32
+	if allowed {
+		v = 1
+	}
+	atomic.StoreInt32(&allowEmptyRuleClear, v)
+}
+
+func emptyRuleClearAllowed() bool {
+	return atomic.LoadInt32(&allowEmptyRuleClear) == 1
+}
+
+type appliedSource struct {
+	priority SourcePriority
+	data     string
+}
+
+// compositeSource combines the payloads from all sources under the precedence of SourcePriority.
+type compositeSource struct {
+	// snapshotDir is the directory of the file snapshots. Empty means disabled.
+	snapshotDir string
+
+	mux      sync.Mutex
+	payloads map[string]map[SourcePriority]string
+	applied  map[string]appliedSource
+}
+
+func newCompositeSource(snapshotDir string) *compositeSource {
+	return &compositeSource{
+		snapshotDir: snapshotDir,
+		payloads:    make(map[string]map[SourcePriority]string),
+		applied:     make(map[string]appliedSource),
+	}
+}
+
+// sourced returns the callback which offers the payloads of the source.
+func (c *compositeSource) sourced(app string, kind RuleKind, priority SourcePriority, apply func(data string)) func(data string) {
+	return func(data string) {
+		c.offer(app, kind, priority, data, apply)
+	}
+}
+
+// offer records the payload of the source, and applies the payload of the highest priority if changed.
+// The empty payload (the config deleted) is recorded as the payload of the source rather than
+// withdrawing the source, see SourcePriority.
+func (c *compositeSource) offer(app string, kind RuleKind, priority SourcePriority, data string, apply func(data string)) {
+	if isEmptyPayload(data) {
+		if !emptyRuleClearAllowed() {
+			logger.Warnf("Ignoring the empty %s rule payload of app %s from %s (config deleted?), "+
+				"as clearing rules by empty payload is not allowed", kind, app, priority)
+			return
+		}
+		logger.Warnf("Clearing %s rules of app %s by the empty payload from %s (config deleted)", kind, app, priority)
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	key := checksumKey(app, kind)
+	if c.payloads[key] == nil {
+		c.payloads[key] = make(map[SourcePriority]string)
+	}
+	c.payloads[key][priority] = data
+
+	winner := priority
+	for p := range c.payloads[key] {
+		if p > winner {
+			winner = p
+		}
+	}
+	if winner != priority {
+		sentinelLogger.Infof("Ignoring %s rules of app %s from %s, as overridden by %s", kind, app, priority, winner)
+		return
+	}
+	if last, ok := c.applied[key]; ok && last.priority != priority {
+		logger.Warnf("The source of %s rules of app %s has changed from %s to %s", kind, app, last.priority, priority)
+	}
+	c.applied[key] = appliedSource{priority: priority, data: data}
+	apply(data)
+	if priority == AcmSourcePriority && payloadUnchanged(app, kind, data) {
+		// Only the successfully applied payloads are snapshotted.
+		c.saveSnapshot(app, kind, data)
+	}
+}
+
+// overridden returns the callback of the override source, where the empty payload
+// (the override config is absent or deleted) withdraws the override.
+func (c *compositeSource) overridden(app string, kind RuleKind, apply func(data string)) func(data string) {
+	return func(data string) {
+		if isEmptyPayload(data) {
+			c.withdraw(app, kind, OverrideSourcePriority, apply)
+			return
+		}
+		c.offer(app, kind, OverrideSourcePriority, data, apply)
+	}
+}
+
+// withdraw removes the payload of the source, and applies the payload of the next highest priority.
+func (c *compositeSource) withdraw(app string, kind RuleKind, priority SourcePriority, apply func(data string)) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	key := checksumKey(app, kind)
+	if _, ok := c.payloads[key][priority]; !ok {
+		return
+	}
+	delete(c.payloads[key], priority)
+	if last, ok := c.applied[key]; !ok || last.priority != priority {
+		return
+	}
+	delete(c.applied, key)
+	next, found := SourcePriority(0), false
+	for p := range c.payloads[key] {
+		if !found || p > next {
+			next, found = p, true
+		}
+	}
+	if !found {
+		logger.Warnf("The %s rules of app %s from %s have been withdrawn, and no other source is available", kind, app, priority)
+		return
+	}
+	logger.Warnf("The %s rules of app %s from %s have been withdrawn, falling back to %s", kind, app, priority, next)
+	c.applied[key] = appliedSource{priority: next, data: c.payloads[key][next]}
+	apply(c.payloads[key][next])
+}
+
+// loadLocal offers the payloads from the snapshots and environment variables.
+func (c *compositeSource) loadLocal(app string, kind RuleKind, apply func(data string)) {
+	if data, ok := c.loadSnapshot(app, kind); ok {
+		c.offer(app, kind, SnapshotSourcePriority, data, apply)
+	}
+	if data, ok := os.LookupEnv(RuleEnvKey(app, kind)); ok {
+		logger.Infof("The %s rules of app %s are overridden by env %s", kind, app, RuleEnvKey(app, kind))
+		c.offer(app, kind, EnvSourcePriority, data, apply)
+	}
+}
+
+func (c *compositeSource) snapshotPath(app string, kind RuleKind) string {
+	return filepath.Join(c.snapshotDir, app, string(kind)+".json")
+}
+
+func (c *compositeSource) loadSnapshot(app string, kind RuleKind) (string, bool) {
+	if c.snapshotDir == "" {
+		return "", false
+	}
+	bs, err := ioutil.ReadFile(c.snapshotPath(app, kind))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("Failed to read the snapshot of %s rules of app %s: %+v", kind, app, err)
+		}
+		return "", false
+	}
+	return string(bs), true
+}
+
+func (c *compositeSource) saveSnapshot(app string, kind RuleKind, data string) {
+	if c.snapshotDir == "" {
+		return
+	}
+	path := c.snapshotPath(app, kind)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.Warnf("Failed to create the snapshot directory of app %s: %+v", app, err)
+		return
+	}
+	// Write to a temporary file and rename, so that a crash never leaves a partial snapshot.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(data), 0644); err != nil {
+		logger.Warnf("Failed to write the snapshot of %s rules of app %s: %+v", kind, app, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logger.Warnf("Failed to save the snapshot of %s rules of app %s: %+v", kind, app, err)
+	}
+}
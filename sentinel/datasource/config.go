@@ -1,11 +1,244 @@
 package datasource
 
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/aliyun"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
 const (
 	DefaultTimeoutMs        uint64 = 4000
 	DefaultListenIntervalMs uint64 = 5000
+
+	// DefaultDataIdTemplate is the default template of the rule dataIds.
+	DefaultDataIdTemplate = "{prefix}{uid}-{namespace}-{app}"
+
+	// DefaultParamsMaxCapacity is the default max count of the parameter values
+	// to keep statistics for in hot-spot parameter flow rules.
+	DefaultParamsMaxCapacity int64 = 500
+
+	// DefaultRuleHistorySize is the default count of previous rule versions kept for rollback.
+	DefaultRuleHistorySize = 5
+
+	// DefaultAcmEndpointPort is the default port of the ACM address server.
+	DefaultAcmEndpointPort = 8080
+
+	// DefaultDebounceMs is the default debounce window of the rule payloads.
+	DefaultDebounceMs uint64 = 500
+
+	// DefaultStartupTimeoutMs is the default timeout of waiting for the registration to AHAS on startup.
+	DefaultStartupTimeoutMs uint64 = 30000
+
+	minStartupRetryBackoff = time.Second
+	maxStartupRetryBackoff = time.Minute
 )
 
 type Config struct {
+	// Mode is the delivery mode of the rules, AcmDeliveryMode (default) or PushDeliveryMode.
+	Mode             string `yaml:"mode"`
 	TimeoutMs        uint64 `yaml:"timeoutMs"`
 	ListenIntervalMs uint64 `yaml:"listenIntervalMs"`
+	// Group is the ACM group of the rule configs. AcmGroupId will be used if absent.
+	Group string `yaml:"group"`
+	// DataIdTemplate is the template of the rule dataIds. Supported placeholders are
+	// {prefix}, {uid}, {namespace} and {app}. DefaultDataIdTemplate will be used if absent.
+	DataIdTemplate string `yaml:"dataIdTemplate"`
+	// ShadowPeriodMs is the grace period of newly received rules, during which the rules
+	// are evaluated in shadow (would-be blocks are logged but not enforced). 0 means disabled.
+	ShadowPeriodMs uint64 `yaml:"shadowPeriodMs"`
+	// ParamsMaxCapacity is the default max count of the parameter values to keep statistics for
+	// in hot-spot parameter (and gateway) flow rules, which could be overridden by each rule.
+	// Values beyond the capacity are evicted in LRU order. DefaultParamsMaxCapacity will be used if absent.
+	ParamsMaxCapacity int64 `yaml:"paramsMaxCapacity"`
+	// RuleHistorySize is the count of previous versions kept per rule type, which
+	// could be rolled back to via Rollback. DefaultRuleHistorySize will be used if absent.
+	RuleHistorySize int `yaml:"ruleHistorySize"`
+	// DebounceMs is the debounce window of the rule payloads. Rules are applied asynchronously,
+	// and only the latest payload is applied if several ones arrive within the window.
+	// DefaultDebounceMs will be used if absent.
+	DebounceMs uint64 `yaml:"debounceMs"`
+	// SnapshotDir is the directory to keep the snapshots of the rules received from ACM,
+	// which are loaded as the fallback on startup, before (or without) ACM delivering any
+	// rules. Empty means disabled. See SourcePriority for the precedence of the sources.
+	SnapshotDir string `yaml:"snapshotDir"`
+	// AllowEmptyRuleClear indicates whether the empty rule payload (received when the rule config
+	// is deleted) clears the rules. Otherwise, the empty payload is ignored and the current rules
+	// are kept, which guards against clearing all rules by accident.
+	AllowEmptyRuleClear bool `yaml:"allowEmptyRuleClear"`
+	// LocalRulePolicy is the precedence of the local rules registered by the application over
+	// the remote rules of the same resource, see LocalRulePolicy. LocalRulesAdditive will be used if absent.
+	LocalRulePolicy LocalRulePolicy `yaml:"localRulePolicy"`
+	// OverrideLabel is the label of the deployment (e.g. canary, or the blue/green group). If not
+	// empty, the override dataIds (the base dataIds with the "-{label}" suffix) are subscribed as well,
+	// which take precedence over the base dataIds, and fall back to the base ones if absent.
+	OverrideLabel string `yaml:"overrideLabel"`
+	// EndpointPort is the port of the ACM address server. DefaultAcmEndpointPort will be used if absent.
+	EndpointPort int `yaml:"endpointPort"`
+	// FallbackDelayMs is the delay before racing the next address when the ACM address server
+	// resolves to multiple (e.g. dual-stack) addresses, the same as the transport one, which is
+	// used if absent (see config.Config). 0 means 250ms, and negative disables racing.
+	FallbackDelayMs int64 `yaml:"fallbackDelayMs"`
+	// Https indicates whether to connect ACM over HTTPS, both the address server (on EndpointPort)
+	// and the config servers (on the ports in the server list).
+	Https bool `yaml:"https"`
+	// Tls is the TLS config of the HTTPS connections to ACM, e.g. the CA bundle and the client
+	// certificate for mutual TLS, and the server name (SNI) to verify the config servers, which
+	// are connected by IP. The TLS config of the transport is used if absent (see config.Config),
+	// and the system roots if neither.
+	Tls transport.TlsConfig `yaml:"tls"`
+	// Proxy is the URL of the HTTP or SOCKS5 proxy to ACM, the same as the transport one, which is
+	// used if absent (see config.Config). "direct" disables the proxy, and HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY are honored if neither is present.
+	Proxy string `yaml:"proxy"`
+	// StartupTimeoutMs is the timeout of waiting for the registration to AHAS (i.e. the tid)
+	// on startup. DefaultStartupTimeoutMs will be used if absent.
+	StartupTimeoutMs uint64 `yaml:"startupTimeoutMs"`
+	// NonBlockingStartup indicates whether to initialize the data-source in the background, which
+	// never fails the initialization but retries with backoff until succeeded or the context is done.
+	NonBlockingStartup bool `yaml:"nonBlockingStartup"`
+	// Nacos is the settings of the embedded Nacos client.
+	Nacos NacosConfig `yaml:"nacos"`
+	// Credential is the credential of the ACM instance which requires authentication (optional).
+	Credential Credential `yaml:"credential"`
+	// Apps is the logical apps hosted by current process (e.g. by a sidecar proxying several apps of
+	// the same license), whose rules are all subscribed with the resources namespaced by the app name,
+	// see AppResource. Empty means the App only. The apps share the license, the credentials and
+	// the connection to AHAS; the apps of other licenses are hosted by the ahas.Client of each license.
+	Apps []string `yaml:"apps"`
+	// App is the app whose rules are subscribed if Apps is absent, which is filled with the app name
+	// of the AHAS config (see config.Config). The Sentinel app name will be used if absent.
+	App string `yaml:"-"`
+}
+
+// NacosConfig is the settings of the local files of the embedded Nacos client, which
+// should point to writable paths in read-only containers. The defaults of the Nacos
+// client will be used if absent.
+type NacosConfig struct {
+	// CacheDir is the directory of the config cache.
+	CacheDir string `yaml:"cacheDir"`
+	// LogDir is the directory of the Nacos client logs.
+	LogDir string `yaml:"logDir"`
+	// LogLevel is the level of the Nacos client logs: debug, info, warn or error.
+	LogLevel string `yaml:"logLevel"`
+	// NotLoadCacheAtStart indicates whether to skip loading the cached configs at start.
+	NotLoadCacheAtStart bool `yaml:"notLoadCacheAtStart"`
+}
+
+// Environment variables of the ACM credential, which are used if absent in the config.
+const (
+	AccessKeyIdEnvKey     = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	AccessKeySecretEnvKey = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+)
+
+// Credential is the AccessKey credential of ACM. The credentials provider of the SDK (see
+// aliyun.CredentialsConfig) will be used if absent in both the config and the environment
+// variables, including the STS credentials of the RAM roles, which are refreshed before
+// they expire.
+type Credential struct {
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	// RegionId is the region of KMS, required if OpenKMS is enabled.
+	RegionId string `yaml:"regionId"`
+	// OpenKMS indicates whether to decrypt the KMS-encrypted configs.
+	OpenKMS bool `yaml:"openKMS"`
+}
+
+// keys returns the AccessKey pair from the config, or the environment variables if absent, or
+// the credentials provider of the SDK if neither. The STS credentials of the provider can't be
+// set in the Nacos client config, as it has no security token, so signed is returned instead,
+// which means that each request is signed with the current credentials, see acmHttpAgent.
+func (c *Credential) keys() (accessKey, secretKey string, signed bool) {
+	if c.AccessKey != "" {
+		return c.AccessKey, c.SecretKey, false
+	}
+	if accessKey = os.Getenv(AccessKeyIdEnvKey); accessKey != "" {
+		return accessKey, os.Getenv(AccessKeySecretEnvKey), false
+	}
+	creds, err := aliyun.GetCredentials()
+	if err == aliyun.ErrNoCredentials {
+		return "", "", false
+	}
+	if err != nil {
+		// The credentials are retrieved again on each request.
+		logger.Warnf("Failed to get the credentials of ACM: %+v", err)
+		return "", "", true
+	}
+	if creds.Temporary() {
+		return "", "", true
+	}
+	return creds.AccessKeyId, creds.AccessKeySecret, false
+}
+
+// sources returns the rule sources of the Apps, or the App if absent.
+func (c *Config) sources() ([]*ruleSource, error) {
+	if len(c.Apps) > 0 {
+		return appSources(c.Apps)
+	}
+	if c.App != "" {
+		return []*ruleSource{{app: c.App}}, nil
+	}
+	return []*ruleSource{{app: sentinelConf.AppName()}}, nil
+}
+
+func (c *Config) group() string {
+	if c.Group == "" {
+		return AcmGroupId
+	}
+	return c.Group
+}
+
+func (c *Config) acmEndpoint(host string) string {
+	port := c.EndpointPort
+	if port <= 0 {
+		port = DefaultAcmEndpointPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (c *Config) paramsMaxCapacity() int64 {
+	if c.ParamsMaxCapacity <= 0 {
+		return DefaultParamsMaxCapacity
+	}
+	return c.ParamsMaxCapacity
+}
+
+func (c *Config) ruleHistorySize() int {
+	if c.RuleHistorySize <= 0 {
+		return DefaultRuleHistorySize
+	}
+	return c.RuleHistorySize
+}
+
+func (c *Config) debounceWindow() time.Duration {
+	if c.DebounceMs == 0 {
+		return time.Duration(DefaultDebounceMs) * time.Millisecond
+	}
+	return time.Duration(c.DebounceMs) * time.Millisecond
+}
+
+func (c *Config) startupTimeout() time.Duration {
+	if c.StartupTimeoutMs == 0 {
+		return time.Duration(DefaultStartupTimeoutMs) * time.Millisecond
+	}
+	return time.Duration(c.StartupTimeoutMs) * time.Millisecond
+}
+
+func (c *Config) formDataId(prefix, userId, namespace, appName string) string {
+	template := c.DataIdTemplate
+	if template == "" {
+		template = DefaultDataIdTemplate
+	}
+	return strings.NewReplacer(
+		"{prefix}", prefix,
+		"{uid}", userId,
+		"{namespace}", namespace,
+		"{app}", appName,
+	).Replace(template)
 }
@@ -0,0 +1,159 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// RuleConflict describes the rules of the same target with contradictory thresholds.
+// The precedence is: the most restrictive rule (i.e. the lowest threshold) wins,
+// and the earliest one wins among equally restrictive rules. Rules from the sources
+// (apps) are ordered by the source name.
+type RuleConflict struct {
+	Kind   RuleKind `json:"kind"`
+	Target string   `json:"target"`
+	Winner string   `json:"winner"`
+	Losers []string `json:"losers"`
+}
+
+// conflictKeyFunc returns the target of the rule, which could only be limited by one
+// threshold, and the threshold. The rule is excluded from detection if !ok.
+type conflictKeyFunc func(rule interface{}) (target string, threshold float64, ok bool)
+
+var (
+	conflictMux   = &sync.RWMutex{}
+	ruleConflicts = make(map[RuleKind][]RuleConflict)
+)
+
+// RuleConflicts returns the conflicts detected when applying the rules last time.
+func RuleConflicts() []RuleConflict {
+	conflictMux.RLock()
+	defer conflictMux.RUnlock()
+	result := make([]RuleConflict, 0)
+	for _, cs := range ruleConflicts {
+		result = append(result, cs...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Target < result[j].Target
+	})
+	return result
+}
+
+// resolveConflicts drops the rules losing in conflicts, and reports the conflicts.
+func resolveConflicts(kind RuleKind, keyOf conflictKeyFunc, rules []interface{}) []interface{} {
+	type candidate struct {
+		index     int
+		threshold float64
+	}
+	groups := make(map[string][]candidate)
+	targets := make([]string, 0)
+	for i, r := range rules {
+		target, threshold, ok := keyOf(r)
+		if !ok {
+			continue
+		}
+		if _, exists := groups[target]; !exists {
+			targets = append(targets, target)
+		}
+		groups[target] = append(groups[target], candidate{index: i, threshold: threshold})
+	}
+
+	dropped := make(map[int]bool)
+	conflicts := make([]RuleConflict, 0)
+	for _, target := range targets {
+		cs := groups[target]
+		winner := cs[0]
+		contradictory := false
+		for _, c := range cs[1:] {
+			if c.threshold != winner.threshold {
+				contradictory = true
+			}
+			if c.threshold < winner.threshold {
+				winner = c
+			}
+		}
+		if !contradictory {
+			// Identical thresholds are redundant rather than contradictory.
+			continue
+		}
+		conflict := RuleConflict{Kind: kind, Target: target, Winner: ruleKey(rules[winner.index])}
+		for _, c := range cs {
+			if c.index != winner.index {
+				dropped[c.index] = true
+				conflict.Losers = append(conflict.Losers, ruleKey(rules[c.index]))
+			}
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	setRuleConflicts(kind, conflicts)
+	if len(dropped) == 0 {
+		return rules
+	}
+	result := make([]interface{}, 0, len(rules)-len(dropped))
+	for i, r := range rules {
+		if !dropped[i] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func setRuleConflicts(kind RuleKind, conflicts []RuleConflict) {
+	for _, c := range conflicts {
+		logger.Warnf("Conflicting %s rules of %s, the most restrictive one wins: %s, ignored: %v", c.Kind, c.Target, c.Winner, c.Losers)
+	}
+	conflictMux.Lock()
+	ruleConflicts[kind] = conflicts
+	conflictMux.Unlock()
+	recordRuleConflicts(kind, len(conflicts))
+}
+
+func flowRuleConflictKey(rule interface{}) (string, float64, bool) {
+	r, ok := rule.(*flow.FlowRule)
+	if !ok {
+		return "", 0, false
+	}
+	return fmt.Sprintf("resource=%s, metricType=%d, relationStrategy=%d, refResource=%s, limitOrigin=%s",
+		r.Resource, r.MetricType, r.RelationStrategy, r.RefResource, r.LimitOrigin), r.Count, true
+}
+
+func systemRuleConflictKey(rule interface{}) (string, float64, bool) {
+	r, ok := rule.(*system.SystemRule)
+	if !ok {
+		return "", 0, false
+	}
+	return fmt.Sprintf("metricType=%d", r.MetricType), r.TriggerCount, true
+}
+
+func circuitBreakingRuleConflictKey(rule interface{}) (string, float64, bool) {
+	r, ok := rule.(*circuitbreaker.Rule)
+	if !ok {
+		return "", 0, false
+	}
+	target := fmt.Sprintf("resource=%s, strategy=%d", r.Resource, r.Strategy)
+	if r.Strategy == circuitbreaker.SlowRequestRatio {
+		// Slow ratio rules with different RT thresholds target different requests.
+		target += fmt.Sprintf(", maxAllowedRtMs=%d", r.MaxAllowedRtMs)
+	}
+	return target, r.Threshold, true
+}
+
+func paramFlowRuleConflictKey(rule interface{}) (string, float64, bool) {
+	switch r := rule.(type) {
+	case *hotspot.Rule:
+		return fmt.Sprintf("resource=%s, metricType=%d, paramIndex=%d", r.Resource, r.MetricType, r.ParamIndex), r.Threshold, true
+	case *keyedParamFlowRule:
+		return fmt.Sprintf("resource=%s, metricType=%d, paramKey=%s", r.Resource, r.MetricType, r.ParamKey), r.Threshold, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,139 @@
+package datasource
+
+import (
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/apigateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/pkg/errors"
+)
+
+// convertPayload decodes the rule payload of the kind, and passes the decoded legacy rules
+// to the convert, which reports false if they are of an unexpected type.
+func convertPayload(kind RuleKind, data string, convert func(decoded interface{}) bool) error {
+	decoded, err := decodeRulePayload(kind, data)
+	if err != nil {
+		return err
+	}
+	if !convert(decoded) {
+		return errors.Errorf("unexpected decoded type %T of %s rules", decoded, kind)
+	}
+	return nil
+}
+
+// ConvertFlowRules converts the flow rule payload in the AHAS console format to Sentinel rules,
+// the same way as the rules pushed via the data-source. Invalid rules are skipped.
+func ConvertFlowRules(data string) ([]*flow.FlowRule, error) {
+	result := make([]*flow.FlowRule, 0)
+	err := convertPayload(FlowRuleKind, data, func(decoded interface{}) bool {
+		rules, ok := decoded.([]*LegacyFlowRule)
+		for _, r := range rules {
+			if rule := r.ToGoRule(); rule != nil {
+				result = append(result, rule)
+			}
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ConvertSystemRules converts the system rule payload in the AHAS console format to Sentinel
+// system rules. Invalid rules are skipped.
+func ConvertSystemRules(data string) ([]*system.SystemRule, error) {
+	result := make([]*system.SystemRule, 0)
+	err := convertPayload(SystemRuleKind, data, func(decoded interface{}) bool {
+		rules, ok := decoded.([]*LegacySystemRule)
+		for _, r := range rules {
+			if rule := r.ToGoRule(); rule != nil {
+				result = append(result, rule)
+			}
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ConvertCircuitBreakingRules converts the degrade rule payload in the AHAS console format
+// to Sentinel circuit breaking rules. Invalid rules are skipped.
+func ConvertCircuitBreakingRules(data string) ([]*circuitbreaker.Rule, error) {
+	result := make([]*circuitbreaker.Rule, 0)
+	err := convertPayload(CircuitBreakingRuleKind, data, func(decoded interface{}) bool {
+		rules, ok := decoded.([]*LegacyDegradeRule)
+		for _, r := range rules {
+			if rule := r.ToGoRule(); rule != nil {
+				result = append(result, rule)
+			}
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ConvertParamFlowRules converts the hot-spot parameter flow rule payload in the AHAS console
+// format to Sentinel hot-spot rules. Invalid rules are skipped. Note that the ParamIndex of
+// the rules with ParamKey is resolved when loaded, which is left as is.
+func ConvertParamFlowRules(data string) ([]*hotspot.Rule, error) {
+	result := make([]*hotspot.Rule, 0)
+	err := convertPayload(ParamFlowRuleKind, data, func(decoded interface{}) bool {
+		rules, ok := decoded.([]*LegacyParamFlowRule)
+		for _, r := range rules {
+			if rule := r.ToGoRule(); rule != nil {
+				result = append(result, rule)
+			}
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ConvertGatewayFlowRules converts the gateway flow rule payload in the AHAS console format
+// to the gateway rules (see apigateway.Rule). Invalid rules are skipped.
+func ConvertGatewayFlowRules(data string) ([]*apigateway.Rule, error) {
+	result := make([]*apigateway.Rule, 0)
+	err := convertPayload(GatewayFlowRuleKind, data, func(decoded interface{}) bool {
+		rules, ok := decoded.([]*LegacyGatewayFlowRule)
+		for _, r := range rules {
+			if rule := r.ToGoRule(); rule != nil {
+				result = append(result, rule)
+			}
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ConvertAuthorityRules converts the authority rule payload in the AHAS console format
+// to the authority rules (see authority.Rule). Invalid rules are skipped.
+func ConvertAuthorityRules(data string) ([]*authority.Rule, error) {
+	result := make([]*authority.Rule, 0)
+	err := convertPayload(AuthorityRuleKind, data, func(decoded interface{}) bool {
+		rules, ok := decoded.([]*LegacyAuthorityRule)
+		for _, r := range rules {
+			if rule := r.ToGoRule(); rule != nil {
+				result = append(result, rule)
+			}
+		}
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
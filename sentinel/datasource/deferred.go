@@ -0,0 +1,50 @@
+package datasource
+
+import (
+	"sync"
+
+	sentinelLogger "github.com/alibaba/sentinel-golang/logging"
+	"github.com/aliyun/aliyun-ahas-go-sdk/health"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+)
+
+var (
+	deferredMux = &sync.Mutex{}
+	// deferredPushes keeps the latest rule payload per app and rule kind, which was
+	// received while the application is not healthy.
+	deferredPushes   = make(map[string]func())
+	deferredInitOnce sync.Once
+)
+
+// deferIfDegraded defers applying the rule payload until the application recovers,
+// if configured so. Rollbacks are never deferred. It reports whether the payload has been deferred.
+func (src *ruleSource) deferIfDegraded(kind RuleKind, data string, apply func(data string)) bool {
+	if !health.ShouldDeferRulePush() || isRollbackPayload(src.app, kind, data) {
+		return false
+	}
+	deferredInitOnce.Do(func() {
+		health.AddListener(func(_, cur health.Snapshot) {
+			if cur.Status == health.Healthy {
+				go applyDeferredPushes()
+			}
+		})
+	})
+	deferredMux.Lock()
+	deferredPushes[checksumKey(src.app, kind)] = func() {
+		apply(data)
+	}
+	deferredMux.Unlock()
+	sentinelLogger.Warnf("Deferred applying %s rules of app %s as the application is %s", kind, src.app, health.Current().Status)
+	return true
+}
+
+func applyDeferredPushes() {
+	defer tools.PrintPanicStackV2("apply deferred rule pushes")
+	deferredMux.Lock()
+	pushes := deferredPushes
+	deferredPushes = make(map[string]func())
+	deferredMux.Unlock()
+	for _, apply := range pushes {
+		apply()
+	}
+}
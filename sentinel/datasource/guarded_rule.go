@@ -0,0 +1,341 @@
+package datasource
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/stat"
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/expression"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/pkg/errors"
+)
+
+const guardEvaluateInterval = time.Second
+
+// Statistic variables supported in rule expressions.
+const (
+	ExprVarQps         = "qps"
+	ExprVarPassQps     = "passQps"
+	ExprVarBlockQps    = "blockQps"
+	ExprVarCompleteQps = "completeQps"
+	ExprVarErrorQps    = "errorQps"
+	ExprVarErrorRatio  = "errorRatio"
+	ExprVarRt          = "rt"
+	ExprVarConcurrency = "concurrency"
+)
+
+var supportedExprVars = map[string]bool{
+	ExprVarQps:         true,
+	ExprVarPassQps:     true,
+	ExprVarBlockQps:    true,
+	ExprVarCompleteQps: true,
+	ExprVarErrorQps:    true,
+	ExprVarErrorRatio:  true,
+	ExprVarRt:          true,
+	ExprVarConcurrency: true,
+}
+
+// compileRuleExpression compiles the expression of a rule. Empty expression results in nil.
+func compileRuleExpression(src string) (*expression.Expression, error) {
+	if src == "" {
+		return nil, nil
+	}
+	if !feature.Enabled(feature.RuleExpression) {
+		return nil, errors.New("rule expression feature is disabled")
+	}
+	expr, err := expression.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range expr.Identifiers() {
+		if !supportedExprVars[v] {
+			return nil, errors.Errorf("unsupported variable <%s> in expression: %s", v, src)
+		}
+	}
+	return expr, nil
+}
+
+// resourceVariables resolves the expression variables from recent statistics of the resource.
+func resourceVariables(resource string) expression.Variables {
+	node := stat.GetResourceNode(resource)
+	return func(name string) (float64, bool) {
+		if !supportedExprVars[name] {
+			return 0, false
+		}
+		if node == nil {
+			return 0, true
+		}
+		switch name {
+		case ExprVarQps:
+			return node.GetQPS(base.MetricEventPass) + node.GetQPS(base.MetricEventBlock), true
+		case ExprVarPassQps:
+			return node.GetQPS(base.MetricEventPass), true
+		case ExprVarBlockQps:
+			return node.GetQPS(base.MetricEventBlock), true
+		case ExprVarCompleteQps:
+			return node.GetQPS(base.MetricEventComplete), true
+		case ExprVarErrorQps:
+			return node.GetQPS(base.MetricEventError), true
+		case ExprVarErrorRatio:
+			complete := node.GetQPS(base.MetricEventComplete)
+			if complete <= 0 {
+				return 0, true
+			}
+			return node.GetQPS(base.MetricEventError) / complete, true
+		case ExprVarRt:
+			return node.AvgRT(), true
+		case ExprVarConcurrency:
+			return float64(node.CurrentGoroutineNum()), true
+		}
+		return 0, false
+	}
+}
+
+// guardedRule is a rule which is only active while its condition holds and it's
+// not in shadow. A rule without condition is always active.
+type guardedRule struct {
+	rule      interface{}
+	resource  string
+	condition *expression.Expression
+
+	// key identifies the content of the rule, and id identifies the rule across edits.
+	key         string
+	id          string
+	shadowUntil time.Time
+	// superseded is the previous version of an edited rule, which is enforced in place
+	// of the rule while it's in shadow.
+	superseded *guardedRule
+}
+
+func (r *guardedRule) inShadow(now time.Time) bool {
+	return now.Before(r.shadowUntil)
+}
+
+// ruleState tells which version of a guarded rule is loaded.
+type ruleState uint8
+
+const (
+	ruleInactive ruleState = iota
+	ruleEnforcing
+	// ruleSuperseded means the previous version of the rule is enforced while the rule is in shadow.
+	ruleSuperseded
+)
+
+// guardedRuleSet keeps the rules of one kind from all sources (apps),
+// and loads the currently active ones.
+type guardedRuleSet struct {
+	mux     sync.Mutex
+	kind    string
+	sources map[string][]guardedRule
+	rules   []guardedRule
+	active  []ruleState
+	load    func(rules []interface{}) error
+	// loaded is the rules last loaded into Sentinel.
+	loaded []interface{}
+	// wouldBlock checks whether the rule would block current traffic, for rules in shadow (optional).
+	wouldBlock shadowChecker
+	// ruleKind and conflictKey are for detecting the conflicting rules (optional).
+	ruleKind    RuleKind
+	conflictKey conflictKeyFunc
+}
+
+func newGuardedRuleSet(kind string, load func(rules []interface{}) error) *guardedRuleSet {
+	return &guardedRuleSet{
+		kind:    kind,
+		sources: make(map[string][]guardedRule),
+		load:    load,
+	}
+}
+
+func (s *guardedRuleSet) withShadowChecker(c shadowChecker) *guardedRuleSet {
+	s.wouldBlock = c
+	return s
+}
+
+func (s *guardedRuleSet) withConflictDetection(kind RuleKind, key conflictKeyFunc) *guardedRuleSet {
+	s.ruleKind = kind
+	s.conflictKey = key
+	return s
+}
+
+// update replaces all rules of the source in the set and loads the active ones immediately.
+func (s *guardedRuleSet) update(source string, rules []guardedRule) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	previous, loaded := s.sources[source]
+	// The local rules are coded into the application, which are never put in shadow.
+	assignShadow(s.kind, previous, rules, loaded && source != localRuleSource)
+	s.sources[source] = rules
+	s.mergeLocked()
+	for _, r := range rules {
+		if r.condition != nil || !r.shadowUntil.IsZero() {
+			startGuardEvaluator()
+			break
+		}
+	}
+	return s.refreshLocked()
+}
+
+// remerge merges the rules of all sources again (e.g. after the local rule policy changed) and reloads them.
+func (s *guardedRuleSet) remerge() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.mergeLocked()
+	return s.refreshLocked()
+}
+
+// mergeLocked merges the rules of all sources, where the local rules and the remote ones
+// of the same resource are merged under the LocalRulePolicy.
+func (s *guardedRuleSet) mergeLocked() {
+	names := make([]string, 0, len(s.sources))
+	for name := range s.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	policy := localRulePolicy()
+	localResources := make(map[string]bool)
+	remoteResources := make(map[string]bool)
+	for name, rules := range s.sources {
+		for _, r := range rules {
+			if name == localRuleSource {
+				localResources[r.resource] = true
+			} else {
+				remoteResources[r.resource] = true
+			}
+		}
+	}
+	s.rules = make([]guardedRule, 0)
+	for _, name := range names {
+		for _, r := range s.sources[name] {
+			if name == localRuleSource && policy == RemoteRulesWin && remoteResources[r.resource] {
+				continue
+			}
+			if name != localRuleSource && policy == LocalRulesWin && localResources[r.resource] {
+				continue
+			}
+			s.rules = append(s.rules, r)
+		}
+	}
+	s.active = nil
+}
+
+// refresh re-evaluates the conditions and reloads the rules if the active set changed.
+func (s *guardedRuleSet) refresh() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.refreshLocked()
+}
+
+func (s *guardedRuleSet) refreshLocked() error {
+	now := time.Now()
+	enforcing := ProtectionEnabled()
+	overridden := activeOverrides(now)
+	active := make([]ruleState, len(s.rules))
+	changed := s.active == nil
+	for i, r := range s.rules {
+		active[i] = ruleEnforcing
+		if !enforcing {
+			// No rules are enforced while the protection is switched off.
+			active[i] = ruleInactive
+		} else if mode, ok := overridden[r.resource]; ok {
+			// Rules of the overridden resources are not enforced.
+			active[i] = ruleInactive
+			if mode == OverrideMonitorOnly {
+				recordOverride(s.kind, &r, s.wouldBlock)
+			}
+		} else if r.inShadow(now) {
+			// Rules in shadow are not enforced, but evaluated against the traffic.
+			// The previous version of an edited rule keeps enforcing meanwhile.
+			active[i] = ruleInactive
+			if r.superseded != nil && s.conditionHolds(r.superseded) {
+				active[i] = ruleSuperseded
+			}
+			recordShadow(s.kind, &r, s.wouldBlock)
+		} else if !s.conditionHolds(&r) {
+			active[i] = ruleInactive
+		}
+		if !r.shadowUntil.IsZero() && !r.inShadow(now) {
+			if st, ok := promoteShadow(s.kind, &r); ok {
+				logger.Infof("The %s rule has been promoted from shadow to enforcing after %d evaluations (%d would block): %s",
+					s.kind, st.Evaluations, st.WouldBlockCount, r.key)
+			}
+		}
+		if !changed && active[i] != s.active[i] {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	s.active = active
+	toLoad := make([]interface{}, 0, len(s.rules))
+	for i, r := range s.rules {
+		switch active[i] {
+		case ruleEnforcing:
+			toLoad = append(toLoad, r.rule)
+		case ruleSuperseded:
+			toLoad = append(toLoad, r.superseded.rule)
+		}
+	}
+	if s.conflictKey != nil {
+		toLoad = resolveConflicts(s.ruleKind, s.conflictKey, toLoad)
+	}
+	s.loaded = toLoad
+	return s.load(toLoad)
+}
+
+func (s *guardedRuleSet) loadedRules() []interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]interface{}(nil), s.loaded...)
+}
+
+// conditionHolds evaluates the condition of the rule, where a rule without condition always holds.
+func (s *guardedRuleSet) conditionHolds(r *guardedRule) bool {
+	if r.condition == nil {
+		return true
+	}
+	ok, err := r.condition.Evaluate(resourceVariables(r.resource))
+	if err != nil {
+		logger.Warnf("Failed to evaluate %s rule expression <%s>: %+v", s.kind, r.condition, err)
+	}
+	return ok
+}
+
+var (
+	guardedRuleSets   = make([]*guardedRuleSet, 0)
+	guardEvaluateOnce sync.Once
+)
+
+func registerGuardedRuleSet(s *guardedRuleSet) *guardedRuleSet {
+	guardedRuleSets = append(guardedRuleSets, s)
+	return s
+}
+
+// startGuardEvaluator starts the background task which periodically re-evaluates
+// the rule conditions against recent statistics.
+func startGuardEvaluator() {
+	guardEvaluateOnce.Do(func() {
+		go func() {
+			defer tools.PrintPanicStackV2("rule expression evaluator")
+			ticker := time.NewTicker(guardEvaluateInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				refreshGuardedRuleSets("evaluating expressions")
+			}
+		}()
+	})
+}
+
+// refreshGuardedRuleSets re-evaluates and reloads the rules of all kinds.
+func refreshGuardedRuleSets(after string) {
+	for _, s := range guardedRuleSets {
+		if err := s.refresh(); err != nil {
+			logger.Warnf("Failed to reload %s rules after %s: %+v", s.kind, after, err)
+		}
+	}
+}
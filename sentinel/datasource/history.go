@@ -0,0 +1,159 @@
+package datasource
+
+import (
+	"sync"
+
+	sentinelLogger "github.com/alibaba/sentinel-golang/logging"
+	"github.com/pkg/errors"
+)
+
+// emptyRulePayload is the payload without rules, for apps which had no rules in the target version.
+const emptyRulePayload = `{"data":[]}`
+
+// appliedPayload is the payload applied for an app, with the handler to re-apply it.
+type appliedPayload struct {
+	data  string
+	apply func(data string)
+}
+
+var (
+	historyMux = &sync.Mutex{}
+	// ruleHistory keeps the recent versions per rule kind. Each version is the snapshot
+	// of the applied payloads of all apps, and the last one is the current version.
+	ruleHistory = make(map[RuleKind][]map[string]appliedPayload)
+	historySize = DefaultRuleHistorySize
+
+	// rollbackMux serializes the rollbacks.
+	rollbackMux = &sync.Mutex{}
+	// rollbackPayloads is the payload being re-applied by the rollback per app and rule kind,
+	// which is not recorded as a new version. The pushes applied concurrently are recorded.
+	rollbackPayloads = make(map[string]string)
+)
+
+func setRuleHistorySize(size int) {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	historySize = size
+}
+
+// recordAppliedPayload records the successfully applied payload as a new version of the rule kind.
+func recordAppliedPayload(app string, kind RuleKind, data string, apply func(data string)) {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	if isRollbackPayloadLocked(app, kind, data) {
+		return
+	}
+	versions := ruleHistory[kind]
+	version := make(map[string]appliedPayload)
+	if len(versions) > 0 {
+		for a, p := range versions[len(versions)-1] {
+			version[a] = p
+		}
+	}
+	version[app] = appliedPayload{data: data, apply: apply}
+	versions = append(versions, version)
+	// Keep the current version and historySize previous versions.
+	if len(versions) > historySize+1 {
+		versions = versions[len(versions)-historySize-1:]
+	}
+	ruleHistory[kind] = versions
+}
+
+// RuleHistoryLen returns the count of previous versions of the rule kind, which could be rolled back to.
+func RuleHistoryLen(ruleType RuleKind) int {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	if n := len(ruleHistory[ruleType]); n > 0 {
+		return n - 1
+	}
+	return 0
+}
+
+// CurrentRules returns the currently applied rule payload of each app of the rule kind.
+func CurrentRules(kind RuleKind) map[string]string {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	result := make(map[string]string)
+	if versions := ruleHistory[kind]; len(versions) > 0 {
+		for app, p := range versions[len(versions)-1] {
+			result[app] = p.data
+		}
+	}
+	return result
+}
+
+// Rollback reverts the rules of the given type to the version applied steps pushes ago,
+// which is intended for reverting a bad rule push locally while the console is being fixed.
+// The reverted versions are discarded. Note that the next push from the console will
+// override the rolled back rules.
+func Rollback(ruleType RuleKind, steps int) error {
+	if steps <= 0 {
+		return errors.Errorf("invalid rollback steps: %d", steps)
+	}
+	rollbackMux.Lock()
+	defer rollbackMux.Unlock()
+
+	historyMux.Lock()
+	versions := ruleHistory[ruleType]
+	if steps >= len(versions) {
+		historyMux.Unlock()
+		return errors.Errorf("cannot roll back %s rules by %d steps, only %d previous versions available",
+			ruleType, steps, RuleHistoryLen(ruleType))
+	}
+	current := versions[len(versions)-1]
+	target := versions[len(versions)-1-steps]
+	ruleHistory[ruleType] = versions[:len(versions)-steps]
+	historyMux.Unlock()
+
+	failed := make([]string, 0)
+	for app, p := range current {
+		if _, ok := target[app]; !ok {
+			// The app had no rules of the type in the target version.
+			reapply(app, ruleType, emptyRulePayload, p.apply)
+			if !payloadUnchanged(app, ruleType, emptyRulePayload) {
+				failed = append(failed, app)
+			}
+		}
+	}
+	for app, p := range target {
+		if cur, ok := current[app]; ok && cur.data == p.data {
+			continue
+		}
+		reapply(app, ruleType, p.data, p.apply)
+		if !payloadUnchanged(app, ruleType, p.data) {
+			failed = append(failed, app)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to roll back %s rules of apps: %v", ruleType, failed)
+	}
+	sentinelLogger.Warnf("Rolled back %s rules by %d steps", ruleType, steps)
+	return nil
+}
+
+// reapply applies the payload of a previous version, which is marked as being rolled back
+// during applying.
+func reapply(app string, kind RuleKind, data string, apply func(data string)) {
+	key := checksumKey(app, kind)
+	historyMux.Lock()
+	rollbackPayloads[key] = data
+	historyMux.Unlock()
+	defer func() {
+		historyMux.Lock()
+		delete(rollbackPayloads, key)
+		historyMux.Unlock()
+	}()
+	apply(data)
+}
+
+// isRollbackPayload reports whether the payload is being re-applied by Rollback.
+func isRollbackPayload(app string, kind RuleKind, data string) bool {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	return isRollbackPayloadLocked(app, kind, data)
+}
+
+func isRollbackPayloadLocked(app string, kind RuleKind, data string) bool {
+	d, ok := rollbackPayloads[checksumKey(app, kind)]
+	return ok && d == data
+}
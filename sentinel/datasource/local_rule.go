@@ -0,0 +1,120 @@
+package datasource
+
+import (
+	"sync/atomic"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+)
+
+// localRuleSource is the source key of the rules registered by the application.
+const localRuleSource = "$local"
+
+// LocalRulePolicy is the precedence of the local rules (registered by the application via
+// SetLocalFlowRules, etc.) over the remote rules (delivered from AHAS) of the same resource.
+type LocalRulePolicy string
+
+const (
+	// LocalRulesAdditive applies both the local and remote rules (the default).
+	LocalRulesAdditive LocalRulePolicy = "additive"
+	// LocalRulesWin ignores the remote rules of the resources with local rules.
+	LocalRulesWin LocalRulePolicy = "localWins"
+	// RemoteRulesWin ignores the local rules of the resources with remote rules, i.e. the local
+	// rules are the baseline only in effect while no rules are configured in AHAS.
+	RemoteRulesWin LocalRulePolicy = "remoteWins"
+)
+
+// currentLocalRulePolicy is the configured Config.LocalRulePolicy.
+var currentLocalRulePolicy atomic.Value
+
+func localRulePolicy() LocalRulePolicy {
+	p, _ := currentLocalRulePolicy.Load().(LocalRulePolicy)
+	if p == "" {
+		return LocalRulesAdditive
+	}
+	return p
+}
+
+// SetLocalRulePolicy changes the precedence of the local rules, and reloads the rules if changed.
+func SetLocalRulePolicy(p LocalRulePolicy) {
+	switch p {
+	case "", LocalRulesAdditive, LocalRulesWin, RemoteRulesWin:
+	default:
+		logger.Warnf("Unknown local rule policy %s, %s will be used", p, LocalRulesAdditive)
+		p = LocalRulesAdditive
+	}
+	if p == "" {
+		p = LocalRulesAdditive
+	}
+	if localRulePolicy() == p {
+		return
+	}
+	currentLocalRulePolicy.Store(p)
+	for _, s := range guardedRuleSets {
+		if err := s.remerge(); err != nil {
+			logger.Warnf("Failed to reload %s rules after the local rule policy changed: %+v", s.kind, err)
+		}
+	}
+}
+
+// SetLocalFlowRules replaces the local flow rules, which are merged with the remote rules
+// under the LocalRulePolicy, e.g. as the baseline protection surviving the rules being
+// cleared in the console by accident.
+func SetLocalFlowRules(rules []*flow.FlowRule) error {
+	arr := make([]guardedRule, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			arr = append(arr, guardedRule{rule: r, resource: r.Resource})
+		}
+	}
+	return flowRuleSet.update(localRuleSource, arr)
+}
+
+// SetLocalSystemRules replaces the local system rules, see SetLocalFlowRules.
+// The system rules are global, so all of them are of the same (empty) resource.
+func SetLocalSystemRules(rules []*system.SystemRule) error {
+	arr := make([]guardedRule, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			arr = append(arr, guardedRule{rule: r})
+		}
+	}
+	return systemRuleSet.update(localRuleSource, arr)
+}
+
+// SetLocalCircuitBreakingRules replaces the local circuit breaking rules, see SetLocalFlowRules.
+func SetLocalCircuitBreakingRules(rules []*circuitbreaker.Rule) error {
+	arr := make([]guardedRule, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			arr = append(arr, guardedRule{rule: r, resource: r.Resource})
+		}
+	}
+	return circuitBreakingRuleSet.update(localRuleSource, arr)
+}
+
+// SetLocalParamFlowRules replaces the local hot-spot parameter flow rules, see SetLocalFlowRules.
+func SetLocalParamFlowRules(rules []*hotspot.Rule) error {
+	arr := make([]guardedRule, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			arr = append(arr, guardedRule{rule: r, resource: r.Resource})
+		}
+	}
+	return paramFlowRuleSet.update(localRuleSource, arr)
+}
+
+// SetLocalAuthorityRules replaces the local authority rules, see SetLocalFlowRules.
+func SetLocalAuthorityRules(rules []*authority.Rule) error {
+	arr := make([]guardedRule, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			arr = append(arr, guardedRule{rule: r, resource: r.Resource})
+		}
+	}
+	return authorityRuleSet.update(localRuleSource, arr)
+}
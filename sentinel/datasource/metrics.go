@@ -0,0 +1,153 @@
+package datasource
+
+import (
+	"sync"
+
+	"github.com/alibaba/sentinel-golang/util"
+)
+
+// RuleLoadMetrics is the statistics of loading the rules of a kind, which could be used
+// to alert on stale or broken rules.
+type RuleLoadMetrics struct {
+	// LoadSuccess is the count of payloads which have been applied successfully.
+	LoadSuccess uint64 `json:"loadSuccess"`
+	// LoadFailure is the count of payloads which failed to be loaded into Sentinel.
+	LoadFailure uint64 `json:"loadFailure"`
+	// ParseFailure is the count of payloads which failed to be decoded.
+	ParseFailure uint64 `json:"parseFailure"`
+	// Unchanged is the count of re-delivered payloads which were identical to the applied one.
+	Unchanged uint64 `json:"unchanged"`
+	// RuleCount is the count of rules in the latest applied payloads (of all apps).
+	RuleCount int `json:"ruleCount"`
+	// Conflicts is the count of conflicts detected when applying the rules last time.
+	Conflicts int `json:"conflicts"`
+	// LastReceivedMs is the timestamp of the latest received payload.
+	LastReceivedMs uint64 `json:"lastReceivedMs"`
+	// LastAppliedMs is the timestamp of the latest successfully applied payload.
+	LastAppliedMs uint64 `json:"lastAppliedMs"`
+}
+
+type ruleKindMetrics struct {
+	RuleLoadMetrics
+	appRuleCount map[string]int
+}
+
+// RuleUpdate is a successful update of the rules of a kind of an app.
+type RuleUpdate struct {
+	Kind RuleKind `json:"kind"`
+	App  string   `json:"app"`
+	// RuleCount is the count of the rules of the app after the update.
+	RuleCount int `json:"ruleCount"`
+	// Initial indicates whether it's the first load of the rules of the app, e.g. at startup.
+	Initial   bool   `json:"initial"`
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// RuleUpdateListener is notified of each successful update of the rules. It must not block.
+type RuleUpdateListener func(u RuleUpdate)
+
+var (
+	metricsMux  = &sync.Mutex{}
+	ruleMetrics = make(map[RuleKind]*ruleKindMetrics)
+
+	ruleListenerMux = &sync.RWMutex{}
+	ruleListeners   = make([]RuleUpdateListener, 0)
+)
+
+// AddRuleUpdateListener adds the listener of the rule updates.
+func AddRuleUpdateListener(l RuleUpdateListener) {
+	if l == nil {
+		return
+	}
+	ruleListenerMux.Lock()
+	defer ruleListenerMux.Unlock()
+	ruleListeners = append(ruleListeners, l)
+}
+
+func notifyRuleUpdate(u RuleUpdate) {
+	ruleListenerMux.RLock()
+	defer ruleListenerMux.RUnlock()
+	for _, l := range ruleListeners {
+		l(u)
+	}
+}
+
+// RuleMetrics returns the snapshot of the rule-load metrics of each rule kind.
+func RuleMetrics() map[RuleKind]RuleLoadMetrics {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	result := make(map[RuleKind]RuleLoadMetrics, len(ruleMetrics))
+	for kind, m := range ruleMetrics {
+		result[kind] = m.RuleLoadMetrics
+	}
+	return result
+}
+
+func kindMetricsLocked(kind RuleKind) *ruleKindMetrics {
+	m, ok := ruleMetrics[kind]
+	if !ok {
+		m = &ruleKindMetrics{appRuleCount: make(map[string]int)}
+		ruleMetrics[kind] = m
+	}
+	return m
+}
+
+func recordRuleReceived(kind RuleKind) {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	kindMetricsLocked(kind).LastReceivedMs = util.CurrentTimeMillis()
+}
+
+func recordRuleUnchanged(kind RuleKind) {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	kindMetricsLocked(kind).Unchanged++
+}
+
+func recordRuleParseFailure(kind RuleKind) {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	kindMetricsLocked(kind).ParseFailure++
+}
+
+// recordRuleLoad records the result of loading the rules of the app.
+func recordRuleLoad(kind RuleKind, app string, count int, err error) {
+	metricsMux.Lock()
+	m := kindMetricsLocked(kind)
+	if err != nil {
+		m.LoadFailure++
+		metricsMux.Unlock()
+		return
+	}
+	now := util.CurrentTimeMillis()
+	m.LoadSuccess++
+	m.LastAppliedMs = now
+	_, loaded := m.appRuleCount[app]
+	m.appRuleCount[app] = count
+	m.RuleCount = 0
+	for _, c := range m.appRuleCount {
+		m.RuleCount += c
+	}
+	metricsMux.Unlock()
+	notifyRuleUpdate(RuleUpdate{Kind: kind, App: app, RuleCount: count, Initial: !loaded, Timestamp: now})
+}
+
+func recordRuleConflicts(kind RuleKind, n int) {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	kindMetricsLocked(kind).Conflicts = n
+}
+
+// LastRuleAppliedMs returns the timestamp of the latest successfully applied rule payload
+// of all rule kinds, 0 if none.
+func LastRuleAppliedMs() uint64 {
+	metricsMux.Lock()
+	defer metricsMux.Unlock()
+	var last uint64
+	for _, m := range ruleMetrics {
+		if m.LastAppliedMs > last {
+			last = m.LastAppliedMs
+		}
+	}
+	return last
+}
@@ -0,0 +1,156 @@
+package datasource
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/pkg/errors"
+)
+
+// OverrideMode is the mode of a runtime override of a resource, see OverrideResource.
+type OverrideMode int
+
+const (
+	// OverrideOff exempts the resource from all the rules.
+	OverrideOff OverrideMode = iota + 1
+	// OverrideMonitorOnly doesn't enforce the rules of the resource, but keeps evaluating them
+	// against the traffic, the same as the rules in shadow.
+	OverrideMonitorOnly
+)
+
+func (m OverrideMode) String() string {
+	switch m {
+	case OverrideOff:
+		return "off"
+	case OverrideMonitorOnly:
+		return "monitorOnly"
+	default:
+		return "unknown"
+	}
+}
+
+// ResourceOverride is the status of a runtime override of a resource.
+type ResourceOverride struct {
+	Resource string       `json:"resource"`
+	Mode     OverrideMode `json:"-"`
+	ModeName string       `json:"mode"`
+	ExpireAt time.Time    `json:"expireAt"`
+	By       string       `json:"by"`
+	// Evaluations is the count of evaluations against the traffic (in OverrideMonitorOnly mode),
+	// once per second per rule.
+	Evaluations uint64 `json:"evaluations"`
+	// WouldBlockCount is the count of evaluations which would block the traffic if enforced.
+	WouldBlockCount uint64 `json:"wouldBlockCount"`
+	LastDetail      string `json:"lastDetail,omitempty"`
+}
+
+var (
+	overrideMux = &sync.Mutex{}
+	overrides   = make(map[string]*ResourceOverride)
+)
+
+// OverrideResource temporarily exempts the resource from the enforcement of its rules, without
+// editing the rules. The override expires after ttl automatically, or is cleared by ClearOverride.
+// A new override of the same resource replaces the previous one.
+func OverrideResource(resource string, mode OverrideMode, ttl time.Duration, by string) error {
+	if resource == "" {
+		return errors.New("empty resource")
+	}
+	if mode != OverrideOff && mode != OverrideMonitorOnly {
+		return errors.Errorf("unknown override mode: %d", mode)
+	}
+	if ttl <= 0 {
+		return errors.Errorf("non-positive override ttl: %s", ttl)
+	}
+	expireAt := time.Now().Add(ttl)
+	overrideMux.Lock()
+	overrides[resource] = &ResourceOverride{
+		Resource: resource,
+		Mode:     mode,
+		ModeName: mode.String(),
+		ExpireAt: expireAt,
+		By:       by,
+	}
+	overrideMux.Unlock()
+	logger.Warnf("The rules of resource <%s> are overridden to %s by %s until %s", resource, mode, by, expireAt.Format(time.RFC3339))
+	// The evaluator reloads the rules once the override expires.
+	startGuardEvaluator()
+	refreshGuardedRuleSets("overriding resource " + resource)
+	return nil
+}
+
+// ClearOverride clears the override of the resource before it expires, and returns false if
+// the resource is not overridden.
+func ClearOverride(resource string, by string) bool {
+	overrideMux.Lock()
+	_, ok := overrides[resource]
+	delete(overrides, resource)
+	overrideMux.Unlock()
+	if !ok {
+		return false
+	}
+	logger.Warnf("The override of resource <%s> is cleared by %s", resource, by)
+	refreshGuardedRuleSets("clearing the override of resource " + resource)
+	return true
+}
+
+// Overrides returns the overrides in effect, ordered by resource.
+func Overrides() []ResourceOverride {
+	now := time.Now()
+	overrideMux.Lock()
+	defer overrideMux.Unlock()
+	result := make([]ResourceOverride, 0, len(overrides))
+	for _, o := range overrides {
+		if now.Before(o.ExpireAt) {
+			result = append(result, *o)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Resource < result[j].Resource
+	})
+	return result
+}
+
+// activeOverrides returns the modes of the overrides in effect, and removes the expired ones.
+func activeOverrides(now time.Time) map[string]OverrideMode {
+	overrideMux.Lock()
+	defer overrideMux.Unlock()
+	if len(overrides) == 0 {
+		return nil
+	}
+	result := make(map[string]OverrideMode, len(overrides))
+	for resource, o := range overrides {
+		if !now.Before(o.ExpireAt) {
+			delete(overrides, resource)
+			logger.Infof("The override of resource <%s> has expired, the rules are enforced again", resource)
+			continue
+		}
+		result[resource] = o.Mode
+	}
+	return result
+}
+
+// recordOverride evaluates the rule of the resource in OverrideMonitorOnly mode against the
+// traffic and records the result.
+func recordOverride(kind string, r *guardedRule, check shadowChecker) {
+	wouldBlock, detail := false, ""
+	if check != nil {
+		wouldBlock, detail = check(r.rule, resourceVariables(r.resource))
+	}
+	overrideMux.Lock()
+	defer overrideMux.Unlock()
+	o, ok := overrides[r.resource]
+	if !ok {
+		return
+	}
+	o.Evaluations++
+	if wouldBlock {
+		if o.WouldBlockCount == 0 {
+			logger.Warnf("[Override] The %s rule would block the traffic of resource <%s> if enforced: %s", kind, r.resource, detail)
+		}
+		o.WouldBlockCount++
+		o.LastDetail = detail
+	}
+}
@@ -0,0 +1,51 @@
+package datasource
+
+import (
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/hotparam"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/paramkey"
+)
+
+// keyedParamFlowRule is a hot-spot parameter flow rule whose parameter is extracted by key.
+type keyedParamFlowRule struct {
+	*hotspot.Rule
+	ParamKey string `json:"paramKey"`
+}
+
+// loadParamFlowRules loads the hot-spot parameter flow rules. The parameter keys of
+// keyed rules are bound to the reversed indexes of each resource.
+func loadParamFlowRules(rules []interface{}) error {
+	arr := make([]*hotspot.Rule, 0, len(rules))
+	keyed := make([]*keyedParamFlowRule, 0)
+	keys := make(map[string][]string)
+	for _, r := range rules {
+		switch rule := r.(type) {
+		case *hotspot.Rule:
+			arr = append(arr, rule)
+		case *keyedParamFlowRule:
+			keyed = append(keyed, rule)
+			if indexOfString(keys[rule.Resource], rule.ParamKey) < 0 {
+				keys[rule.Resource] = append(keys[rule.Resource], rule.ParamKey)
+			}
+		}
+	}
+	for _, r := range keyed {
+		ks := keys[r.Resource]
+		rule := *r.Rule
+		rule.ParamIndex = paramkey.ReversedIndex(indexOfString(ks, r.ParamKey), len(ks))
+		arr = append(arr, &rule)
+	}
+	paramkey.SetKeys(keys)
+	_, err := hotspot.LoadRules(arr)
+	hotparam.SetRules(arr)
+	return err
+}
+
+func indexOfString(arr []string, s string) int {
+	for i, v := range arr {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,118 @@
+package datasource
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+)
+
+// pendingPayload is the latest payload of a subscription, which is waiting for the debounce window.
+type pendingPayload struct {
+	data     string
+	apply    func(data string)
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// rulePipeline applies the rule payloads in a background worker rather than in the
+// listener callbacks of ACM. Payloads of the same dataId arriving within the debounce
+// window are coalesced, and only the latest one is applied.
+type rulePipeline struct {
+	ctx    context.Context
+	window time.Duration
+
+	mux     sync.Mutex
+	pending map[string]*pendingPayload
+	wake    chan struct{}
+}
+
+func newRulePipeline(ctx context.Context, window time.Duration) *rulePipeline {
+	return &rulePipeline{
+		ctx:     ctx,
+		window:  window,
+		pending: make(map[string]*pendingPayload),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// debounced returns the listener callback which submits the payloads of the dataId to the pipeline.
+func (p *rulePipeline) debounced(dataId string, apply func(data string)) func(data string) {
+	return func(data string) {
+		p.submit(dataId, data, apply)
+	}
+}
+
+func (p *rulePipeline) submit(dataId, data string, apply func(data string)) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	deadline := time.Now().Add(p.window)
+	if pp, ok := p.pending[dataId]; ok {
+		logger.Infof("Superseded pending rule payload of dataId %s within the debounce window", dataId)
+		pp.data = data
+		pp.deadline = deadline
+		pp.timer.Reset(p.window)
+		return
+	}
+	p.pending[dataId] = &pendingPayload{
+		data:     data,
+		apply:    apply,
+		deadline: deadline,
+		timer:    time.AfterFunc(p.window, p.notify),
+	}
+}
+
+func (p *rulePipeline) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takeDue removes and returns the payloads whose debounce window has passed, in the order of arrival.
+func (p *rulePipeline) takeDue() []*pendingPayload {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	now := time.Now()
+	due := make([]*pendingPayload, 0)
+	for dataId, pp := range p.pending {
+		if !pp.deadline.After(now) {
+			due = append(due, pp)
+			delete(p.pending, dataId)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].deadline.Before(due[j].deadline)
+	})
+	return due
+}
+
+func (p *rulePipeline) run() {
+	defer tools.PrintPanicStackV2("rule application pipeline")
+	for {
+		select {
+		case <-p.wake:
+		case <-p.ctx.Done():
+			p.mux.Lock()
+			for _, pp := range p.pending {
+				pp.timer.Stop()
+			}
+			p.pending = make(map[string]*pendingPayload)
+			p.mux.Unlock()
+			return
+		}
+		for _, pp := range p.takeDue() {
+			p.safeApply(pp)
+		}
+	}
+}
+
+// safeApply applies the payload and recovers from its panic, so that a malformed payload
+// does not stop the worker and the later payloads of all dataIds.
+func (p *rulePipeline) safeApply(pp *pendingPayload) {
+	defer tools.PrintPanicStackV2("applying the rule payload")
+	pp.apply(pp.data)
+}
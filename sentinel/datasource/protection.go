@@ -0,0 +1,35 @@
+package datasource
+
+import (
+	"sync/atomic"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// protectionDisabled is 1 while all the rules are switched off, see SetProtectionEnabled.
+var protectionDisabled int32
+
+// SetProtectionEnabled switches all the rules on or off at once, e.g. in the emergencies where
+// a mis-set rule is blocking the legitimate traffic. While switched off, no rules are enforced,
+// but the statistics are still collected. The rules loaded into Sentinel directly (rather than
+// by the data-source) are not affected.
+func SetProtectionEnabled(enabled bool, by string) {
+	v := int32(1)
+	if enabled {
+		v = 0
+	}
+	if atomic.SwapInt32(&protectionDisabled, v) == v {
+		return
+	}
+	if enabled {
+		logger.Warnf("Sentinel protection enabled by %s", by)
+	} else {
+		logger.Warnf("Sentinel protection disabled by %s, no rules are enforced", by)
+	}
+	refreshGuardedRuleSets("switching the protection")
+}
+
+// ProtectionEnabled returns whether the rules are switched on, see SetProtectionEnabled.
+func ProtectionEnabled() bool {
+	return atomic.LoadInt32(&protectionDisabled) == 0
+}
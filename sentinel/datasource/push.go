@@ -0,0 +1,71 @@
+package datasource
+
+import (
+	"context"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+const (
+	// AcmDeliveryMode delivers the rules via ACM listening (the default).
+	AcmDeliveryMode = "acm"
+	// PushDeliveryMode delivers the rules via the server push over the AHAS transport
+	// connection, which propagates the rules in sub-second time without the Nacos client.
+	PushDeliveryMode = "push"
+)
+
+// pushChannel receives the rule payloads pushed over the AHAS transport.
+type pushChannel struct {
+	cancel context.CancelFunc
+	// app is the app of the payloads pushed without the app.
+	app string
+	// callbacks are keyed by checksumKey(app, kind).
+	callbacks map[string]func(data string)
+}
+
+// InitPush initializes the data-source in push mode (see PushDeliveryMode), which receives
+// the rules via PushRules rather than ACM. Any previously initialized data-source will be closed.
+func InitPush(ctx context.Context, conf Config) error {
+	sources, err := conf.sources()
+	if err != nil {
+		return err
+	}
+	initPush(ctx, processSubscription, conf, sources)
+	return nil
+}
+
+// InitPushForApps is the push mode version of InitAcmForApps.
+func InitPushForApps(ctx context.Context, apps []string, conf Config) error {
+	sources, err := appSources(apps)
+	if err != nil {
+		return err
+	}
+	initPush(ctx, processSubscription, conf, sources)
+	return nil
+}
+
+func initPush(ctx context.Context, s *Subscription, conf Config, sources []*ruleSource) {
+	applyConfig(conf)
+	ctx, cancel := context.WithCancel(ctx)
+	pipeline := newRulePipeline(ctx, conf.debounceWindow())
+	go pipeline.run()
+	composite := newCompositeSource(conf.SnapshotDir)
+	ch := &pushChannel{cancel: cancel, app: pushAppOf(sources), callbacks: make(map[string]func(data string))}
+	for _, src := range sources {
+		for _, h := range src.handlers() {
+			composite.loadLocal(src.app, h.kind, h.onChange)
+			key := checksumKey(src.app, h.kind)
+			ch.callbacks[key] = pipeline.debounced(key, composite.sourced(src.app, h.kind, AcmSourcePriority, h.onChange))
+		}
+	}
+	if err := s.install(nil, ch); err != nil {
+		logger.Warnf("Failed to close previous data source: %+v", err)
+	}
+	logger.Infof("Push data source initialized successfully, apps: %d", len(sources))
+}
+
+// PushRules delivers the rule payload pushed from the AHAS console to the process-wide
+// data-source. An empty app refers to the app of current process.
+func PushRules(app string, kind RuleKind, data string) error {
+	return processSubscription.PushRules(app, kind, data)
+}
@@ -0,0 +1,148 @@
+package datasource
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/pkg/errors"
+)
+
+type RuleKind string
+
+const (
+	FlowRuleKind            RuleKind = "flow"
+	SystemRuleKind          RuleKind = "system"
+	CircuitBreakingRuleKind RuleKind = "degrade"
+	ParamFlowRuleKind       RuleKind = "param-flow"
+	GatewayFlowRuleKind     RuleKind = "gateway-flow"
+	AuthorityRuleKind       RuleKind = "authority"
+
+	// RulePayloadVersionV1 is the default version of the rule payload schema.
+	RulePayloadVersionV1 = "v1"
+)
+
+// RuleDecoder decodes the "data" of the rule payload with a specific schema version (the
+// "schemaVersion" of the payload) into the legacy rule models of the kind, e.g.
+// []*LegacyFlowRule for FlowRuleKind.
+type RuleDecoder func(data json.RawMessage) (interface{}, error)
+
+var (
+	decoderMux   = &sync.RWMutex{}
+	ruleDecoders = map[RuleKind]map[string]RuleDecoder{
+		FlowRuleKind: {RulePayloadVersionV1: func(data json.RawMessage) (interface{}, error) {
+			rules := make([]*LegacyFlowRule, 0)
+			return rules, unmarshalRuleData(data, &rules)
+		}},
+		SystemRuleKind: {RulePayloadVersionV1: func(data json.RawMessage) (interface{}, error) {
+			rules := make([]*LegacySystemRule, 0)
+			return rules, unmarshalRuleData(data, &rules)
+		}},
+		CircuitBreakingRuleKind: {RulePayloadVersionV1: func(data json.RawMessage) (interface{}, error) {
+			rules := make([]*LegacyDegradeRule, 0)
+			return rules, unmarshalRuleData(data, &rules)
+		}},
+		ParamFlowRuleKind: {RulePayloadVersionV1: func(data json.RawMessage) (interface{}, error) {
+			rules := make([]*LegacyParamFlowRule, 0)
+			return rules, unmarshalRuleData(data, &rules)
+		}},
+		GatewayFlowRuleKind: {RulePayloadVersionV1: func(data json.RawMessage) (interface{}, error) {
+			rules := make([]*LegacyGatewayFlowRule, 0)
+			return rules, unmarshalRuleData(data, &rules)
+		}},
+		AuthorityRuleKind: {RulePayloadVersionV1: func(data json.RawMessage) (interface{}, error) {
+			rules := make([]*LegacyAuthorityRule, 0)
+			return rules, unmarshalRuleData(data, &rules)
+		}},
+	}
+)
+
+// isEmptyPayload reports whether the payload is empty, which is received when the config is deleted.
+func isEmptyPayload(payload string) bool {
+	return strings.TrimSpace(payload) == ""
+}
+
+func unmarshalRuleData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// RegisterRuleDecoder registers the decoder for the given rule kind and payload schema version.
+func RegisterRuleDecoder(kind RuleKind, version string, decoder RuleDecoder) {
+	if decoder == nil {
+		return
+	}
+	decoderMux.Lock()
+	defer decoderMux.Unlock()
+	if ruleDecoders[kind] == nil {
+		ruleDecoders[kind] = make(map[string]RuleDecoder)
+	}
+	ruleDecoders[kind][normalizeRulePayloadVersion(version)] = decoder
+}
+
+// normalizeRulePayloadVersion normalizes the version, e.g. "", "1" and "V1" are all "v1".
+func normalizeRulePayloadVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version == "" {
+		return RulePayloadVersionV1
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+// rulePayloadEnvelope is the envelope of the rule payloads.
+type rulePayloadEnvelope struct {
+	// SchemaVersion is the version of the schema of Data, which is v1 if absent.
+	SchemaVersion json.RawMessage `json:"schemaVersion"`
+	// Version is the legacy field of the revision of the payload assigned by the console,
+	// which is unrelated to the schema.
+	Version json.RawMessage `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decodeRulePayload decodes the envelope of the rules, and dispatches the data to the decoder
+// of the schema version. Unknown versions fall back to the v1 decoder, which ignores the
+// unknown fields.
+func decodeRulePayload(kind RuleKind, payload string) (interface{}, error) {
+	if isEmptyPayload(payload) {
+		// The config has been deleted, which means no rules.
+		payload = emptyRulePayload
+	}
+	envelope := &rulePayloadEnvelope{}
+	if err := json.Unmarshal([]byte(payload), envelope); err != nil {
+		return nil, err
+	}
+	version := RulePayloadVersionV1
+	if len(envelope.SchemaVersion) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(envelope.SchemaVersion, &v); err != nil {
+			return nil, errors.Wrap(err, "bad rule payload schema version")
+		}
+		switch vv := v.(type) {
+		case string:
+			version = normalizeRulePayloadVersion(vv)
+		case float64:
+			version = normalizeRulePayloadVersion(strings.TrimSuffix(string(envelope.SchemaVersion), ".0"))
+		}
+	}
+
+	decoderMux.RLock()
+	decoders := ruleDecoders[kind]
+	decoder, ok := decoders[version]
+	if !ok {
+		decoder, ok = decoders[RulePayloadVersionV1]
+		if ok {
+			logger.Warnf("Unknown %s rule payload schema version <%s>, falling back to %s decoder", kind, version, RulePayloadVersionV1)
+		}
+	}
+	decoderMux.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("no decoder for %s rule payload schema version: %s", kind, version)
+	}
+	return decoder(envelope.Data)
+}
@@ -2,11 +2,15 @@ package datasource
 
 import (
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
 	"github.com/alibaba/sentinel-golang/core/flow"
 	"github.com/alibaba/sentinel-golang/core/hotspot"
 	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/apigateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
 )
 
 type LegacyFlowRule struct {
@@ -29,6 +33,9 @@ type LegacyFlowRule struct {
 
 	// ClusterMode indicates whether the rule is for cluster flow control or local.
 	ClusterMode bool `json:"clusterMode"`
+	// Expression is the optional condition (e.g. "qps > 100 && errorRatio > 0.2"),
+	// and the rule only takes effect while the condition holds.
+	Expression string `json:"expression,omitempty"`
 }
 
 func (lr *LegacyFlowRule) ToGoRule() *flow.FlowRule {
@@ -99,6 +106,9 @@ type LegacyDegradeRule struct {
 	MinRequestAmount   uint64  `json:"minRequestAmount"`
 	SlowRatioThreshold float64 `json:"slowRatioThreshold"`
 	StatIntervalMs     uint32  `json:"statIntervalMs"`
+	// Expression is the optional condition (e.g. "qps > 100 && errorRatio > 0.2"),
+	// and the rule only takes effect while the condition holds.
+	Expression string `json:"expression,omitempty"`
 }
 
 func (lr *LegacyDegradeRule) ToGoRule() *circuitbreaker.Rule {
@@ -135,13 +145,31 @@ type LegacyParamFlowItem struct {
 	ParamType string  `json:"classType"`
 }
 
+// paramsMaxCapacity is the configured default of ParamsMaxCapacity in hot-spot parameter flow rules.
+var paramsMaxCapacity = DefaultParamsMaxCapacity
+
+func setParamsMaxCapacity(c int64) {
+	atomic.StoreInt64(&paramsMaxCapacity, c)
+}
+
+// resolveParamsMaxCapacity returns the capacity specified in the rule, or the configured default.
+func resolveParamsMaxCapacity(c int64) int64 {
+	if c > 0 {
+		return c
+	}
+	return atomic.LoadInt64(&paramsMaxCapacity)
+}
+
 type LegacyParamFlowRule struct {
 	Id         uint64             `json:"id,omitempty"`
 	Resource   string             `json:"resource"`
 	MetricType hotspot.MetricType `json:"grade"`
 	Threshold  float64            `json:"count"`
 	// ParamIndex is the index in context arguments slice.
-	ParamIndex        int32                  `json:"paramIdx"`
+	ParamIndex int32 `json:"paramIdx"`
+	// ParamKey is the key of the parameter in the entry attachments (see package paramkey),
+	// which takes precedence over ParamIndex if not empty.
+	ParamKey          string                 `json:"paramKey,omitempty"`
 	DurationInSec     int64                  `json:"durationInSec"`
 	ControlBehavior   uint32                 `json:"controlBehavior"`
 	MaxQueueingTimeMs int64                  `json:"maxQueueingTimeMs"`
@@ -149,6 +177,8 @@ type LegacyParamFlowRule struct {
 	SpecificItems     []*LegacyParamFlowItem `json:"paramFlowItemList,omitempty"`
 	// ClusterMode indicates whether the rule is for cluster flow control or local.
 	ClusterMode bool `json:"clusterMode"`
+	// ParamsMaxCapacity overrides the max count of the parameter values to keep statistics for (optional).
+	ParamsMaxCapacity int64 `json:"paramsMaxCapacity,omitempty"`
 }
 
 func (lr *LegacyParamFlowRule) ToGoRule() *hotspot.Rule {
@@ -186,7 +216,83 @@ func (lr *LegacyParamFlowRule) ToGoRule() *hotspot.Rule {
 		MaxQueueingTimeMs: lr.MaxQueueingTimeMs,
 		BurstCount:        lr.BurstCount,
 		DurationInSec:     lr.DurationInSec,
-		ParamsMaxCapacity: 500,
+		ParamsMaxCapacity: resolveParamsMaxCapacity(lr.ParamsMaxCapacity),
 		SpecificItems:     items,
 	}
 }
+
+type LegacyGatewayParamFlowItem struct {
+	ParseStrategy int32  `json:"parseStrategy"`
+	FieldName     string `json:"fieldName,omitempty"`
+	Pattern       string `json:"pattern,omitempty"`
+	MatchStrategy int32  `json:"matchStrategy"`
+}
+
+type LegacyGatewayFlowRule struct {
+	Id uint64 `json:"id,omitempty"`
+	// Resource is the route ID or the custom API name.
+	Resource             string             `json:"resource"`
+	ResourceMode         int32              `json:"resourceMode"`
+	MetricType           hotspot.MetricType `json:"grade"`
+	Count                float64            `json:"count"`
+	IntervalSec          int64              `json:"intervalSec"`
+	ControlBehavior      uint32             `json:"controlBehavior"`
+	Burst                int64              `json:"burst"`
+	MaxQueueingTimeoutMs int64              `json:"maxQueueingTimeoutMs"`
+	// ParamsMaxCapacity overrides the max count of the parameter values to keep statistics for (optional).
+	ParamsMaxCapacity int64 `json:"paramsMaxCapacity,omitempty"`
+	// ParamItem is optional. Absent param item means flow control on the whole route or API.
+	ParamItem *LegacyGatewayParamFlowItem `json:"paramItem,omitempty"`
+}
+
+func (lr *LegacyGatewayFlowRule) ToGoRule() *apigateway.Rule {
+	cb := hotspot.Reject
+	if lr.ControlBehavior == 2 {
+		cb = hotspot.Throttling
+	}
+	rule := &apigateway.Rule{
+		Id:                strconv.FormatUint(lr.Id, 10),
+		Resource:          lr.Resource,
+		ResourceMode:      apigateway.ResourceMode(lr.ResourceMode),
+		MetricType:        lr.MetricType,
+		Count:             lr.Count,
+		IntervalSec:       lr.IntervalSec,
+		ControlBehavior:   cb,
+		Burst:             lr.Burst,
+		MaxQueueingTimeMs: lr.MaxQueueingTimeoutMs,
+		ParamsMaxCapacity: resolveParamsMaxCapacity(lr.ParamsMaxCapacity),
+	}
+	if lr.ParamItem != nil {
+		rule.ParamItem = &apigateway.ParamItem{
+			ParseStrategy: apigateway.ParseStrategy(lr.ParamItem.ParseStrategy),
+			FieldName:     lr.ParamItem.FieldName,
+			Pattern:       lr.ParamItem.Pattern,
+			MatchStrategy: apigateway.MatchStrategy(lr.ParamItem.MatchStrategy),
+		}
+	}
+	return rule
+}
+
+type LegacyAuthorityRule struct {
+	Id       uint64 `json:"id,omitempty"`
+	Resource string `json:"resource"`
+	// LimitApp is the comma-separated origins.
+	LimitApp string `json:"limitApp"`
+	// Strategy is 0 for white list and 1 for black list.
+	Strategy int32 `json:"strategy"`
+}
+
+func (lr *LegacyAuthorityRule) ToGoRule() *authority.Rule {
+	apps := make([]string, 0)
+	for _, app := range strings.Split(lr.LimitApp, ",") {
+		if app = strings.TrimSpace(app); app != "" {
+			apps = append(apps, app)
+		}
+	}
+	return &authority.Rule{
+		ID:        strconv.FormatUint(lr.Id, 10),
+		Resource:  lr.Resource,
+		Strategy:  authority.Strategy(lr.Strategy),
+		LimitApps: apps,
+	}
+}
@@ -0,0 +1,210 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// shadowChecker checks whether the rule would block current traffic,
+// and returns the description of the statistics.
+type shadowChecker func(rule interface{}, vars func(name string) (float64, bool)) (bool, string)
+
+// ShadowRuleStatus is the statistics of a rule in shadow mode.
+type ShadowRuleStatus struct {
+	Kind        string    `json:"kind"`
+	Rule        string    `json:"rule"`
+	Resource    string    `json:"resource"`
+	ShadowUntil time.Time `json:"shadowUntil"`
+	// Evaluations is the count of evaluations against the traffic, once per second.
+	Evaluations uint64 `json:"evaluations"`
+	// WouldBlockCount is the count of evaluations which would block the traffic if enforced.
+	WouldBlockCount uint64 `json:"wouldBlockCount"`
+	LastDetail      string `json:"lastDetail,omitempty"`
+}
+
+var (
+	shadowMux    = &sync.Mutex{}
+	shadowPeriod time.Duration
+	shadowStats  = make(map[string]*ShadowRuleStatus)
+)
+
+// setShadowPeriod sets the grace period of newly received rules. Zero disables the shadow mode.
+func setShadowPeriod(d time.Duration) {
+	shadowMux.Lock()
+	defer shadowMux.Unlock()
+	shadowPeriod = d
+}
+
+// ShadowRules returns the statistics of the rules which are in shadow mode. The statistics
+// of a rule are dropped once it is promoted or removed.
+func ShadowRules() []ShadowRuleStatus {
+	shadowMux.Lock()
+	defer shadowMux.Unlock()
+	result := make([]ShadowRuleStatus, 0, len(shadowStats))
+	for _, st := range shadowStats {
+		result = append(result, *st)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ShadowUntil.Before(result[j].ShadowUntil)
+	})
+	return result
+}
+
+// ruleKey identifies the content of the rule.
+func ruleKey(rule interface{}) string {
+	bs, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Sprintf("%+v", rule)
+	}
+	return string(bs)
+}
+
+// ruleIdentity identifies the rule across deliveries, by the resource and the id of the rule,
+// so that an edited rule is regarded as a new version of the same rule. Rules without id are
+// identified by their content.
+func ruleIdentity(r *guardedRule) string {
+	v := reflect.ValueOf(r.rule)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return r.resource + "|" + r.key
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		for _, name := range []string{"ID", "Id"} {
+			f := v.FieldByName(name)
+			if f.IsValid() && !f.IsZero() {
+				return r.resource + "#" + fmt.Sprint(f.Interface())
+			}
+		}
+	}
+	return r.resource + "|" + r.key
+}
+
+// assignShadow sets the shadow deadline for the newly received rules. Rules which
+// already exist keep their state. The first delivery of a source is regarded as
+// the baseline, which is enforced immediately. An edited rule keeps its previous
+// version enforcing until the new one is promoted.
+func assignShadow(kind string, previous, rules []guardedRule, loaded bool) {
+	shadowMux.Lock()
+	defer shadowMux.Unlock()
+	prev := make(map[string]*guardedRule, len(previous))
+	for i := range previous {
+		prev[previous[i].id] = &previous[i]
+	}
+	kept := make(map[string]bool, len(rules))
+	deadline := time.Now().Add(shadowPeriod)
+	for i := range rules {
+		r := &rules[i]
+		r.key = ruleKey(r.rule)
+		r.id = ruleIdentity(r)
+		kept[r.key] = true
+		p, ok := prev[r.id]
+		if ok && p.key == r.key {
+			r.shadowUntil, r.superseded = p.shadowUntil, p.superseded
+			continue
+		}
+		if !loaded || shadowPeriod <= 0 {
+			continue
+		}
+		if ok {
+			if p.superseded != nil {
+				// Edited again in shadow: the last enforced version keeps enforcing.
+				r.superseded = p.superseded
+			} else if !p.inShadow(time.Now()) {
+				r.superseded = &guardedRule{rule: p.rule, resource: p.resource, condition: p.condition, key: p.key, id: p.id}
+			}
+		}
+		r.shadowUntil = deadline
+		shadowStats[kind+"|"+r.key] = &ShadowRuleStatus{
+			Kind:        kind,
+			Rule:        r.key,
+			Resource:    r.resource,
+			ShadowUntil: deadline,
+		}
+		logger.Infof("New %s rule received in shadow mode until %s: %s", kind, deadline.Format(time.RFC3339), r.key)
+	}
+	for _, p := range previous {
+		if !kept[p.key] {
+			delete(shadowStats, kind+"|"+p.key)
+		}
+	}
+}
+
+// promoteShadow drops the statistics of the rule whose shadow period is over, and
+// returns them if the rule has just been promoted.
+func promoteShadow(kind string, r *guardedRule) (ShadowRuleStatus, bool) {
+	shadowMux.Lock()
+	defer shadowMux.Unlock()
+	st, ok := shadowStats[kind+"|"+r.key]
+	if !ok {
+		return ShadowRuleStatus{}, false
+	}
+	delete(shadowStats, kind+"|"+r.key)
+	return *st, true
+}
+
+// recordShadow evaluates the rule in shadow against the traffic and records the result.
+func recordShadow(kind string, r *guardedRule, check shadowChecker) {
+	wouldBlock, detail := false, ""
+	if check != nil {
+		wouldBlock, detail = check(r.rule, resourceVariables(r.resource))
+	}
+	shadowMux.Lock()
+	defer shadowMux.Unlock()
+	st, ok := shadowStats[kind+"|"+r.key]
+	if !ok {
+		return
+	}
+	st.Evaluations++
+	if wouldBlock {
+		if st.WouldBlockCount == 0 {
+			logger.Warnf("[Shadow] The %s rule would block the traffic of resource <%s> if enforced: %s", kind, r.resource, detail)
+		}
+		st.WouldBlockCount++
+		st.LastDetail = detail
+	}
+}
+
+func flowRuleWouldBlock(rule interface{}, vars func(name string) (float64, bool)) (bool, string) {
+	r, ok := rule.(*flow.FlowRule)
+	if !ok {
+		return false, ""
+	}
+	name := ExprVarPassQps
+	if r.MetricType == flow.Concurrency {
+		name = ExprVarConcurrency
+	}
+	v, _ := vars(name)
+	return v > r.Count, fmt.Sprintf("%s=%.2f, threshold=%.2f", name, v, r.Count)
+}
+
+func circuitBreakingRuleWouldBlock(rule interface{}, vars func(name string) (float64, bool)) (bool, string) {
+	r, ok := rule.(*circuitbreaker.Rule)
+	if !ok {
+		return false, ""
+	}
+	var name string
+	threshold := r.Threshold
+	switch r.Strategy {
+	case circuitbreaker.SlowRequestRatio:
+		// The slow ratio is not available from the statistics, so compare the average RT instead.
+		name, threshold = ExprVarRt, float64(r.MaxAllowedRtMs)
+	case circuitbreaker.ErrorRatio:
+		name = ExprVarErrorRatio
+	case circuitbreaker.ErrorCount:
+		name = ExprVarErrorQps
+	default:
+		return false, ""
+	}
+	v, _ := vars(name)
+	return v > threshold, fmt.Sprintf("%s=%.2f, threshold=%.2f", name, v, threshold)
+}
@@ -0,0 +1,115 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"github.com/pkg/errors"
+)
+
+// Subscription is a data-source of the rules, either from ACM or pushed over the AHAS transport.
+// Besides the process-wide one initialized by InitAcm and InitPush, each ahas.Client keeps its own.
+// The rules of all subscriptions are loaded into the same Sentinel keyed by the app, so they should
+// be of disjoint apps (see Config.Apps), and the settings shared by all rule kinds (e.g. the
+// ShadowPeriodMs) are process-wide.
+type Subscription struct {
+	mux  sync.Mutex
+	acm  *acmDataSource
+	push *pushChannel
+}
+
+// processSubscription is the process-wide data-source of InitAcm and InitPush.
+var processSubscription = &Subscription{}
+
+// SubscribeAcm subscribes to the rules of the config from ACM like InitAcmWithContext, but in
+// a subscription of its own, which leaves the process-wide data-source untouched.
+func SubscribeAcm(ctx context.Context, acmHost string, conf Config, m *meta.Meta) (*Subscription, error) {
+	sources, err := conf.sources()
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscription{}
+	if err = initAcm(ctx, s, acmHost, conf, m, sources); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// SubscribePush receives the rules of the config in push mode like InitPush, but in a subscription
+// of its own, whose PushRules delivers the pushed rules.
+func SubscribePush(ctx context.Context, conf Config) (*Subscription, error) {
+	sources, err := conf.sources()
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscription{}
+	initPush(ctx, s, conf, sources)
+	return s, nil
+}
+
+// PushRules delivers the rule payload pushed from the AHAS console. An empty app
+// refers to the app of the subscription (see Config.App).
+func (s *Subscription) PushRules(app string, kind RuleKind, data string) error {
+	s.mux.Lock()
+	ch := s.push
+	s.mux.Unlock()
+	if ch == nil {
+		return errors.New("push data source is not initialized")
+	}
+	if app == "" {
+		app = ch.app
+	}
+	callback, ok := ch.callbacks[checksumKey(app, kind)]
+	if !ok {
+		return errors.Errorf("unknown app or rule kind: %s, %s", app, kind)
+	}
+	callback(data)
+	return nil
+}
+
+// Status returns the status of the ACM subscriptions of the rule dataIds, or nil if the ACM
+// data-source is not initialized (yet).
+func (s *Subscription) Status() []SubscriptionStatus {
+	s.mux.Lock()
+	ds := s.acm
+	s.mux.Unlock()
+	if ds == nil {
+		return nil
+	}
+	return ds.status()
+}
+
+// Close cancels all ACM listeners and releases the config client, or stops receiving the
+// pushed rules in push mode. It's safe to call Close multiple times.
+func (s *Subscription) Close() error {
+	return s.install(nil, nil)
+}
+
+// install replaces the data-sources of the subscription, and closes the previous ones.
+func (s *Subscription) install(ds *acmDataSource, ch *pushChannel) error {
+	s.mux.Lock()
+	prevAcm, prevPush := s.acm, s.push
+	s.acm, s.push = ds, ch
+	s.mux.Unlock()
+	if prevPush != nil {
+		prevPush.cancel()
+		logger.Info("Push data source closed")
+	}
+	if prevAcm == nil {
+		return nil
+	}
+	return prevAcm.close()
+}
+
+// pushAppOf returns the app of the pushed rules without the app, which is the app of the
+// single source, or the Sentinel app if the config hosts several apps.
+func pushAppOf(sources []*ruleSource) string {
+	if len(sources) == 1 && sources[0].resourcePrefix == "" {
+		return sources[0].app
+	}
+	return sentinelConf.AppName()
+}
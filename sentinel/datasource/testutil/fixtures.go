@@ -0,0 +1,90 @@
+package testutil
+
+import (
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/apigateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+)
+
+// The sample payloads and the rules they convert to (golden fixtures).
+
+const SampleFlowRulePayload = `{"version":"v1","data":[
+{"id":1,"resource":"GET:/users/:id","limitApp":"default","grade":1,"count":100,"strategy":0,"controlBehavior":0,"warmUpPeriodSec":10,"maxQueueingTimeMs":500,"clusterMode":false},
+{"id":2,"resource":"GET:/orders","limitApp":"default","grade":0,"count":20,"strategy":0,"controlBehavior":0,"warmUpPeriodSec":10,"maxQueueingTimeMs":500,"clusterMode":false}
+]}`
+
+func ExpectedFlowRules() []*flow.FlowRule {
+	return []*flow.FlowRule{
+		{ID: 1, Resource: "GET:/users/:id", LimitOrigin: "default", MetricType: flow.QPS, Count: 100,
+			RelationStrategy: flow.Direct, ControlBehavior: flow.Reject, WarmUpPeriodSec: 10, MaxQueueingTimeMs: 500},
+		{ID: 2, Resource: "GET:/orders", LimitOrigin: "default", MetricType: flow.Concurrency, Count: 20,
+			RelationStrategy: flow.Direct, ControlBehavior: flow.Reject, WarmUpPeriodSec: 10, MaxQueueingTimeMs: 500},
+	}
+}
+
+const SampleSystemRulePayload = `{"version":"v1","data":[
+{"id":3,"resource":"","highestSystemLoad":-1,"highestCpuUsage":0.8,"qps":-1,"avgRt":-1,"maxThread":-1}
+]}`
+
+func ExpectedSystemRules() []*system.SystemRule {
+	return []*system.SystemRule{
+		{ID: 3, MetricType: system.CpuUsage, TriggerCount: 0.8, Strategy: system.BBR},
+	}
+}
+
+const SampleDegradeRulePayload = `{"version":"v1","data":[
+{"id":4,"resource":"SELECT * FROM user WHERE id=?","count":200,"grade":0,"timeWindow":10,"minRequestAmount":5,"slowRatioThreshold":0.5,"statIntervalMs":1000},
+{"id":5,"resource":"GET:/orders","count":0.3,"grade":1,"timeWindow":5,"minRequestAmount":10,"statIntervalMs":1000}
+]}`
+
+func ExpectedCircuitBreakingRules() []*circuitbreaker.Rule {
+	return []*circuitbreaker.Rule{
+		{Id: "4", Resource: "SELECT * FROM user WHERE id=?", Strategy: circuitbreaker.SlowRequestRatio, RetryTimeoutMs: 10000,
+			MinRequestAmount: 5, StatIntervalMs: 1000, MaxAllowedRtMs: 200, Threshold: 0.5},
+		{Id: "5", Resource: "GET:/orders", Strategy: circuitbreaker.ErrorRatio, RetryTimeoutMs: 5000,
+			MinRequestAmount: 10, StatIntervalMs: 1000, Threshold: 0.3},
+	}
+}
+
+const SampleParamFlowRulePayload = `{"version":"v1","data":[
+{"id":6,"resource":"GET:/users/:id","grade":1,"count":10,"paramIdx":0,"durationInSec":1,"controlBehavior":0,"maxQueueingTimeMs":0,"burstCount":0,"clusterMode":false,
+"paramFlowItemList":[{"object":"42","count":100,"classType":"int"}]}
+]}`
+
+// ExpectedParamFlowRules returns the converted hot-spot parameter flow rules, with the
+// default ParamsMaxCapacity (which could be changed by the data-source config).
+func ExpectedParamFlowRules() []*hotspot.Rule {
+	return []*hotspot.Rule{
+		{ID: "6", Resource: "GET:/users/:id", MetricType: hotspot.QPS, ControlBehavior: hotspot.Reject, ParamIndex: 0,
+			Threshold: 10, DurationInSec: 1, ParamsMaxCapacity: datasource.DefaultParamsMaxCapacity,
+			SpecificItems: []hotspot.SpecificValue{{ValKind: hotspot.KindInt, ValStr: "42", Threshold: 100}}},
+	}
+}
+
+const SampleGatewayFlowRulePayload = `{"version":"v1","data":[
+{"id":7,"resource":"user-route","resourceMode":0,"grade":1,"count":50,"intervalSec":1,"controlBehavior":0,"burst":0,"maxQueueingTimeoutMs":0,
+"paramItem":{"parseStrategy":0,"matchStrategy":0}}
+]}`
+
+// ExpectedGatewayFlowRules returns the converted gateway flow rules, with the default ParamsMaxCapacity.
+func ExpectedGatewayFlowRules() []*apigateway.Rule {
+	return []*apigateway.Rule{
+		{Id: "7", Resource: "user-route", ResourceMode: apigateway.ResourceMode(0), MetricType: hotspot.QPS, Count: 50,
+			IntervalSec: 1, ControlBehavior: hotspot.Reject, ParamsMaxCapacity: datasource.DefaultParamsMaxCapacity,
+			ParamItem: &apigateway.ParamItem{ParseStrategy: apigateway.ParseStrategy(0), MatchStrategy: apigateway.MatchStrategy(0)}},
+	}
+}
+
+const SampleAuthorityRulePayload = `{"version":"v1","data":[
+{"id":8,"resource":"GET:/admin","limitApp":"ops-portal, billing","strategy":0}
+]}`
+
+func ExpectedAuthorityRules() []*authority.Rule {
+	return []*authority.Rule{
+		{ID: "8", Resource: "GET:/admin", Strategy: authority.WhiteList, LimitApps: []string{"ops-portal", "billing"}},
+	}
+}
@@ -0,0 +1,63 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+)
+
+func TestConvertFixtures(t *testing.T) {
+	tests := []struct {
+		kind    datasource.RuleKind
+		convert func(t testing.TB, payload string) interface{}
+		want    interface{}
+	}{
+		{datasource.FlowRuleKind, func(t testing.TB, payload string) interface{} {
+			return MustConvertFlowRules(t, payload)
+		}, ExpectedFlowRules()},
+		{datasource.SystemRuleKind, func(t testing.TB, payload string) interface{} {
+			return MustConvertSystemRules(t, payload)
+		}, ExpectedSystemRules()},
+		{datasource.CircuitBreakingRuleKind, func(t testing.TB, payload string) interface{} {
+			return MustConvertCircuitBreakingRules(t, payload)
+		}, ExpectedCircuitBreakingRules()},
+		{datasource.ParamFlowRuleKind, func(t testing.TB, payload string) interface{} {
+			return MustConvertParamFlowRules(t, payload)
+		}, ExpectedParamFlowRules()},
+		{datasource.GatewayFlowRuleKind, func(t testing.TB, payload string) interface{} {
+			return MustConvertGatewayFlowRules(t, payload)
+		}, ExpectedGatewayFlowRules()},
+		{datasource.AuthorityRuleKind, func(t testing.TB, payload string) interface{} {
+			return MustConvertAuthorityRules(t, payload)
+		}, ExpectedAuthorityRules()},
+	}
+	payloads := SamplePayloads()
+	if len(payloads) != len(tests) {
+		t.Fatalf("%d sample payloads, but %d converters tested", len(payloads), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			payload, ok := payloads[tt.kind]
+			if !ok {
+				t.Fatalf("no sample payload of %s rules", tt.kind)
+			}
+			AssertRulesEqual(t, tt.convert(t, payload), tt.want)
+		})
+	}
+}
+
+func TestConvertEmptyPayload(t *testing.T) {
+	rules, err := datasource.ConvertFlowRules("")
+	if err != nil {
+		t.Fatalf("Failed to convert the empty payload: %+v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("Converted %d rules from the empty payload", len(rules))
+	}
+}
+
+func TestConvertMalformedPayload(t *testing.T) {
+	if _, err := datasource.ConvertFlowRules("{"); err == nil {
+		t.Error("Converted the malformed payload without error")
+	}
+}
@@ -0,0 +1,103 @@
+// Package testutil provides the sample rule payloads in the exact format produced by the
+// AHAS console, the rules they convert to, and the helpers to assert the conversion,
+// so that applications could write regression tests against their own console payloads:
+//
+//	func TestFlowRules(t *testing.T) {
+//		rules := testutil.MustConvertFlowRules(t, payloadFromConsole)
+//		testutil.AssertRulesEqual(t, rules, []*flow.FlowRule{...})
+//	}
+package testutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+	"github.com/alibaba/sentinel-golang/core/system"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/apigateway"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+)
+
+// SamplePayloads returns the sample payloads of all rule kinds.
+func SamplePayloads() map[datasource.RuleKind]string {
+	return map[datasource.RuleKind]string{
+		datasource.FlowRuleKind:            SampleFlowRulePayload,
+		datasource.SystemRuleKind:          SampleSystemRulePayload,
+		datasource.CircuitBreakingRuleKind: SampleDegradeRulePayload,
+		datasource.ParamFlowRuleKind:       SampleParamFlowRulePayload,
+		datasource.GatewayFlowRuleKind:     SampleGatewayFlowRulePayload,
+		datasource.AuthorityRuleKind:       SampleAuthorityRulePayload,
+	}
+}
+
+// MustConvertFlowRules converts the flow rule payload, and fails the test on error.
+func MustConvertFlowRules(t testing.TB, payload string) []*flow.FlowRule {
+	t.Helper()
+	rules, err := datasource.ConvertFlowRules(payload)
+	mustConvert(t, "flow rules", err)
+	return rules
+}
+
+// MustConvertSystemRules converts the system rule payload, and fails the test on error.
+func MustConvertSystemRules(t testing.TB, payload string) []*system.SystemRule {
+	t.Helper()
+	rules, err := datasource.ConvertSystemRules(payload)
+	mustConvert(t, "system rules", err)
+	return rules
+}
+
+// MustConvertCircuitBreakingRules converts the degrade rule payload, and fails the test on error.
+func MustConvertCircuitBreakingRules(t testing.TB, payload string) []*circuitbreaker.Rule {
+	t.Helper()
+	rules, err := datasource.ConvertCircuitBreakingRules(payload)
+	mustConvert(t, "circuit breaking rules", err)
+	return rules
+}
+
+// MustConvertParamFlowRules converts the hot-spot parameter flow rule payload, and fails the test on error.
+func MustConvertParamFlowRules(t testing.TB, payload string) []*hotspot.Rule {
+	t.Helper()
+	rules, err := datasource.ConvertParamFlowRules(payload)
+	mustConvert(t, "hot-spot parameter flow rules", err)
+	return rules
+}
+
+// MustConvertGatewayFlowRules converts the gateway flow rule payload, and fails the test on error.
+func MustConvertGatewayFlowRules(t testing.TB, payload string) []*apigateway.Rule {
+	t.Helper()
+	rules, err := datasource.ConvertGatewayFlowRules(payload)
+	mustConvert(t, "gateway flow rules", err)
+	return rules
+}
+
+// MustConvertAuthorityRules converts the authority rule payload, and fails the test on error.
+func MustConvertAuthorityRules(t testing.TB, payload string) []*authority.Rule {
+	t.Helper()
+	rules, err := datasource.ConvertAuthorityRules(payload)
+	mustConvert(t, "authority rules", err)
+	return rules
+}
+
+// mustConvert fails the test on the error of converting the rules.
+func mustConvert(t testing.TB, rules string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Failed to convert %s: %+v", rules, err)
+	}
+}
+
+// AssertRulesEqual asserts the converted rules are deeply equal to the expected ones,
+// and reports both in JSON on mismatch.
+func AssertRulesEqual(t testing.TB, got, want interface{}) {
+	t.Helper()
+	if reflect.DeepEqual(got, want) {
+		return
+	}
+	gotJson, _ := json.MarshalIndent(got, "", "  ")
+	wantJson, _ := json.MarshalIndent(want, "", "  ")
+	t.Errorf("Converted rules mismatch\ngot:  %s\nwant: %s", gotJson, wantJson)
+}
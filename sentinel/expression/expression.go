@@ -0,0 +1,335 @@
+// Package expression provides a tiny expression language for composite rule conditions,
+// e.g. "qps > 100 && errorRatio > 0.2".
+//
+// The grammar is:
+//
+//	expr       := and ('||' and)*
+//	and        := unary ('&&' unary)*
+//	unary      := '!' unary | '(' expr ')' | comparison
+//	comparison := operand ('>' | '>=' | '<' | '<=' | '==' | '!=') operand
+//	operand    := identifier | number
+package expression
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Variables resolves the value of the named variable. The second return value
+// reports whether the variable exists.
+type Variables func(name string) (float64, bool)
+
+type Expression struct {
+	src    string
+	root   node
+	idents []string
+}
+
+// Compile parses the expression source.
+func Compile(src string) (*Expression, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, errors.Errorf("unexpected token %q at position %d", p.peek().text, p.peek().pos)
+	}
+	idents := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, t := range tokens {
+		if t.kind == tokenIdent && !seen[t.text] {
+			seen[t.text] = true
+			idents = append(idents, t.text)
+		}
+	}
+	return &Expression{src: src, root: root, idents: idents}, nil
+}
+
+// Identifiers returns the distinct variable names referenced by the expression.
+func (e *Expression) Identifiers() []string {
+	return e.idents
+}
+
+// Evaluate evaluates the expression with the given variables.
+func (e *Expression) Evaluate(vars Variables) (bool, error) {
+	return e.root.eval(vars)
+}
+
+func (e *Expression) String() string {
+	return e.src
+}
+
+type node interface {
+	eval(vars Variables) (bool, error)
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(vars Variables) (bool, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	// Short-circuit evaluation.
+	if n.op == "&&" && !l {
+		return false, nil
+	}
+	if n.op == "||" && l {
+		return true, nil
+	}
+	return n.right.eval(vars)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(vars Variables) (bool, error) {
+	v, err := n.operand.eval(vars)
+	return !v, err
+}
+
+type operand struct {
+	ident string
+	value float64
+}
+
+func (o operand) resolve(vars Variables) (float64, error) {
+	if o.ident == "" {
+		return o.value, nil
+	}
+	v, ok := vars(o.ident)
+	if !ok {
+		return 0, errors.Errorf("unknown variable: %s", o.ident)
+	}
+	return v, nil
+}
+
+type comparisonNode struct {
+	op          string
+	left, right operand
+}
+
+func (n *comparisonNode) eval(vars Variables) (bool, error) {
+	l, err := n.left.resolve(vars)
+	if err != nil {
+		return false, err
+	}
+	r, err := n.right.resolve(vars)
+	if err != nil {
+		return false, err
+	}
+	switch n.op {
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	default:
+		return false, errors.Errorf("unsupported operator: %s", n.op)
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenOperator && t.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	if t.kind == tokenLeftParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRightParen {
+			return nil, errors.Errorf("missing ')' for '(' at position %d", t.pos)
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.next()
+	if t.kind != tokenOperator || !isComparisonOperator(t.text) {
+		return nil, errors.Errorf("expected comparison operator at position %d, got %q", t.pos, t.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &comparisonNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenIdent:
+		return operand{ident: t.text}, nil
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, errors.Errorf("bad number %q at position %d", t.text, t.pos)
+		}
+		return operand{value: v}, nil
+	default:
+		return operand{}, errors.Errorf("expected identifier or number at position %d, got %q", t.pos, t.text)
+	}
+}
+
+func isComparisonOperator(op string) bool {
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	default:
+		return false
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: i})
+			i++
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+		case isDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], pos: start})
+		default:
+			op := ""
+			if i+1 < len(src) {
+				switch src[i : i+2] {
+				case ">=", "<=", "==", "!=", "&&", "||":
+					op = src[i : i+2]
+				}
+			}
+			if op == "" {
+				switch c {
+				case '>', '<', '!':
+					op = string(c)
+				default:
+					return nil, errors.Errorf("unexpected character %q at position %d", c, i)
+				}
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, text: "EOF", pos: len(src)})
+	return tokens, nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/chaos"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	StartChaosExperimentCommandName = "startChaosExperiment"
+	StopChaosExperimentCommandName  = "stopChaosExperiment"
+	GetChaosStatusCommandName       = "getChaosStatus"
+)
+
+// StartChaosExperimentHandler starts the chaos experiment of the "experiment" parameter in JSON.
+type StartChaosExperimentHandler struct {
+}
+
+func (h *StartChaosExperimentHandler) Handle(request *transport.Request) *transport.Response {
+	data := request.Params["experiment"]
+	if data == "" {
+		return transport.ReturnFail(transport.Code[transport.ParameterEmpty], "empty experiment")
+	}
+	var exp chaos.Experiment
+	if err := json.Unmarshal([]byte(data), &exp); err != nil {
+		return transport.ReturnFail(transport.Code[transport.ParameterTypeError], "bad experiment: "+err.Error())
+	}
+	if err := chaos.Start(exp); err != nil {
+		return transport.ReturnFail(transport.Code[transport.Forbidden], err.Error())
+	}
+	return transport.ReturnSuccess(exp.ID)
+}
+
+// StopChaosExperimentHandler stops the chaos experiment of the "id" parameter,
+// or all the experiments if the parameter is empty.
+type StopChaosExperimentHandler struct {
+}
+
+func (h *StopChaosExperimentHandler) Handle(request *transport.Request) *transport.Response {
+	id := request.Params["id"]
+	if id == "" {
+		chaos.StopAll()
+		return transport.ReturnSuccess("")
+	}
+	if !chaos.Stop(id) {
+		return transport.ReturnFail(transport.Code[transport.ParameterTypeError], "experiment not running: "+id)
+	}
+	return transport.ReturnSuccess(id)
+}
+
+// GetChaosStatusHandler reports the chaos status, including the running experiments.
+type GetChaosStatusHandler struct {
+}
+
+func (h *GetChaosStatusHandler) Handle(request *transport.Request) *transport.Response {
+	bs, err := json.Marshal(chaos.CurrentStatus())
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.ServerError], "bad data")
+	}
+	return transport.ReturnSuccess(string(bs))
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	FetchRulesCommandName  = "getRules"
+	SetLogLevelCommandName = "setLogLevel"
+)
+
+// FetchRulesHandler reports the currently applied rules of the kind given by the "type" parameter,
+// keyed by the app.
+type FetchRulesHandler struct {
+	// Apps limits the reported rules to the ones of the apps, e.g. of an ahas.Client. The rules of
+	// all apps are reported if empty.
+	Apps []string
+}
+
+func (h *FetchRulesHandler) Handle(request *transport.Request) *transport.Response {
+	kind := request.Params["type"]
+	if kind == "" {
+		return transport.ReturnFail(transport.Code[transport.ParameterEmpty], "empty rule type")
+	}
+	rules := datasource.CurrentRules(datasource.RuleKind(kind))
+	if len(h.Apps) > 0 {
+		selected := make(map[string]string, len(h.Apps))
+		for _, app := range h.Apps {
+			if data, ok := rules[app]; ok {
+				selected[app] = data
+			}
+		}
+		rules = selected
+	}
+	bs, err := json.Marshal(rules)
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.ServerError], "bad data")
+	}
+	return transport.ReturnSuccess(string(bs))
+}
+
+// SetLogLevelHandler changes the log level to the "level" parameter (see logger.SetLevel),
+// or reports current level if the parameter is empty.
+type SetLogLevelHandler struct {
+}
+
+func (h *SetLogLevelHandler) Handle(request *transport.Request) *transport.Response {
+	level := request.Params["level"]
+	if level == "" {
+		return transport.ReturnSuccess(logger.Level())
+	}
+	if err := logger.SetLevel(level); err != nil {
+		return transport.ReturnFail(transport.Code[transport.ParameterTypeError], err.Error())
+	}
+	logger.Infof("Log level changed to %s by the AHAS console", level)
+	return transport.ReturnSuccess(level)
+}
+
+// CommandFunc handles a custom command from the AHAS console with its parameters,
+// and the result is encoded as JSON.
+type CommandFunc func(params map[string]string) (interface{}, error)
+
+// CustomCommandHandler adapts a CommandFunc to the RequestHandler.
+type CustomCommandHandler struct {
+	Func CommandFunc
+}
+
+func (h *CustomCommandHandler) Handle(request *transport.Request) *transport.Response {
+	result, err := h.Func(request.Params)
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.ServerError], err.Error())
+	}
+	bs, err := json.Marshal(result)
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.EncodeError], "bad data")
+	}
+	return transport.ReturnSuccess(string(bs))
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	SetFeatureCommandName = "setFeature"
+)
+
+// SetFeatureHandler handles the feature toggles pushed from the AHAS console.
+// An empty "enabled" parameter clears the pushed toggle.
+type SetFeatureHandler struct {
+}
+
+func (h *SetFeatureHandler) Handle(request *transport.Request) *transport.Response {
+	name := request.Params["name"]
+	if name == "" {
+		return transport.ReturnFail(transport.Code[transport.ParameterEmpty], "empty feature name")
+	}
+	enabledStr := request.Params["enabled"]
+	if enabledStr == "" {
+		feature.ClearRemote(name)
+	} else {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return transport.ReturnFail(transport.Code[transport.ParameterTypeError], "bad enabled: "+enabledStr)
+		}
+		feature.SetRemote(name, enabled)
+	}
+	bs, err := json.Marshal(feature.All())
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.ServerError], "bad data")
+	}
+	return transport.ReturnSuccess(string(bs))
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"github.com/aliyun/aliyun-ahas-go-sdk/health"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+// HealthGuardedHandler refuses the console-initiated action while the application
+// is not healthy, if configured so (see health.Config).
+type HealthGuardedHandler struct {
+	Action  string
+	Handler transport.RequestHandler
+}
+
+func (h *HealthGuardedHandler) Handle(request *transport.Request) *transport.Response {
+	if err := health.CheckAction(h.Action); err != nil {
+		return transport.ReturnFail(transport.Code[transport.Forbidden], err.Error())
+	}
+	return h.Handler.Handle(request)
+}
@@ -3,7 +3,6 @@ package handler
 import (
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/alibaba/sentinel-golang/core/base"
 	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
@@ -19,12 +18,27 @@ const (
 
 type FetchMetricHandler struct {
 	searcher metric.MetricSearcher
+	encoder  *transport.ContentEncoder
+	filter   func(resource string) bool
 }
 
 func NewFetchMetricHandler() *FetchMetricHandler {
+	return NewFetchMetricHandlerWithEncoding(transport.EncodingConfig{})
+}
+
+// NewFetchMetricHandlerWithEncoding creates the handler which compresses the metrics
+// if the server declares the accepted content-encodings.
+func NewFetchMetricHandlerWithEncoding(conf transport.EncodingConfig) *FetchMetricHandler {
 	s, _ := metric.NewDefaultMetricSearcher(sentinelConf.LogBaseDir(),
 		metric.FormMetricFileName(sentinelConf.AppName(), sentinelConf.LogUsePid()))
-	return &FetchMetricHandler{searcher: s}
+	return &FetchMetricHandler{searcher: s, encoder: transport.NewContentEncoder(conf)}
+}
+
+// WithResourceFilter selects the resources to report, e.g. the ones of the apps of an ahas.Client.
+// The system metrics (the CPU usage and the load) are always reported.
+func (h *FetchMetricHandler) WithResourceFilter(filter func(resource string) bool) *FetchMetricHandler {
+	h.filter = filter
+	return h
 }
 
 func (h *FetchMetricHandler) Handle(request *transport.Request) *transport.Response {
@@ -67,30 +81,47 @@ func (h *FetchMetricHandler) Handle(request *transport.Request) *transport.Respo
 	if identity == "" {
 		list = append(list, h.fetchCpuAndLoadMetric()...)
 	}
-	b := strings.Builder{}
+	b := transport.AcquireBuffer()
+	defer transport.ReleaseBuffer(b)
 	for _, item := range list {
+		if h.filter != nil && !h.filter(item.Resource) && !isSystemMetric(item.Resource) {
+			continue
+		}
 		str, err := item.ToThinString()
 		if err != nil {
 			return transport.ReturnFail(transport.Code[transport.ServerError], fmt.Sprintf("Unexpected error: %v", err.Error()))
 		}
-		b.Write([]byte(str))
+		b.WriteString(str)
 		b.WriteByte('\n')
 	}
 	result := b.String()
+	// Legacy servers do not declare the accepted encodings, and expect the plain text.
+	if acceptEncoding := request.Params[transport.AcceptEncodingParam]; acceptEncoding != "" {
+		return transport.ReturnSuccess(h.encoder.Encode(acceptEncoding, result))
+	}
 	return transport.ReturnSuccess(result)
 }
 
+const (
+	systemLoadResource = "__system_load__"
+	cpuUsageResource   = "__cpu_usage__"
+)
+
+func isSystemMetric(resource string) bool {
+	return resource == systemLoadResource || resource == cpuUsageResource
+}
+
 func (h *FetchMetricHandler) fetchCpuAndLoadMetric() []*base.MetricItem {
 	list := make([]*base.MetricItem, 0)
 	t := util.CurrentTimeMillis() / 1000 * 1000
 	load1 := system.CurrentLoad()
 	cpuUsage := system.CurrentCpuUsage()
 	if load1 > 0 {
-		mi := &base.MetricItem{Resource: "__system_load__", Timestamp: t, PassQps: uint64(load1 * 10000)}
+		mi := &base.MetricItem{Resource: systemLoadResource, Timestamp: t, PassQps: uint64(load1 * 10000)}
 		list = append(list, mi)
 	}
 	if cpuUsage > 0 {
-		mi := &base.MetricItem{Resource: "__cpu_usage__", Timestamp: t, PassQps: uint64(cpuUsage * 10000)}
+		mi := &base.MetricItem{Resource: cpuUsageResource, Timestamp: t, PassQps: uint64(cpuUsage * 10000)}
 		list = append(list, mi)
 	}
 	return list
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	SetProtectionCommandName = "setProtection"
+)
+
+// SetProtectionHandler switches all the rules on or off by the "enabled" parameter (see
+// datasource.SetProtectionEnabled), or reports the current state if the parameter is empty.
+type SetProtectionHandler struct {
+}
+
+func (h *SetProtectionHandler) Handle(request *transport.Request) *transport.Response {
+	enabledStr := request.Params["enabled"]
+	if enabledStr != "" {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return transport.ReturnFail(transport.Code[transport.ParameterTypeError], "bad enabled: "+enabledStr)
+		}
+		datasource.SetProtectionEnabled(enabled, "the AHAS console")
+	}
+	return transport.ReturnSuccess(strconv.FormatBool(datasource.ProtectionEnabled()))
+}
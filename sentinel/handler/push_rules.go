@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/datasource"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	// PushRulesCommandName is the command of the rules pushed by the console, which is only sent
+	// by the servers acknowledging transport.CapabilityRulePush.
+	PushRulesCommandName = "pushRules"
+)
+
+// PushRulesHandler handles the rule payloads pushed from the AHAS console in push mode
+// (see datasource.PushDeliveryMode). The rules are applied asynchronously.
+type PushRulesHandler struct {
+	// Subscription receives the rules, e.g. of an ahas.Client. The process-wide data-source
+	// receives them if nil.
+	Subscription *datasource.Subscription
+}
+
+func (h *PushRulesHandler) Handle(request *transport.Request) *transport.Response {
+	kind := request.Params["kind"]
+	if kind == "" {
+		return transport.ReturnFail(transport.Code[transport.ParameterEmpty], "empty rule kind")
+	}
+	push := datasource.PushRules
+	if h.Subscription != nil {
+		push = h.Subscription.PushRules
+	}
+	err := push(request.Params["app"], datasource.RuleKind(kind), request.Params["data"])
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.ParameterTypeError], err.Error())
+	}
+	return transport.ReturnSuccess("OK")
+}
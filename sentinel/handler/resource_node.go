@@ -42,12 +42,18 @@ func NodeVoFromReal(n *stat.ResourceNode) *NodeVO {
 }
 
 type ResourceNodeHandler struct {
+	// Filter selects the resources to report, e.g. the ones of the apps of an ahas.Client. All
+	// resources are reported if nil.
+	Filter func(resource string) bool
 }
 
 func (r *ResourceNodeHandler) Handle(_ *transport.Request) *transport.Response {
 	nodes := stat.ResourceNodeList()
 	voList := make([]*NodeVO, 0)
 	for _, n := range nodes {
+		if r.Filter != nil && !r.Filter(n.ResourceName()) {
+			continue
+		}
 		voList = append(voList, NodeVoFromReal(n))
 	}
 	bs, err := json.Marshal(voList)
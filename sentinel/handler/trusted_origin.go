@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/sentinel/authority"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+)
+
+const (
+	SetTrustedOriginsCommandName = "setTrustedOrigins"
+)
+
+// SetTrustedOriginsHandler handles the trusted origins pushed from the AHAS console.
+// The "origins" parameter is comma-separated, and an empty one clears the trusted origins.
+type SetTrustedOriginsHandler struct {
+}
+
+func (h *SetTrustedOriginsHandler) Handle(request *transport.Request) *transport.Response {
+	origins := make([]string, 0)
+	if s := request.Params["origins"]; s != "" {
+		origins = strings.Split(s, ",")
+	}
+	authority.SetTrustedOrigins(origins)
+	bs, err := json.Marshal(authority.TrustedOrigins())
+	if err != nil {
+		return transport.ReturnFail(transport.Code[transport.ServerError], "bad data")
+	}
+	return transport.ReturnSuccess(string(bs))
+}
@@ -0,0 +1,251 @@
+// Package hotparam collects the statistics of the hot-spot parameter values, and reports the
+// hottest ones per hot-spot parameter flow rule periodically, so that the operators could tell
+// which tenant (or key) is being throttled.
+package hotparam
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/hotspot"
+)
+
+const (
+	DefaultTopN       = 10
+	DefaultMaxValues  = 1000
+	DefaultIntervalMs = 10000
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// TopN is the count of the hottest values reported per rule. DefaultTopN will be used if absent.
+	TopN int `yaml:"topN"`
+	// IntervalMs is the statistic window and the reporting interval. DefaultIntervalMs will be used if absent.
+	IntervalMs uint64 `yaml:"intervalMs"`
+	// MaxValues is the maximum count of distinct values tracked per rule in a window, beyond which
+	// the new values are counted into the "others". DefaultMaxValues will be used if absent.
+	MaxValues int `yaml:"maxValues"`
+}
+
+// ValueStat is the statistics of a parameter value within the window.
+type ValueStat struct {
+	Value      string  `json:"value"`
+	PassQps    float64 `json:"passQps"`
+	BlockQps   float64 `json:"blockQps"`
+	BlockCount uint64  `json:"blockCount"`
+}
+
+// RuleStat is the hottest parameter values of a hot-spot parameter flow rule within the window.
+type RuleStat struct {
+	Resource   string      `json:"resource"`
+	RuleId     string      `json:"ruleId"`
+	ParamIndex int         `json:"paramIndex"`
+	Top        []ValueStat `json:"top"`
+	// Others is the statistics of the values not tracked as the MaxValues exceeded.
+	Others    *ValueStat `json:"others,omitempty"`
+	Timestamp uint64     `json:"timestamp"`
+}
+
+type counter struct {
+	pass  uint64
+	block uint64
+}
+
+// ruleStats counts the parameter values of a rule within current window.
+type ruleStats struct {
+	rule hotspot.Rule
+
+	mux    sync.Mutex
+	values map[string]*counter
+	others counter
+}
+
+func (s *ruleStats) add(value string, blocked bool, maxValues int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.values[value]
+	if !ok {
+		if len(s.values) >= maxValues {
+			c = &s.others
+		} else {
+			c = &counter{}
+			s.values[value] = c
+		}
+	}
+	if blocked {
+		c.block++
+	} else {
+		c.pass++
+	}
+}
+
+// reset returns the counters of the window and starts a new one.
+func (s *ruleStats) reset() (map[string]*counter, counter) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	values, others := s.values, s.others
+	s.values = make(map[string]*counter)
+	s.others = counter{}
+	return values, others
+}
+
+var (
+	statsMux = &sync.RWMutex{}
+	// resourceStats is the statistics of the rules of each resource.
+	resourceStats = make(map[string][]*ruleStats)
+	maxValues     = DefaultMaxValues
+	initOnce      sync.Once
+)
+
+// Init registers the parameter statistic slot to the global Sentinel slot chain.
+func Init() {
+	initOnce.Do(func() {
+		sentinel.GlobalSlotChain().AddStatSlotLast(&statSlot{})
+	})
+}
+
+// SetRules replaces the hot-spot parameter flow rules to collect the statistics for, which
+// should be called after the rules are loaded. The statistics of the previous rules are discarded.
+func SetRules(rules []*hotspot.Rule) {
+	m := make(map[string][]*ruleStats)
+	for _, r := range rules {
+		if r == nil {
+			continue
+		}
+		m[r.Resource] = append(m[r.Resource], &ruleStats{rule: *r, values: make(map[string]*counter)})
+	}
+	statsMux.Lock()
+	defer statsMux.Unlock()
+	resourceStats = m
+}
+
+func setMaxValues(n int) {
+	statsMux.Lock()
+	defer statsMux.Unlock()
+	maxValues = n
+}
+
+func statsOf(resource string) ([]*ruleStats, int) {
+	statsMux.RLock()
+	defer statsMux.RUnlock()
+	return resourceStats[resource], maxValues
+}
+
+// collect resets the window of all rules, and returns the top n values of each rule.
+func collect(n int, windowSec float64, now uint64) []RuleStat {
+	statsMux.RLock()
+	all := make([]*ruleStats, 0)
+	for _, rs := range resourceStats {
+		all = append(all, rs...)
+	}
+	statsMux.RUnlock()
+
+	result := make([]RuleStat, 0, len(all))
+	for _, s := range all {
+		values, others := s.reset()
+		if len(values) == 0 && others.pass+others.block == 0 {
+			continue
+		}
+		stat := RuleStat{
+			Resource:   s.rule.Resource,
+			RuleId:     s.rule.ID,
+			ParamIndex: s.rule.ParamIndex,
+			Top:        make([]ValueStat, 0, n),
+			Timestamp:  now,
+		}
+		for v, c := range values {
+			stat.Top = append(stat.Top, toValueStat(v, c, windowSec))
+		}
+		sort.Slice(stat.Top, func(i, j int) bool {
+			ti, tj := stat.Top[i].PassQps+stat.Top[i].BlockQps, stat.Top[j].PassQps+stat.Top[j].BlockQps
+			if ti == tj {
+				return stat.Top[i].Value < stat.Top[j].Value
+			}
+			return ti > tj
+		})
+		if len(stat.Top) > n {
+			stat.Top = stat.Top[:n]
+		}
+		if others.pass+others.block > 0 {
+			o := toValueStat("", &others, windowSec)
+			stat.Others = &o
+		}
+		result = append(result, stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Resource == result[j].Resource {
+			return result[i].ParamIndex < result[j].ParamIndex
+		}
+		return result[i].Resource < result[j].Resource
+	})
+	return result
+}
+
+func toValueStat(value string, c *counter, windowSec float64) ValueStat {
+	return ValueStat{
+		Value:      value,
+		PassQps:    float64(c.pass) / windowSec,
+		BlockQps:   float64(c.block) / windowSec,
+		BlockCount: c.block,
+	}
+}
+
+// paramValue returns the value of the parameter at the index (negative means reversed), or false if absent.
+func paramValue(args []interface{}, index int) (string, bool) {
+	if index < 0 {
+		index += len(args)
+	}
+	if index < 0 || index >= len(args) || args[index] == nil {
+		return "", false
+	}
+	return fmt.Sprint(args[index]), true
+}
+
+// blockedBy returns whether the invocation is blocked by the hot-spot rule.
+func blockedBy(blockError *base.BlockError, rule *hotspot.Rule) bool {
+	if blockError == nil || blockError.BlockType() != base.BlockTypeHotSpotParamFlow {
+		return false
+	}
+	triggered, ok := blockError.TriggeredRule().(*hotspot.Rule)
+	if !ok || triggered == nil {
+		return false
+	}
+	if triggered.ID != "" || rule.ID != "" {
+		return triggered.ID == rule.ID
+	}
+	return triggered.ParamIndex == rule.ParamIndex
+}
+
+type statSlot struct {
+}
+
+func (s *statSlot) record(ctx *base.EntryContext, blockError *base.BlockError) {
+	if ctx == nil || ctx.Resource == nil || ctx.Input == nil {
+		return
+	}
+	stats, max := statsOf(ctx.Resource.Name())
+	for _, rs := range stats {
+		blocked := blockedBy(blockError, &rs.rule)
+		// The invocations blocked by other rules are not counted.
+		if blockError != nil && !blocked {
+			continue
+		}
+		if v, ok := paramValue(ctx.Input.Args, rs.rule.ParamIndex); ok {
+			rs.add(v, blocked, max)
+		}
+	}
+}
+
+func (s *statSlot) OnEntryPassed(ctx *base.EntryContext) {
+	s.record(ctx, nil)
+}
+
+func (s *statSlot) OnEntryBlocked(ctx *base.EntryContext, blockError *base.BlockError) {
+	s.record(ctx, blockError)
+}
+
+func (s *statSlot) OnCompleted(_ *base.EntryContext) {
+}
@@ -0,0 +1,122 @@
+package hotparam
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
+)
+
+const (
+	ReportServerName = "Sentinel"
+	// ReportHandlerName is the upstream handler receiving the statistics, which is
+	// only invoked if the server acknowledges transport.CapabilityHotParam.
+	ReportHandlerName = "hotParam"
+)
+
+var (
+	reportMux = &sync.Mutex{}
+	stopCh    chan struct{}
+
+	latestMux = &sync.RWMutex{}
+	latest    = make([]RuleStat, 0)
+)
+
+// Latest returns the hottest parameter values of the latest window.
+func Latest() []RuleStat {
+	latestMux.RLock()
+	defer latestMux.RUnlock()
+	return latest
+}
+
+// StartReporter starts collecting the parameter statistics, and reporting the hottest values
+// of each window to AHAS if the transport is given.
+func StartReporter(conf Config, tsp *transport.Transport) {
+	if !conf.Enabled {
+		return
+	}
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if stopCh != nil {
+		return
+	}
+	if conf.TopN <= 0 {
+		conf.TopN = DefaultTopN
+	}
+	if conf.IntervalMs == 0 {
+		conf.IntervalMs = DefaultIntervalMs
+	}
+	if conf.MaxValues <= 0 {
+		conf.MaxValues = DefaultMaxValues
+	}
+	setMaxValues(conf.MaxValues)
+	Init()
+	stopCh = make(chan struct{})
+	go runReporter(conf, tsp, stopCh)
+	logger.Infof("Hot parameter reporter started, topN: %d, interval: %dms", conf.TopN, conf.IntervalMs)
+}
+
+// StopReporter stops the running reporter.
+func StopReporter() {
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	stopCh = nil
+}
+
+func runReporter(conf Config, tsp *transport.Transport, stop chan struct{}) {
+	defer tools.PrintPanicStackV2("hot parameter reporter")
+
+	interval := time.Duration(conf.IntervalMs) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// Discard the statistics before the first window.
+	collect(conf.TopN, interval.Seconds(), util.CurrentTimeMillis())
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		stats := collect(conf.TopN, interval.Seconds(), util.CurrentTimeMillis())
+		latestMux.Lock()
+		latest = stats
+		latestMux.Unlock()
+		if tsp == nil || len(stats) == 0 {
+			continue
+		}
+		if err := report(tsp, stats); err != nil {
+			logger.Warnf("Failed to report hot parameter statistics: %+v", err)
+		}
+	}
+}
+
+func report(tsp *transport.Transport, stats []RuleStat) error {
+	if !tsp.SupportsUpstream(transport.CapabilityHotParam) {
+		return nil
+	}
+	bs, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	request := transport.NewRequest()
+	request.AddParam("stats", string(bs))
+	uri := transport.NewUri(ReportServerName, ReportHandlerName)
+	uri.CompressVersion = transport.AllCompress
+	response, err := tsp.Invoke(uri, request)
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.Errorf("bad response, code: %d, error: %s", response.Code, response.Error)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package metriclog
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/core/base"
+	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
+	"github.com/alibaba/sentinel-golang/core/log/metric"
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
+)
+
+const (
+	UploadServerName = "Sentinel"
+	// UploadHandlerName is the upstream handler receiving the metrics, which is only invoked if the
+	// server acknowledges transport.CapabilityMetricUpload.
+	UploadHandlerName = "uploadMetric"
+
+	DefaultUploadIntervalMs = 1000
+	DefaultUploadBatchSize  = 6000
+	DefaultMaxPendingItems  = 60000
+
+	maxUploadBackoff = 60 * time.Second
+)
+
+// UploadConfig is the config of uploading the per-second metrics of the resources to AHAS,
+// which are aggregated in the Sentinel metric logs.
+type UploadConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// IntervalMs is the interval of collecting and uploading the metrics.
+	IntervalMs uint64 `yaml:"intervalMs"`
+	// BatchSize is the maximum number of metric items in one request.
+	BatchSize int `yaml:"batchSize"`
+	// MaxPendingItems is the capacity of the metric items pending for upload while AHAS is slow
+	// or unavailable. The oldest items are dropped (and counted) once exceeded.
+	MaxPendingItems int `yaml:"maxPendingItems"`
+}
+
+type uploader struct {
+	conf     UploadConfig
+	tsp      *transport.Transport
+	searcher metric.MetricSearcher
+	// next is the beginning timestamp of the next collection. The states are only accessed in the run goroutine.
+	next    uint64
+	pending []*base.MetricItem
+	dropped uint64
+	flushCh chan chan error
+	stopCh  chan struct{}
+}
+
+var (
+	uploadMux       = &sync.Mutex{}
+	currentUploader *uploader
+)
+
+// StartUploader starts collecting the metrics from the Sentinel metric logs and uploading
+// them to AHAS in batches. Failed batches are kept and retried with backoff, and the oldest
+// metrics are dropped once the pending ones exceed MaxPendingItems.
+func StartUploader(conf UploadConfig, tsp *transport.Transport) error {
+	if !conf.Enabled || tsp == nil {
+		return nil
+	}
+	uploadMux.Lock()
+	defer uploadMux.Unlock()
+	if currentUploader != nil {
+		return nil
+	}
+	if conf.IntervalMs == 0 {
+		conf.IntervalMs = DefaultUploadIntervalMs
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = DefaultUploadBatchSize
+	}
+	if conf.MaxPendingItems <= 0 {
+		conf.MaxPendingItems = DefaultMaxPendingItems
+	}
+	searcher, err := metric.NewDefaultMetricSearcher(sentinelConf.LogBaseDir(),
+		metric.FormMetricFileName(sentinelConf.AppName(), sentinelConf.LogUsePid()))
+	if err != nil {
+		return errors.Wrap(err, "failed to create metric searcher")
+	}
+	u := &uploader{
+		conf:     conf,
+		tsp:      tsp,
+		searcher: searcher,
+		// Metrics before the start are not uploaded.
+		next:    util.CurrentTimeMillis() / 1000 * 1000,
+		pending: make([]*base.MetricItem, 0),
+		flushCh: make(chan chan error),
+		stopCh:  make(chan struct{}),
+	}
+	currentUploader = u
+	go u.run()
+	logger.Infof("Metric uploader started, interval: %dms, batchSize: %d", conf.IntervalMs, conf.BatchSize)
+	return nil
+}
+
+// StopUploader stops the running uploader. Pending metrics are discarded.
+func StopUploader() {
+	uploadMux.Lock()
+	defer uploadMux.Unlock()
+	if currentUploader == nil {
+		return
+	}
+	close(currentUploader.stopCh)
+	currentUploader = nil
+}
+
+// FlushUploader uploads the metrics written so far at once, e.g. before the process exits.
+// The context bounds the waiting for the upload.
+func FlushUploader(ctx context.Context) error {
+	uploadMux.Lock()
+	u := currentUploader
+	uploadMux.Unlock()
+	if u == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	select {
+	case u.flushCh <- done:
+	case <-u.stopCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (u *uploader) run() {
+	defer tools.PrintPanicStackV2("metric uploader")
+
+	interval := time.Duration(u.conf.IntervalMs) * time.Millisecond
+	backoff := time.Duration(0)
+	for {
+		wait := interval
+		if backoff > 0 {
+			wait = backoff
+		}
+		select {
+		case <-time.After(wait):
+		case done := <-u.flushCh:
+			u.collect()
+			done <- u.flush()
+			continue
+		case <-u.stopCh:
+			return
+		}
+		u.collect()
+		if err := u.flush(); err != nil {
+			if backoff == 0 {
+				backoff = interval
+			} else if backoff *= 2; backoff > maxUploadBackoff {
+				backoff = maxUploadBackoff
+			}
+			logger.Warnf("Failed to upload metrics (%d pending), retry after %v: %+v", len(u.pending), backoff, err)
+			continue
+		}
+		backoff = 0
+	}
+}
+
+// collect reads the metrics written since the last collection into the pending ones.
+func (u *uploader) collect() {
+	items, err := u.searcher.FindFromTimeWithMaxLines(u.next, uint32(u.conf.BatchSize))
+	if err != nil {
+		logger.Warnf("Failed to read metric logs: %+v", err)
+		return
+	}
+	var last uint64
+	for _, item := range items {
+		if item.Timestamp > last {
+			last = item.Timestamp
+		}
+	}
+	// The read might stop in the middle of the last second, which is re-read next time then.
+	partial := len(items) >= u.conf.BatchSize && last > u.next
+	for _, item := range items {
+		if item.Timestamp < u.next || (partial && item.Timestamp == last) {
+			continue
+		}
+		u.pending = append(u.pending, item)
+	}
+	if partial {
+		u.next = last
+	} else if last >= u.next {
+		u.next = last + 1
+	}
+	if overflow := len(u.pending) - u.conf.MaxPendingItems; overflow > 0 {
+		u.pending = u.pending[overflow:]
+		u.dropped += uint64(overflow)
+	}
+}
+
+// flush uploads the pending metrics in batches, and keeps the failed ones for retry.
+// The metrics are discarded if the server doesn't support the upload, where the console
+// fetches them by the "metric" command instead.
+func (u *uploader) flush() error {
+	if !u.tsp.SupportsUpstream(transport.CapabilityMetricUpload) {
+		u.pending = u.pending[:0]
+		return nil
+	}
+	for len(u.pending) > 0 {
+		n := len(u.pending)
+		if n > u.conf.BatchSize {
+			n = u.conf.BatchSize
+		}
+		if err := u.upload(u.pending[:n]); err != nil {
+			return err
+		}
+		u.pending = u.pending[n:]
+		u.dropped = 0
+	}
+	return nil
+}
+
+func (u *uploader) upload(batch []*base.MetricItem) error {
+	b := transport.AcquireBuffer()
+	defer transport.ReleaseBuffer(b)
+	for _, item := range batch {
+		str, err := item.ToThinString()
+		if err != nil {
+			return err
+		}
+		b.WriteString(str)
+		b.WriteByte('\n')
+	}
+	request := transport.NewRequest()
+	request.AddParam("metrics", b.String())
+	request.AddParam("dropped", strconv.FormatUint(u.dropped, 10))
+	uri := transport.NewUri(UploadServerName, UploadHandlerName)
+	uri.CompressVersion = transport.AllCompress
+	response, err := u.tsp.Invoke(uri, request)
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.Errorf("bad response, code: %d, error: %s", response.Code, response.Error)
+	}
+	return nil
+}
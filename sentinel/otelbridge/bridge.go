@@ -0,0 +1,102 @@
+package otelbridge
+
+import (
+	"context"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+	"github.com/alibaba/sentinel-golang/core/stat"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// BlockedEventName is the name of the span event recorded when the request is blocked.
+	BlockedEventName = "sentinel.blocked"
+
+	ResourceKey  = "sentinel.resource"
+	BlockedKey   = "sentinel.blocked"
+	BlockTypeKey = "sentinel.block_type"
+	RuleKey      = "sentinel.rule"
+)
+
+// Entry is the same as sentinel.Entry, and additionally records the block event into the
+// current span of ctx if blocked.
+func Entry(ctx context.Context, resource string, opts ...sentinel.EntryOption) (*base.SentinelEntry, *base.BlockError) {
+	e, b := sentinel.Entry(resource, opts...)
+	if b != nil {
+		RecordBlock(ctx, resource, b)
+	}
+	return e, b
+}
+
+// RecordBlock records the block error into the current span of ctx, for the integrations
+// calling sentinel.Entry themselves.
+func RecordBlock(ctx context.Context, resource string, b *base.BlockError) {
+	span := trace.SpanFromContext(ctx)
+	if b == nil || !span.IsRecording() {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String(ResourceKey, resource),
+		attribute.String(BlockTypeKey, b.BlockType().String()),
+	}
+	if rule := b.TriggeredRule(); rule != nil {
+		attrs = append(attrs, attribute.String(RuleKey, rule.String()))
+	}
+	span.AddEvent(BlockedEventName, trace.WithAttributes(attrs...))
+	span.SetAttributes(attribute.Bool(BlockedKey, true))
+}
+
+// RegisterMetrics registers the per-resource Sentinel metrics (the QPS of the passed, blocked,
+// completed and error requests, the average RT and the concurrency) to the meter, which are
+// observed on each collection. The returned registration could be used to unregister them.
+func RegisterMetrics(meter metric.Meter) (metric.Registration, error) {
+	type gauge struct {
+		name, desc, unit string
+		value            func(n *stat.ResourceNode) float64
+		instrument       metric.Float64ObservableGauge
+	}
+	qps := func(event base.MetricEvent) func(n *stat.ResourceNode) float64 {
+		return func(n *stat.ResourceNode) float64 {
+			return n.GetQPS(event)
+		}
+	}
+	gauges := []*gauge{
+		{name: "sentinel.resource.pass_qps", desc: "The passed requests per second of the resource.",
+			unit: "{request}/s", value: qps(base.MetricEventPass)},
+		{name: "sentinel.resource.block_qps", desc: "The blocked requests per second of the resource.",
+			unit: "{request}/s", value: qps(base.MetricEventBlock)},
+		{name: "sentinel.resource.complete_qps", desc: "The completed requests per second of the resource.",
+			unit: "{request}/s", value: qps(base.MetricEventComplete)},
+		{name: "sentinel.resource.error_qps", desc: "The requests with exceptions per second of the resource.",
+			unit: "{request}/s", value: qps(base.MetricEventError)},
+		{name: "sentinel.resource.avg_rt", desc: "The average response time of the resource.",
+			unit: "ms", value: func(n *stat.ResourceNode) float64 {
+				return n.AvgRT()
+			}},
+		{name: "sentinel.resource.concurrency", desc: "The concurrent requests of the resource.",
+			unit: "{request}", value: func(n *stat.ResourceNode) float64 {
+				return float64(n.CurrentGoroutineNum())
+			}},
+	}
+	instruments := make([]metric.Observable, 0, len(gauges))
+	for _, g := range gauges {
+		instrument, err := meter.Float64ObservableGauge(g.name, metric.WithDescription(g.desc), metric.WithUnit(g.unit))
+		if err != nil {
+			return nil, err
+		}
+		g.instrument = instrument
+		instruments = append(instruments, instrument)
+	}
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, n := range stat.ResourceNodeList() {
+			attrs := metric.WithAttributes(attribute.String(ResourceKey, n.ResourceName()))
+			for _, g := range gauges {
+				o.ObserveFloat64(g.instrument, g.value(n), attrs)
+			}
+		}
+		return nil
+	}, instruments...)
+}
@@ -0,0 +1,14 @@
+// Package otelbridge records the Sentinel block events into the OpenTelemetry spans, and
+// exports the Sentinel metrics via the OpenTelemetry metrics API, so that the traces tell
+// the requests rejected by Sentinel from the ones failing downstream:
+//
+//	e, b := otelbridge.Entry(ctx, resource)
+//	if b != nil {
+//		// The span in ctx has got the "sentinel.blocked" event.
+//		return
+//	}
+//	defer e.Exit()
+//
+// The bridge is a separate module requiring go.opentelemetry.io/otel (v1.x, which requires a
+// newer Go version than the SDK), so that the SDK doesn't depend on it.
+package otelbridge
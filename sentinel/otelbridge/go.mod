@@ -0,0 +1,20 @@
+module github.com/aliyun/aliyun-ahas-go-sdk/sentinel/otelbridge
+
+go 1.20
+
+require (
+	github.com/alibaba/sentinel-golang v0.6.0
+	go.opentelemetry.io/otel v1.19.0
+	go.opentelemetry.io/otel/metric v1.19.0
+	go.opentelemetry.io/otel/trace v1.19.0
+)
+
+require (
+	github.com/StackExchange/wmi v0.0.0-20190523213315-cbe66965904d // indirect
+	github.com/go-ole/go-ole v1.2.4 // indirect
+	github.com/google/uuid v1.1.1 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/shirou/gopsutil v2.19.12+incompatible // indirect
+	golang.org/x/sys v0.0.0-20200523222454-059865788121 // indirect
+	gopkg.in/yaml.v2 v2.2.8 // indirect
+)
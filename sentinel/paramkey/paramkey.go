@@ -0,0 +1,83 @@
+// Package paramkey supports extracting the hot-spot parameters by key rather than
+// by position. The keyed values are carried in the entry attachments:
+//
+//	e, b := sentinel.Entry(resource, paramkey.WithParam("userId", uid))
+//
+// As sentinel-golang resolves the parameters by index only, the keyed values of the
+// resource are appended to the arguments in a fixed order, and the rules refer to
+// them by negative (reversed) index.
+package paramkey
+
+import (
+	"sync"
+
+	sentinel "github.com/alibaba/sentinel-golang/api"
+	"github.com/alibaba/sentinel-golang/core/base"
+)
+
+var (
+	keysMux = &sync.RWMutex{}
+	// resourceKeys is the keys of the parameters appended to the arguments of each resource.
+	resourceKeys = make(map[string][]string)
+	initOnce     sync.Once
+)
+
+// WithParam carries the keyed parameter value of the invocation.
+func WithParam(key string, value interface{}) sentinel.EntryOption {
+	return sentinel.WithAttachment(key, value)
+}
+
+// Init registers the keyed parameter extractor to the global Sentinel slot chain.
+func Init() {
+	initOnce.Do(func() {
+		sentinel.GlobalSlotChain().AddStatPrepareSlotLast(&prepareSlot{})
+	})
+}
+
+// SetKeys replaces the parameter keys of all resources. Keys of a resource are appended
+// to the arguments in the given order, so the i-th of n keys is at the reversed index i-n.
+func SetKeys(keys map[string][]string) {
+	m := make(map[string][]string, len(keys))
+	for res, ks := range keys {
+		if len(ks) > 0 {
+			m[res] = append([]string(nil), ks...)
+		}
+	}
+	if len(m) > 0 {
+		Init()
+	}
+	keysMux.Lock()
+	defer keysMux.Unlock()
+	resourceKeys = m
+}
+
+// ReversedIndex returns the reversed index of the i-th of n keys.
+func ReversedIndex(i, n int) int {
+	return i - n
+}
+
+func keysOf(resource string) []string {
+	keysMux.RLock()
+	defer keysMux.RUnlock()
+	return resourceKeys[resource]
+}
+
+type prepareSlot struct {
+}
+
+func (s *prepareSlot) Prepare(ctx *base.EntryContext) {
+	if ctx == nil || ctx.Resource == nil || ctx.Input == nil {
+		return
+	}
+	keys := keysOf(ctx.Resource.Name())
+	if len(keys) == 0 {
+		return
+	}
+	args := make([]interface{}, len(ctx.Input.Args), len(ctx.Input.Args)+len(keys))
+	copy(args, ctx.Input.Args)
+	for _, k := range keys {
+		// Absent values are nil, which are ignored by the hot-spot parameter flow control.
+		args = append(args, ctx.Input.Attachments[k])
+	}
+	ctx.Input.Args = args
+}
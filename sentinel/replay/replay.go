@@ -0,0 +1,150 @@
+// Package replay simulates the access log against the flow control and circuit breaking
+// rules offline, to tell how many requests would be blocked, which helps tuning the thresholds.
+// The simulation follows the Sentinel semantics with the timestamps in the log rather than
+// the wall clock, so it's an estimation rather than an exact reproduction.
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+	"github.com/pkg/errors"
+)
+
+// Entry is an access log entry.
+type Entry struct {
+	// Timestamp is the start time of the request in milliseconds.
+	Timestamp uint64
+	Resource  string
+	RtMs      uint64
+	// Error indicates whether the request failed (the business error, not blocked).
+	Error bool
+}
+
+// ParseEntries parses the access log in the format of
+//
+//	timestamp(ms),resource,rt(ms),outcome
+//
+// per line, where outcome is "ok" or "error". Empty lines and lines starting with '#' are skipped.
+func ParseEntries(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, errors.Errorf("line %d: expected 4 fields, got %d", lineNo, len(fields))
+		}
+		ts, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("line %d: bad timestamp: %s", lineNo, fields[0])
+		}
+		rt, err := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("line %d: bad rt: %s", lineNo, fields[2])
+		}
+		var isError bool
+		switch strings.ToLower(strings.TrimSpace(fields[3])) {
+		case "ok":
+		case "error":
+			isError = true
+		default:
+			return nil, errors.Errorf("line %d: bad outcome: %s", lineNo, fields[3])
+		}
+		entries = append(entries, Entry{Timestamp: ts, Resource: strings.TrimSpace(fields[1]), RtMs: rt, Error: isError})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Rules is the rule set to simulate against.
+type Rules struct {
+	Flow            []*flow.FlowRule
+	CircuitBreaking []*circuitbreaker.Rule
+}
+
+// ResourceReport is the simulation result of a resource.
+type ResourceReport struct {
+	Resource             string `json:"resource"`
+	Total                int    `json:"total"`
+	Passed               int    `json:"passed"`
+	BlockedByFlow        int    `json:"blockedByFlow"`
+	BlockedByBreaker     int    `json:"blockedByBreaker"`
+	BreakerOpenedTimes   int    `json:"breakerOpenedTimes"`
+	FirstBlockedAtMillis uint64 `json:"firstBlockedAtMillis,omitempty"`
+}
+
+func (r *ResourceReport) Blocked() int {
+	return r.BlockedByFlow + r.BlockedByBreaker
+}
+
+// Report is the simulation result.
+type Report struct {
+	Total     int               `json:"total"`
+	Blocked   int               `json:"blocked"`
+	Resources []*ResourceReport `json:"resources"`
+}
+
+func (r *Report) String() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("total: %d, blocked: %d\n", r.Total, r.Blocked))
+	b.WriteString("resource|total|passed|blockedByFlow|blockedByBreaker|breakerOpened\n")
+	for _, res := range r.Resources {
+		b.WriteString(fmt.Sprintf("%s|%d|%d|%d|%d|%d\n", res.Resource, res.Total, res.Passed,
+			res.BlockedByFlow, res.BlockedByBreaker, res.BreakerOpenedTimes))
+	}
+	return b.String()
+}
+
+// Simulate replays the entries (ordered by timestamp) against the rules.
+func Simulate(entries []Entry, rules Rules) *Report {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+
+	s := newSimulator(rules)
+	reports := make(map[string]*ResourceReport)
+	report := &Report{Resources: make([]*ResourceReport, 0)}
+	for _, e := range sorted {
+		res, ok := reports[e.Resource]
+		if !ok {
+			res = &ResourceReport{Resource: e.Resource}
+			reports[e.Resource] = res
+			report.Resources = append(report.Resources, res)
+		}
+		res.Total++
+		report.Total++
+		switch s.entry(e, res) {
+		case blockedByFlow:
+			res.BlockedByFlow++
+		case blockedByBreaker:
+			res.BlockedByBreaker++
+		default:
+			res.Passed++
+			continue
+		}
+		report.Blocked++
+		if res.FirstBlockedAtMillis == 0 {
+			res.FirstBlockedAtMillis = e.Timestamp
+		}
+	}
+	sort.Slice(report.Resources, func(i, j int) bool {
+		return report.Resources[i].Resource < report.Resources[j].Resource
+	})
+	return report
+}
@@ -0,0 +1,233 @@
+package replay
+
+import (
+	"container/heap"
+
+	"github.com/alibaba/sentinel-golang/core/circuitbreaker"
+	"github.com/alibaba/sentinel-golang/core/flow"
+)
+
+// qpsBucketLengthMs is the bucket length of the QPS statistics, as Sentinel counts QPS
+// in a sliding window of 1s with 2 buckets.
+const qpsBucketLengthMs = 500
+
+type outcome int
+
+const (
+	passed outcome = iota
+	blockedByFlow
+	blockedByBreaker
+)
+
+// resourceStat keeps the statistics of the passed requests of a resource.
+type resourceStat struct {
+	// buckets holds the passed count of the current and the previous bucket.
+	bucketStart uint64
+	buckets     [2]uint64
+	// inflight holds the end time of the passed requests which have not completed yet.
+	inflight endTimeHeap
+	// lastPassedMs is the (queued) pass time of the last request, for throttling flow rules.
+	lastPassedMs uint64
+}
+
+func (s *resourceStat) rotate(now uint64) {
+	start := now - now%qpsBucketLengthMs
+	switch {
+	case start == s.bucketStart:
+	case start == s.bucketStart+qpsBucketLengthMs:
+		s.buckets[0], s.buckets[1] = s.buckets[1], 0
+	default:
+		s.buckets[0], s.buckets[1] = 0, 0
+	}
+	s.bucketStart = start
+	for s.inflight.Len() > 0 && s.inflight[0] <= now {
+		heap.Pop(&s.inflight)
+	}
+}
+
+func (s *resourceStat) qps() float64 {
+	return float64(s.buckets[0] + s.buckets[1])
+}
+
+type endTimeHeap []uint64
+
+func (h endTimeHeap) Len() int            { return len(h) }
+func (h endTimeHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h endTimeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *endTimeHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *endTimeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// breaker simulates the state machine of a circuit breaking rule.
+type breaker struct {
+	rule  *circuitbreaker.Rule
+	state circuitbreaker.State
+	// nextRetryMs is the time when the open breaker turns half-open.
+	nextRetryMs uint64
+	// The statistics of the current interval.
+	intervalStart uint64
+	total         uint64
+	bad           uint64
+}
+
+func (b *breaker) tryPass(now uint64) bool {
+	switch b.state {
+	case circuitbreaker.Closed:
+		return true
+	case circuitbreaker.Open:
+		if now >= b.nextRetryMs {
+			// Let one probe request pass.
+			b.state = circuitbreaker.HalfOpen
+			return true
+		}
+		return false
+	default:
+		// Only the probe passes while half-open.
+		return false
+	}
+}
+
+func (b *breaker) isBad(e Entry) bool {
+	if b.rule.Strategy == circuitbreaker.SlowRequestRatio {
+		return e.RtMs > b.rule.MaxAllowedRtMs
+	}
+	return e.Error
+}
+
+// onComplete records the outcome of the passed request, and reports whether the breaker opened.
+func (b *breaker) onComplete(e Entry) bool {
+	now := e.Timestamp
+	bad := b.isBad(e)
+	if b.state == circuitbreaker.HalfOpen {
+		if bad {
+			b.open(now)
+			return true
+		}
+		b.state = circuitbreaker.Closed
+		b.total, b.bad = 0, 0
+		b.intervalStart = now
+		return false
+	}
+	interval := uint64(b.rule.StatIntervalMs)
+	if interval == 0 {
+		interval = 1000
+	}
+	if now >= b.intervalStart+interval {
+		b.intervalStart = now - now%interval
+		b.total, b.bad = 0, 0
+	}
+	b.total++
+	if bad {
+		b.bad++
+	}
+	if b.state != circuitbreaker.Closed || b.total < b.rule.MinRequestAmount {
+		return false
+	}
+	var exceeded bool
+	if b.rule.Strategy == circuitbreaker.ErrorCount {
+		exceeded = float64(b.bad) > b.rule.Threshold
+	} else {
+		exceeded = float64(b.bad)/float64(b.total) > b.rule.Threshold
+	}
+	if exceeded {
+		b.open(now)
+	}
+	return exceeded
+}
+
+func (b *breaker) open(now uint64) {
+	b.state = circuitbreaker.Open
+	b.nextRetryMs = now + uint64(b.rule.RetryTimeoutMs)
+	b.total, b.bad = 0, 0
+}
+
+type simulator struct {
+	flowRules map[string][]*flow.FlowRule
+	breakers  map[string][]*breaker
+	stats     map[string]*resourceStat
+}
+
+func newSimulator(rules Rules) *simulator {
+	s := &simulator{
+		flowRules: make(map[string][]*flow.FlowRule),
+		breakers:  make(map[string][]*breaker),
+		stats:     make(map[string]*resourceStat),
+	}
+	for _, r := range rules.Flow {
+		if r != nil {
+			s.flowRules[r.Resource] = append(s.flowRules[r.Resource], r)
+		}
+	}
+	for _, r := range rules.CircuitBreaking {
+		if r != nil {
+			s.breakers[r.Resource] = append(s.breakers[r.Resource], &breaker{rule: r})
+		}
+	}
+	return s
+}
+
+func (s *simulator) stat(resource string, now uint64) *resourceStat {
+	st, ok := s.stats[resource]
+	if !ok {
+		st = &resourceStat{}
+		s.stats[resource] = st
+	}
+	st.rotate(now)
+	return st
+}
+
+func (s *simulator) entry(e Entry, report *ResourceReport) outcome {
+	now := e.Timestamp
+	st := s.stat(e.Resource, now)
+	for _, r := range s.flowRules[e.Resource] {
+		if !s.flowPass(r, st, now) {
+			return blockedByFlow
+		}
+	}
+	breakers := s.breakers[e.Resource]
+	for _, b := range breakers {
+		if !b.tryPass(now) {
+			return blockedByBreaker
+		}
+	}
+	st.buckets[1]++
+	heap.Push(&st.inflight, now+e.RtMs)
+	for _, b := range breakers {
+		if b.onComplete(e) {
+			report.BreakerOpenedTimes++
+		}
+	}
+	return passed
+}
+
+func (s *simulator) flowPass(r *flow.FlowRule, st *resourceStat, now uint64) bool {
+	if r.RelationStrategy == flow.AssociatedResource {
+		st = s.stat(r.RefResource, now)
+	}
+	if r.MetricType == flow.Concurrency {
+		return float64(st.inflight.Len())+1 <= r.Count
+	}
+	if r.Count <= 0 {
+		return false
+	}
+	if r.ControlBehavior == flow.Throttling && r.RelationStrategy == flow.Direct {
+		// Requests are queued to pass at the even interval, and blocked if they'd wait too long.
+		interval := uint64(1000 / r.Count)
+		expected := st.lastPassedMs + interval
+		if st.lastPassedMs != 0 && expected > now && expected-now > uint64(r.MaxQueueingTimeMs) {
+			return false
+		}
+		if expected < now || st.lastPassedMs == 0 {
+			expected = now
+		}
+		st.lastPassedMs = expected
+		return true
+	}
+	// Warm-up is simulated as the stable threshold.
+	return st.qps()+1 <= r.Count
+}
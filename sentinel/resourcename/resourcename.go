@@ -0,0 +1,246 @@
+// Package resourcename provides the canonical resource naming for common frameworks.
+// Integrations should always name their resources via this package, so that the rules
+// configured in the console match the names generated in code, e.g.
+//
+//	HTTP("get", "/users/{id}")           // "GET:/users/:id"
+//	GRPC("helloworld.Greeter/SayHello")  // "/helloworld.Greeter/SayHello"
+//	Dubbo("com.foo.Greeter", "SayHello") // "com.foo.Greeter:SayHello"
+//	RPC("greeter", "Greeter.Hello")      // "greeter:Greeter.Hello"
+//	SQL("SELECT * FROM t WHERE id = 1")  // "SELECT * FROM t WHERE id = ?"
+//	Redis("get", "user:1001:profile")    // "GET user:*:profile"
+//	MQ("order-group", "orders")          // "order-group:orders"
+package resourcename
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// KeyPatternWildcard replaces the variable segments in Redis key patterns.
+	KeyPatternWildcard = "*"
+	// SQLPlaceholder replaces the literals in SQL digests.
+	SQLPlaceholder = "?"
+	// UnmatchedRoute is the route template of the HTTP requests which match no route (e.g. the
+	// 404s of scanners), so that they share a resource per method rather than one per raw path.
+	UnmatchedRoute = "/*unmatched"
+
+	redisKeySeparator = ":"
+)
+
+// HTTP returns the resource name of an HTTP route, in the form of "METHOD:/path/template".
+// Path variables in the style of "{id}" and "<id>" are normalized to ":id" (the same as
+// the gin and echo routers), while wildcards like "*path" are kept as is.
+// An empty method results in the path only.
+func HTTP(method, template string) string {
+	path := normalizePathTemplate(template)
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		return path
+	}
+	return method + ":" + path
+}
+
+func normalizePathTemplate(template string) string {
+	template = strings.TrimSpace(template)
+	if i := strings.IndexAny(template, "?#"); i >= 0 {
+		template = template[:i]
+	}
+	segments := strings.Split(template, "/")
+	result := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		if len(seg) > 2 && (seg[0] == '{' && seg[len(seg)-1] == '}' || seg[0] == '<' && seg[len(seg)-1] == '>') {
+			name := seg[1 : len(seg)-1]
+			// Strip the type or regexp constraint, e.g. "<int:id>" and "{id:[0-9]+}".
+			if seg[0] == '<' {
+				if i := strings.LastIndex(name, ":"); i >= 0 {
+					name = name[i+1:]
+				}
+			} else if i := strings.Index(name, ":"); i >= 0 {
+				name = name[:i]
+			}
+			seg = ":" + name
+		}
+		result = append(result, seg)
+	}
+	return "/" + strings.Join(result, "/")
+}
+
+// GRPC returns the resource name of a gRPC method, which is the full method
+// in the form of "/package.Service/Method".
+func GRPC(fullMethod string) string {
+	fullMethod = strings.TrimSpace(fullMethod)
+	if !strings.HasPrefix(fullMethod, "/") {
+		return "/" + fullMethod
+	}
+	return fullMethod
+}
+
+// Dubbo returns the resource name of a Dubbo method, in the form of "interface:method".
+// Unlike the Java SDK, the parameter types are not part of the name, as they are unknown
+// to dubbo-go. The resource of the interface itself is named by the interface.
+func Dubbo(iface, method string) string {
+	return strings.TrimSpace(iface) + ":" + strings.TrimSpace(method)
+}
+
+// RPC returns the resource name of an endpoint of the RPC frameworks which address the
+// endpoints by service (e.g. go-micro), in the form of "service:endpoint".
+// An empty service results in the endpoint only.
+func RPC(service, endpoint string) string {
+	service, endpoint = strings.TrimSpace(service), strings.TrimSpace(endpoint)
+	if service == "" {
+		return endpoint
+	}
+	return service + ":" + endpoint
+}
+
+// SQL returns the digest of the SQL statement as the resource name. Comments are removed,
+// literals are replaced with "?", value lists such as "IN (?, ?)" are collapsed to "(?)"
+// and whitespaces are collapsed. The case of keywords and identifiers is kept.
+func SQL(statement string) string {
+	var b strings.Builder
+	b.Grow(len(statement))
+	rs := []rune(statement)
+	pendingSpace := false
+	write := func(s string) {
+		if last := b.String(); pendingSpace && last != "" && !strings.HasSuffix(last, "(") && !strings.HasSuffix(last, ",") {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteString(s)
+	}
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			pendingSpace = true
+		case c == '-' && i+1 < len(rs) && rs[i+1] == '-', c == '#':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			pendingSpace = true
+		case c == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			i += 2
+			for i < len(rs) && !(rs[i] == '*' && i+1 < len(rs) && rs[i+1] == '/') {
+				i++
+			}
+			i++
+			pendingSpace = true
+		case c == '\'' || c == '"':
+			for i++; i < len(rs); i++ {
+				if rs[i] == '\\' {
+					i++
+				} else if rs[i] == c {
+					if i+1 < len(rs) && rs[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			write(SQLPlaceholder)
+		case c == '`':
+			start := i
+			for i++; i < len(rs) && rs[i] != '`'; i++ {
+			}
+			write(string(rs[start:minInt(i+1, len(rs))]))
+		case unicode.IsDigit(c) && !precededByIdentifier(rs, i):
+			for i+1 < len(rs) && (unicode.IsDigit(rs[i+1]) || unicode.IsLetter(rs[i+1]) || rs[i+1] == '.') {
+				i++
+			}
+			write(SQLPlaceholder)
+		case isIdentifierRune(c):
+			start := i
+			for i+1 < len(rs) && isIdentifierRune(rs[i+1]) {
+				i++
+			}
+			write(string(rs[start : i+1]))
+		default:
+			if c == ',' || c == ')' {
+				pendingSpace = false
+			}
+			write(string(c))
+		}
+	}
+	return collapseValueLists(b.String())
+}
+
+func isIdentifierRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '$'
+}
+
+func precededByIdentifier(rs []rune, i int) bool {
+	return i > 0 && (isIdentifierRune(rs[i-1]) || rs[i-1] == '.' && i > 1 && isIdentifierRune(rs[i-2]))
+}
+
+// collapseValueLists collapses "(?,?,?)" to "(?)".
+func collapseValueLists(s string) string {
+	const single = "(" + SQLPlaceholder + ")"
+	const repeated = SQLPlaceholder + "," + SQLPlaceholder
+	for strings.Contains(s, repeated) {
+		s = strings.Replace(s, repeated, SQLPlaceholder, -1)
+	}
+	for strings.Contains(s, single+","+single) {
+		s = strings.Replace(s, single+","+single, single, -1)
+	}
+	return s
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Redis returns the resource name of a Redis command, in the form of "CMD key-pattern".
+// Variable segments (separated by ':') of the key, such as numbers, UUIDs and hex ids,
+// are replaced with "*". An empty key results in the command only.
+func Redis(cmd, key string) string {
+	cmd = strings.ToUpper(strings.TrimSpace(cmd))
+	if key == "" {
+		return cmd
+	}
+	return cmd + " " + RedisKeyPattern(key)
+}
+
+// RedisKeyPattern returns the pattern of the Redis key.
+func RedisKeyPattern(key string) string {
+	segments := strings.Split(key, redisKeySeparator)
+	for i, seg := range segments {
+		if isVariableSegment(seg) {
+			segments[i] = KeyPatternWildcard
+		}
+	}
+	return strings.Join(segments, redisKeySeparator)
+}
+
+func isVariableSegment(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	digits, hex := true, true
+	for _, c := range seg {
+		if !unicode.IsDigit(c) {
+			digits = false
+		}
+		if !(unicode.IsDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == '-') {
+			hex = false
+		}
+	}
+	// Long hex strings are regarded as ids (e.g. UUIDs and digests).
+	return digits || hex && len(seg) >= 16
+}
+
+// MQ returns the resource name of the messages of a topic consumed by a group, in the form
+// of "group:topic" (the same as the Java SDK). An empty group results in the topic only.
+func MQ(group, topic string) string {
+	group, topic = strings.TrimSpace(group), strings.TrimSpace(topic)
+	if group == "" {
+		return topic
+	}
+	return group + ":" + topic
+}
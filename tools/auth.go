@@ -10,7 +10,7 @@ import (
 	"fmt"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
 	"os"
-	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -21,27 +21,70 @@ const (
 	Delimiter     = "="
 )
 
-var metaFile = path.Join(GetUserHome(), ".ahas-go.meta")
-var localSoleilKey = ""
-var localLuneKey = ""
+var metaFile = filepath.Join(GetUserHome(), ".ahas-go.meta")
 var mutex = sync.RWMutex{}
 
+// localKeys are the process-wide keys, which are saved to the meta file as well.
+var localKeys = &Keys{}
+
+// Keys is the key pair issued by the AHAS server on registration, which signs the requests
+// to the server and authenticates the ones from it.
+type Keys struct {
+	mux       sync.RWMutex
+	soleilKey string
+	luneKey   string
+}
+
+// Set replaces the key pair.
+func (k *Keys) Set(soleilKey, luneKey string) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.soleilKey = soleilKey
+	k.luneKey = luneKey
+}
+
+func (k *Keys) SoleilKey() string {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return k.soleilKey
+}
+
+func (k *Keys) LuneKey() string {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return k.luneKey
+}
+
+func (k *Keys) Sign(signData string) string {
+	sum256 := sha256.Sum256([]byte((signData + k.LuneKey())))
+	encodeToString := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%x", string(sum256[:]))))
+	return encodeToString
+}
+
+func (k *Keys) Auth(sign, signData string) bool {
+	expectSign := k.Sign(signData)
+	if expectSign != sign {
+		logger.Warnf("Sign not equal. ExpectSign: %s, receiveSign: %s", expectSign, sign)
+		return false
+	}
+	return true
+}
+
+// LocalKeys returns the process-wide keys, which are set by SaveMetadataToFile.
+func LocalKeys() *Keys {
+	return localKeys
+}
+
 func GetSoleilKey() string {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	return localSoleilKey
+	return localKeys.SoleilKey()
 }
 
 func GetLuneKey() string {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	return localLuneKey
+	return localKeys.LuneKey()
 }
 
 func Sign(signData string) string {
-	sum256 := sha256.Sum256([]byte((signData + localLuneKey)))
-	encodeToString := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%x", string(sum256[:]))))
-	return encodeToString
+	return localKeys.Sign(signData)
 }
 
 func splitToChunk(encodeString string, size int) string {
@@ -59,12 +102,7 @@ func splitToChunk(encodeString string, size int) string {
 }
 
 func Auth(sign, signData string) bool {
-	expectSign := Sign(signData)
-	if expectSign != sign {
-		logger.Warnf("Sign not equal. ExpectSign: %s, receiveSign: %s", expectSign, sign)
-		return false
-	}
-	return true
+	return localKeys.Auth(sign, signData)
 }
 
 func SaveMetadataToFile(k1, k2 string) error {
@@ -89,8 +127,7 @@ func SaveMetadataToFile(k1, k2 string) error {
 	if err != nil {
 		return err
 	}
-	localSoleilKey = k1
-	localLuneKey = k2
+	localKeys.Set(k1, k2)
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"os"
 	"os/user"
 )
 
@@ -53,13 +54,17 @@ func InitConstant(environment, regionId string) {
 	}
 }
 
-// GetUserHome return user home.
+// GetUserHome return user home, or the temp directory if the home is unknown.
 func GetUserHome() string {
 	user, err := user.Current()
-	if err == nil {
+	if err == nil && user.HomeDir != "" {
 		return user.HomeDir
 	}
-	return "/root"
+	// $HOME on Unix, and %USERPROFILE% on Windows.
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	return os.TempDir()
 }
 
 func IsPublicEnv(regionId string) bool {
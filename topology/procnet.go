@@ -0,0 +1,150 @@
+package topology
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	tcpEstablished = "01"
+	tcpListen      = "0A"
+)
+
+// procNetSampler samples the TCP connections of the process from /proc/net, where the outbound
+// ones are those whose local ports are not listened on.
+type procNetSampler struct {
+	root string
+}
+
+// procNetEntry is a line of /proc/net/tcp or /proc/net/tcp6.
+type procNetEntry struct {
+	localPort  int
+	remoteIp   net.IP
+	remotePort int
+	state      string
+	inode      string
+}
+
+func (s *procNetSampler) Sample() ([]Connection, error) {
+	inodes, err := s.socketInodes()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]procNetEntry, 0)
+	for _, name := range []string{"tcp", "tcp6"} {
+		es, err := readProcNet(filepath.Join(s.root, "self", "net", name))
+		if err != nil {
+			if os.IsNotExist(err) && name == "tcp6" {
+				continue
+			}
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	listening := make(map[int]bool)
+	for _, e := range entries {
+		if e.state == tcpListen {
+			listening[e.localPort] = true
+		}
+	}
+	conns := make([]Connection, 0)
+	for _, e := range entries {
+		if e.state != tcpEstablished || listening[e.localPort] || !inodes[e.inode] || e.remoteIp.IsLoopback() {
+			continue
+		}
+		conns = append(conns, Connection{Protocol: "tcp", RemoteIp: e.remoteIp.String(), RemotePort: e.remotePort})
+	}
+	return conns, nil
+}
+
+// socketInodes returns the inodes of the sockets opened by the process, as /proc/net covers
+// all the processes of the network namespace.
+func (s *procNetSampler) socketInodes() (map[string]bool, error) {
+	dir := filepath.Join(s.root, "self", "fd")
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the file descriptors")
+	}
+	fds, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the file descriptors")
+	}
+	inodes := make(map[string]bool)
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(dir, fd))
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(link, "socket:[") && strings.HasSuffix(link, "]") {
+			inodes[link[len("socket:["):len(link)-1]] = true
+		}
+	}
+	return inodes, nil
+}
+
+func readProcNet(path string) ([]procNetEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries := make([]procNetEntry, 0)
+	scanner := bufio.NewScanner(f)
+	// Skip the header.
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		_, localPort, err := parseProcNetAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteIp, remotePort, err := parseProcNetAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, procNetEntry{
+			localPort:  localPort,
+			remoteIp:   remoteIp,
+			remotePort: remotePort,
+			state:      fields[3],
+			inode:      fields[9],
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// parseProcNetAddr parses the address like "0100007F:1F90", whose IP is in the host byte order
+// (little-endian) of each 32-bit word.
+func parseProcNetAddr(s string) (net.IP, int, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, 0, errors.Errorf("bad address: %s", s)
+	}
+	bs, err := hex.DecodeString(s[:i])
+	if err != nil || len(bs) != net.IPv4len && len(bs) != net.IPv6len {
+		return nil, 0, errors.Errorf("bad address: %s", s)
+	}
+	for w := 0; w+4 <= len(bs); w += 4 {
+		bs[w], bs[w+1], bs[w+2], bs[w+3] = bs[w+3], bs[w+2], bs[w+1], bs[w]
+	}
+	port, err := strconv.ParseUint(s[i+1:], 16, 16)
+	if err != nil {
+		return nil, 0, errors.Errorf("bad address: %s", s)
+	}
+	ip := net.IP(bs)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return ip, int(port), nil
+}
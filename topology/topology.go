@@ -0,0 +1,233 @@
+// Package topology samples the outbound connections of the process, and reports the peer
+// endpoints to AHAS, so that the console could draw the dependency topology of the service.
+//
+// The connections are sampled from /proc/net by default (on Linux only), and the other
+// samplers (e.g. based on eBPF) could be plugged in by SetSampler.
+package topology
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sentinel-golang/util"
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+	"github.com/aliyun/aliyun-ahas-go-sdk/transport"
+	"github.com/pkg/errors"
+)
+
+const (
+	ReportServerName = transport.Topology
+	// ReportHandlerName is the upstream handler receiving the peers, which is
+	// only invoked if the server acknowledges transport.CapabilityDependencies.
+	ReportHandlerName = "dependencies"
+
+	DefaultSampleIntervalMs = 10 * 1000
+	DefaultReportIntervalMs = 60 * 1000
+	DefaultMaxPeers         = 256
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// SampleIntervalMs is the interval of sampling the connections. DefaultSampleIntervalMs will be used if absent.
+	SampleIntervalMs uint64 `yaml:"sampleIntervalMs"`
+	// ReportIntervalMs is the interval of reporting the peers. DefaultReportIntervalMs will be used if absent.
+	ReportIntervalMs uint64 `yaml:"reportIntervalMs"`
+	// MaxPeers is the maximum count of the peers reported, the ones of the most connections.
+	// DefaultMaxPeers will be used if absent.
+	MaxPeers int `yaml:"maxPeers"`
+}
+
+// Connection is an outbound connection of the process.
+type Connection struct {
+	Protocol string
+	RemoteIp string
+	// RemotePort is the port of the peer.
+	RemotePort int
+}
+
+// Peer is a peer endpoint of the outbound connections within the report interval.
+type Peer struct {
+	Protocol string `json:"protocol"`
+	Ip       string `json:"ip"`
+	Port     int    `json:"port"`
+	// Connections is the maximum count of the connections to the peer in the samples.
+	Connections int `json:"connections"`
+	// LastSeenMs is the timestamp of the latest sample with the connections to the peer.
+	LastSeenMs uint64 `json:"lastSeenMs"`
+}
+
+// Sampler samples the current outbound connections of the process.
+type Sampler interface {
+	Sample() ([]Connection, error)
+}
+
+var (
+	reportMux = &sync.Mutex{}
+	stopCh    chan struct{}
+	sampler   Sampler = &procNetSampler{root: "/proc"}
+
+	latestMux = &sync.RWMutex{}
+	latest    = make([]Peer, 0)
+)
+
+// SetSampler replaces the sampler of the connections, which must be called before StartReporter.
+func SetSampler(s Sampler) {
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if s != nil {
+		sampler = s
+	}
+}
+
+// Latest returns the peers of the latest report interval.
+func Latest() []Peer {
+	latestMux.RLock()
+	defer latestMux.RUnlock()
+	return latest
+}
+
+// StartReporter starts sampling the connections, and reporting the peers of each interval to AHAS.
+func StartReporter(conf Config, tsp *transport.Transport) {
+	if !conf.Enabled {
+		return
+	}
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if stopCh != nil {
+		return
+	}
+	if conf.SampleIntervalMs == 0 {
+		conf.SampleIntervalMs = DefaultSampleIntervalMs
+	}
+	if conf.ReportIntervalMs == 0 {
+		conf.ReportIntervalMs = DefaultReportIntervalMs
+	}
+	if conf.MaxPeers <= 0 {
+		conf.MaxPeers = DefaultMaxPeers
+	}
+	if _, err := sampler.Sample(); err != nil {
+		logger.Warnf("Topology reporter disabled as the connections can't be sampled: %+v", err)
+		return
+	}
+	stopCh = make(chan struct{})
+	go runReporter(conf, sampler, tsp, stopCh)
+	logger.Infof("Topology reporter started, sample interval: %dms, report interval: %dms",
+		conf.SampleIntervalMs, conf.ReportIntervalMs)
+}
+
+// StopReporter stops the running reporter.
+func StopReporter() {
+	reportMux.Lock()
+	defer reportMux.Unlock()
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	stopCh = nil
+}
+
+type peerKey struct {
+	protocol string
+	ip       string
+	port     int
+}
+
+func runReporter(conf Config, s Sampler, tsp *transport.Transport, stop chan struct{}) {
+	defer tools.PrintPanicStackV2("topology reporter")
+
+	sampleTicker := time.NewTicker(time.Duration(conf.SampleIntervalMs) * time.Millisecond)
+	defer sampleTicker.Stop()
+	reportTicker := time.NewTicker(time.Duration(conf.ReportIntervalMs) * time.Millisecond)
+	defer reportTicker.Stop()
+	peers := make(map[peerKey]*Peer)
+	for {
+		select {
+		case <-sampleTicker.C:
+			conns, err := s.Sample()
+			if err != nil {
+				logger.Warnf("Failed to sample the connections: %+v", err)
+				continue
+			}
+			merge(peers, conns, util.CurrentTimeMillis())
+		case <-reportTicker.C:
+			result := topPeers(peers, conf.MaxPeers)
+			peers = make(map[peerKey]*Peer)
+			latestMux.Lock()
+			latest = result
+			latestMux.Unlock()
+			if len(result) == 0 {
+				continue
+			}
+			if err := report(tsp, result); err != nil {
+				logger.Warnf("Failed to report topology: %+v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// merge merges the connections of a sample into the peers.
+func merge(peers map[peerKey]*Peer, conns []Connection, now uint64) {
+	counts := make(map[peerKey]int)
+	for _, c := range conns {
+		counts[peerKey{protocol: c.Protocol, ip: c.RemoteIp, port: c.RemotePort}]++
+	}
+	for k, n := range counts {
+		p, ok := peers[k]
+		if !ok {
+			p = &Peer{Protocol: k.protocol, Ip: k.ip, Port: k.port}
+			peers[k] = p
+		}
+		if n > p.Connections {
+			p.Connections = n
+		}
+		p.LastSeenMs = now
+	}
+}
+
+// topPeers returns the peers of the most connections.
+func topPeers(peers map[peerKey]*Peer, max int) []Peer {
+	result := make([]Peer, 0, len(peers))
+	for _, p := range peers {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Connections != result[j].Connections {
+			return result[i].Connections > result[j].Connections
+		}
+		if result[i].Ip != result[j].Ip {
+			return result[i].Ip < result[j].Ip
+		}
+		return result[i].Port < result[j].Port
+	})
+	if len(result) > max {
+		result = result[:max]
+	}
+	return result
+}
+
+func report(tsp *transport.Transport, peers []Peer) error {
+	if !tsp.SupportsUpstream(transport.CapabilityDependencies) {
+		return nil
+	}
+	bs, err := json.Marshal(peers)
+	if err != nil {
+		return err
+	}
+	request := transport.NewRequest()
+	request.AddParam("peers", string(bs))
+	uri := transport.NewUri(ReportServerName, ReportHandlerName)
+	uri.CompressVersion = transport.AllCompress
+	response, err := tsp.Invoke(uri, request)
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return errors.Errorf("bad response, code: %d, error: %s", response.Code, response.Error)
+	}
+	return nil
+}
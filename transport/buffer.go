@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBufferBytes is the capacity limit of the buffers put back to the pool,
+// so that occasional large payloads won't pin memory.
+const maxPooledBufferBytes = 1 << 20
+
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+	gzipWriterPool = sync.Pool{
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	}
+)
+
+// AcquireBuffer gets an empty buffer from the pool. The buffer should be
+// returned via ReleaseBuffer once its content is no longer referenced.
+func AcquireBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// ReleaseBuffer returns the buffer to the pool.
+func ReleaseBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBufferBytes {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// marshalJSON encodes the value with a pooled buffer, which saves the intermediate
+// allocations of json.Marshal. The result is the same as json.Marshal.
+func marshalJSON(v interface{}) (string, error) {
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return "", err
+	}
+	// Trim the newline appended by the encoder.
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})), nil
+}
+
+// gzipEncode compresses the data with a pooled gzip writer and buffer.
+func gzipEncode(data []byte) ([]byte, error) {
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+	zw := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(zw)
+	zw.Reset(buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	result := make([]byte, buf.Len())
+	copy(result, buf.Bytes())
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+// benchMetricItem is shaped like the metric items fetched by the console.
+type benchMetricItem struct {
+	Resource   string `json:"resource"`
+	Timestamp  uint64 `json:"timestamp"`
+	PassQps    uint64 `json:"passQps"`
+	BlockQps   uint64 `json:"blockQps"`
+	SuccessQps uint64 `json:"successQps"`
+	ErrorQps   uint64 `json:"errorQps"`
+	AvgRt      uint64 `json:"avgRt"`
+	Concurrent uint64 `json:"concurrent"`
+}
+
+func benchMetricItems(n int) []benchMetricItem {
+	items := make([]benchMetricItem, n)
+	for i := range items {
+		items[i] = benchMetricItem{
+			Resource:   "GET:/api/v1/resources/" + strconv.Itoa(i%50),
+			Timestamp:  1600000000000 + uint64(i)*1000,
+			PassQps:    uint64(i * 7 % 1000),
+			BlockQps:   uint64(i % 13),
+			SuccessQps: uint64(i * 7 % 1000),
+			AvgRt:      uint64(i % 200),
+			Concurrent: uint64(i % 30),
+		}
+	}
+	return items
+}
+
+func BenchmarkJSONMarshal(b *testing.B) {
+	items := benchMetricItems(500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bs, err := json.Marshal(items)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = string(bs)
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	items := benchMetricItems(500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := marshalJSON(items); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGzipEncode(b *testing.B) {
+	data, err := json.Marshal(benchMetricItems(500))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := gzipEncode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkContentEncoderEncode(b *testing.B) {
+	payload, err := marshalJSON(benchMetricItems(500))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// The budget is large enough that every payload is compressed.
+	e := NewContentEncoder(EncodingConfig{
+		Preferred:            []string{EncodingGzip},
+		CpuBudgetMsPerSecond: 1000,
+	})
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if content := e.Encode(EncodingGzip, payload); content.ContentEncoding != EncodingGzip {
+			b.Fatalf("unexpected content encoding: %s", content.ContentEncoding)
+		}
+	}
+}
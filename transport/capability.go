@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"strings"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+const (
+	// CapabilitiesParam is the param of the connect request and response carrying the capabilities,
+	// which are advertised by the client and acknowledged by the server.
+	CapabilitiesParam = "capabilities"
+
+	// CapabilityRequestCompress means the gzip compression of the request bodies (e.g. heartbeats).
+	CapabilityRequestCompress = "requestCompress"
+
+	// The capabilities below are the upstream handlers (and the commands) beyond the basic
+	// protocol, which are only used once the server acknowledges them.
+
+	// CapabilityMetricUpload means the "uploadMetric" handler receiving the resource metrics,
+	// rather than the console fetching them by the "metric" command.
+	CapabilityMetricUpload = "uploadMetric"
+	// CapabilityBlockLog means the "blockLog" handler receiving the sampled block events.
+	CapabilityBlockLog = "blockLog"
+	// CapabilityBreakerEvent means the "breakerEvent" handler receiving the state transitions
+	// of the circuit breakers.
+	CapabilityBreakerEvent = "breakerEvent"
+	// CapabilityHotParam means the "hotParam" handler receiving the hottest parameter values.
+	CapabilityHotParam = "hotParam"
+	// CapabilityDependencies means the "dependencies" handler receiving the outbound connections.
+	CapabilityDependencies = "dependencies"
+	// CapabilityRulePush means the console pushing the rules by the "pushRules" command, which
+	// reads the "ruleDelivery" heartbeat param.
+	CapabilityRulePush = "rulePush"
+)
+
+// clientCapabilities are the capabilities supported by current client.
+var clientCapabilities = []string{
+	CapabilityRequestCompress,
+	CapabilityMetricUpload,
+	CapabilityBlockLog,
+	CapabilityBreakerEvent,
+	CapabilityHotParam,
+	CapabilityDependencies,
+	CapabilityRulePush,
+}
+
+// Supports returns whether the capability has been acknowledged by the server on registration.
+func (t *Transport) Supports(capability string) bool {
+	t.stateMux.Lock()
+	defer t.stateMux.Unlock()
+	return t.capabilities[capability]
+}
+
+// SupportsUpstream returns whether the capability of an upstream handler has been acknowledged
+// by the server, which logs once for each unsupported one, so that the callers could simply
+// skip the requests.
+func (t *Transport) SupportsUpstream(capability string) bool {
+	t.stateMux.Lock()
+	defer t.stateMux.Unlock()
+	if t.capabilities[capability] {
+		return true
+	}
+	if !t.unsupportedLogged[capability] {
+		if t.unsupportedLogged == nil {
+			t.unsupportedLogged = make(map[string]bool)
+		}
+		t.unsupportedLogged[capability] = true
+		logger.Infof("The AHAS server doesn't support %s, the requests are skipped", capability)
+	}
+	return false
+}
+
+// setCapabilities records the capabilities acknowledged in the connect response. The old servers
+// without the capabilities in the response support none of them.
+func (t *Transport) setCapabilities(result interface{}) {
+	acked := make(map[string]bool)
+	if v, ok := result.(map[string]interface{}); ok {
+		if s, ok := v[CapabilitiesParam].(string); ok {
+			for _, c := range strings.Split(s, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					acked[c] = true
+				}
+			}
+		}
+	}
+	t.stateMux.Lock()
+	defer t.stateMux.Unlock()
+	t.capabilities = acked
+}
@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+)
+
+const (
+	EncodingZstd     = "zstd"
+	EncodingGzip     = "gzip"
+	EncodingIdentity = "identity"
+
+	// AcceptEncodingParam is the request parameter carrying the content-encodings accepted by the server.
+	AcceptEncodingParam = "acceptEncoding"
+
+	DefaultMinCompressBytes             = 4096
+	DefaultCompressCpuBudgetMsPerSecond = 50
+)
+
+// Codec compresses the payload with a specific content-encoding.
+type Codec interface {
+	Encode(data []byte) ([]byte, error)
+}
+
+type gzipCodec struct {
+}
+
+func (c *gzipCodec) Encode(data []byte) ([]byte, error) {
+	return gzipEncode(data)
+}
+
+var (
+	codecMux = &sync.RWMutex{}
+	codecs   = map[string]Codec{
+		EncodingGzip: &gzipCodec{},
+	}
+)
+
+// RegisterCodec registers the codec of the content-encoding, e.g. a zstd codec.
+func RegisterCodec(encoding string, codec Codec) {
+	if encoding == "" || codec == nil {
+		return
+	}
+	codecMux.Lock()
+	defer codecMux.Unlock()
+	codecs[encoding] = codec
+}
+
+func getCodec(encoding string) (Codec, bool) {
+	codecMux.RLock()
+	defer codecMux.RUnlock()
+	c, ok := codecs[encoding]
+	return c, ok
+}
+
+type EncodingConfig struct {
+	// Preferred is the content-encodings in order of preference. Default: zstd, gzip.
+	Preferred []string `yaml:"preferred"`
+	// MinCompressBytes is the minimum payload size to compress.
+	MinCompressBytes int `yaml:"minCompressBytes"`
+	// CpuBudgetMsPerSecond is the maximum time spent on compression per second.
+	// Payloads will be sent uncompressed once the budget is exhausted.
+	CpuBudgetMsPerSecond int64 `yaml:"cpuBudgetMsPerSecond"`
+}
+
+// EncodedContent is the result of content-encoding negotiation. The compressed content is base64-encoded.
+type EncodedContent struct {
+	ContentEncoding string `json:"contentEncoding"`
+	Content         string `json:"content"`
+}
+
+// ContentEncoder negotiates the content-encoding with the server and compresses
+// the payloads within the CPU budget.
+type ContentEncoder struct {
+	preferred []string
+	minBytes  int
+	budget    time.Duration
+
+	mux         sync.Mutex
+	windowStart time.Time
+	used        time.Duration
+}
+
+func NewContentEncoder(conf EncodingConfig) *ContentEncoder {
+	preferred := conf.Preferred
+	if len(preferred) == 0 {
+		preferred = []string{EncodingZstd, EncodingGzip}
+	}
+	minBytes := conf.MinCompressBytes
+	if minBytes <= 0 {
+		minBytes = DefaultMinCompressBytes
+	}
+	budgetMs := conf.CpuBudgetMsPerSecond
+	if budgetMs <= 0 {
+		budgetMs = DefaultCompressCpuBudgetMsPerSecond
+	}
+	return &ContentEncoder{
+		preferred: preferred,
+		minBytes:  minBytes,
+		budget:    time.Duration(budgetMs) * time.Millisecond,
+	}
+}
+
+// Negotiate picks the most preferred content-encoding which is both accepted by
+// the server and supported locally. It falls back to identity if none matches.
+func (e *ContentEncoder) Negotiate(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, enc := range strings.Split(acceptEncoding, ",") {
+		// Ignore the quality values, e.g. "gzip;q=0.8".
+		enc = strings.TrimSpace(strings.SplitN(enc, ";", 2)[0])
+		if enc != "" {
+			accepted[strings.ToLower(enc)] = true
+		}
+	}
+	for _, enc := range e.preferred {
+		if !accepted[enc] || (enc == EncodingZstd && !feature.Enabled(feature.Zstd)) {
+			continue
+		}
+		if _, ok := getCodec(enc); ok {
+			return enc
+		}
+	}
+	return EncodingIdentity
+}
+
+// Encode compresses the payload with the negotiated content-encoding.
+func (e *ContentEncoder) Encode(acceptEncoding string, payload string) *EncodedContent {
+	identity := &EncodedContent{ContentEncoding: EncodingIdentity, Content: payload}
+	if len(payload) < e.minBytes || !feature.Enabled(feature.MetricCompression) {
+		return identity
+	}
+	enc := e.Negotiate(acceptEncoding)
+	codec, ok := getCodec(enc)
+	if !ok || !e.acquireBudget() {
+		return identity
+	}
+	start := time.Now()
+	data, err := codec.Encode([]byte(payload))
+	e.consumeBudget(time.Since(start))
+	if err != nil {
+		return identity
+	}
+	return &EncodedContent{
+		ContentEncoding: enc,
+		Content:         base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+func (e *ContentEncoder) acquireBudget() bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	now := time.Now()
+	if now.Sub(e.windowStart) >= time.Second {
+		e.windowStart = now
+		e.used = 0
+	}
+	return e.used < e.budget
+}
+
+func (e *ContentEncoder) consumeBudget(d time.Duration) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.used += d
+}
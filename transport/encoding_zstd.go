@@ -0,0 +1,28 @@
+//go:build ahas_zstd
+// +build ahas_zstd
+
+package transport
+
+import (
+	"github.com/aliyun/aliyun-ahas-go-sdk/feature"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The zstd codec requires github.com/klauspost/compress, and is only built with the ahas_zstd tag.
+func init() {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		return
+	}
+	RegisterCodec(EncodingZstd, &zstdCodec{encoder: encoder})
+	feature.SetCompiled(feature.Zstd, true)
+}
+
+type zstdCodec struct {
+	encoder *zstd.Encoder
+}
+
+func (c *zstdCodec) Encode(data []byte) ([]byte, error) {
+	// Metric payloads usually compress well, so preallocate a quarter of the input size.
+	return c.encoder.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
 	"github.com/aliyun/aliyun-ahas-go-sdk/service"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
 )
 
 type RequestHandler interface {
@@ -27,7 +28,7 @@ func (handler *AgwRequestHandler) DoStop() error {
 //NewCommonHandler with default interceptor
 func NewCommonHandler(handler RequestHandler) AgwRequestHandler {
 	requestHandler := AgwRequestHandler{
-		Interceptor: buildInterceptor(),
+		Interceptor: buildInterceptor(tools.LocalKeys()),
 		Handler:     handler,
 	}
 	requestHandler.Controller = service.NewController(&requestHandler)
@@ -59,9 +60,5 @@ func (handler *AgwRequestHandler) Handle(request string) (string, error) {
 		}
 	}
 	// encode
-	bytes, err := json.Marshal(response)
-	if err != nil {
-		return "", err
-	}
-	return string(bytes), nil
+	return marshalJSON(response)
 }
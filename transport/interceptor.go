@@ -60,6 +60,7 @@ func (interceptor *requestInterceptorChain) Invoke(request *Request) (*Response,
 
 type authInterceptor struct {
 	requestInterceptorChain
+	keys *tools.Keys
 }
 
 func (authInterceptor *authInterceptor) doHandler(request *Request) (*Response, bool) {
@@ -69,7 +70,7 @@ func (authInterceptor *authInterceptor) doHandler(request *Request) (*Response,
 		return ReturnFail(Code[Forbidden], "missing sign"), false
 	}
 	soleilKey := request.Headers[SoleilKey]
-	if soleilKey != "" && soleilKey != tools.GetSoleilKey() {
+	if soleilKey != "" && soleilKey != authInterceptor.keys.SoleilKey() {
 		return ReturnFail(Code[Forbidden], "soleilKey not matched"), false
 	}
 	signData := request.Headers[SignData]
@@ -80,15 +81,15 @@ func (authInterceptor *authInterceptor) doHandler(request *Request) (*Response,
 		}
 		signData = string(bytes)
 	}
-	if !tools.Auth(sign, signData) {
+	if !authInterceptor.keys.Auth(sign, signData) {
 		return ReturnFail(Code[Forbidden], "illegal request"), false
 	}
 	return nil, true
 }
 
 func (authInterceptor *authInterceptor) doInvoker(request *Request) (*Response, bool) {
-	soleilKey := tools.GetSoleilKey()
-	luneKey := tools.GetLuneKey()
+	soleilKey := authInterceptor.keys.SoleilKey()
+	luneKey := authInterceptor.keys.LuneKey()
 	if soleilKey == "" || luneKey == "" {
 		return ReturnFail(Code[TokenNotFound], "soleilKey or luneKey not found"), false
 	}
@@ -101,7 +102,7 @@ func (authInterceptor *authInterceptor) doInvoker(request *Request) (*Response,
 		}
 		signData = string(bytes)
 	}
-	sign := tools.Sign(signData)
+	sign := authInterceptor.keys.Sign(signData)
 	request.AddHeader(SignKey, sign)
 	return nil, true
 }
@@ -40,13 +40,13 @@ func (invoker *agwRequestInvoker) Invoke(uri Uri, request *Request) (*Response,
 	uri.RequestId = requestId
 
 	// encode
-	bytes, err := json.Marshal(request)
+	payload, err := marshalJSON(request)
 	if err != nil {
 		logger.Warnf("Marshal request to json error (%s, %s): %+v", uri.ServerName, uri.HandlerName, err)
 		return nil, err
 	}
 	// doInvoke
-	result, err := invoker.doInvoker(uri, string(bytes))
+	result, err := invoker.doInvoker(uri, payload)
 	if err != nil {
 		logger.Warnf("Invoke failed, requestId: %s, error: %s", requestId, err.Error())
 		return nil, err
@@ -68,10 +68,14 @@ type agwClientRequestInvoker struct {
 }
 
 func NewInvoker(client *gateway.AgwClient, needInterceptor bool) RequestInvoker {
+	return newInvoker(client, needInterceptor, tools.LocalKeys())
+}
+
+func newInvoker(client *gateway.AgwClient, needInterceptor bool, keys *tools.Keys) RequestInvoker {
 	//  Not need request interceptor when first connect,
 	var interceptor RequestInterceptor
 	if needInterceptor {
-		interceptor = buildInterceptor()
+		interceptor = buildInterceptor(keys)
 	} else {
 		interceptor = nil
 	}
@@ -87,9 +91,9 @@ func NewInvoker(client *gateway.AgwClient, needInterceptor bool) RequestInvoker
 	return invoker
 }
 
-func buildInterceptor() RequestInterceptor {
+func buildInterceptor(keys *tools.Keys) RequestInterceptor {
 	// auth
-	authInterceptor := &authInterceptor{}
+	authInterceptor := &authInterceptor{keys: keys}
 	chain := requestInterceptorChain{}
 	chain.chain = nil
 	chain.RequestInterceptor = &chain
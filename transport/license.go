@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+)
+
+// LicenseStatus is the status of the license (and the AHAS service of the account), which is
+// known from the responses of the registrations and the heartbeats.
+type LicenseStatus int32
+
+const (
+	// LicenseUnknown means the license has not been verified by the server yet.
+	LicenseUnknown LicenseStatus = iota
+	LicenseValid
+	// LicenseInvalid means the license is wrong, or the account is not authorized.
+	LicenseInvalid
+	LicenseExpired
+	// LicenseOverQuota means the instances of the account exceed the quota.
+	LicenseOverQuota
+	// LicenseServiceNotOpened means the AHAS service has not been opened for the account.
+	LicenseServiceNotOpened
+)
+
+func (s LicenseStatus) String() string {
+	switch s {
+	case LicenseUnknown:
+		return "unknown"
+	case LicenseValid:
+		return "valid"
+	case LicenseInvalid:
+		return "invalid"
+	case LicenseExpired:
+		return "expired"
+	case LicenseOverQuota:
+		return "overQuota"
+	case LicenseServiceNotOpened:
+		return "serviceNotOpened"
+	default:
+		return "unknown"
+	}
+}
+
+// LicenseError is returned when the registration is rejected for the license, e.g. by Init.
+type LicenseError struct {
+	Status LicenseStatus
+	// Code and Message are of the response of the server.
+	Code    int32
+	Message string
+}
+
+func (e *LicenseError) Error() string {
+	return fmt.Sprintf("AHAS license %s, code: %d, message: %s", e.Status, e.Code, e.Message)
+}
+
+// LicenseListener observes the changes of the license status, which is called synchronously,
+// so it should return quickly. The message is of the server, empty if the license is valid.
+type LicenseListener func(status LicenseStatus, message string)
+
+var (
+	licenseMux       = &sync.RWMutex{}
+	licenseStatus    = LicenseUnknown
+	licenseMessage   string
+	licenseListeners = make([]LicenseListener, 0)
+)
+
+// RegisterLicenseListener registers the listener of the license status changes.
+func RegisterLicenseListener(l LicenseListener) {
+	if l == nil {
+		return
+	}
+	licenseMux.Lock()
+	defer licenseMux.Unlock()
+	licenseListeners = append(licenseListeners, l)
+}
+
+// CurrentLicenseStatus returns the latest known status of the license, and the message of the server.
+func CurrentLicenseStatus() (LicenseStatus, string) {
+	licenseMux.RLock()
+	defer licenseMux.RUnlock()
+	return licenseStatus, licenseMessage
+}
+
+// ObserveLicense updates the license status by the response (e.g. of the heartbeats), and returns
+// the LicenseError if the response is rejected for the license.
+func ObserveLicense(response *Response) *LicenseError {
+	return observeLicense(response, setLicenseStatus)
+}
+
+// ObserveLicense is the ObserveLicense of the transport, which keeps the status to itself if isolated.
+func (t *Transport) ObserveLicense(response *Response) *LicenseError {
+	if !t.isolated {
+		return ObserveLicense(response)
+	}
+	return observeLicense(response, func(s LicenseStatus, message string) {
+		t.licenseMux.Lock()
+		defer t.licenseMux.Unlock()
+		t.licenseStatus, t.licenseMessage = s, message
+	})
+}
+
+// LicenseStatus returns the latest known status of the license of the transport, see CurrentLicenseStatus.
+func (t *Transport) LicenseStatus() (LicenseStatus, string) {
+	if !t.isolated {
+		return CurrentLicenseStatus()
+	}
+	t.licenseMux.RLock()
+	defer t.licenseMux.RUnlock()
+	return t.licenseStatus, t.licenseMessage
+}
+
+func observeLicense(response *Response, setStatus func(s LicenseStatus, message string)) *LicenseError {
+	if response.Success {
+		setStatus(LicenseValid, "")
+		return nil
+	}
+	e := licenseErrorOf(response)
+	if e != nil {
+		setStatus(e.Status, e.Message)
+	}
+	return e
+}
+
+// licenseErrorOf returns the LicenseError of the failed response, or nil if the failure is not
+// related to the license. The server tells the expiration and the quota by the messages only.
+func licenseErrorOf(response *Response) *LicenseError {
+	var status LicenseStatus
+	switch response.Code {
+	case Code[ServiceNotOpened].Code:
+		status = LicenseServiceNotOpened
+	case Code[ServiceNotAuthorized].Code, Code[Forbidden].Code, Code[TokenNotFound].Code:
+		msg := strings.ToLower(response.Error)
+		switch {
+		case strings.Contains(msg, "expire"):
+			status = LicenseExpired
+		case strings.Contains(msg, "quota") || strings.Contains(msg, "exceed"):
+			status = LicenseOverQuota
+		default:
+			status = LicenseInvalid
+		}
+	default:
+		return nil
+	}
+	return &LicenseError{Status: status, Code: response.Code, Message: response.Error}
+}
+
+func setLicenseStatus(s LicenseStatus, message string) {
+	licenseMux.Lock()
+	from := licenseStatus
+	licenseStatus, licenseMessage = s, message
+	licenseMux.Unlock()
+	if from == s {
+		return
+	}
+	if s == LicenseValid {
+		logger.Infof("AHAS license status changed from %s to %s", from, s)
+	} else {
+		logger.Errorf("AHAS license status changed from %s to %s, the rules may stop being updated: %s", from, s, message)
+	}
+	licenseMux.RLock()
+	defer licenseMux.RUnlock()
+	for _, l := range licenseListeners {
+		l(s, message)
+	}
+}
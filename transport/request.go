@@ -19,6 +19,8 @@ const (
 var (
 	NoCompress  = fmt.Sprintf("%d", gateway.NoCompress)
 	AllCompress = fmt.Sprintf("%d", gateway.AllCompress)
+	// RequestCompress compresses the request body only.
+	RequestCompress = fmt.Sprintf("%d", gateway.RequestCompress)
 )
 
 type Request struct {
@@ -0,0 +1,126 @@
+package transport
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
+	"github.com/aliyun/aliyun-ahas-go-sdk/tools"
+)
+
+// ConnState is the state of the connection (registration) to the AHAS gateway.
+type ConnState int32
+
+const (
+	StateConnecting ConnState = iota
+	StateConnected
+	StateDisconnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// StateListener observes the state changes of the connection.
+type StateListener func(from, to ConnState)
+
+var (
+	listenerMux    = &sync.RWMutex{}
+	stateListeners = make([]StateListener, 0)
+)
+
+// RegisterStateListener registers the listener of the connection state changes, which is
+// called synchronously, so it should return quickly. The isolated transports are left out,
+// see WithIsolatedState.
+func RegisterStateListener(l StateListener) {
+	listenerMux.Lock()
+	defer listenerMux.Unlock()
+	stateListeners = append(stateListeners, l)
+}
+
+// State returns the current state of the connection.
+func (t *Transport) State() ConnState {
+	t.stateMux.Lock()
+	defer t.stateMux.Unlock()
+	return t.state
+}
+
+func (t *Transport) setState(s ConnState) {
+	t.stateMux.Lock()
+	from := t.state
+	t.state = s
+	t.stateMux.Unlock()
+	if from == s {
+		return
+	}
+	logger.Infof("AGW transport state changed from %s to %s", from, s)
+	if t.isolated {
+		return
+	}
+	listenerMux.RLock()
+	defer listenerMux.RUnlock()
+	for _, l := range stateListeners {
+		l(from, s)
+	}
+}
+
+// Disconnected marks the connection as dropped (e.g. the heartbeats keep failing), and reconnects
+// in the background with capped exponential backoff and jitter, until registered again.
+func (t *Transport) Disconnected(cause error) {
+	t.stateMux.Lock()
+	if t.reconnecting || t.stopped {
+		t.stateMux.Unlock()
+		return
+	}
+	t.reconnecting = true
+	t.stateMux.Unlock()
+
+	logger.Warnf("AGW transport disconnected: %v", cause)
+	t.setState(StateDisconnected)
+	go t.reconnectLoop()
+}
+
+func (t *Transport) reconnectLoop() {
+	defer tools.PrintPanicStackV2("AGW transport reconnection")
+	defer func() {
+		t.stateMux.Lock()
+		t.reconnecting = false
+		t.stateMux.Unlock()
+	}()
+	backoff := minReconnectBackoff
+	for {
+		// Full jitter in [backoff/2, backoff), to avoid the reconnection storm after a gateway outage.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+		select {
+		case <-time.After(delay):
+		case <-t.stopCh:
+			return
+		}
+		t.setState(StateConnecting)
+		err := t.Reconnect()
+		if err == nil {
+			t.setState(StateConnected)
+			return
+		}
+		logger.Warnf("Failed to reconnect AGW transport, will retry in about %v: %+v", backoff, err)
+		t.setState(StateDisconnected)
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TlsConfig is the TLS config of the connection to the AHAS gateway (see Config.Tls), or to
+// ACM (see datasource.Config.Tls). If absent, the server certificate downloaded from AHAS is
+// used for the gateway as before, and the system roots for ACM.
+type TlsConfig struct {
+	// CaFile is the PEM file of the root CAs to verify the gateway. The system roots are used if absent.
+	CaFile string `yaml:"caFile"`
+	// CertFile and KeyFile are the PEM files of the client certificate for mutual TLS (optional).
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ServerName is the name to verify the gateway certificate against. The gateway host is used if absent.
+	ServerName string `yaml:"serverName"`
+	// MinVersion is the minimum TLS version: 1.0, 1.1, 1.2 (default) or 1.3.
+	MinVersion string `yaml:"minVersion"`
+	// InsecureSkipVerify disables the verification of the gateway certificate, which is only for test labs.
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify"`
+}
+
+// Configured returns whether any of the TLS settings is present.
+func (c *TlsConfig) Configured() bool {
+	return c.CaFile != "" || c.CertFile != "" || c.KeyFile != "" || c.ServerName != "" ||
+		c.MinVersion != "" || c.InsecureSkipVerify
+}
+
+// Build builds the tls.Config, or returns nil if the TLS config is absent.
+func (c *TlsConfig) Build() (*tls.Config, error) {
+	if !c.Configured() {
+		return nil, nil
+	}
+	conf := &tls.Config{
+		ServerName:         c.ServerName,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, errors.Errorf("unsupported TLS version: %s", c.MinVersion)
+		}
+		conf.MinVersion = v
+	}
+	if c.CaFile != "" {
+		pem, err := ioutil.ReadFile(c.CaFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no valid certificate in CA file: %s", c.CaFile)
+		}
+		conf.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
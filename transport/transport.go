@@ -6,6 +6,7 @@ import (
 	sentinelConf "github.com/alibaba/sentinel-golang/core/config"
 	"github.com/aliyun/aliyun-ahas-go-sdk/logger"
 	"github.com/aliyun/aliyun-ahas-go-sdk/meta"
+	"net"
 	"net/http"
 	"runtime"
 	"strconv"
@@ -24,29 +25,83 @@ type Transport struct {
 	mutex    sync.Mutex
 	config   *Config
 	metadata *meta.Meta
+
+	stateMux     sync.Mutex
+	state        ConnState
+	reconnecting bool
+	stopped      bool
+	stopCh       chan struct{}
+	// capabilities are acknowledged by the server on registration.
+	capabilities map[string]bool
+	// unsupportedLogged are the capabilities which have been logged as unsupported by SupportsUpstream.
+	unsupportedLogged map[string]bool
+
+	// isolated indicates whether the state below is kept to the transport, see WithIsolatedState.
+	isolated bool
+	// keys are issued by the server on registration, which are the process-wide ones if not isolated.
+	keys           *tools.Keys
+	licenseMux     sync.RWMutex
+	licenseStatus  LicenseStatus
+	licenseMessage string
+}
+
+// Option is the option of New.
+type Option func(*Transport)
+
+// WithIsolatedState makes the transport keep the keys issued by the server and the license status
+// to itself, rather than in the process-wide state (and the key file) shared with the transport of
+// InitAhas*, and leaves out the process-wide state listeners, so that the transports of several
+// licenses could coexist in a process.
+func WithIsolatedState() Option {
+	return func(t *Transport) {
+		t.isolated = true
+		t.keys = &tools.Keys{}
+	}
 }
 
+// Shutdown stops the background reconnection and closes the connections to the gateway,
+// after which another transport could be created. It does nothing if called again.
 func (t *Transport) Shutdown() error {
+	t.stateMux.Lock()
+	if t.stopped {
+		t.stateMux.Unlock()
+		return nil
+	}
+	t.stopped = true
+	t.stateMux.Unlock()
+	close(t.stopCh)
+	t.client.Close()
+	t.setState(StateDisconnected)
 	return nil
 }
 
-func New(conf *Config, metadata *meta.Meta) (*Transport, error) {
+func New(conf *Config, metadata *meta.Meta, opts ...Option) (*Transport, error) {
 	if conf == nil {
 		return nil, errors.New("nil transport config")
 	}
 	if metadata == nil {
 		return nil, errors.New("nil metadata")
 	}
-	client := gateway.GetAgwClientInstance()
+	client := gateway.NewAgwClient()
 
-	hostAndPort := strings.SplitN(metadata.AhasEndpoint(), ":", 2)
-	port, err := strconv.Atoi(hostAndPort[1])
-	if err != nil {
-		return nil, err
+	endpoints := append(metadata.AhasEndpoints(), conf.FailoverEndpoints...)
+	if len(endpoints) == 0 {
+		return nil, errors.New("no available AHAS endpoint")
+	}
+	gateways := make([]gateway.GatewayAddr, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		addr, err := parseGatewayAddr(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		gateways = append(gateways, addr)
 	}
 	// TODO: privateIp
 	ip := metadata.Ip()
-	processFlag := meta.GoSDK + ":" + ip + ":" + metadata.Pid()
+	if err := checkClientIp(ip); err != nil {
+		return nil, err
+	}
+	processFlag := processFlagOf(ip, metadata.Pid())
 
 	if conf.TimeoutMs == 0 {
 		conf.TimeoutMs = 3000
@@ -55,39 +110,101 @@ func New(conf *Config, metadata *meta.Meta) (*Transport, error) {
 		ClientVpcId:       metadata.VpcId(),
 		ClientIp:          ip,
 		ClientProcessFlag: processFlag,
-		GatewayIp:         hostAndPort[0],
-		GatewayPort:       uint32(port),
+		GatewayIp:         gateways[0].Ip,
+		GatewayPort:       gateways[0].Port,
+		Failovers:         gateways[1:],
 		Timeout:           time.Duration(conf.TimeoutMs) * time.Millisecond,
+		FallbackDelay:     time.Duration(conf.FallbackDelayMs) * time.Millisecond,
 	}
 	secure := true
 	if secure {
 		agwConfig.ClientRegionId = metadata.RegionId()
-		agwConfig.ClientEnv = meta.DeployEnv()
+		agwConfig.ClientEnv = metadata.DeployEnv()
+		agwConfig.ClientInVpc = metadata.InVpc()
 		agwConfig.TlsFlag = true
 	}
-	err = client.Init(agwConfig)
+	tlsConfig, err := conf.Tls.Build()
 	if err != nil {
 		return nil, err
 	}
-	return &Transport{
+	agwConfig.TlsConfig = tlsConfig
+	if agwConfig.Proxy, err = conf.proxyFunc(); err != nil {
+		return nil, err
+	}
+	t := &Transport{
 		client:   client,
-		invoker:  NewInvoker(client, true),
 		handlers: make(map[string]*AgwRequestHandler),
 		mutex:    sync.Mutex{},
 		config:   conf,
 		metadata: metadata,
-	}, nil
+		state:    StateConnecting,
+		stopCh:   make(chan struct{}),
+		keys:     tools.LocalKeys(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.invoker = newInvoker(client, true, t.keys)
+	err = client.Init(agwConfig)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Metadata returns the metadata registered by the transport.
+func (t *Transport) Metadata() *meta.Meta {
+	return t.metadata
+}
+
+// Isolated returns whether the transport keeps its state to itself, see WithIsolatedState.
+func (t *Transport) Isolated() bool {
+	return t.isolated
+}
+
+func parseGatewayAddr(endpoint string) (gateway.GatewayAddr, error) {
+	// SplitHostPort also handles the bracketed IPv6 literals, e.g. "[::1]:9528".
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return gateway.GatewayAddr{}, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return gateway.GatewayAddr{}, err
+	}
+	return gateway.GatewayAddr{Ip: host, Port: uint32(port)}, nil
+}
+
+// checkClientIp checks that the client IP is an IPv4 address. The gateway protocol carries
+// IPv4 client addresses only, so the IPv6 addresses are reported by the heartbeats instead
+// (see meta.DualStack), and an IPv6-only host can't connect.
+func checkClientIp(ip string) error {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return fmt.Errorf("AHAS transport requires an IPv4 client address, but got %s; "+
+			"use the IP policy %s or %s on dual-stack hosts", ip, meta.PreferIPv4, meta.DualStack)
+	}
+	return nil
+}
+
+func processFlagOf(ip, pid string) string {
+	return meta.GoSDK + ":" + ip + ":" + pid
 }
 
 //addHandler register handler
 func (t *Transport) RegisterHandler(handlerName string, handler *AgwRequestHandler) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
+	if _, ok := handler.Interceptor.(*timestampInterceptor); ok && t.isolated {
+		// The built-in interceptor authenticates the requests with the process-wide keys.
+		h := *handler
+		h.Interceptor = buildInterceptor(t.keys)
+		handler = &h
+	}
 	if t.handlers[handlerName] == nil {
 		t.handlers[handlerName] = handler
 		t.client.AddHandler(handlerName, handler)
 	}
-	if meta.DebugEnabled() {
+	if t.metadata.DebugEnabled() {
 		http.HandleFunc("/ahas/"+handlerName, func(writer http.ResponseWriter, request *http.Request) {
 			request.ParseForm()
 			response, err := handler.Handle(request.Form["body"][0])
@@ -104,8 +221,10 @@ func (t *Transport) Start() (*Transport, error) {
 	err := t.connect()
 	if err != nil {
 		logger.Errorf("Connection to server failed: %+v", err)
+		t.setState(StateDisconnected)
 		return nil, err
 	}
+	t.setState(StateConnected)
 	logger.Info("AGW transport service started successfully")
 	return t, nil
 }
@@ -114,19 +233,40 @@ func (t *Transport) Stop() error {
 	return nil
 }
 
+// Reconnect registers to the server again with the current IP of the metadata,
+// which should be called after the IP changes.
+func (t *Transport) Reconnect() error {
+	ip := t.metadata.Ip()
+	if err := checkClientIp(ip); err != nil {
+		return err
+	}
+	if err := t.client.SetClientIdentity(ip, processFlagOf(ip, t.metadata.Pid())); err != nil {
+		return err
+	}
+	if err := t.connect(); err != nil {
+		return err
+	}
+	logger.Infof("Registered to the server again with the IP: %s", ip)
+	return nil
+}
+
 // Connect to remote
 func (t *Transport) connect() error {
 	// TODO
 	request := NewRequest()
 	request.AddParam("vpcId", t.metadata.VpcId()).AddParam("ip", t.metadata.Ip())
 	request.AddParam("pid", t.metadata.Pid()).AddParam("type", meta.GoSDK)
-	request.AddParam("appName", sentinelConf.AppName())
+	appName := t.metadata.AppName()
+	if appName == "" {
+		appName = sentinelConf.AppName()
+	}
+	request.AddParam("appName", appName)
 	request.AddParam("appType", strconv.Itoa(int(sentinelConf.AppType())))
-	request.AddParam("namespace", meta.Namespace())
+	request.AddParam("namespace", t.metadata.Namespace())
 
 	uid := t.metadata.Uid()
 	if uid == "" {
-		request.AddParam("ak", meta.License())
+		request.AddParam("ak", t.metadata.License())
 	} else {
 		request.AddParam("uid", uid)
 	}
@@ -137,6 +277,7 @@ func (t *Transport) connect() error {
 	request.AddParam("v", t.metadata.Version())
 	request.AddParam("hostIp", t.metadata.Ip())
 	request.AddParam("cpuNum", strconv.Itoa(runtime.NumCPU()))
+	request.AddParam(CapabilitiesParam, strings.Join(clientCapabilities, ","))
 
 	uri := NewUri(Topology, Connect)
 	invoker := NewInvoker(t.client, false)
@@ -144,17 +285,22 @@ func (t *Transport) connect() error {
 	if err != nil {
 		return err
 	}
-	return handleConnectResponse(*response, t.metadata)
+	if err = t.handleConnectResponse(*response); err != nil {
+		return err
+	}
+	t.setCapabilities(response.Result)
+	return nil
 }
 
 // Handle response: record ak/sk and uid information
-func handleConnectResponse(response Response, metadata *meta.Meta) error {
-	if !response.Success {
-		if response.Code == Code[ServiceNotOpened].Code {
+func (t *Transport) handleConnectResponse(response Response) error {
+	if licenseErr := t.ObserveLicense(&response); licenseErr != nil {
+		if licenseErr.Status == LicenseServiceNotOpened {
 			logger.Errorf("AHAS service not opened, please initiate it in the AHAS console")
-		} else if response.Code == Code[ServiceNotAuthorized].Code {
-			logger.Errorf("AHAS service not authorized")
 		}
+		return licenseErr
+	}
+	if !response.Success {
 		return errors.New(fmt.Sprintf("connect server failed, %s", response.Error))
 	}
 	result := response.Result
@@ -170,10 +316,14 @@ func handleConnectResponse(response Response, metadata *meta.Meta) error {
 		return errors.New("uid is empty")
 	}
 
-	metadata.SetUid(v[Uid].(string))
-	metadata.SetTid(v[Tid].(string))
-	metadata.SetCid(v[Aid].(string))
+	t.metadata.SetUid(v[Uid].(string))
+	t.metadata.SetTid(v[Tid].(string))
+	t.metadata.SetCid(v[Aid].(string))
 
+	if t.isolated {
+		t.keys.Set(v["ak"].(string), v["sk"].(string))
+		return nil
+	}
 	err := tools.SaveMetadataToFile(v["ak"].(string), v["sk"].(string))
 	return err
 }